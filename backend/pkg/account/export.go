@@ -0,0 +1,144 @@
+package account
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/storage"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// exportFormatVersion is bumped whenever the layout of an export archive changes
+const exportFormatVersion = 1
+
+// manifest describes the files of an export archive
+type manifest struct {
+	Version     int             `json:"version"`
+	UserID      string          `json:"userId"`
+	GeneratedAt time.Time       `json:"generatedAt"`
+	Files       []manifestEntry `json:"files"`
+}
+
+type manifestEntry struct {
+	File        string `json:"file"`
+	Description string `json:"description"`
+	Records     *int   `json:"records,omitempty"`
+}
+
+// imageEntry describes one uploaded image copied into an export
+type imageEntry struct {
+	File       string `json:"file"`
+	UploadPath string `json:"uploadPath"`
+	Size       int64  `json:"size"`
+}
+
+// WriteExport writes a ZIP archive of a user's data to w: one JSON file per
+// section, the images they uploaded under images/, and a manifest.json
+// describing the rest. Images whose files are gone are left out.
+func WriteExport(w io.Writer, userID string, sections []*models.ExportSection, uploads []string) error {
+	archive := zip.NewWriter(w)
+	m := manifest{
+		Version:     exportFormatVersion,
+		UserID:      userID,
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	for _, section := range sections {
+		name := section.Name + ".json"
+		var content interface{} = section.Records
+		if section.Single && len(section.Records) > 0 {
+			content = section.Records[0]
+		}
+		if err := writeJSON(archive, name, m.GeneratedAt, content); err != nil {
+			return err
+		}
+
+		entry := manifestEntry{File: name, Description: section.Description}
+		if !section.Single {
+			records := len(section.Records)
+			entry.Records = &records
+		}
+		m.Files = append(m.Files, entry)
+	}
+
+	images := []imageEntry{}
+	for _, upload := range uploads {
+		image, err := copyImage(archive, upload)
+		if err != nil {
+			return err
+		}
+		if image != nil {
+			images = append(images, *image)
+		}
+	}
+	if err := writeJSON(archive, "images/manifest.json", m.GeneratedAt, images); err != nil {
+		return err
+	}
+	imageCount := len(images)
+	m.Files = append(m.Files, manifestEntry{
+		File:        "images/manifest.json",
+		Description: "Images you uploaded and the upload path each one was used under",
+		Records:     &imageCount,
+	})
+
+	if err := writeJSON(archive, "manifest.json", m.GeneratedAt, m); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to finish export archive: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(archive *zip.Writer, name string, modified time.Time, content interface{}) error {
+	file, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to export: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// copyImage adds an uploaded image to the archive, returning nil if its file no longer exists
+func copyImage(archive *zip.Writer, upload string) (*imageEntry, error) {
+	key, err := utils.ImageKey(upload)
+	if err != nil {
+		return nil, nil
+	}
+
+	source, info, err := utils.UploadStorage.Get(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", upload, err)
+	}
+	defer source.Close()
+
+	// Uploads are stored as <directory>/<uuid><ext>, so this can't collide.
+	// Images are already compressed, so they are stored as they are.
+	name := path.Join("images", path.Base(path.Dir(upload)), path.Base(upload))
+	destination, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: info.ModTime})
+	if err != nil {
+		return nil, fmt.Errorf("failed to add %s to export: %w", name, err)
+	}
+
+	size, err := io.Copy(destination, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy %s: %w", upload, err)
+	}
+
+	return &imageEntry{File: name, UploadPath: upload, Size: size}, nil
+}
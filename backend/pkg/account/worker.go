@@ -0,0 +1,225 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// orphanGracePeriod is how old an unreferenced upload must be before it is
+// removed, so images saved just before their row is written survive
+const orphanGracePeriod = time.Hour
+
+// Worker runs the account jobs in the background: it builds queued data
+// exports, deletes accounts whose grace period is over, and removes the
+// files both leave behind
+type Worker struct {
+	Accounts  *models.AccountService
+	Exports   *models.DataExportService
+	ExportDir string        // Directory export archives are written to
+	ExportTTL time.Duration // How long a finished export can be downloaded
+	Interval  time.Duration // How often to look for work when not woken
+
+	wake chan struct{}
+}
+
+// NewWorker creates a Worker
+func NewWorker(accounts *models.AccountService, exports *models.DataExportService, exportDir string, exportTTL, interval time.Duration) *Worker {
+	return &Worker{
+		Accounts:  accounts,
+		Exports:   exports,
+		ExportDir: exportDir,
+		ExportTTL: exportTTL,
+		Interval:  interval,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Wake makes a running worker look for work now instead of at its next interval
+func (w *Worker) Wake() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run works until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	// Exports a previous run was interrupted in the middle of start over
+	if err := w.Exports.RequeueStale(); err != nil {
+		log.Printf("Error requeueing data exports: %v", err)
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.runOnce()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+func (w *Worker) runOnce() {
+	w.buildExports()
+	w.removeExpiredExports()
+	w.deleteDueAccounts()
+}
+
+// buildExports works through the export queue
+func (w *Worker) buildExports() {
+	for {
+		export, err := w.Exports.ClaimNext()
+		if err != nil {
+			log.Printf("Error claiming data export: %v", err)
+			return
+		}
+		if export == nil {
+			return
+		}
+
+		filePath, size, err := w.buildExport(export)
+		if err != nil {
+			log.Printf("Error building data export %s: %v", export.ID, err)
+			if err := w.Exports.MarkFailed(export.ID, "The export could not be built"); err != nil {
+				log.Printf("Error marking data export %s failed: %v", export.ID, err)
+			}
+			continue
+		}
+
+		if err := w.Exports.MarkReady(export.ID, filePath, size, time.Now().Add(w.ExportTTL)); err != nil {
+			log.Printf("Error marking data export %s ready: %v", export.ID, err)
+			os.Remove(filePath)
+		}
+	}
+}
+
+// buildExport writes the archive for one export, returning its path and size
+func (w *Worker) buildExport(export *models.DataExport) (string, int64, error) {
+	sections, err := w.Accounts.CollectExport(export.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	uploads, err := w.Accounts.UserUploads(export.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := os.MkdirAll(w.ExportDir, 0700); err != nil {
+		return "", 0, fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	// Write to a temporary file so a half-built archive is never handed out
+	filePath := filepath.Join(w.ExportDir, export.ID+".zip")
+	file, err := os.CreateTemp(w.ExportDir, export.ID+"-*.tmp")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if err := WriteExport(file, export.UserID, sections, uploads); err != nil {
+		file.Close()
+		return "", 0, err
+	}
+	if err := file.Close(); err != nil {
+		return "", 0, fmt.Errorf("failed to write export file: %w", err)
+	}
+
+	info, err := os.Stat(file.Name())
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read export file: %w", err)
+	}
+	if err := os.Rename(file.Name(), filePath); err != nil {
+		return "", 0, fmt.Errorf("failed to store export file: %w", err)
+	}
+
+	return filePath, info.Size(), nil
+}
+
+// removeExpiredExports deletes exports whose download window has closed
+func (w *Worker) removeExpiredExports() {
+	exports, err := w.Exports.GetExpired(time.Now())
+	if err != nil {
+		log.Printf("Error getting expired data exports: %v", err)
+		return
+	}
+
+	for _, export := range exports {
+		if err := removeFile(export.FilePath); err != nil {
+			log.Printf("Error removing data export %s: %v", export.ID, err)
+			continue
+		}
+		if err := w.Exports.Delete(export.ID); err != nil {
+			log.Printf("Error deleting data export %s: %v", export.ID, err)
+		}
+	}
+}
+
+// deleteDueAccounts deletes the accounts whose grace period is over, then
+// the uploads and export archives nothing refers to any more
+func (w *Worker) deleteDueAccounts() {
+	userIDs, err := w.Accounts.DueForDeletion(time.Now())
+	if err != nil {
+		log.Printf("Error getting accounts due for deletion: %v", err)
+		return
+	}
+	if len(userIDs) == 0 {
+		return
+	}
+
+	for _, userID := range userIDs {
+		// Export rows go with the account, so note their files first
+		archives, err := w.Exports.FilePaths(userID)
+		if err != nil {
+			log.Printf("Error getting data exports of account %s: %v", userID, err)
+			continue
+		}
+
+		if err := w.Accounts.Delete(userID); err != nil {
+			log.Printf("Error deleting account %s: %v", userID, err)
+			continue
+		}
+		log.Printf("Deleted account %s", userID)
+
+		for _, archive := range archives {
+			if err := removeFile(archive); err != nil {
+				log.Printf("Error removing data export of account %s: %v", userID, err)
+			}
+		}
+	}
+
+	referenced, err := w.Accounts.ReferencedUploads()
+	if err != nil {
+		log.Printf("Error getting referenced uploads: %v", err)
+		return
+	}
+	removed, err := utils.RemoveOrphanedImages(referenced, time.Now().Add(-orphanGracePeriod))
+	if err != nil {
+		log.Printf("Error removing orphaned uploads: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("Removed %d orphaned uploads", removed)
+	}
+}
+
+// removeFile deletes a file, treating one that is already gone as removed
+func removeFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
@@ -15,9 +16,19 @@ import (
 
 // SessionInvalidationBroadcaster interface for broadcasting session invalidation
 type SessionInvalidationBroadcaster interface {
-	BroadcastSessionInvalidation(userID string)
+	// BroadcastSessionInvalidation signs out the devices bound to sessionID, or every device of the user when sessionID is empty
+	BroadcastSessionInvalidation(userID, sessionID, reason string)
 }
 
+// Session invalidation reasons sent to connected clients
+const (
+	SessionRevokedReason   = "session_revoked"
+	LoggedOutReason        = "logged_out"
+	PasswordChangedReason  = "password_changed"
+	AccountSuspendedReason = "account_suspended"
+	AccountBannedReason    = "account_banned"
+)
+
 // Session cookie name
 const (
 	SessionCookieName = "social_network_session"
@@ -27,46 +38,28 @@ const (
 // Store is the session store
 var Store *sessions.CookieStore
 
-// Initialize initializes the auth package
-func Initialize(secret []byte) {
+// Initialize initializes the auth package. The secret signs both session
+// cookies and tokens; secureCookies should be true whenever the site is
+// served over HTTPS.
+func Initialize(secret []byte, secureCookies bool) {
+	signingKey = secret
 	Store = sessions.NewCookieStore(secret)
 	Store.Options = &sessions.Options{
 		Path:     "/",
 		MaxAge:   int(SessionDuration.Seconds()),
 		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
+		Secure:   secureCookies,
 		SameSite: http.SameSiteLaxMode,
 	}
 }
 
-// CreateSession creates a new session for a user
+// CreateSession creates a new session for a user on the requesting device.
+// Existing sessions on other devices are left untouched.
 func CreateSession(ctx context.Context, db *sql.DB, userID string, w http.ResponseWriter, r *http.Request) (string, error) {
-	return CreateSessionWithHub(ctx, db, userID, w, r, nil)
-}
-
-// CreateSessionWithHub creates a new session for a user and optionally broadcasts session invalidation
-func CreateSessionWithHub(ctx context.Context, db *sql.DB, userID string, w http.ResponseWriter, r *http.Request, hub SessionInvalidationBroadcaster) (string, error) {
 	sessionService := models.NewSessionService(db)
 
-	// First, notify existing sessions that they will be invalidated (if hub is provided)
-	// This gives connected clients a chance to receive the message before sessions are deleted
-	if hub != nil {
-		log.Printf("Broadcasting session invalidation for user: %s", userID)
-		hub.BroadcastSessionInvalidation(userID)
-
-		// Give a brief moment to ensure the WebSocket message is sent
-		// This is necessary because WebSocket sending is asynchronous
-		time.Sleep(50 * time.Millisecond)
-	}
-
-	// Delete all existing sessions for this user
-	log.Printf("Deleting all existing sessions for user: %s", userID)
-	if err := sessionService.DeleteAllForUser(userID); err != nil {
-		return "", fmt.Errorf("failed to delete existing sessions: %w", err)
-	}
-
 	// Create new session
-	session, err := sessionService.Create(userID, SessionDuration)
+	session, err := sessionService.CreateForDevice(userID, SessionDuration, r.UserAgent(), ClientIP(r))
 	if err != nil {
 		return "", fmt.Errorf("failed to create session in database: %w", err)
 	}
@@ -141,18 +134,9 @@ func ValidateSession(ctx context.Context, db *sql.DB, sessionID string) (string,
 		return "", errors.New("session has expired")
 	}
 
-	// Check if this is the most recent session for this user
-	isValid, err := sessionService.IsLatestSession(session.UserID, sessionID)
-	if err != nil {
-		log.Printf("Session validation failed - error checking latest session: %s, error: %v", sessionID, err)
-		return "", fmt.Errorf("failed to validate session: %w", err)
-	}
-
-	if !isValid {
-		log.Printf("Session validation failed - not the latest session: %s for user: %s", sessionID, session.UserID)
-		// Delete invalid session
-		sessionService.Delete(sessionID)
-		return "", errors.New("session invalidated by newer login")
+	// Record device activity for the active sessions list
+	if err := sessionService.Touch(sessionID); err != nil {
+		log.Printf("Failed to update session activity: %s, error: %v", sessionID, err)
 	}
 
 	log.Printf("Session validation successful: %s for user: %s", sessionID, session.UserID)
@@ -190,3 +174,61 @@ func ClearSession(ctx context.Context, db *sql.DB, w http.ResponseWriter, r *htt
 	log.Printf("Session cleared successfully")
 	return nil
 }
+
+// RevokeSession deletes one of a user's sessions and disconnects the devices using it
+func RevokeSession(db *sql.DB, userID, sessionID string, hub SessionInvalidationBroadcaster) error {
+	sessionService := models.NewSessionService(db)
+	if err := sessionService.DeleteForUser(sessionID, userID); err != nil {
+		return err
+	}
+
+	if hub != nil {
+		hub.BroadcastSessionInvalidation(userID, sessionID, SessionRevokedReason)
+	}
+
+	log.Printf("Session revoked: %s for user: %s", sessionID, userID)
+	return nil
+}
+
+// RevokeUserSessions deletes every session of a user except keepSessionID (which may be empty)
+// and disconnects the affected devices. It returns the number of sessions revoked.
+func RevokeUserSessions(db *sql.DB, userID, keepSessionID, reason string, hub SessionInvalidationBroadcaster) (int, error) {
+	sessionService := models.NewSessionService(db)
+	sessions, err := sessionService.GetActiveByUser(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == keepSessionID {
+			continue
+		}
+		if err := sessionService.Delete(session.ID); err != nil {
+			return revoked, err
+		}
+		if hub != nil {
+			hub.BroadcastSessionInvalidation(userID, session.ID, reason)
+		}
+		revoked++
+	}
+
+	// Expired sessions are not listed above but should not outlive a sign-out everywhere
+	if keepSessionID == "" {
+		if err := sessionService.DeleteAllForUser(userID); err != nil {
+			return revoked, err
+		}
+	}
+
+	log.Printf("Revoked %d sessions for user: %s", revoked, userID)
+	return revoked, nil
+}
+
+// ClientIP returns the IP address of the remote end of the request
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
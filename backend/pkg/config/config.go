@@ -0,0 +1,548 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/imaging"
+	"github.com/bernaotieno/social-network/backend/pkg/storage"
+)
+
+// Environment names
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// PlaceholderSessionSecret is the development secret the server used to ship with.
+// It is only accepted in development mode.
+const PlaceholderSessionSecret = "your-secret-key-here"
+
+// Mail drivers
+const (
+	MailDriverLog  = "log"
+	MailDriverFile = "file"
+)
+
+// minSessionSecretLength is the shortest session secret accepted outside development
+const minSessionSecretLength = 32
+
+// Config holds the server settings
+type Config struct {
+	Env            string      `json:"env"`
+	Port           string      `json:"port"`
+	DBPath         string      `json:"dbPath"`
+	MigrationsPath string      `json:"migrationsPath"`
+	SessionSecret  string      `json:"sessionSecret"`
+	CookieSecure   *bool       `json:"cookieSecure"` // Defaults to true when TLS is enabled or in production
+	AllowedOrigins []string    `json:"allowedOrigins"`
+	AppURL         string      `json:"appUrl"` // Frontend base URL used for links in emails
+	Upload         Upload      `json:"upload"`
+	Account        Account     `json:"account"`
+	Log            Log         `json:"log"`
+	Mail           Mail        `json:"mail"`
+	LinkPreview    LinkPreview `json:"linkPreview"`
+	TLS            TLS         `json:"tls"`
+	Timeouts       Timeouts    `json:"timeouts"`
+}
+
+// Upload holds the settings for uploaded files
+type Upload struct {
+	Storage        string `json:"storage"`        // "local" keeps files in Root, "s3" in the S3 bucket
+	Root           string `json:"root"`           // Directory files are stored in by the local backend
+	MaxImageSize   int64  `json:"maxImageSize"`   // Largest accepted image of any format, in bytes
+	MaxRequestSize int64  `json:"maxRequestSize"` // Largest accepted multipart request, in bytes
+	// How long the signed image URLs in API responses work for at least; they
+	// work for at most twice as long
+	SignedURLTTL Duration `json:"signedUrlTtl"`
+	// Limits for each image format, keyed by jpeg, png, gif and webp. A format
+	// given in a config file replaces all of that format's default limits.
+	Formats map[string]imaging.Limits `json:"formats"`
+	S3      storage.S3Config          `json:"s3"`
+}
+
+// Account holds the settings for account deletion and data exports
+type Account struct {
+	DeletionGracePeriod Duration `json:"deletionGracePeriod"` // How long a deletion request can still be cancelled
+	ExportDir           string   `json:"exportDir"`           // Directory data export archives are written to; never served directly
+	ExportTTL           Duration `json:"exportTtl"`           // How long a finished export can be downloaded
+	JobInterval         Duration `json:"jobInterval"`         // How often pending exports and deletions are looked for
+}
+
+// Log holds the logging settings
+type Log struct {
+	File string `json:"file"` // Log file path, or "stderr"
+}
+
+// Mail holds the outgoing email settings
+type Mail struct {
+	Driver string `json:"driver"` // "log" writes mail to the log, "file" drops .eml files into Dir
+	From   string `json:"from"`
+	Dir    string `json:"dir"`
+}
+
+// LinkPreview holds the settings for previewing the links in posts and messages
+type LinkPreview struct {
+	// Off by default, as previews make the server fetch any URL users post
+	Enabled     bool     `json:"enabled"`
+	Timeout     Duration `json:"timeout"`     // How long fetching a page and its image may take
+	MaxPageSize int64    `json:"maxPageSize"` // How much of a page is read looking for its preview, in bytes
+	CacheTTL    Duration `json:"cacheTtl"`    // How long a fetched preview is used before the page is fetched again
+	MaxLinks    int      `json:"maxLinks"`    // How many links in a post or message are previewed
+}
+
+// TLS holds the certificate used to serve HTTPS. TLS is disabled when both are empty.
+type TLS struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+// Timeouts holds the HTTP server timeouts
+type Timeouts struct {
+	Read     Duration `json:"read"`
+	Write    Duration `json:"write"`
+	Idle     Duration `json:"idle"`
+	Shutdown Duration `json:"shutdown"`
+}
+
+// Duration is a time.Duration written as a string such as "15s" in config files
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return errors.New("duration must be a string such as \"15s\"")
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Default returns the settings used when nothing is configured. They are
+// production settings, so a deployment that forgets APP_ENV refuses to boot
+// with the placeholder secret instead of running as development.
+func Default() *Config {
+	return &Config{
+		Env:            EnvProduction,
+		Port:           "8080",
+		DBPath:         "./social_network.db",
+		MigrationsPath: "./pkg/db/migrations/sqlite",
+		SessionSecret:  PlaceholderSessionSecret,
+		AllowedOrigins: []string{
+			"http://localhost:3000", // Frontend URL
+			"file://",               // Electron app
+			"http://localhost",      // Local development
+			"https://localhost",     // HTTPS local development
+		},
+		AppURL: "http://localhost:3000",
+		Upload: Upload{
+			Storage:        storage.BackendLocal,
+			Root:           "uploads",
+			MaxImageSize:   5 << 20,
+			MaxRequestSize: 10 << 20,
+			SignedURLTTL:   Duration(time.Hour),
+			Formats:        imaging.DefaultLimits(),
+			S3:             storage.S3Config{Region: "us-east-1"},
+		},
+		Account: Account{
+			DeletionGracePeriod: Duration(14 * 24 * time.Hour),
+			ExportDir:           "exports",
+			ExportTTL:           Duration(7 * 24 * time.Hour),
+			JobInterval:         Duration(time.Minute),
+		},
+		Log: Log{
+			File: "logs/application.log",
+		},
+		Mail: Mail{
+			Driver: MailDriverLog,
+			From:   "no-reply@localhost",
+			Dir:    "mail",
+		},
+		LinkPreview: LinkPreview{
+			Enabled:     false,
+			Timeout:     Duration(5 * time.Second),
+			MaxPageSize: 512 << 10,
+			CacheTTL:    Duration(24 * time.Hour),
+			MaxLinks:    3,
+		},
+		Timeouts: Timeouts{
+			Read:     Duration(15 * time.Second),
+			Write:    Duration(15 * time.Second),
+			Idle:     Duration(60 * time.Second),
+			Shutdown: Duration(10 * time.Second),
+		},
+	}
+}
+
+// Load reads the configuration from the defaults, then the JSON file at path
+// (skipped when path is empty), then environment variables. Call Validate
+// once any command line overrides have been applied.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile overlays the settings present in a JSON config file
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv overlays the settings given as environment variables
+func (c *Config) loadEnv() error {
+	setString(&c.Env, "APP_ENV")
+	setString(&c.Port, "PORT")
+	setString(&c.DBPath, "DB_PATH")
+	setString(&c.MigrationsPath, "MIGRATIONS_PATH")
+	setString(&c.SessionSecret, "SESSION_SECRET")
+	setString(&c.Upload.Storage, "UPLOAD_STORAGE")
+	setString(&c.Upload.Root, "UPLOAD_ROOT")
+	setString(&c.Upload.S3.Endpoint, "S3_ENDPOINT")
+	setString(&c.Upload.S3.Region, "S3_REGION")
+	setString(&c.Upload.S3.Bucket, "S3_BUCKET")
+	setString(&c.Upload.S3.AccessKeyID, "S3_ACCESS_KEY_ID")
+	setString(&c.Upload.S3.SecretAccessKey, "S3_SECRET_ACCESS_KEY")
+	setString(&c.Upload.S3.PublicURL, "S3_PUBLIC_URL")
+	setString(&c.Account.ExportDir, "EXPORT_DIR")
+	setString(&c.Log.File, "LOG_FILE")
+	setString(&c.AppURL, "APP_URL")
+	setString(&c.Mail.Driver, "MAIL_DRIVER")
+	setString(&c.Mail.From, "MAIL_FROM")
+	setString(&c.Mail.Dir, "MAIL_DIR")
+	setString(&c.TLS.CertFile, "TLS_CERT_FILE")
+	setString(&c.TLS.KeyFile, "TLS_KEY_FILE")
+
+	if value, ok := os.LookupEnv("ALLOWED_ORIGINS"); ok {
+		c.AllowedOrigins = nil
+		for _, origin := range strings.Split(value, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				c.AllowedOrigins = append(c.AllowedOrigins, origin)
+			}
+		}
+	}
+
+	if value, ok := os.LookupEnv("COOKIE_SECURE"); ok {
+		secure, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid COOKIE_SECURE: %w", err)
+		}
+		c.CookieSecure = &secure
+	}
+
+	if value, ok := os.LookupEnv("LINK_PREVIEW_ENABLED"); ok {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid LINK_PREVIEW_ENABLED: %w", err)
+		}
+		c.LinkPreview.Enabled = enabled
+	}
+
+	if value, ok := os.LookupEnv("S3_PATH_STYLE"); ok {
+		pathStyle, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid S3_PATH_STYLE: %w", err)
+		}
+		c.Upload.S3.PathStyle = pathStyle
+	}
+
+	sizes := map[string]*int64{
+		"UPLOAD_MAX_IMAGE_SIZE":      &c.Upload.MaxImageSize,
+		"UPLOAD_MAX_REQUEST_SIZE":    &c.Upload.MaxRequestSize,
+		"LINK_PREVIEW_MAX_PAGE_SIZE": &c.LinkPreview.MaxPageSize,
+	}
+	for name, target := range sizes {
+		if value, ok := os.LookupEnv(name); ok {
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = size
+		}
+	}
+
+	// Image limits are set per format, e.g. UPLOAD_GIF_MAX_FRAMES
+	for _, format := range imaging.Formats() {
+		limits := c.Upload.Formats[format]
+		prefix := "UPLOAD_" + strings.ToUpper(format) + "_"
+		if value, ok := os.LookupEnv(prefix + "MAX_SIZE"); ok {
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %sMAX_SIZE: %w", prefix, err)
+			}
+			limits.MaxSize = size
+		}
+		if value, ok := os.LookupEnv(prefix + "MAX_TOTAL_PIXELS"); ok {
+			pixels, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %sMAX_TOTAL_PIXELS: %w", prefix, err)
+			}
+			limits.MaxTotalPixels = pixels
+		}
+		dimensions := map[string]*int{
+			"MAX_WIDTH":  &limits.MaxWidth,
+			"MAX_HEIGHT": &limits.MaxHeight,
+			"MAX_PIXELS": &limits.MaxPixels,
+			"MAX_FRAMES": &limits.MaxFrames,
+		}
+		for name, target := range dimensions {
+			if value, ok := os.LookupEnv(prefix + name); ok {
+				limit, err := strconv.Atoi(value)
+				if err != nil {
+					return fmt.Errorf("invalid %s%s: %w", prefix, name, err)
+				}
+				*target = limit
+			}
+		}
+		if c.Upload.Formats == nil {
+			c.Upload.Formats = make(map[string]imaging.Limits)
+		}
+		c.Upload.Formats[format] = limits
+	}
+
+	durations := map[string]*Duration{
+		"READ_TIMEOUT":     &c.Timeouts.Read,
+		"WRITE_TIMEOUT":    &c.Timeouts.Write,
+		"IDLE_TIMEOUT":     &c.Timeouts.Idle,
+		"SHUTDOWN_TIMEOUT": &c.Timeouts.Shutdown,
+
+		"ACCOUNT_DELETION_GRACE_PERIOD": &c.Account.DeletionGracePeriod,
+		"EXPORT_TTL":                    &c.Account.ExportTTL,
+		"ACCOUNT_JOB_INTERVAL":          &c.Account.JobInterval,
+		"UPLOAD_SIGNED_URL_TTL":         &c.Upload.SignedURLTTL,
+		"LINK_PREVIEW_TIMEOUT":          &c.LinkPreview.Timeout,
+		"LINK_PREVIEW_CACHE_TTL":        &c.LinkPreview.CacheTTL,
+	}
+	for name, target := range durations {
+		if value, ok := os.LookupEnv(name); ok {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*target = Duration(duration)
+		}
+	}
+
+	return nil
+}
+
+// setString overwrites target with the environment variable if it is set
+func setString(target *string, name string) {
+	if value, ok := os.LookupEnv(name); ok {
+		*target = value
+	}
+}
+
+// Validate checks the configuration is usable. Outside development it refuses
+// the placeholder secret and secrets too short to sign cookies safely.
+func (c *Config) Validate() error {
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		return fmt.Errorf("env must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env)
+	}
+
+	if c.Port == "" {
+		return errors.New("port is required")
+	}
+	if c.DBPath == "" {
+		return errors.New("database path is required")
+	}
+
+	if c.SessionSecret == "" {
+		return errors.New("session secret is required")
+	}
+	if !c.IsDevelopment() {
+		if c.SessionSecret == PlaceholderSessionSecret {
+			return errors.New("the placeholder session secret is only allowed in development; set SESSION_SECRET")
+		}
+		if len(c.SessionSecret) < minSessionSecretLength {
+			return fmt.Errorf("session secret must be at least %d characters outside development", minSessionSecretLength)
+		}
+	}
+
+	if len(c.AllowedOrigins) == 0 {
+		return errors.New("at least one allowed origin is required")
+	}
+
+	switch c.Upload.Storage {
+	case storage.BackendLocal:
+		if c.Upload.Root == "" {
+			return errors.New("upload root is required for the local storage backend")
+		}
+	case storage.BackendS3:
+		if _, err := storage.NewS3(c.Upload.S3); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("upload storage must be %q or %q, got %q", storage.BackendLocal, storage.BackendS3, c.Upload.Storage)
+	}
+	if c.Upload.MaxImageSize <= 0 || c.Upload.MaxRequestSize <= 0 {
+		return errors.New("upload size limits must be positive")
+	}
+	if c.Upload.MaxImageSize > c.Upload.MaxRequestSize {
+		return errors.New("upload max image size cannot exceed the max request size")
+	}
+	if c.Upload.SignedURLTTL < Duration(time.Minute) {
+		return errors.New("upload signed URL TTL must be at least a minute")
+	}
+	if err := c.validateImageLimits(); err != nil {
+		return err
+	}
+
+	if c.Account.ExportDir == "" {
+		return errors.New("export dir is required")
+	}
+	if c.Account.DeletionGracePeriod < 0 {
+		return errors.New("account deletion grace period cannot be negative")
+	}
+	if c.Account.ExportTTL <= 0 || c.Account.JobInterval <= 0 {
+		return errors.New("export TTL and account job interval must be positive")
+	}
+
+	if c.Log.File == "" {
+		return errors.New("log file is required")
+	}
+
+	if c.AppURL == "" {
+		return errors.New("app URL is required")
+	}
+
+	switch c.Mail.Driver {
+	case MailDriverLog:
+	case MailDriverFile:
+		if c.Mail.Dir == "" {
+			return errors.New("mail dir is required for the file mail driver")
+		}
+	default:
+		return fmt.Errorf("mail driver must be %q or %q, got %q", MailDriverLog, MailDriverFile, c.Mail.Driver)
+	}
+	if c.Mail.From == "" {
+		return errors.New("mail from address is required")
+	}
+
+	if c.LinkPreview.Enabled {
+		if c.LinkPreview.Timeout <= 0 || c.LinkPreview.CacheTTL <= 0 {
+			return errors.New("link preview timeout and cache TTL must be positive")
+		}
+		if c.LinkPreview.MaxPageSize <= 0 || c.LinkPreview.MaxLinks <= 0 {
+			return errors.New("link preview max page size and max links must be positive")
+		}
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return errors.New("TLS needs both a certificate and a key file")
+	}
+	if c.TLSEnabled() {
+		for _, file := range []string{c.TLS.CertFile, c.TLS.KeyFile} {
+			if _, err := os.Stat(file); err != nil {
+				return fmt.Errorf("TLS file %s: %w", file, err)
+			}
+		}
+	}
+
+	timeouts := map[string]Duration{
+		"read":     c.Timeouts.Read,
+		"write":    c.Timeouts.Write,
+		"idle":     c.Timeouts.Idle,
+		"shutdown": c.Timeouts.Shutdown,
+	}
+	for name, timeout := range timeouts {
+		if timeout <= 0 {
+			return fmt.Errorf("%s timeout must be positive", name)
+		}
+	}
+
+	return nil
+}
+
+// validateImageLimits checks there are usable limits for every image format
+func (c *Config) validateImageLimits() error {
+	for format := range c.Upload.Formats {
+		if !slices.Contains(imaging.Formats(), format) {
+			return fmt.Errorf("unknown upload image format %q", format)
+		}
+	}
+
+	for _, format := range imaging.Formats() {
+		limits, ok := c.Upload.Formats[format]
+		if !ok {
+			return fmt.Errorf("upload limits for %s images are required", format)
+		}
+		if limits.MaxSize <= 0 || limits.MaxWidth <= 0 || limits.MaxHeight <= 0 || limits.MaxPixels <= 0 {
+			return fmt.Errorf("upload %s size, width, height and pixel limits must be positive", format)
+		}
+		if limits.MaxSize > c.Upload.MaxImageSize {
+			return fmt.Errorf("upload %s max size cannot exceed the max image size", format)
+		}
+		if (format == "gif" || format == "webp") && (limits.MaxFrames <= 0 || limits.MaxTotalPixels <= 0) {
+			return fmt.Errorf("upload %s frame and total pixel limits must be positive", format)
+		}
+	}
+
+	return nil
+}
+
+// UploadStorage creates the named upload storage backend from the settings
+func (c *Config) UploadStorage(backend string) (storage.Storage, error) {
+	switch backend {
+	case storage.BackendLocal:
+		return storage.NewLocal(c.Upload.Root), nil
+	case storage.BackendS3:
+		return storage.NewS3(c.Upload.S3)
+	default:
+		return nil, fmt.Errorf("unknown upload storage backend %q", backend)
+	}
+}
+
+// IsDevelopment reports whether the server runs in development mode
+func (c *Config) IsDevelopment() bool {
+	return c.Env == EnvDevelopment
+}
+
+// TLSEnabled reports whether the server should serve HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLS.CertFile != "" && c.TLS.KeyFile != ""
+}
+
+// SecureCookies reports whether session cookies should carry the Secure flag
+func (c *Config) SecureCookies() bool {
+	if c.CookieSecure != nil {
+		return *c.CookieSecure
+	}
+	return c.TLSEnabled() || !c.IsDevelopment()
+}
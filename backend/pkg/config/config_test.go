@@ -0,0 +1,18 @@
+package config
+
+import "testing"
+
+func TestLinkPreviewsAreOffByDefault(t *testing.T) {
+	if Default().LinkPreview.Enabled {
+		t.Error("Expected link previews to be off by default")
+	}
+
+	t.Setenv("LINK_PREVIEW_ENABLED", "true")
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.LinkPreview.Enabled {
+		t.Error("Expected LINK_PREVIEW_ENABLED=true to turn link previews on")
+	}
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_message_reactions_message_id;
+DROP TABLE IF EXISTS message_reactions;
+
+ALTER TABLE messages DROP COLUMN deleted_at;
+ALTER TABLE messages DROP COLUMN edited_at;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_messages_created_at_id;
+DROP INDEX IF EXISTS idx_messages_sender_client_message_id;
+
+ALTER TABLE messages DROP COLUMN client_message_id;
@@ -0,0 +1,9 @@
+-- Let clients tag messages with an idempotency key so retried sends are deduplicated
+ALTER TABLE messages ADD COLUMN client_message_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_sender_client_message_id
+    ON messages(sender_id, client_message_id)
+    WHERE client_message_id IS NOT NULL;
+
+-- Resuming after a reconnect walks messages in (created_at, id) order
+CREATE INDEX IF NOT EXISTS idx_messages_created_at_id ON messages(created_at, id);
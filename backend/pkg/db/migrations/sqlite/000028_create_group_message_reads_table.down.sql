@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_group_id_created_at;
+DROP INDEX IF EXISTS idx_messages_receiver_unread;
+DROP TABLE IF EXISTS group_message_reads;
@@ -0,0 +1,14 @@
+-- Per-member read cursor for group chats; direct messages keep using messages.read_at
+CREATE TABLE IF NOT EXISTS group_message_reads (
+    group_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    last_read_message_id TEXT NOT NULL,
+    last_read_message_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, user_id),
+    FOREIGN KEY (group_id) REFERENCES groups(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_receiver_unread ON messages(receiver_id, read_at);
+CREATE INDEX IF NOT EXISTS idx_messages_group_id_created_at ON messages(group_id, created_at);
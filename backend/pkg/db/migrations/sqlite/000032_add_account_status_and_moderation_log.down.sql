@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS moderation_audit_log_no_delete;
+DROP TRIGGER IF EXISTS moderation_audit_log_no_update;
+DROP INDEX IF EXISTS idx_moderation_audit_log_target;
+DROP INDEX IF EXISTS idx_moderation_audit_log_created_at;
+DROP TABLE IF EXISTS moderation_audit_log;
+
+ALTER TABLE users DROP COLUMN status_reason;
+ALTER TABLE users DROP COLUMN suspended_until;
+ALTER TABLE users DROP COLUMN status;
@@ -0,0 +1,34 @@
+-- Account standing set by platform staff
+ALTER TABLE users ADD COLUMN status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended', 'banned'));
+ALTER TABLE users ADD COLUMN suspended_until TIMESTAMP;
+ALTER TABLE users ADD COLUMN status_reason TEXT NOT NULL DEFAULT '';
+
+-- Migration 000024 defaulted roles to 'user', which is not one of the roles the code knows
+UPDATE users SET role = 'member' WHERE role IS NULL OR role IN ('', 'user');
+
+-- Every staff action; rows are never changed or removed
+CREATE TABLE IF NOT EXISTS moderation_audit_log (
+    id TEXT PRIMARY KEY,
+    actor_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_audit_log_created_at ON moderation_audit_log(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_moderation_audit_log_target ON moderation_audit_log(target_type, target_id);
+
+CREATE TRIGGER IF NOT EXISTS moderation_audit_log_no_update
+BEFORE UPDATE ON moderation_audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'moderation audit log is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS moderation_audit_log_no_delete
+BEFORE DELETE ON moderation_audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'moderation audit log is append-only');
+END;
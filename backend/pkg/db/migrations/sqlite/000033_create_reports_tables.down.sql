@@ -0,0 +1,28 @@
+-- Remove the report_resolved notification type
+CREATE TABLE notifications_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    sender_id TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('follow_request', 'follow_accepted', 'new_follower', 'post_like', 'post_comment', 'group_invite', 'group_join_request', 'group_join_approved', 'group_join_rejected', 'event_invite', 'group_event_created')),
+    content TEXT NOT NULL,
+    data TEXT,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'approved', 'rejected')),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO notifications_new (id, user_id, sender_id, type, content, data, read_at, created_at, status)
+SELECT id, user_id, sender_id, type, content, data, read_at, created_at, status
+FROM notifications
+WHERE type != 'report_resolved';
+
+DROP TABLE notifications;
+
+ALTER TABLE notifications_new RENAME TO notifications;
+
+DROP TABLE IF EXISTS report_submissions;
+DROP INDEX IF EXISTS idx_reports_status;
+DROP INDEX IF EXISTS idx_reports_pending_target;
+DROP TABLE IF EXISTS reports;
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_data_exports_status;
+DROP INDEX IF EXISTS idx_data_exports_user_id;
+DROP TABLE IF EXISTS data_exports;
+
+DROP TRIGGER IF EXISTS group_posts_delete_comments_and_likes;
+DROP TRIGGER IF EXISTS posts_delete_comments_and_likes;
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled_at;
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
@@ -0,0 +1,47 @@
+-- Set while an account waits out its deletion grace period
+ALTER TABLE users ADD COLUMN deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+
+-- Migration 000017 dropped the post foreign keys from comments and likes so
+-- they could belong to group posts too, which left nothing to cascade them.
+-- Remove them whenever either kind of post is deleted, including when a
+-- deleted user takes their posts with them.
+CREATE TRIGGER IF NOT EXISTS posts_delete_comments_and_likes
+AFTER DELETE ON posts
+BEGIN
+    DELETE FROM comments WHERE post_id = old.id;
+    DELETE FROM likes WHERE post_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_delete_comments_and_likes
+AFTER DELETE ON group_posts
+BEGIN
+    DELETE FROM comments WHERE post_id = old.id;
+    DELETE FROM likes WHERE post_id = old.id;
+END;
+
+-- Clear out what was orphaned before the triggers existed
+DELETE FROM comments
+WHERE post_id NOT IN (SELECT id FROM posts) AND post_id NOT IN (SELECT id FROM group_posts);
+
+DELETE FROM likes
+WHERE post_id NOT IN (SELECT id FROM posts) AND post_id NOT IN (SELECT id FROM group_posts);
+
+-- Data export jobs. The archive is written outside the public uploads
+-- directory and only handed to its owner.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'ready', 'failed')),
+    file_path TEXT NOT NULL DEFAULT '',
+    file_size INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user_id ON data_exports(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status, created_at);
@@ -0,0 +1,66 @@
+-- Remove the comment_reply and comment_like notification types
+CREATE TABLE notifications_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    sender_id TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('follow_request', 'follow_accepted', 'new_follower', 'post_like', 'post_comment', 'group_invite', 'group_join_request', 'group_join_approved', 'group_join_rejected', 'event_invite', 'group_event_created', 'report_resolved')),
+    content TEXT NOT NULL,
+    data TEXT,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'approved', 'rejected')),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO notifications_new (id, user_id, sender_id, type, content, data, read_at, created_at, status)
+SELECT id, user_id, sender_id, type, content, data, read_at, created_at, status
+FROM notifications
+WHERE type NOT IN ('comment_reply', 'comment_like');
+
+DROP TABLE notifications;
+
+ALTER TABLE notifications_new RENAME TO notifications;
+
+DROP INDEX IF EXISTS idx_comment_likes_user_id;
+DROP TABLE IF EXISTS comment_likes;
+
+DROP INDEX IF EXISTS idx_comments_parent_comment_id;
+DROP INDEX IF EXISTS idx_comments_post_id;
+
+-- SQLite can't drop a column used by a foreign key, so rebuild the table.
+-- Flattening the threads keeps every reply.
+-- The triggers deleting comments with their posts go while it is missing.
+DROP TRIGGER IF EXISTS group_posts_delete_comments_and_likes;
+DROP TRIGGER IF EXISTS posts_delete_comments_and_likes;
+
+CREATE TABLE comments_new (
+    id TEXT PRIMARY KEY,
+    post_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    content TEXT NOT NULL,
+    image TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO comments_new (id, post_id, user_id, content, image, created_at, updated_at)
+SELECT id, post_id, user_id, content, image, created_at, updated_at FROM comments;
+
+DROP TABLE comments;
+ALTER TABLE comments_new RENAME TO comments;
+
+CREATE TRIGGER IF NOT EXISTS posts_delete_comments_and_likes
+AFTER DELETE ON posts
+BEGIN
+    DELETE FROM comments WHERE post_id = old.id;
+    DELETE FROM likes WHERE post_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_delete_comments_and_likes
+AFTER DELETE ON group_posts
+BEGIN
+    DELETE FROM comments WHERE post_id = old.id;
+    DELETE FROM likes WHERE post_id = old.id;
+END;
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS comments_delete_revisions;
+DROP TRIGGER IF EXISTS group_posts_delete_revisions;
+DROP TRIGGER IF EXISTS posts_delete_revisions;
+
+DROP INDEX IF EXISTS idx_content_revisions_target;
+DROP TABLE IF EXISTS content_revisions;
+
+ALTER TABLE comments DROP COLUMN edited_at;
+ALTER TABLE group_posts DROP COLUMN edited_at;
+ALTER TABLE posts DROP COLUMN edited_at;
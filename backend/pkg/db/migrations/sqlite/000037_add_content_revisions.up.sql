@@ -0,0 +1,35 @@
+-- Set when the content of a post, group post or comment was last changed
+ALTER TABLE posts ADD COLUMN edited_at TIMESTAMP;
+ALTER TABLE group_posts ADD COLUMN edited_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN edited_at TIMESTAMP;
+
+-- The content each edit replaced, so what was said can be checked later
+CREATE TABLE IF NOT EXISTS content_revisions (
+    id TEXT PRIMARY KEY,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'group_post', 'comment')),
+    target_id TEXT NOT NULL,
+    content TEXT NOT NULL,
+    replaced_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_revisions_target ON content_revisions(target_type, target_id, replaced_at);
+
+-- Revisions can't reference their target with a foreign key, so remove them
+-- when it is deleted, whether directly or through a cascade
+CREATE TRIGGER IF NOT EXISTS posts_delete_revisions
+AFTER DELETE ON posts
+BEGIN
+    DELETE FROM content_revisions WHERE target_type = 'post' AND target_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_delete_revisions
+AFTER DELETE ON group_posts
+BEGIN
+    DELETE FROM content_revisions WHERE target_type = 'group_post' AND target_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_delete_revisions
+AFTER DELETE ON comments
+BEGIN
+    DELETE FROM content_revisions WHERE target_type = 'comment' AND target_id = old.id;
+END;
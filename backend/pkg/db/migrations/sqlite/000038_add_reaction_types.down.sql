@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_comment_likes_comment_id_reaction;
+DROP INDEX IF EXISTS idx_likes_post_id_reaction;
+
+-- Every reaction goes back to being a like
+ALTER TABLE comment_likes DROP COLUMN reaction;
+ALTER TABLE likes DROP COLUMN reaction;
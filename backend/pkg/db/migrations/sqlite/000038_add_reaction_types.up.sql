@@ -0,0 +1,7 @@
+-- Likes become typed reactions; every existing like is a reaction of type like
+ALTER TABLE likes ADD COLUMN reaction TEXT NOT NULL DEFAULT 'like' CHECK (reaction IN ('like', 'love', 'laugh', 'wow', 'sad', 'angry'));
+ALTER TABLE comment_likes ADD COLUMN reaction TEXT NOT NULL DEFAULT 'like' CHECK (reaction IN ('like', 'love', 'laugh', 'wow', 'sad', 'angry'));
+
+-- For listing who gave a particular reaction
+CREATE INDEX IF NOT EXISTS idx_likes_post_id_reaction ON likes(post_id, reaction, created_at);
+CREATE INDEX IF NOT EXISTS idx_comment_likes_comment_id_reaction ON comment_likes(comment_id, reaction, created_at);
@@ -0,0 +1,35 @@
+-- Remove the mention notification type
+CREATE TABLE notifications_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    sender_id TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('follow_request', 'follow_accepted', 'new_follower', 'post_like', 'post_comment', 'group_invite', 'group_join_request', 'group_join_approved', 'group_join_rejected', 'event_invite', 'group_event_created', 'report_resolved', 'comment_reply', 'comment_like')),
+    content TEXT NOT NULL,
+    data TEXT,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'approved', 'rejected')),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO notifications_new (id, user_id, sender_id, type, content, data, read_at, created_at, status)
+SELECT id, user_id, sender_id, type, content, data, read_at, created_at, status
+FROM notifications
+WHERE type != 'mention';
+
+DROP TABLE notifications;
+
+ALTER TABLE notifications_new RENAME TO notifications;
+
+DROP TRIGGER IF EXISTS messages_delete_entities;
+DROP TRIGGER IF EXISTS comments_delete_entities;
+DROP TRIGGER IF EXISTS group_posts_delete_entities;
+DROP TRIGGER IF EXISTS posts_delete_entities;
+
+DROP INDEX IF EXISTS idx_content_tags_created_at;
+DROP INDEX IF EXISTS idx_content_tags_tag;
+DROP TABLE IF EXISTS content_tags;
+
+DROP INDEX IF EXISTS idx_mentions_user_id;
+DROP TABLE IF EXISTS mentions;
@@ -0,0 +1,82 @@
+-- Users mentioned in posts, group posts, comments and messages, with the
+-- username as it was written so it can be found in the content again.
+-- notified_at is set once the user has been told, which only happens when
+-- they can see the content.
+CREATE TABLE IF NOT EXISTS mentions (
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'group_post', 'comment', 'message')),
+    target_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    username TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    notified_at TIMESTAMP,
+    PRIMARY KEY (target_type, target_id, user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mentions_user_id ON mentions(user_id);
+
+-- Hashtags used in posts and group posts, lowercased, for tag feeds and
+-- trending tags
+CREATE TABLE IF NOT EXISTS content_tags (
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'group_post')),
+    target_id TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (target_type, target_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_tags_tag ON content_tags(tag, created_at);
+CREATE INDEX IF NOT EXISTS idx_content_tags_created_at ON content_tags(created_at);
+
+-- Mentions and tags can't reference their target with a foreign key, so
+-- remove them when it is deleted, whether directly or through a cascade
+CREATE TRIGGER IF NOT EXISTS posts_delete_entities
+AFTER DELETE ON posts
+BEGIN
+    DELETE FROM mentions WHERE target_type = 'post' AND target_id = old.id;
+    DELETE FROM content_tags WHERE target_type = 'post' AND target_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_delete_entities
+AFTER DELETE ON group_posts
+BEGIN
+    DELETE FROM mentions WHERE target_type = 'group_post' AND target_id = old.id;
+    DELETE FROM content_tags WHERE target_type = 'group_post' AND target_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS comments_delete_entities
+AFTER DELETE ON comments
+BEGIN
+    DELETE FROM mentions WHERE target_type = 'comment' AND target_id = old.id;
+END;
+
+-- Deleted messages stay behind as tombstones without their content
+CREATE TRIGGER IF NOT EXISTS messages_delete_entities
+AFTER UPDATE OF deleted_at ON messages
+WHEN new.deleted_at IS NOT NULL
+BEGIN
+    DELETE FROM mentions WHERE target_type = 'message' AND target_id = old.id;
+END;
+
+-- Add the mention notification type
+CREATE TABLE notifications_new (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    sender_id TEXT NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('follow_request', 'follow_accepted', 'new_follower', 'post_like', 'post_comment', 'group_invite', 'group_join_request', 'group_join_approved', 'group_join_rejected', 'event_invite', 'group_event_created', 'report_resolved', 'comment_reply', 'comment_like', 'mention')),
+    content TEXT NOT NULL,
+    data TEXT,
+    read_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    status TEXT DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'approved', 'rejected')),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO notifications_new (id, user_id, sender_id, type, content, data, read_at, created_at, status)
+SELECT id, user_id, sender_id, type, content, data, read_at, created_at, status
+FROM notifications;
+
+DROP TABLE notifications;
+
+ALTER TABLE notifications_new RENAME TO notifications;
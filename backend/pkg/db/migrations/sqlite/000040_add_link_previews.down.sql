@@ -0,0 +1,8 @@
+DROP TRIGGER IF EXISTS messages_delete_links;
+DROP TRIGGER IF EXISTS messages_purge_links;
+DROP TRIGGER IF EXISTS group_posts_delete_links;
+DROP TRIGGER IF EXISTS posts_delete_links;
+
+DROP INDEX IF EXISTS idx_content_links_url;
+DROP TABLE IF EXISTS content_links;
+DROP TABLE IF EXISTS link_previews;
@@ -0,0 +1,51 @@
+-- Previews of the pages linked from posts, group posts and messages, cached
+-- by URL. A failed fetch is cached too, with why it failed, so the page
+-- isn't fetched again for every post linking it; those previews aren't shown.
+-- image is the page's preview image, copied into the uploads.
+CREATE TABLE IF NOT EXISTS link_previews (
+    url TEXT PRIMARY KEY,
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT '',
+    image TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    fetched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The links in each post, group post and message, in the order they appear
+CREATE TABLE IF NOT EXISTS content_links (
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'group_post', 'message')),
+    target_id TEXT NOT NULL,
+    url TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (target_type, target_id, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_links_url ON content_links(url);
+
+-- Like mentions, links can't reference their target with a foreign key
+CREATE TRIGGER IF NOT EXISTS posts_delete_links
+AFTER DELETE ON posts
+BEGIN
+    DELETE FROM content_links WHERE target_type = 'post' AND target_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS group_posts_delete_links
+AFTER DELETE ON group_posts
+BEGIN
+    DELETE FROM content_links WHERE target_type = 'group_post' AND target_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS messages_purge_links
+AFTER DELETE ON messages
+BEGIN
+    DELETE FROM content_links WHERE target_type = 'message' AND target_id = old.id;
+END;
+
+-- Deleted messages stay behind as tombstones without their content
+CREATE TRIGGER IF NOT EXISTS messages_delete_links
+AFTER UPDATE OF deleted_at ON messages
+WHEN new.deleted_at IS NOT NULL
+BEGIN
+    DELETE FROM content_links WHERE target_type = 'message' AND target_id = old.id;
+END;
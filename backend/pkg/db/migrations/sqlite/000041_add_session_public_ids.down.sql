@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_public_id;
+
+ALTER TABLE sessions DROP COLUMN public_id;
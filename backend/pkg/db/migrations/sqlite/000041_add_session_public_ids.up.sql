@@ -0,0 +1,7 @@
+-- The session ID is the bearer token, so sessions are listed and revoked by a
+-- separate public ID that can't be used to sign in
+ALTER TABLE sessions ADD COLUMN public_id TEXT;
+
+UPDATE sessions SET public_id = lower(hex(randomblob(16))) WHERE public_id IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_public_id ON sessions(public_id);
@@ -0,0 +1 @@
+DROP TRIGGER IF EXISTS messages_purge_entities;
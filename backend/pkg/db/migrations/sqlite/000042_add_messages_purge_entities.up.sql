@@ -0,0 +1,12 @@
+-- Remove the mentions of messages that are deleted outright, such as through
+-- the cascade when their sender or receiver deletes their account, not just
+-- those left as tombstones
+CREATE TRIGGER IF NOT EXISTS messages_purge_entities
+AFTER DELETE ON messages
+BEGIN
+    DELETE FROM mentions WHERE target_type = 'message' AND target_id = old.id;
+END;
+
+-- Mentions of messages deleted before this trigger existed
+DELETE FROM mentions
+WHERE target_type = 'message' AND target_id NOT IN (SELECT id FROM messages);
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/auth"
+	"github.com/bernaotieno/social-network/backend/pkg/mail"
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// DeleteAccountRequest represents a request to delete the current user's account
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// GetAccountDeletion handles reporting whether the current user's account is
+// scheduled for deletion
+func (h *Handler) GetAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	scheduledAt, err := h.AccountService.GetDeletionSchedule(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get account deletion")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Account deletion retrieved successfully", map[string]interface{}{
+		"scheduled":   scheduledAt != nil,
+		"scheduledAt": scheduledAt,
+	})
+}
+
+// RequestAccountDeletion handles a user asking for their account to be deleted.
+// The account stays usable until the grace period ends and the deletion can be
+// cancelled until then.
+func (h *Handler) RequestAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Password == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+
+	user, err := h.UserService.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	// Guessing the password counts towards the same lockout as login
+	ip := auth.ClientIP(r)
+	if wait := h.loginWait(user.Email, ip); wait > 0 {
+		utils.RespondWithRateLimit(w, wait, "Too many failed attempts, please try again later")
+		return
+	}
+	if !h.UserService.CheckPassword(user, req.Password) {
+		h.recordLoginFailure(user.Email, ip)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Password is incorrect")
+		return
+	}
+
+	scheduledAt := time.Now().Add(h.DeletionGracePeriod)
+	if err := h.AccountService.ScheduleDeletion(userID, scheduledAt); err != nil {
+		if err.Error() == "deletion already scheduled" {
+			utils.RespondWithError(w, http.StatusConflict, "Account deletion is already scheduled")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to schedule account deletion")
+		return
+	}
+
+	if err := h.Mailer.Send(&mail.Message{
+		To:      user.Email,
+		Subject: "Your account is scheduled for deletion",
+		Body: fmt.Sprintf("Hi %s,\n\nYour account and everything you posted will be permanently deleted on %s.\n\nIf you change your mind, sign in and cancel the deletion before then. If you did not ask for this, sign in, cancel it and change your password.\n",
+			user.FirstName, scheduledAt.UTC().Format(time.RFC1123)),
+	}); err != nil {
+		log.Printf("Failed to send account deletion email to user %s: %v", userID, err)
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Account deletion scheduled", map[string]interface{}{
+		"scheduledAt": scheduledAt,
+	})
+}
+
+// CancelAccountDeletion handles a user keeping an account they asked to delete
+func (h *Handler) CancelAccountDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.AccountService.CancelDeletion(userID); err != nil {
+		if err.Error() == "no deletion scheduled" {
+			utils.RespondWithError(w, http.StatusNotFound, "No account deletion is scheduled")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to cancel account deletion")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Account deletion cancelled", nil)
+}
+
+// RequestDataExport handles a user asking for an archive of their data. The
+// archive is built in the background; poll the export until it is ready.
+func (h *Handler) RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	export, err := h.DataExportService.Create(userID)
+	if err != nil {
+		if err.Error() == "export already in progress" {
+			utils.RespondWithError(w, http.StatusConflict, "An export is already being prepared")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to start data export")
+		return
+	}
+
+	if h.AccountJobs != nil {
+		h.AccountJobs.Wake()
+	}
+
+	utils.RespondWithSuccess(w, http.StatusAccepted, "Data export started", map[string]interface{}{
+		"export": export,
+	})
+}
+
+// GetDataExports handles listing the current user's data exports
+func (h *Handler) GetDataExports(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	exports, err := h.DataExportService.GetByUser(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get data exports")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Data exports retrieved successfully", map[string]interface{}{
+		"exports": exports,
+	})
+}
+
+// GetDataExport handles retrieving one of the current user's data exports
+func (h *Handler) GetDataExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	export, err := h.DataExportService.GetByID(mux.Vars(r)["id"], userID)
+	if err != nil {
+		if err.Error() == "export not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "Export not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get data export")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Data export retrieved successfully", map[string]interface{}{
+		"export": export,
+	})
+}
+
+// DownloadDataExport handles downloading a finished data export archive
+func (h *Handler) DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	export, err := h.DataExportService.GetByID(mux.Vars(r)["id"], userID)
+	if err != nil {
+		if err.Error() == "export not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "Export not found")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get data export")
+		return
+	}
+	if export.FilePath == "" || (export.ExpiresAt != nil && time.Now().After(*export.ExpiresAt)) {
+		utils.RespondWithError(w, http.StatusConflict, "Export is not ready for download")
+		return
+	}
+
+	file, err := os.Open(export.FilePath)
+	if err != nil {
+		log.Printf("Failed to open data export %s: %v", export.ID, err)
+		utils.RespondWithError(w, http.StatusNotFound, "Export not found")
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="social-network-export-%s.zip"`, export.CreatedAt.UTC().Format("2006-01-02")))
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, "", time.Time{}, file)
+}
@@ -0,0 +1,483 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/auth"
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// errContentNotFound is returned when staff act on content that does not exist
+var errContentNotFound = errors.New("content not found")
+
+// ModerationRequest represents a staff action against a user account
+type ModerationRequest struct {
+	Reason        string `json:"reason"`
+	Until         string `json:"until"`         // RFC 3339; suspensions only
+	DurationHours int    `json:"durationHours"` // Alternative to Until; suspensions only
+}
+
+// ChangeRoleRequest represents a request to change a user's platform role
+type ChangeRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// adminUserView returns a user with the account details only staff may see
+func adminUserView(user *models.User) map[string]interface{} {
+	user.Password = ""
+	view := map[string]interface{}{
+		"user":   user,
+		"status": user.EffectiveStatus(),
+	}
+	if user.Status != models.AccountStatusActive {
+		view["statusReason"] = user.StatusReason
+		view["suspendedUntil"] = user.SuspendedUntil
+	}
+	return view
+}
+
+// recordModeration appends a staff action by the current user to the audit
+// log, in tx, the transaction taking the action
+func (h *Handler) recordModeration(tx *sql.Tx, r *http.Request, action models.ModerationAction, targetType models.ModerationTarget, targetID, reason, details string) error {
+	actorID, _ := middleware.GetUserID(r)
+	return h.ModerationLogService.Record(tx, &models.ModerationLogEntry{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		Details:    details,
+	})
+}
+
+// moderationTarget loads the user named in the URL and checks the current
+// staff member may act on them. It responds and returns nil when not allowed.
+func (h *Handler) moderationTarget(w http.ResponseWriter, r *http.Request) *models.User {
+	vars := mux.Vars(r)
+	return h.moderatableUser(w, r, vars["id"])
+}
+
+// moderatableUser loads a user and checks the current staff member may act
+// on them: nobody acts on their own account, and only admins act on other
+// staff. It responds and returns nil when not allowed.
+func (h *Handler) moderatableUser(w http.ResponseWriter, r *http.Request, userID string) *models.User {
+	actorID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return nil
+	}
+	actorRole, err := middleware.GetUserRole(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusForbidden, "Forbidden: insufficient permissions")
+		return nil
+	}
+
+	target, err := h.UserService.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return nil
+	}
+
+	if target.ID == actorID {
+		utils.RespondWithError(w, http.StatusBadRequest, "You cannot moderate your own account")
+		return nil
+	}
+	if models.UserRole(target.Role).IsStaff() && actorRole != models.UserRoleAdmin {
+		utils.RespondWithError(w, http.StatusForbidden, "Only admins can moderate staff accounts")
+		return nil
+	}
+
+	return target
+}
+
+// signOutEverywhere revokes all of a user's sessions and drops their WebSocket connections
+func (h *Handler) signOutEverywhere(userID, reason string) {
+	if _, err := auth.RevokeUserSessions(h.DB, userID, "", reason, h.Hub); err != nil {
+		log.Printf("Failed to revoke sessions for user %s: %v", userID, err)
+	}
+	// Catch connections whose session had already expired
+	h.Hub.BroadcastSessionInvalidation(userID, "", reason)
+}
+
+// parseSuspensionEnd reads when a suspension ends from an RFC 3339 time or a
+// duration in hours. It returns nil, for a suspension until reinstated, when neither is given.
+func parseSuspensionEnd(until string, durationHours int) (*time.Time, error) {
+	var end time.Time
+	switch {
+	case until != "":
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return nil, errors.New("invalid until time, expected RFC 3339")
+		}
+		end = t
+	case durationHours > 0:
+		end = time.Now().Add(time.Duration(durationHours) * time.Hour)
+	default:
+		return nil, nil
+	}
+
+	if !end.After(time.Now()) {
+		return nil, errors.New("suspension must end in the future")
+	}
+	return &end, nil
+}
+
+// restrictAccount suspends or bans a user and records the action in tx. Once
+// tx is committed the user is signed out with signOutRestricted.
+func (h *Handler) restrictAccount(tx *sql.Tx, r *http.Request, target *models.User, status models.AccountStatus, until *time.Time, reason string) error {
+	if err := h.UserService.SetStatus(tx, target.ID, status, until, reason); err != nil {
+		return err
+	}
+
+	if status == models.AccountStatusBanned {
+		return h.recordModeration(tx, r, models.ModerationActionBanUser, models.ModerationTargetUser, target.ID, reason, "")
+	}
+
+	details := "indefinite"
+	if until != nil {
+		details = "until " + until.UTC().Format(time.RFC3339)
+	}
+	return h.recordModeration(tx, r, models.ModerationActionSuspendUser, models.ModerationTargetUser, target.ID, reason, details)
+}
+
+// signOutRestricted signs a user who was just suspended or banned out everywhere
+func (h *Handler) signOutRestricted(userID string, status models.AccountStatus) {
+	if status == models.AccountStatusBanned {
+		h.signOutEverywhere(userID, auth.AccountBannedReason)
+		return
+	}
+	h.signOutEverywhere(userID, auth.AccountSuspendedReason)
+}
+
+// AdminListUsers handles listing and searching users for staff
+func (h *Handler) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePage(r, 20)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.AdminUserFilter{
+		Query:  strings.TrimSpace(query.Get("q")),
+		Status: models.AccountStatus(query.Get("status")),
+		Role:   models.UserRole(query.Get("role")),
+	}
+
+	users, nextCursor, err := h.UserService.ListForAdmin(filter, page)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get users")
+		return
+	}
+
+	views := make([]map[string]interface{}, len(users))
+	for i, user := range users {
+		views[i] = adminUserView(user)
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, "Users retrieved successfully", map[string]interface{}{
+		"users": views,
+	}, nextCursor)
+}
+
+// AdminGetUser handles getting a user's account details and moderation history
+func (h *Handler) AdminGetUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID := vars["id"]
+
+	user, err := h.UserService.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	history, _, err := h.ModerationLogService.List(models.ModerationLogFilter{
+		TargetType: models.ModerationTargetUser,
+		TargetID:   userID,
+	}, models.Page{Limit: 20})
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get moderation history")
+		return
+	}
+
+	view := adminUserView(user)
+	view["history"] = history
+	utils.RespondWithSuccess(w, http.StatusOK, "User retrieved successfully", view)
+}
+
+// SuspendUser handles suspending an account for a period or until reinstated
+func (h *Handler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	var req ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Reason is required")
+		return
+	}
+
+	until, err := parseSuspensionEnd(req.Until, req.DurationHours)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	target := h.moderationTarget(w, r)
+	if target == nil {
+		return
+	}
+
+	err = h.ModerationLogService.Moderate(func(tx *sql.Tx) error {
+		return h.restrictAccount(tx, r, target, models.AccountStatusSuspended, until, req.Reason)
+	})
+	if err != nil {
+		log.Printf("Failed to suspend user %s: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to suspend user")
+		return
+	}
+	h.signOutRestricted(target.ID, models.AccountStatusSuspended)
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User suspended successfully", map[string]interface{}{
+		"suspendedUntil": until,
+	})
+}
+
+// BanUser handles permanently banning an account
+func (h *Handler) BanUser(w http.ResponseWriter, r *http.Request) {
+	var req ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Reason is required")
+		return
+	}
+
+	target := h.moderationTarget(w, r)
+	if target == nil {
+		return
+	}
+
+	err := h.ModerationLogService.Moderate(func(tx *sql.Tx) error {
+		return h.restrictAccount(tx, r, target, models.AccountStatusBanned, nil, req.Reason)
+	})
+	if err != nil {
+		log.Printf("Failed to ban user %s: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to ban user")
+		return
+	}
+	h.signOutRestricted(target.ID, models.AccountStatusBanned)
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User banned successfully", nil)
+}
+
+// ReinstateUser handles lifting a suspension or ban
+func (h *Handler) ReinstateUser(w http.ResponseWriter, r *http.Request) {
+	var req ModerationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	target := h.moderationTarget(w, r)
+	if target == nil {
+		return
+	}
+
+	if target.Status == models.AccountStatusActive {
+		utils.RespondWithError(w, http.StatusBadRequest, "User is not suspended or banned")
+		return
+	}
+
+	err := h.ModerationLogService.Moderate(func(tx *sql.Tx) error {
+		if err := h.UserService.SetStatus(tx, target.ID, models.AccountStatusActive, nil, ""); err != nil {
+			return err
+		}
+		return h.recordModeration(tx, r, models.ModerationActionReinstateUser, models.ModerationTargetUser, target.ID, strings.TrimSpace(req.Reason), "was "+string(target.Status))
+	})
+	if err != nil {
+		log.Printf("Failed to reinstate user %s: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reinstate user")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User reinstated successfully", nil)
+}
+
+// ChangeUserRole handles an admin changing a user's platform role
+func (h *Handler) ChangeUserRole(w http.ResponseWriter, r *http.Request) {
+	var req ChangeRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	role := models.UserRole(req.Role)
+	if role != models.UserRoleMember && role != models.UserRoleModerator && role != models.UserRoleAdmin {
+		utils.RespondWithError(w, http.StatusBadRequest, "Role must be member, moderator or admin")
+		return
+	}
+
+	target := h.moderationTarget(w, r)
+	if target == nil {
+		return
+	}
+
+	if models.UserRole(target.Role) == role {
+		utils.RespondWithError(w, http.StatusBadRequest, "User already has this role")
+		return
+	}
+
+	err := h.ModerationLogService.Moderate(func(tx *sql.Tx) error {
+		if err := h.UserService.UpdateRole(tx, target.ID, role); err != nil {
+			return err
+		}
+		return h.recordModeration(tx, r, models.ModerationActionChangeRole, models.ModerationTargetUser, target.ID, "", fmt.Sprintf("%s -> %s", target.Role, role))
+	})
+	if err != nil {
+		log.Printf("Failed to change role of user %s: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to change role")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Role changed successfully", map[string]interface{}{
+		"role": role,
+	})
+}
+
+// UnlockAccount handles staff clearing the failed login lockout on a user's account
+func (h *Handler) UnlockAccount(w http.ResponseWriter, r *http.Request) {
+	target := h.moderationTarget(w, r)
+	if target == nil {
+		return
+	}
+
+	// The lockout isn't kept in the database, so the entry is written first
+	// and rolled back if clearing the lockout fails
+	err := h.ModerationLogService.Moderate(func(tx *sql.Tx) error {
+		if err := h.recordModeration(tx, r, models.ModerationActionUnlockAccount, models.ModerationTargetUser, target.ID, "", ""); err != nil {
+			return err
+		}
+		return h.RateLimits.AccountLockout.Reset(accountLockoutKey(target.Email))
+	})
+	if err != nil {
+		log.Printf("Failed to unlock account of user %s: %v", target.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to unlock account")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Account unlocked successfully", nil)
+}
+
+// removeContent deletes a post, comment or group on behalf of staff, in tx.
+// Post IDs may name a regular post or a group post.
+func (h *Handler) removeContent(tx *sql.Tx, targetType models.ModerationTarget, id string) error {
+	var err error
+	switch targetType {
+	case models.ModerationTargetPost:
+		err = h.PostService.ForceDelete(tx, id)
+		if err != nil && err.Error() == "post not found" {
+			err = h.GroupPostService.ForceDelete(tx, id)
+		}
+	case models.ModerationTargetComment:
+		err = h.CommentService.ForceDelete(tx, id)
+	case models.ModerationTargetGroup:
+		err = h.GroupService.ForceDelete(tx, id)
+	default:
+		return fmt.Errorf("cannot remove %s content", targetType)
+	}
+
+	if err != nil && strings.HasSuffix(err.Error(), "not found") {
+		return errContentNotFound
+	}
+	return err
+}
+
+// adminDeleteContent handles staff deleting a piece of content named in the URL
+func (h *Handler) adminDeleteContent(w http.ResponseWriter, r *http.Request, targetType models.ModerationTarget, action models.ModerationAction) {
+	var req ModerationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	err := h.ModerationLogService.Moderate(func(tx *sql.Tx) error {
+		if err := h.removeContent(tx, targetType, id); err != nil {
+			return err
+		}
+		return h.recordModeration(tx, r, action, targetType, id, strings.TrimSpace(req.Reason), "")
+	})
+	if err != nil {
+		if err == errContentNotFound {
+			utils.RespondWithError(w, http.StatusNotFound, "Not found")
+		} else {
+			log.Printf("Failed to delete %s %s: %v", targetType, id, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete "+string(targetType))
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Deleted successfully", nil)
+}
+
+// AdminDeletePost handles staff deleting any post or group post
+func (h *Handler) AdminDeletePost(w http.ResponseWriter, r *http.Request) {
+	h.adminDeleteContent(w, r, models.ModerationTargetPost, models.ModerationActionDeletePost)
+}
+
+// AdminDeleteComment handles staff deleting any comment
+func (h *Handler) AdminDeleteComment(w http.ResponseWriter, r *http.Request) {
+	h.adminDeleteContent(w, r, models.ModerationTargetComment, models.ModerationActionDeleteComment)
+}
+
+// AdminDeleteGroup handles staff deleting any group
+func (h *Handler) AdminDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	h.adminDeleteContent(w, r, models.ModerationTargetGroup, models.ModerationActionDeleteGroup)
+}
+
+// GetModerationLog handles reading the moderation audit log
+func (h *Handler) GetModerationLog(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePage(r, 50)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.ModerationLogFilter{
+		ActorID:    query.Get("actorId"),
+		TargetType: models.ModerationTarget(query.Get("targetType")),
+		TargetID:   query.Get("targetId"),
+	}
+
+	entries, nextCursor, err := h.ModerationLogService.List(filter, page)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get moderation log")
+		return
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, "Moderation log retrieved successfully", map[string]interface{}{
+		"entries": entries,
+	}, nextCursor)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strings"
@@ -34,7 +35,7 @@ type LoginRequest struct {
 // Register handles user registration
 func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form (for avatar upload support)
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
@@ -65,8 +66,8 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate password length
-	if len(req.Password) < 6 {
-		utils.RespondWithError(w, http.StatusBadRequest, "Password must be at least 6 characters")
+	if len(req.Password) < minPasswordLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
 		return
 	}
 
@@ -102,7 +103,7 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		// Save avatar image
 		avatarPath, err = utils.SaveImage(file, header, "avatars")
 		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, "Failed to save avatar: "+err.Error())
+			utils.RespondWithImageError(w, err)
 			return
 		}
 	}
@@ -134,8 +135,14 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create session with hub for session invalidation broadcasting
-	sessionID, err := auth.CreateSessionWithHub(r.Context(), h.DB, user.ID, w, r, h.Hub)
+	// The account stays pending until the emailed link is followed; a failed
+	// send is not fatal since the user can ask for another link
+	if err := h.sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", user.ID, err)
+	}
+
+	// Create session for this device
+	sessionID, err := auth.CreateSession(r.Context(), h.DB, user.ID, w, r)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create session")
 		return
@@ -166,11 +173,20 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuse attempts while the account or IP is held back after failed logins
+	ip := auth.ClientIP(r)
+	if wait := h.loginWait(req.Email, ip); wait > 0 {
+		log.Printf("Login refused for email %s from %s: locked for %s", req.Email, ip, wait)
+		utils.RespondWithRateLimit(w, wait, "Too many failed login attempts, please try again later")
+		return
+	}
+
 	log.Printf("Looking up user by email: %s", req.Email)
 	// Get user by email
 	user, err := h.UserService.GetByEmail(req.Email)
 	if err != nil {
 		log.Printf("User lookup failed for email %s: %v", req.Email, err)
+		h.recordLoginFailure(req.Email, ip)
 		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
@@ -180,14 +196,67 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	log.Println("Checking password...")
 	if !h.UserService.CheckPassword(user, req.Password) {
 		log.Printf("Password check failed for user %s", user.Email)
+		h.recordLoginFailure(req.Email, ip)
 		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		return
 	}
 	log.Printf("Password check successful for user %s", user.Email)
 
-	// Create session with hub for session invalidation broadcasting
+	// Banned and suspended accounts cannot sign in
+	if h.refuseRestrictedAccount(w, user) {
+		return
+	}
+
+	// With two-factor authentication on, the password alone does not sign in;
+	// the client trades the challenge token and a code for a session
+	twoFactorEnabled, err := h.TwoFactorService.IsEnabled(user.ID)
+	if err != nil {
+		log.Printf("Failed to check two-factor status for user %s: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+	if twoFactorEnabled {
+		log.Printf("Two-factor code required for user %s", user.Email)
+		utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication required", map[string]interface{}{
+			"twoFactorRequired": true,
+			"challengeToken":    auth.SignToken(auth.TwoFactorChallengePurpose, twoFactorChallengeTTL, user.ID),
+		})
+		return
+	}
+
+	h.completeLogin(w, r, user)
+}
+
+// refuseRestrictedAccount responds with an error and returns true if staff
+// have banned the user or suspended them for a period that has not ended
+func (h *Handler) refuseRestrictedAccount(w http.ResponseWriter, user *models.User) bool {
+	switch user.EffectiveStatus() {
+	case models.AccountStatusBanned:
+		log.Printf("Login refused for banned user %s", user.ID)
+		utils.RespondWithError(w, http.StatusForbidden, "This account has been banned")
+		return true
+	case models.AccountStatusSuspended:
+		log.Printf("Login refused for suspended user %s", user.ID)
+		message := "This account has been suspended"
+		if user.SuspendedUntil != nil {
+			message += " until " + user.SuspendedUntil.UTC().Format(time.RFC1123)
+		}
+		utils.RespondWithError(w, http.StatusForbidden, message)
+		return true
+	}
+	return false
+}
+
+// completeLogin signs a user in on the requesting device once every login check has passed
+func (h *Handler) completeLogin(w http.ResponseWriter, r *http.Request, user *models.User) {
+	// A successful login clears the account's failed attempts
+	if err := h.RateLimits.AccountLockout.Reset(accountLockoutKey(user.Email)); err != nil {
+		log.Printf("Failed to reset login failures for user %s: %v", user.Email, err)
+	}
+
+	// Create session for this device, leaving sessions on other devices signed in
 	log.Printf("Creating session for user ID: %s", user.ID)
-	sessionID, err := auth.CreateSessionWithHub(r.Context(), h.DB, user.ID, w, r, h.Hub)
+	sessionID, err := auth.CreateSession(r.Context(), h.DB, user.ID, w, r)
 	if err != nil {
 		log.Printf("Failed to create session: %v", err)
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create session")
@@ -205,25 +274,29 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	utils.RespondWithSuccess(w, http.StatusOK, "Login successful", responseData)
 }
 
-// Logout handles user logout
+// Logout handles user logout for the current device
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
-	// Get user ID from context
+	// Get user ID and session ID from context
 	userID, err := middleware.GetUserID(r)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	sessionID, _ := middleware.GetSessionID(r)
 
-	// Clear session
+	// Clear session cookie
 	if err := auth.ClearSession(r.Context(), h.DB, w, r); err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to logout")
 		return
 	}
 
-	// Delete all sessions for the user
-	if err := h.SessionService.DeleteAllForUser(userID); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete sessions")
-		return
+	// Delete the current session only; other devices stay signed in
+	if sessionID != "" {
+		if err := h.SessionService.Delete(sessionID); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete session")
+			return
+		}
+		h.Hub.BroadcastSessionInvalidation(userID, sessionID, auth.LoggedOutReason)
 	}
 
 	utils.RespondWithSuccess(w, http.StatusOK, "Logout successful", nil)
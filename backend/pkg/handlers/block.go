@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// BlockUser handles blocking a user, which also ends any follow relationship
+// between the two
+func (h *Handler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	targetID := mux.Vars(r)["id"]
+	if targetID == userID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Cannot block yourself")
+		return
+	}
+
+	if _, err := h.UserService.GetByID(targetID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := h.BlockService.Block(userID, targetID); err != nil {
+		if err.Error() == "user already blocked" {
+			utils.RespondWithError(w, http.StatusConflict, "User already blocked")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to block user")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User blocked successfully", nil)
+}
+
+// UnblockUser handles lifting a block
+func (h *Handler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.BlockService.Unblock(userID, mux.Vars(r)["id"]); err != nil {
+		if err.Error() == "user not blocked" {
+			utils.RespondWithError(w, http.StatusNotFound, "User not blocked")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to unblock user")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User unblocked successfully", nil)
+}
+
+// GetBlockedUsers handles listing the users the current user has blocked
+func (h *Handler) GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	users, err := h.BlockService.GetBlockedUsers(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get blocked users")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Blocked users retrieved successfully", map[string]interface{}{
+		"users": users,
+	})
+}
+
+// MuteUser handles muting a user, hiding their posts from the current user's
+// feed without telling them
+func (h *Handler) MuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	targetID := mux.Vars(r)["id"]
+	if targetID == userID {
+		utils.RespondWithError(w, http.StatusBadRequest, "Cannot mute yourself")
+		return
+	}
+
+	if _, err := h.UserService.GetProfile(targetID, userID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+
+	if err := h.BlockService.Mute(userID, targetID); err != nil {
+		if err.Error() == "user already muted" {
+			utils.RespondWithError(w, http.StatusConflict, "User already muted")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to mute user")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User muted successfully", nil)
+}
+
+// UnmuteUser handles lifting a mute
+func (h *Handler) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	if err := h.BlockService.Unmute(userID, mux.Vars(r)["id"]); err != nil {
+		if err.Error() == "user not muted" {
+			utils.RespondWithError(w, http.StatusNotFound, "User not muted")
+			return
+		}
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to unmute user")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "User unmuted successfully", nil)
+}
+
+// GetMutedUsers handles listing the users the current user has muted
+func (h *Handler) GetMutedUsers(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	users, err := h.BlockService.GetMutedUsers(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get muted users")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Muted users retrieved successfully", map[string]interface{}{
+		"users": users,
+	})
+}
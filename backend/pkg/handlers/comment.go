@@ -2,8 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
-	"strconv"
 
 	"github.com/bernaotieno/social-network/backend/pkg/middleware"
 	"github.com/bernaotieno/social-network/backend/pkg/models"
@@ -14,6 +15,12 @@ import (
 
 // AddCommentRequest represents a request to add a comment
 type AddCommentRequest struct {
+	Content         string `json:"content"`
+	ParentCommentID string `json:"parentCommentId"`
+}
+
+// UpdateCommentRequest represents a request to edit a comment
+type UpdateCommentRequest struct {
 	Content string `json:"content"`
 }
 
@@ -38,38 +45,22 @@ func (h *Handler) GetComments(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	// Set default values
-	limit := 50
-	offset := 0
-
-	// Parse limit and offset
-	if limitStr != "" {
-		parsedLimit, err := strconv.Atoi(limitStr)
-		if err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	if offsetStr != "" {
-		parsedOffset, err := strconv.Atoi(offsetStr)
-		if err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	page, err := parsePage(r, 50)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
 	}
 
 	// Get comments
-	comments, err := h.CommentService.GetCommentsByPost(postID, currentUserID, limit, offset)
+	comments, nextCursor, err := h.CommentService.GetCommentsByPostPage(postID, currentUserID, page)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get comments")
 		return
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, "Comments retrieved successfully", map[string]interface{}{
+	utils.RespondWithPage(w, http.StatusOK, "Comments retrieved successfully", map[string]interface{}{
 		"comments": comments,
-	})
+	}, nextCursor)
 }
 
 // AddComment handles adding a comment to a post
@@ -87,6 +78,7 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 
 	var content string
 	var imagePath string
+	var parentCommentID string
 
 	// Check content type to determine how to parse the request
 	contentType := r.Header.Get("Content-Type")
@@ -99,15 +91,17 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		content = req.Content
+		parentCommentID = req.ParentCommentID
 	} else {
 		// Parse multipart form (for comments with images)
-		if err := r.ParseMultipartForm(10 << 20); err != nil {
+		if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 			utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 			return
 		}
 
 		// Get content from form
 		content = r.FormValue("content")
+		parentCommentID = r.FormValue("parentCommentId")
 
 		// Check if image was uploaded
 		file, header, err := r.FormFile("image")
@@ -117,7 +111,7 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 			// Save image
 			imagePath, err = utils.SaveImage(file, header, "comments")
 			if err != nil {
-				utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+				utils.RespondWithImageError(w, err)
 				return
 			}
 		}
@@ -136,18 +130,34 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check the comment being replied to, if any
+	var parent *models.Comment
+	if parentCommentID != "" {
+		parent, err = h.getPostComment(postID, parentCommentID, userID)
+		if err != nil {
+			respondWithCommentError(w, err, "Parent comment not found")
+			return
+		}
+	}
+
 	// Create comment
 	comment := &models.Comment{
-		PostID:  postID,
-		UserID:  userID,
-		Content: content,
-		Image:   imagePath,
+		PostID:          postID,
+		UserID:          userID,
+		Content:         content,
+		Image:           imagePath,
+		ParentCommentID: parentCommentID,
 	}
 
 	if err := h.CommentService.Create(comment); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create comment")
+		if err.Error() == "parent comment not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "Parent comment not found")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create comment")
+		}
 		return
 	}
+	comment.Mentions = h.syncEntities(entityTarget{Type: models.EntityTargetComment, ID: comment.ID, AuthorID: userID, PostID: postID}, comment.Content)
 
 	// Get user for response
 	user, err := h.UserService.GetByID(userID)
@@ -160,8 +170,12 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 	// Add user to comment for response
 	comment.Author = user
 
-	// Create notification for post owner (if not the same user)
-	if post.UserID != userID {
+	// Notify the author of the comment replied to
+	h.notifyCommentReply(parent, comment, "")
+
+	// Create notification for post owner (if not the same user, and not
+	// already notified of the reply)
+	if post.UserID != userID && (parent == nil || parent.UserID != post.UserID) {
 		notification := &models.Notification{
 			UserID:   post.UserID,
 			SenderID: userID,
@@ -201,6 +215,29 @@ func (h *Handler) AddComment(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// UpdateComment handles editing a comment on a post
+func (h *Handler) UpdateComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get post ID and comment ID from URL
+	vars := mux.Vars(r)
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	// Check if post exists and user can view it
+	if _, err := h.PostService.GetByID(postID, userID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	h.updateComment(w, r, postID, commentID, userID, "")
+}
+
 // DeleteComment handles deleting a comment
 func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
@@ -254,3 +291,350 @@ func (h *Handler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 
 	utils.RespondWithSuccess(w, http.StatusOK, "Comment deleted successfully", nil)
 }
+
+// GetCommentReplies handles retrieving the replies to a comment on a post
+func (h *Handler) GetCommentReplies(w http.ResponseWriter, r *http.Request) {
+	// Get current user ID from context (authenticated user required)
+	currentUserID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get post ID and comment ID from URL
+	vars := mux.Vars(r)
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	// Check if post exists and user can view it
+	if _, err := h.PostService.GetByID(postID, currentUserID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	h.respondWithReplies(w, r, postID, commentID, currentUserID)
+}
+
+// LikeComment handles liking a comment on a post
+func (h *Handler) LikeComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get post ID and comment ID from URL
+	vars := mux.Vars(r)
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	// Check if post exists and user can view it
+	if _, err := h.PostService.GetByID(postID, userID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	h.likeComment(w, postID, commentID, userID, "")
+}
+
+// UnlikeComment handles unliking a comment on a post
+func (h *Handler) UnlikeComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get post ID and comment ID from URL
+	vars := mux.Vars(r)
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	// Check if post exists and user can view it
+	if _, err := h.PostService.GetByID(postID, userID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+		return
+	}
+
+	h.unlikeComment(w, postID, commentID, userID, "")
+}
+
+// getPostComment gets a comment on a post, as long as neither the user nor
+// the comment's author has blocked the other
+func (h *Handler) getPostComment(postID, commentID, userID string) (*models.Comment, error) {
+	comment, err := h.CommentService.GetByID(commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.PostID != postID {
+		return nil, errors.New("comment not found")
+	}
+
+	blocked, err := h.BlockService.IsBlocked(userID, comment.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, errors.New("comment not found")
+	}
+
+	return comment, nil
+}
+
+// respondWithCommentError responds to an error from getPostComment
+func respondWithCommentError(w http.ResponseWriter, err error, notFoundMessage string) {
+	if err.Error() == "comment not found" {
+		utils.RespondWithError(w, http.StatusNotFound, notFoundMessage)
+	} else {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get comment")
+	}
+}
+
+// respondWithReplies responds with a page of the replies to a comment, once
+// the user is known to be able to see the post it is on
+func (h *Handler) respondWithReplies(w http.ResponseWriter, r *http.Request, postID, commentID, userID string) {
+	if _, err := h.getPostComment(postID, commentID, userID); err != nil {
+		respondWithCommentError(w, err, "Comment not found")
+		return
+	}
+
+	// Parse query parameters
+	page, err := parsePage(r, 50)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
+	// Get replies
+	replies, nextCursor, err := h.CommentService.GetRepliesPage(commentID, userID, page)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get replies")
+		return
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, "Replies retrieved successfully", map[string]interface{}{
+		"replies": replies,
+	}, nextCursor)
+}
+
+// likeComment likes a comment on a post or group post, once the user is known
+// to be able to see the post. groupID is empty for posts outside groups.
+func (h *Handler) likeComment(w http.ResponseWriter, postID, commentID, userID, groupID string) {
+	comment, err := h.getPostComment(postID, commentID, userID)
+	if err != nil {
+		respondWithCommentError(w, err, "Comment not found")
+		return
+	}
+
+	// Like comment
+	if err := h.CommentLikeService.Create(commentID, userID); err != nil {
+		if err.Error() == "comment already liked by user" {
+			utils.RespondWithError(w, http.StatusConflict, "Comment already liked")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to like comment")
+		}
+		return
+	}
+
+	// Create notification for comment author (if not the same user)
+	if comment.UserID != userID {
+		commentContent := comment.Content
+		if len(commentContent) > 50 {
+			commentContent = commentContent[:50] + "..."
+		}
+
+		notificationData := map[string]interface{}{
+			"postId":    postID,
+			"commentId": commentID,
+			"comment":   commentContent,
+		}
+		if groupID != "" {
+			notificationData["groupId"] = groupID
+		}
+		dataJSON, _ := json.Marshal(notificationData)
+
+		notification := &models.Notification{
+			UserID:   comment.UserID,
+			SenderID: userID,
+			Type:     models.NotificationTypeCommentLike,
+			Content:  "liked your comment",
+			Data:     string(dataJSON),
+		}
+
+		if err := h.NotificationService.Create(notification); err != nil {
+			// Log error but don't fail the request
+			log.Printf("Error creating notification: %v", err)
+		}
+	}
+
+	h.respondWithCommentLikes(w, "Comment liked successfully", postID, commentID, userID, groupID, "like")
+}
+
+// unlikeComment removes the user's like from a comment on a post or group post
+func (h *Handler) unlikeComment(w http.ResponseWriter, postID, commentID, userID, groupID string) {
+	if _, err := h.getPostComment(postID, commentID, userID); err != nil {
+		respondWithCommentError(w, err, "Comment not found")
+		return
+	}
+
+	// Unlike comment
+	if err := h.CommentLikeService.Delete(commentID, userID); err != nil {
+		if err.Error() == "like not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "Comment not liked")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to unlike comment")
+		}
+		return
+	}
+
+	h.respondWithCommentLikes(w, "Comment unliked successfully", postID, commentID, userID, groupID, "unlike")
+}
+
+// respondWithCommentLikes broadcasts a change to a comment's likes and
+// responds with the new like count
+func (h *Handler) respondWithCommentLikes(w http.ResponseWriter, successMessage, postID, commentID, userID, groupID, action string) {
+	likesCount, err := h.CommentLikeService.GetLikeCount(commentID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get like count")
+		return
+	}
+
+	// Broadcast like event via WebSocket
+	likeEvent := map[string]interface{}{
+		"postId":     postID,
+		"commentId":  commentID,
+		"userId":     userID,
+		"action":     action,
+		"likesCount": likesCount,
+	}
+
+	// Comments on group posts are only broadcast to group members
+	messageType := "comment_like"
+	roomID := "" // Default room (all users)
+	if groupID != "" {
+		likeEvent["groupId"] = groupID
+		messageType = "group_post_comment_like"
+		roomID = "group_" + groupID
+	}
+
+	message := map[string]interface{}{
+		"type":    messageType,
+		"payload": likeEvent,
+	}
+
+	messageData, _ := json.Marshal(message)
+
+	h.Hub.Broadcast <- &websocket.Broadcast{
+		RoomID:  roomID,
+		Message: messageData,
+		Sender:  nil, // No specific sender for server events
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, successMessage, map[string]interface{}{
+		"likesCount": likesCount,
+		"isLiked":    action == "like",
+	})
+}
+
+// notifyCommentReply notifies the author of the comment replied to, if any.
+// groupID is empty for posts outside groups.
+func (h *Handler) notifyCommentReply(parent, reply *models.Comment, groupID string) {
+	if parent == nil || parent.UserID == reply.UserID {
+		return
+	}
+
+	notificationData := map[string]interface{}{
+		"postId":          reply.PostID,
+		"commentId":       reply.ID,
+		"parentCommentId": parent.ID,
+		"comment":         reply.Content,
+	}
+	if groupID != "" {
+		notificationData["groupId"] = groupID
+	}
+	dataJSON, _ := json.Marshal(notificationData)
+
+	notification := &models.Notification{
+		UserID:   parent.UserID,
+		SenderID: reply.UserID,
+		Type:     models.NotificationTypeCommentReply,
+		Content:  "replied to your comment",
+		Data:     string(dataJSON),
+	}
+
+	if err := h.NotificationService.Create(notification); err != nil {
+		// Log error but don't fail the request
+		log.Printf("Error creating notification: %v", err)
+	}
+}
+
+// updateComment edits a comment on a post or group post by its author, once
+// the user is known to be able to see the post. groupID is empty for posts
+// outside groups.
+func (h *Handler) updateComment(w http.ResponseWriter, r *http.Request, postID, commentID, userID, groupID string) {
+	var req UpdateCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	comment, err := h.getPostComment(postID, commentID, userID)
+	if err != nil {
+		respondWithCommentError(w, err, "Comment not found")
+		return
+	}
+
+	// Check if user is the comment author
+	if comment.UserID != userID {
+		utils.RespondWithError(w, http.StatusForbidden, "Not authorized to update this comment")
+		return
+	}
+
+	// Validate content (allow empty content if the comment has an image)
+	if req.Content == "" && comment.Image == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Content or image is required")
+		return
+	}
+
+	// Save changes
+	comment.Content = req.Content
+	if err := h.CommentService.Update(comment); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update comment")
+		return
+	}
+	comment.Mentions = h.syncEntities(entityTarget{Type: models.EntityTargetComment, ID: comment.ID, AuthorID: userID, PostID: postID, GroupID: groupID}, comment.Content)
+
+	// Broadcast comment update event via WebSocket
+	updateCommentEvent := map[string]interface{}{
+		"postId":  postID,
+		"comment": comment,
+	}
+
+	// Comments on group posts are only broadcast to group members
+	messageType := "comment_updated"
+	roomID := "" // Default room (all users)
+	if groupID != "" {
+		updateCommentEvent["groupId"] = groupID
+		messageType = "group_post_comment_updated"
+		roomID = "group_" + groupID
+	}
+
+	message := map[string]interface{}{
+		"type":    messageType,
+		"payload": updateCommentEvent,
+	}
+
+	messageData, _ := json.Marshal(message)
+
+	h.Hub.Broadcast <- &websocket.Broadcast{
+		RoomID:  roomID,
+		Message: messageData,
+		Sender:  nil, // No specific sender for server events
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Comment updated successfully", map[string]interface{}{
+		"comment": comment,
+	})
+}
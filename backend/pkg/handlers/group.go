@@ -108,7 +108,7 @@ func (h *Handler) CreateGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse multipart form
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
@@ -145,7 +145,7 @@ func (h *Handler) CreateGroup(w http.ResponseWriter, r *http.Request) {
 		// Save image
 		imagePath, err := utils.SaveImage(file, header, "groups")
 		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			utils.RespondWithImageError(w, err)
 			return
 		}
 
@@ -215,7 +215,7 @@ func (h *Handler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 	groupID := vars["id"]
 
 	// Parse multipart form
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
@@ -267,7 +267,7 @@ func (h *Handler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
 		// Save image
 		imagePath, err := utils.SaveImage(file, header, "groups")
 		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			utils.RespondWithImageError(w, err)
 			return
 		}
 
@@ -607,7 +607,7 @@ func (h *Handler) CreateGroupPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse multipart form
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
@@ -636,7 +636,7 @@ func (h *Handler) CreateGroupPost(w http.ResponseWriter, r *http.Request) {
 		// Save image
 		imagePath, err := utils.SaveImage(file, header, "group_posts")
 		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			utils.RespondWithImageError(w, err)
 			return
 		}
 
@@ -648,6 +648,8 @@ func (h *Handler) CreateGroupPost(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create post")
 		return
 	}
+	post.Mentions = h.syncEntities(entityTarget{Type: models.EntityTargetGroupPost, ID: post.ID, AuthorID: userID, GroupID: post.GroupID}, post.Content)
+	post.LinkPreviews = h.syncLinkPreviews(models.EntityTargetGroupPost, post.ID, post.Content)
 
 	// Get user for response
 	user, err := h.UserService.GetByID(userID)
@@ -1334,14 +1336,11 @@ func (h *Handler) InviteToGroup(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create group member with invited status (user needs to accept the invitation)
-	member := &models.GroupMember{
-		GroupID: groupID,
-		UserID:  req.UserID,
-		Role:    models.GroupMemberRoleMember,
-		Status:  models.GroupMemberStatusInvited,
-	}
-
-	if err := h.GroupMemberService.Create(member); err != nil {
+	if _, err := h.GroupMemberService.Invite(groupID, req.UserID, currentUserID); err != nil {
+		if err.Error() == "user blocked" {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to invite user")
 		return
 	}
@@ -1687,16 +1686,23 @@ func (h *Handler) GetGroupPostComments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Parse query parameters
+	page, err := parsePage(r, 50)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
 	// Get comments
-	comments, err := h.CommentService.GetCommentsByPost(postID, currentUserID, 50, 0)
+	comments, nextCursor, err := h.CommentService.GetCommentsByPostPage(postID, currentUserID, page)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get comments")
 		return
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, "Comments retrieved successfully", map[string]interface{}{
+	utils.RespondWithPage(w, http.StatusOK, "Comments retrieved successfully", map[string]interface{}{
 		"comments": comments,
-	})
+	}, nextCursor)
 }
 
 // AddGroupPostComment handles adding a comment to a group post
@@ -1740,29 +1746,30 @@ func (h *Handler) AddGroupPostComment(w http.ResponseWriter, r *http.Request) {
 
 	var content string
 	var imagePath string
+	var parentCommentID string
 
 	// Check content type to determine how to parse the request
 	contentType := r.Header.Get("Content-Type")
 
 	if contentType == "application/json" {
 		// Parse JSON request body (for text-only comments)
-		var req struct {
-			Content string `json:"content"`
-		}
+		var req AddCommentRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 			return
 		}
 		content = req.Content
+		parentCommentID = req.ParentCommentID
 	} else {
 		// Parse multipart form (for comments with images)
-		if err := r.ParseMultipartForm(10 << 20); err != nil {
+		if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 			utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 			return
 		}
 
 		// Get content from form
 		content = r.FormValue("content")
+		parentCommentID = r.FormValue("parentCommentId")
 
 		// Check if image was uploaded
 		file, header, err := r.FormFile("image")
@@ -1772,7 +1779,7 @@ func (h *Handler) AddGroupPostComment(w http.ResponseWriter, r *http.Request) {
 			// Save image
 			imagePath, err = utils.SaveImage(file, header, "comments")
 			if err != nil {
-				utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+				utils.RespondWithImageError(w, err)
 				return
 			}
 		}
@@ -1784,18 +1791,34 @@ func (h *Handler) AddGroupPostComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check the comment being replied to, if any
+	var parent *models.Comment
+	if parentCommentID != "" {
+		parent, err = h.getPostComment(postID, parentCommentID, userID)
+		if err != nil {
+			respondWithCommentError(w, err, "Parent comment not found")
+			return
+		}
+	}
+
 	// Create comment
 	comment := &models.Comment{
-		PostID:  postID,
-		UserID:  userID,
-		Content: content,
-		Image:   imagePath,
+		PostID:          postID,
+		UserID:          userID,
+		Content:         content,
+		Image:           imagePath,
+		ParentCommentID: parentCommentID,
 	}
 
 	if err := h.CommentService.Create(comment); err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create comment")
+		if err.Error() == "parent comment not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "Parent comment not found")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create comment")
+		}
 		return
 	}
+	comment.Mentions = h.syncEntities(entityTarget{Type: models.EntityTargetComment, ID: comment.ID, AuthorID: userID, PostID: postID, GroupID: groupID}, comment.Content)
 
 	// Get user for response
 	user, err := h.UserService.GetByID(userID)
@@ -1808,8 +1831,12 @@ func (h *Handler) AddGroupPostComment(w http.ResponseWriter, r *http.Request) {
 	// Add user to comment for response
 	comment.Author = user
 
-	// Create notification for post owner (if not the same user)
-	if post.UserID != userID {
+	// Notify the author of the comment replied to
+	h.notifyCommentReply(parent, comment, groupID)
+
+	// Create notification for post owner (if not the same user, and not
+	// already notified of the reply)
+	if post.UserID != userID && (parent == nil || parent.UserID != post.UserID) {
 		notification := &models.Notification{
 			UserID:   post.UserID,
 			SenderID: userID,
@@ -1850,6 +1877,126 @@ func (h *Handler) AddGroupPostComment(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GetGroupPostCommentReplies handles retrieving the replies to a comment on a
+// group post
+func (h *Handler) GetGroupPostCommentReplies(w http.ResponseWriter, r *http.Request) {
+	// Get current user ID from context
+	currentUserID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get group ID, post ID, and comment ID from URL
+	vars := mux.Vars(r)
+	groupID := vars["groupId"]
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	if _, ok := h.getGroupPostForMember(w, groupID, postID, currentUserID); !ok {
+		return
+	}
+
+	h.respondWithReplies(w, r, postID, commentID, currentUserID)
+}
+
+// LikeGroupPostComment handles liking a comment on a group post
+func (h *Handler) LikeGroupPostComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get group ID, post ID, and comment ID from URL
+	vars := mux.Vars(r)
+	groupID := vars["groupId"]
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	if _, ok := h.getGroupPostForMember(w, groupID, postID, userID); !ok {
+		return
+	}
+
+	h.likeComment(w, postID, commentID, userID, groupID)
+}
+
+// UnlikeGroupPostComment handles unliking a comment on a group post
+func (h *Handler) UnlikeGroupPostComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get group ID, post ID, and comment ID from URL
+	vars := mux.Vars(r)
+	groupID := vars["groupId"]
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	if _, ok := h.getGroupPostForMember(w, groupID, postID, userID); !ok {
+		return
+	}
+
+	h.unlikeComment(w, postID, commentID, userID, groupID)
+}
+
+// getGroupPostForMember gets a post in a group the user is a member of,
+// responding with an error if the user isn't or the post isn't in it
+func (h *Handler) getGroupPostForMember(w http.ResponseWriter, groupID, postID, userID string) (*models.GroupPost, bool) {
+	// Check if user is a member of the group
+	isMember, err := h.GroupMemberService.IsGroupMember(groupID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check group membership")
+		return nil, false
+	}
+
+	if !isMember {
+		utils.RespondWithError(w, http.StatusForbidden, "Not a member of this group")
+		return nil, false
+	}
+
+	// Check if group post exists
+	post, err := h.GroupPostService.GetByID(postID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+		return nil, false
+	}
+
+	// Verify post belongs to the group
+	if post.GroupID != groupID {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found in this group")
+		return nil, false
+	}
+
+	return post, true
+}
+
+// UpdateGroupPostComment handles editing a comment on a group post
+func (h *Handler) UpdateGroupPostComment(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get group ID, post ID, and comment ID from URL
+	vars := mux.Vars(r)
+	groupID := vars["groupId"]
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	if _, ok := h.getGroupPostForMember(w, groupID, postID, userID); !ok {
+		return
+	}
+
+	h.updateComment(w, r, postID, commentID, userID, groupID)
+}
+
 // DeleteGroupPostComment handles deleting a comment from a group post
 func (h *Handler) DeleteGroupPostComment(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
@@ -1962,16 +2109,23 @@ func (h *Handler) GetGroupMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Parse query parameters
+	page, err := parsePage(r, 50)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
 	// Get messages
-	messages, err := h.MessageService.GetGroupMessages(groupID, 50, 0)
+	messages, nextCursor, err := h.MessageService.GetGroupMessagesPage(groupID, page)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get group messages")
 		return
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, "Group messages retrieved successfully", map[string]interface{}{
+	utils.RespondWithPage(w, http.StatusOK, "Group messages retrieved successfully", map[string]interface{}{
 		"messages": messages,
-	})
+	}, nextCursor)
 }
 
 // SendGroupMessage handles sending a message to a group
@@ -1989,7 +2143,8 @@ func (h *Handler) SendGroupMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		Content string `json:"content"`
+		Content         string `json:"content"`
+		ClientMessageID string `json:"clientMessageId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
@@ -2014,18 +2169,24 @@ func (h *Handler) SendGroupMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create message
+	// Create message; a retry under the same client message ID returns the stored copy
 	message := &models.Message{
-		SenderID: userID,
-		GroupID:  groupID,
-		Content:  req.Content,
+		SenderID:        userID,
+		GroupID:         groupID,
+		Content:         req.Content,
+		ClientMessageID: req.ClientMessageID,
 	}
 
 	// Save to database
-	if err := h.MessageService.Create(message); err != nil {
+	created, err := h.MessageService.CreateOnce(message)
+	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to send message")
 		return
 	}
+	if created {
+		message.Mentions = h.syncEntities(messageEntityTarget(message), message.Content)
+		message.LinkPreviews = h.syncLinkPreviews(models.EntityTargetMessage, message.ID, message.Content)
+	}
 
 	// Get user for response
 	user, err := h.UserService.GetByID(userID)
@@ -2043,11 +2204,14 @@ func (h *Handler) SendGroupMessage(w http.ResponseWriter, r *http.Request) {
 	responseMsg := map[string]interface{}{
 		"roomId": roomID,
 		"message": map[string]interface{}{
-			"id":        message.ID,
-			"content":   req.Content,
-			"sender":    userID,
-			"groupId":   groupID,
-			"timestamp": message.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			"id":              message.ID,
+			"clientMessageId": message.ClientMessageID,
+			"content":         message.Content,
+			"sender":          userID,
+			"groupId":         groupID,
+			"timestamp":       message.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			"entities":        models.ContentEntities(message.Content, message.Mentions),
+			"linkPreviews":    message.LinkPreviews,
 			"senderInfo": map[string]interface{}{
 				"id":             user.ID,
 				"username":       user.Username,
@@ -2062,8 +2226,8 @@ func (h *Handler) SendGroupMessage(w http.ResponseWriter, r *http.Request) {
 		"type":    "new_message",
 		"payload": responseMsg,
 	})
-	if err == nil {
-		// Broadcast to the room via WebSocket
+	if err == nil && created {
+		// Broadcast to the room via WebSocket; retries were broadcast the first time
 		h.Hub.Broadcast <- &websocket.Broadcast{
 			RoomID:  roomID,
 			Message: data,
@@ -2076,6 +2240,58 @@ func (h *Handler) SendGroupMessage(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// UpdateGroupPost handles editing a group post
+func (h *Handler) UpdateGroupPost(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get group ID and post ID from URL
+	vars := mux.Vars(r)
+	groupID := vars["groupId"]
+	postID := vars["postId"]
+
+	// Parse multipart form
+	if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
+		return
+	}
+
+	// Validate content
+	content := r.FormValue("content")
+	if content == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Content is required")
+		return
+	}
+
+	post, ok := h.getGroupPostForMember(w, groupID, postID, userID)
+	if !ok {
+		return
+	}
+
+	// Check if user is the post author
+	if post.UserID != userID {
+		utils.RespondWithError(w, http.StatusForbidden, "Not authorized to update this post")
+		return
+	}
+
+	// Save changes
+	post.Content = content
+	if err := h.GroupPostService.Update(post); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update post")
+		return
+	}
+	post.Mentions = h.syncEntities(entityTarget{Type: models.EntityTargetGroupPost, ID: post.ID, AuthorID: userID, GroupID: post.GroupID}, post.Content)
+	post.LinkPreviews = h.syncLinkPreviews(models.EntityTargetGroupPost, post.ID, post.Content)
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Post updated successfully", map[string]interface{}{
+		"post": post,
+	})
+}
+
 // DeleteGroupPost handles deleting a group post
 func (h *Handler) DeleteGroupPost(w http.ResponseWriter, r *http.Request) {
 	// Get user ID from context
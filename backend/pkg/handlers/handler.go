@@ -3,14 +3,20 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/bernaotieno/social-network/backend/pkg/account"
+	"github.com/bernaotieno/social-network/backend/pkg/linkpreview"
+	"github.com/bernaotieno/social-network/backend/pkg/mail"
 	"github.com/bernaotieno/social-network/backend/pkg/middleware"
 	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/ratelimit"
 	"github.com/bernaotieno/social-network/backend/pkg/websocket"
 	"github.com/gorilla/mux"
 )
@@ -18,17 +24,86 @@ import (
 // MessageServiceAdapter adapts the models.MessageService to the websocket.MessageService interface
 type MessageServiceAdapter struct {
 	service *models.MessageService
+	handler *Handler
 }
 
 // Create adapts the Create method to match the websocket interface
 func (a *MessageServiceAdapter) Create(dbMessage *websocket.DBMessage) error {
-	message := &models.Message{
-		SenderID:   dbMessage.SenderID,
-		ReceiverID: dbMessage.ReceiverID,
-		GroupID:    dbMessage.GroupID,
-		Content:    dbMessage.Content,
+	// REST sends are checked by middleware; WebSocket sends get the same checks here
+	verified, err := a.handler.UserService.IsEmailVerified(dbMessage.SenderID)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return errors.New("please verify your email address first")
+	}
+	if !a.handler.allowMessage(dbMessage.SenderID) {
+		return errors.New("you are sending messages too quickly, please slow down")
+	}
+
+	message, created, err := a.handler.createMessage(&models.Message{
+		SenderID:        dbMessage.SenderID,
+		ReceiverID:      dbMessage.ReceiverID,
+		GroupID:         dbMessage.GroupID,
+		Content:         dbMessage.Content,
+		ClientMessageID: dbMessage.ClientMessageID,
+	})
+	if err != nil {
+		return err
+	}
+
+	dbMessage.ID = message.ID
+	dbMessage.CreatedAt = message.CreatedAt
+	dbMessage.Duplicate = !created
+	dbMessage.Entities = models.ContentEntities(message.Content, message.Mentions)
+	dbMessage.LinkPreviews = message.LinkPreviews
+	if message.Sender != nil {
+		dbMessage.ProfilePicture = message.Sender.ProfilePicture
+	}
+	return nil
+}
+
+// MessagesSince returns the messages a reconnecting WebSocket client missed
+func (a *MessageServiceAdapter) MessagesSince(userID, messageID string, limit int) ([]interface{}, string, error) {
+	messages, err := a.service.GetMessagesSince(userID, messageID, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	result := make([]interface{}, len(messages))
+	for i, message := range messages {
+		result[i] = message
+	}
+
+	lastMessageID := messageID
+	if len(messages) > 0 {
+		lastMessageID = messages[len(messages)-1].ID
 	}
-	return a.service.Create(message)
+	return result, lastMessageID, nil
+}
+
+// MarkRead marks a conversation read on behalf of a WebSocket client
+func (a *MessageServiceAdapter) MarkRead(userID, receiverID, groupID, upToMessageID string) error {
+	_, err := a.handler.markConversationRead(userID, receiverID, groupID, upToMessageID)
+	return err
+}
+
+// Edit edits a message on behalf of a WebSocket client
+func (a *MessageServiceAdapter) Edit(userID, messageID, content string) error {
+	_, err := a.handler.editMessage(userID, messageID, content)
+	return err
+}
+
+// Delete deletes a message for everyone on behalf of a WebSocket client
+func (a *MessageServiceAdapter) Delete(userID, messageID string) error {
+	_, err := a.handler.deleteMessage(userID, messageID)
+	return err
+}
+
+// React adds or removes a reaction on behalf of a WebSocket client
+func (a *MessageServiceAdapter) React(userID, messageID, emoji string, add bool) error {
+	_, err := a.handler.reactToMessage(userID, messageID, emoji, add)
+	return err
 }
 
 // Handler contains all the HTTP handlers for the API
@@ -41,6 +116,7 @@ type Handler struct {
 	PostService          *models.PostService
 	PostViewerService    *models.PostViewerService
 	CommentService       *models.CommentService
+	CommentLikeService   *models.CommentLikeService
 	LikeService          *models.LikeService
 	GroupService         *models.GroupService
 	GroupMemberService   *models.GroupMemberService
@@ -49,7 +125,30 @@ type Handler struct {
 	EventResponseService *models.EventResponseService
 	MessageService       *models.MessageService
 	NotificationService  *models.NotificationService
+	SearchService        *models.SearchService
+	PasswordResetService *models.PasswordResetService
+	TwoFactorService     *models.TwoFactorService
+	ModerationLogService *models.ModerationLogService
+	ReportService        *models.ReportService
+	BlockService         *models.BlockService
+	AccountService       *models.AccountService
+	DataExportService    *models.DataExportService
+	MediaService         *models.MediaService
+	RevisionService      *models.RevisionService
+	ReactionService      *models.ReactionService
+	EntityService        *models.EntityService
+	LinkPreviewService   *models.LinkPreviewService
+	RateLimits           *RateLimits
+	Mailer               mail.Mailer
+	AppURL               string              // Frontend base URL used for links in emails
+	DeletionGracePeriod  time.Duration       // How long a deleted account can still be restored
+	AccountJobs          *account.Worker     // Builds data exports and deletes accounts; may be nil
+	LinkPreviewFetcher   linkpreview.Fetcher // Fetches previews of linked pages; nil turns them off
+	LinkPreviewCacheTTL  time.Duration       // How long a fetched preview is used before the page is fetched again
+	MaxLinkPreviews      int                 // How many links in a post or message are previewed
 	Upgrader             websocket.Upgrader
+
+	linkPreviews *linkPreviewQueue // Pages waiting for RunLinkPreviewFetches
 }
 
 // NewHandler creates a new Handler
@@ -63,6 +162,7 @@ func NewHandler(db *sql.DB, hub *websocket.Hub) *Handler {
 		PostService:          models.NewPostService(db),
 		PostViewerService:    models.NewPostViewerService(db),
 		CommentService:       models.NewCommentService(db),
+		CommentLikeService:   models.NewCommentLikeService(db),
 		LikeService:          models.NewLikeService(db),
 		GroupService:         models.NewGroupService(db),
 		GroupMemberService:   models.NewGroupMemberService(db),
@@ -71,6 +171,26 @@ func NewHandler(db *sql.DB, hub *websocket.Hub) *Handler {
 		EventResponseService: models.NewEventResponseService(db),
 		MessageService:       models.NewMessageService(db),
 		NotificationService:  models.NewNotificationServiceWithHub(db, hub),
+		SearchService:        models.NewSearchService(db),
+		PasswordResetService: models.NewPasswordResetService(db),
+		TwoFactorService:     models.NewTwoFactorService(db),
+		ModerationLogService: models.NewModerationLogService(db),
+		ReportService:        models.NewReportService(db),
+		BlockService:         models.NewBlockService(db),
+		AccountService:       models.NewAccountService(db),
+		DataExportService:    models.NewDataExportService(db),
+		MediaService:         models.NewMediaService(db),
+		RevisionService:      models.NewRevisionService(db),
+		ReactionService:      models.NewReactionService(db),
+		EntityService:        models.NewEntityService(db),
+		LinkPreviewService:   models.NewLinkPreviewService(db),
+		RateLimits:           NewRateLimits(ratelimit.NewMemoryStore()),
+		Mailer:               mail.NewLogMailer("no-reply@localhost"),
+		AppURL:               "http://localhost:3000",
+		DeletionGracePeriod:  14 * 24 * time.Hour,
+		LinkPreviewCacheTTL:  24 * time.Hour,
+		MaxLinkPreviews:      3,
+		linkPreviews:         newLinkPreviewQueue(linkPreviewQueueSize),
 		Upgrader: websocket.Upgrader{
 			ReadBufferSize:  1024,
 			WriteBufferSize: 1024,
@@ -118,18 +238,16 @@ func (h *Handler) broadcastNotificationToUsers(notification interface{}) {
 		return
 	}
 
-	// Send notification only to the specific user who should receive it
-	// Check if the target user is online
-	if targetClient, exists := h.Hub.OnlineUsers[notif.UserID]; exists {
-		select {
-		case targetClient.Send <- data:
-			log.Printf("Sent notification to user %s: %s", notif.UserID, notif.Content)
-		default:
-			// Client's send buffer is full, log but don't fail
-			log.Printf("Failed to send notification to user %s: send buffer full", notif.UserID)
-		}
-	} else {
+	// Send notification only to the specific user who should receive it, on every connected device
+	if !h.Hub.IsUserOnline(notif.UserID) {
 		log.Printf("User %s is not online, notification will be delivered when they connect", notif.UserID)
+		return
+	}
+	if delivered := h.Hub.SendToUser(notif.UserID, data); delivered > 0 {
+		log.Printf("Sent notification to user %s on %d device(s): %s", notif.UserID, delivered, notif.Content)
+	} else {
+		// Client send buffers are full, log but don't fail
+		log.Printf("Failed to send notification to user %s: send buffer full", notif.UserID)
 	}
 }
 
@@ -154,6 +272,7 @@ func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("HandleWebSocket: User ID found: %s", userID)
+	sessionID, _ := middleware.GetSessionID(r)
 
 	// Fetch user information for the WebSocket client
 	user, err := h.UserService.GetByID(userID)
@@ -171,8 +290,8 @@ func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create a new client with message service adapter
-	messageAdapter := &MessageServiceAdapter{service: h.MessageService}
-	client := websocket.NewClient(h.Hub, conn, userID, userInfo, messageAdapter)
+	messageAdapter := &MessageServiceAdapter{service: h.MessageService, handler: h}
+	client := websocket.NewClient(h.Hub, conn, userID, sessionID, userInfo, messageAdapter)
 	log.Printf("HandleWebSocket: Created WebSocket client for user %s (%s)", userID, user.FullName)
 
 	// Register the client with the hub
@@ -200,9 +319,10 @@ func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
 
 	// Parse request body
 	var req struct {
-		ReceiverID string `json:"receiverId"`
-		GroupID    string `json:"groupId"`
-		Content    string `json:"content"`
+		ReceiverID      string `json:"receiverId"`
+		GroupID         string `json:"groupId"`
+		Content         string `json:"content"`
+		ClientMessageID string `json:"clientMessageId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -222,83 +342,70 @@ func (h *Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var roomID string
-	var message *models.Message
-
-	if req.ReceiverID != "" {
-		// Private message - validate follow relationship or public profile
-		if err := h.validatePrivateMessagePermission(userID, req.ReceiverID); err != nil {
-			http.Error(w, err.Error(), http.StatusForbidden)
-			return
+	message, created, err := h.createMessage(&models.Message{
+		SenderID:        userID,
+		ReceiverID:      req.ReceiverID,
+		GroupID:         req.GroupID,
+		Content:         req.Content,
+		ClientMessageID: req.ClientMessageID,
+	})
+	if err != nil {
+		if actionErr, ok := err.(*messageActionError); ok {
+			http.Error(w, actionErr.Message, actionErr.Status)
+		} else {
+			http.Error(w, "Failed to send message", http.StatusInternalServerError)
 		}
+		return
+	}
 
-		message = &models.Message{
-			SenderID:   userID,
-			ReceiverID: req.ReceiverID,
-			Content:    req.Content,
-		}
-		roomID = generateRoomID(userID, req.ReceiverID)
-	} else {
-		// Group message - validate group membership
-		isMember, err := h.GroupMemberService.IsGroupMember(req.GroupID, userID)
-		if err != nil {
-			http.Error(w, "Failed to check group membership", http.StatusInternalServerError)
-			return
-		}
-		if !isMember {
-			http.Error(w, "You must be a member of this group to send messages", http.StatusForbidden)
-			return
+	// A retried send was already broadcast the first time
+	if created {
+		roomID := "group-" + message.GroupID
+		if message.ReceiverID != "" {
+			roomID = generateRoomID(userID, message.ReceiverID)
 		}
 
-		message = &models.Message{
-			SenderID: userID,
-			GroupID:  req.GroupID,
-			Content:  req.Content,
+		// Also broadcast the message via WebSocket for real-time delivery
+		responseMsg := map[string]interface{}{
+			"roomId": roomID,
+			"message": map[string]interface{}{
+				"id":              message.ID,
+				"clientMessageId": message.ClientMessageID,
+				"content":         message.Content,
+				"sender":          userID,
+				"timestamp":       message.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+				"linkPreviews":    message.LinkPreviews,
+			},
 		}
-		roomID = "group-" + req.GroupID
-	}
 
-	// Save to database
-	if err := h.MessageService.Create(message); err != nil {
-		log.Printf("Error creating message: %v", err)
-		http.Error(w, "Failed to send message", http.StatusInternalServerError)
-		return
-	}
-
-	// Also broadcast the message via WebSocket for real-time delivery
-	responseMsg := map[string]interface{}{
-		"roomId": roomID,
-		"message": map[string]interface{}{
-			"content":   req.Content,
-			"sender":    userID,
-			"timestamp": message.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		},
-	}
-
-	// Serialize the response message
-	data, err := json.Marshal(map[string]interface{}{
-		"type":    "new_message",
-		"payload": responseMsg,
-	})
-	if err != nil {
-		log.Printf("Error marshaling WebSocket message: %v", err)
-		// Continue even if WebSocket broadcast fails
-	} else {
-		// Broadcast to the room via WebSocket
-		h.Hub.Broadcast <- &websocket.Broadcast{
-			RoomID:  roomID,
-			Message: data,
-			Sender:  nil, // No specific sender client since this is from HTTP API
+		// Serialize the response message
+		data, err := json.Marshal(map[string]interface{}{
+			"type":    "new_message",
+			"payload": responseMsg,
+		})
+		if err != nil {
+			log.Printf("Error marshaling WebSocket message: %v", err)
+			// Continue even if WebSocket broadcast fails
+		} else {
+			// Broadcast to the room via WebSocket
+			h.Hub.Broadcast <- &websocket.Broadcast{
+				RoomID:  roomID,
+				Message: data,
+				Sender:  nil, // No specific sender client since this is from HTTP API
+			}
+			log.Printf("Message broadcasted via WebSocket to room %s", roomID)
 		}
-		log.Printf("Message broadcasted via WebSocket to room %s", roomID)
 	}
 
 	// Return success response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Message sent successfully",
+		"success":      true,
+		"message":      "Message sent successfully",
+		"messageId":    message.ID,
+		"duplicate":    !created,
+		"linkPreviews": message.LinkPreviews,
 	})
 }
 
@@ -319,8 +426,15 @@ func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get messages between the two users (limit to 50 messages, no offset)
-	messages, err := h.MessageService.GetPrivateMessages(userID, otherUserID, 50, 0)
+	// Parse query parameters (50 messages per page by default)
+	page, err := parsePage(r, 50)
+	if err != nil {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+
+	// Get messages between the two users
+	messages, nextCursor, err := h.MessageService.GetPrivateMessagesPage(userID, otherUserID, page)
 	if err != nil {
 		log.Printf("Error getting messages: %v", err)
 		http.Error(w, "Failed to get messages", http.StatusInternalServerError)
@@ -330,8 +444,9 @@ func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
 	// Return messages
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":  true,
-		"messages": messages,
+		"success":    true,
+		"messages":   messages,
+		"nextCursor": nextCursor,
 	})
 }
 
@@ -376,17 +491,13 @@ func (h *Handler) getOnlineUserIDs() []string {
 		return []string{}
 	}
 
-	var userIDs []string
-	for userID := range h.Hub.OnlineUsers {
-		userIDs = append(userIDs, userID)
-	}
-	return userIDs
+	return h.Hub.OnlineUserIDs()
 }
 
 // validatePrivateMessagePermission checks if a user can send a private message to another user
 func (h *Handler) validatePrivateMessagePermission(senderID, receiverID string) error {
-	// Get receiver's profile
-	receiver, err := h.UserService.GetByID(receiverID)
+	// Get receiver's profile; users on either side of a block can't see it
+	receiver, err := h.UserService.GetProfile(receiverID, senderID)
 	if err != nil {
 		return fmt.Errorf("user not found")
 	}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"image"
+	"image/png"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/auth"
+	"github.com/bernaotieno/social-network/backend/pkg/db/sqlite"
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/storage"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/bernaotieno/social-network/backend/pkg/websocket"
+	"github.com/google/uuid"
+)
+
+// newTestHandler creates a Handler on a freshly migrated database, with
+// uploads stored and signed in a temporary directory
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	if err := sqlite.RunMigrations(dbPath, "../db/migrations/sqlite"); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+	db, err := sqlite.NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, maxImageSize, maxRequestSize := utils.UploadStorage, utils.MaxImageSize, utils.MaxUploadRequestSize
+	t.Cleanup(func() { utils.ConfigureUploads(store, maxImageSize, maxRequestSize) })
+	utils.ConfigureUploads(storage.NewLocal(filepath.Join(dir, "uploads")), maxImageSize, maxRequestSize)
+	utils.ConfigureSignedUploadURLs([]byte("test secret for signing upload urls"), time.Hour)
+	auth.Initialize([]byte("test secret for signing session cookies"), false)
+
+	hub := websocket.NewHub()
+	go hub.Run()
+	return NewHandler(db, hub)
+}
+
+// createTestUser adds a user, returning their ID and a session token
+func createTestUser(t *testing.T, h *Handler, username string, private bool) (string, string) {
+	t.Helper()
+	id := uuid.New().String()
+	_, err := h.DB.Exec(`
+		INSERT INTO users (id, username, email, full_name, first_name, last_name, bio, profile_picture, cover_photo, password, is_private, email_verified_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, '', '', '', '', '', 'not a hash', ?, ?, ?, ?)
+	`, id, username, username+"@example.com", username, private, time.Now(), time.Now(), time.Now())
+	if err != nil {
+		t.Fatalf("Failed to create user %s: %v", username, err)
+	}
+
+	session, err := h.SessionService.CreateForDevice(id, time.Hour, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to create session for %s: %v", username, err)
+	}
+	return id, session.ID
+}
+
+// mustExec runs a statement setting up a test
+func mustExec(t *testing.T, db *sql.DB, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("Failed to set up test data: %v", err)
+	}
+}
+
+// saveTestImage stores a small PNG in an upload directory, returning its path
+func saveTestImage(t *testing.T, directory string) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, image.NewNRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	path, err := utils.SaveImageData(buffer.Bytes(), directory)
+	if err != nil {
+		t.Fatalf("Failed to save image: %v", err)
+	}
+	return path
+}
+
+// withTestDB adds the database to a request's context, as the server does
+// for every request
+func withTestDB(h *Handler, r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), middleware.DBKey, h.DB))
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/linkpreview"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// failedLinkPreviewTTL is how long a page that couldn't be previewed is left
+// before it is tried again, which is sooner than a good preview is refreshed
+const failedLinkPreviewTTL = time.Hour
+
+// How many pages are fetched at once, and how many can wait to be fetched.
+// Links past a full queue are previewed the next time content with them is saved.
+const (
+	linkPreviewWorkers   = 4
+	linkPreviewQueueSize = 256
+)
+
+// linkPreviewQueue holds the pages waiting for their previews to be fetched.
+// A page is only queued once at a time, however many posts link to it.
+type linkPreviewQueue struct {
+	urls    chan string
+	mu      sync.Mutex
+	pending map[string]bool // Pages queued or being fetched
+}
+
+func newLinkPreviewQueue(size int) *linkPreviewQueue {
+	return &linkPreviewQueue{
+		urls:    make(chan string, size),
+		pending: make(map[string]bool),
+	}
+}
+
+// add queues a page unless it is already waiting or being fetched
+func (q *linkPreviewQueue) add(url string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending[url] {
+		return
+	}
+	select {
+	case q.urls <- url:
+		q.pending[url] = true
+	default:
+		log.Printf("Link preview queue is full, not fetching %s", url)
+	}
+}
+
+// done lets a page be queued again once it has been fetched
+func (q *linkPreviewQueue) done(url string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, url)
+}
+
+// RunLinkPreviewFetches fetches the queued link previews, a few pages at a
+// time, until ctx is cancelled
+func (h *Handler) RunLinkPreviewFetches(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < linkPreviewWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case url := <-h.linkPreviews.urls:
+					h.refreshLinkPreview(ctx, url)
+					h.linkPreviews.done(url)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// syncLinkPreviews records the links in content that was just created or
+// edited and returns the previews already cached for them. Pages that
+// aren't cached, or were cached too long ago, are fetched in the background
+// and show up the next time the content is read. Failures are only logged,
+// as the content itself has been saved.
+func (h *Handler) syncLinkPreviews(targetType models.EntityTargetType, targetID, content string) models.LinkPreviews {
+	var urls []string
+	if h.LinkPreviewFetcher != nil {
+		urls = linkpreview.FindURLs(content, h.MaxLinkPreviews)
+	}
+
+	previews, err := h.LinkPreviewService.SyncLinks(targetType, targetID, urls)
+	if err != nil {
+		log.Printf("Error saving links of %s %s: %v", targetType, targetID, err)
+		return nil
+	}
+
+	for _, url := range urls {
+		if _, fresh := h.cachedLinkPreview(url); !fresh {
+			h.linkPreviews.add(url)
+		}
+	}
+
+	return previews
+}
+
+// cachedLinkPreview returns the cached preview of a page, if there is one,
+// and whether it is recent enough to be used without fetching the page again
+func (h *Handler) cachedLinkPreview(url string) (*models.LinkPreview, bool) {
+	cached, err := h.LinkPreviewService.Get(url)
+	if err != nil {
+		if err.Error() != "link preview not found" {
+			log.Printf("Error getting link preview of %s: %v", url, err)
+		}
+		return nil, false
+	}
+
+	ttl := h.LinkPreviewCacheTTL
+	if cached.Error != "" {
+		ttl = min(ttl, failedLinkPreviewTTL)
+	}
+	return cached, time.Since(cached.FetchedAt) < ttl
+}
+
+// refreshLinkPreview fetches the preview of a page unless a recent one is
+// cached. The preview image is copied into the uploads, so clients never
+// load it from the linked site.
+func (h *Handler) refreshLinkPreview(ctx context.Context, url string) {
+	cached, fresh := h.cachedLinkPreview(url)
+	if fresh || h.LinkPreviewFetcher == nil {
+		return
+	}
+
+	preview := &models.LinkPreview{URL: url}
+	fetched, err := h.LinkPreviewFetcher.Fetch(ctx, url)
+	if err != nil && ctx.Err() != nil {
+		// Shutting down; the page is fetched the next time it is linked
+		return
+	}
+	switch {
+	case err != nil && cached != nil && cached.Error == "":
+		// Keep showing the preview the page had until it is back
+		preview = cached
+	case err != nil:
+		preview.Error = err.Error()
+	default:
+		preview.Title = fetched.Title
+		preview.Description = fetched.Description
+		preview.SiteName = fetched.SiteName
+		if fetched.Image != nil {
+			if preview.Image, err = utils.SavePreviewImage(fetched.Image, "link_previews"); err != nil {
+				log.Printf("Error saving preview image of %s: %v", url, err)
+			}
+		}
+	}
+
+	if err := h.LinkPreviewService.Save(preview); err != nil {
+		log.Printf("Error caching link preview of %s: %v", url, err)
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/linkpreview"
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/gorilla/mux"
+)
+
+// stubFetcher previews every page with the same title, once release is closed
+type stubFetcher struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (f *stubFetcher) Fetch(ctx context.Context, pageURL string) (*linkpreview.Preview, error) {
+	f.calls.Add(1)
+	select {
+	case <-f.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &linkpreview.Preview{URL: pageURL, Title: "Example article"}, nil
+}
+
+func TestLinkPreviewsAreFetchedInTheBackground(t *testing.T) {
+	h := newTestHandler(t)
+	fetcher := &stubFetcher{release: make(chan struct{})}
+	h.LinkPreviewFetcher = fetcher
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go h.RunLinkPreviewFetches(ctx)
+
+	_, authorToken := createTestUser(t, h, "author", false)
+	friendID, _ := createTestUser(t, h, "friend", false)
+	const link = "https://example.com/article"
+
+	createPost := func() map[string]interface{} {
+		t.Helper()
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("content", "Have a look at "+link)
+		form.Close()
+
+		req := withTestDB(h, httptest.NewRequest(http.MethodPost, "/api/posts", &body))
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+authorToken)
+		rec := httptest.NewRecorder()
+		middleware.AuthMiddleware(h.CreatePost)(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d creating a post, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+
+		var response struct {
+			Data struct {
+				Post map[string]interface{} `json:"post"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return response.Data.Post
+	}
+
+	// The page isn't cached yet, so the post is saved without waiting for it
+	first := createPost()
+	if previews, ok := first["linkPreviews"]; ok {
+		t.Errorf("Expected no link previews before the page is fetched, got %v", previews)
+	}
+	// Linking the page again while it is being fetched doesn't fetch it twice
+	createPost()
+	close(fetcher.release)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, fresh := h.cachedLinkPreview(link); fresh {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the link preview to be fetched")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Run("later read", func(t *testing.T) {
+		req := withTestDB(h, httptest.NewRequest(http.MethodGet, "/api/posts/"+first["id"].(string), nil))
+		req = mux.SetURLVars(req, map[string]string{"id": first["id"].(string)})
+		rec := httptest.NewRecorder()
+		h.GetPost(rec, req)
+
+		var response struct {
+			Data struct {
+				Post map[string]interface{} `json:"post"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		assertLinkPreviewTitle(t, response.Data.Post["linkPreviews"])
+	})
+
+	t.Run("create post", func(t *testing.T) {
+		assertLinkPreviewTitle(t, createPost()["linkPreviews"])
+	})
+
+	t.Run("send message", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]string{"receiverId": friendID, "content": "Read " + link})
+		req := withTestDB(h, httptest.NewRequest(http.MethodPost, "/api/messages", bytes.NewReader(body)))
+		req.Header.Set("Authorization", "Bearer "+authorToken)
+		rec := httptest.NewRecorder()
+		middleware.AuthMiddleware(h.SendMessage)(rec, req)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("Expected status %d sending a message, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+		}
+
+		var response map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		assertLinkPreviewTitle(t, response["linkPreviews"])
+	})
+
+	if calls := fetcher.calls.Load(); calls != 1 {
+		t.Errorf("Expected the page to be fetched once, got %d fetches", calls)
+	}
+}
+
+// assertLinkPreviewTitle checks a response's link previews hold the stub's preview
+func assertLinkPreviewTitle(t *testing.T, previews interface{}) {
+	t.Helper()
+	list, ok := previews.([]interface{})
+	if !ok || len(list) != 1 {
+		t.Fatalf("Expected one link preview, got %v", previews)
+	}
+	if title := list[0].(map[string]interface{})["title"]; title != "Example article" {
+		t.Errorf("Expected the title %q, got %v", "Example article", title)
+	}
+}
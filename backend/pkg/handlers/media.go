@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// uploadCacheMaxAge is how long clients may cache an upload fetched with
+// their session; it bounds how long a revoked viewer can still see it
+const uploadCacheMaxAge = 5 * time.Minute
+
+// ServeUpload serves an uploaded file to whoever may see what it belongs to.
+// Signed URLs handed out in API responses work without credentials; other
+// requests need a session that passes the same checks as the API.
+func (h *Handler) ServeUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		utils.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	key, err := utils.ImageKey(r.URL.Path)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "File not found")
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Has("signature") {
+		expires, ok := utils.VerifyUploadURL(key, query.Get("expires"), query.Get("signature"))
+		if !ok {
+			utils.RespondWithError(w, http.StatusForbidden, "This link is invalid or has expired")
+			return
+		}
+		utils.ServeUpload(w, r, key, min(time.Until(expires), utils.UploadURLTTL))
+		return
+	}
+
+	middleware.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := middleware.GetUserID(r)
+		allowed, err := h.canViewUpload(userID, "/uploads/"+key)
+		if err != nil {
+			log.Printf("Error checking access to upload %s: %v", key, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check access")
+			return
+		}
+		if !allowed {
+			// Don't reveal whether files the user can't see exist
+			utils.RespondWithError(w, http.StatusNotFound, "File not found")
+			return
+		}
+
+		// Responses differ by who is asking
+		w.Header().Add("Vary", "Cookie, Authorization")
+		utils.ServeUpload(w, r, key, uploadCacheMaxAge)
+	})(w, r)
+}
+
+// canViewUpload reports whether a user may see the uploaded file at imagePath
+func (h *Handler) canViewUpload(userID, imagePath string) (bool, error) {
+	owner, err := h.MediaService.GetOwner(imagePath)
+	if err != nil {
+		if err.Error() == "media not found" {
+			return false, nil
+		}
+		return false, err
+	}
+	return h.canViewMedia(owner, userID)
+}
+
+// canViewMedia applies the visibility rules of what an upload belongs to
+func (h *Handler) canViewMedia(owner *models.MediaOwner, userID string) (bool, error) {
+	if owner.UserID == userID {
+		return true, nil
+	}
+
+	// Neither side of a block sees the other's uploads
+	if owner.UserID != "" {
+		blocked, err := h.BlockService.IsBlocked(userID, owner.UserID)
+		if err != nil || blocked {
+			return false, err
+		}
+	}
+
+	switch owner.Type {
+	case models.MediaOwnerAvatar, models.MediaOwnerCover, models.MediaOwnerGroup:
+		// Profile pictures and cover photos are part of even the limited
+		// profile of a private account, and every group is listed with its cover
+		return true, nil
+
+	case models.MediaOwnerLinkPreview:
+		// Preview images are copies of what the linked page shows anyone
+		return true, nil
+
+	case models.MediaOwnerPost:
+		// Only followers see the posts of a private account, even public ones
+		if owner.UserPrivate {
+			following, err := h.FollowService.IsFollowing(userID, owner.UserID)
+			if err != nil || !following {
+				return false, err
+			}
+		}
+		switch owner.Visibility {
+		case models.PostVisibilityPublic:
+			return true, nil
+		case models.PostVisibilityFollowers:
+			return h.FollowService.IsFollowing(userID, owner.UserID)
+		case models.PostVisibilityCustom:
+			return h.PostViewerService.CanUserViewPost(owner.ID, userID)
+		default:
+			return false, nil
+		}
+
+	case models.MediaOwnerGroupPost:
+		if owner.GroupPrivacy != models.GroupPrivacyPrivate {
+			return true, nil
+		}
+		member, err := h.GroupMemberService.GetByGroupAndUser(owner.GroupID, userID)
+		if err != nil {
+			if err.Error() == "group member not found" {
+				return false, nil
+			}
+			return false, err
+		}
+		return member.Status == models.GroupMemberStatusAccepted, nil
+
+	case models.MediaOwnerComment:
+		// Comment images are seen by whoever sees the post
+		return h.canViewMedia(owner.Parent, userID)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/google/uuid"
+)
+
+func TestServeUploadVisibility(t *testing.T) {
+	h := newTestHandler(t)
+
+	authorID, authorToken := createTestUser(t, h, "author", false)
+	privateID, _ := createTestUser(t, h, "private", true)
+	_, viewerToken := createTestUser(t, h, "viewer", false)
+	followerID, followerToken := createTestUser(t, h, "follower", false)
+	memberID, memberToken := createTestUser(t, h, "member", false)
+	blockedID, blockedToken := createTestUser(t, h, "blocked", false)
+
+	for _, followingID := range []string{authorID, privateID} {
+		mustExec(t, h.DB, `INSERT INTO follows (id, follower_id, following_id, status) VALUES (?, ?, ?, 'accepted')`,
+			uuid.New().String(), followerID, followingID)
+	}
+	mustExec(t, h.DB, `INSERT INTO user_blocks (blocker_id, blocked_id) VALUES (?, ?)`, authorID, blockedID)
+
+	post := func(userID, visibility string) string {
+		id, image := uuid.New().String(), saveTestImage(t, "posts")
+		mustExec(t, h.DB, `INSERT INTO posts (id, user_id, content, image, visibility) VALUES (?, ?, 'post', ?, ?)`, id, userID, image, visibility)
+		return image
+	}
+	publicImage := post(authorID, "public")
+	followersImage := post(authorID, "followers")
+	privateImage := post(authorID, "private")
+	privateAccountImage := post(privateID, "public")
+
+	customImage := post(authorID, "custom")
+	mustExec(t, h.DB, `INSERT INTO post_viewers (id, post_id, user_id) SELECT ?, id, ? FROM posts WHERE image = ?`,
+		uuid.New().String(), memberID, customImage)
+
+	groupID, groupPostID := uuid.New().String(), uuid.New().String()
+	groupPostImage := saveTestImage(t, "group_posts")
+	mustExec(t, h.DB, `INSERT INTO groups (id, name, creator_id, privacy) VALUES (?, 'Private group', ?, 'private')`, groupID, authorID)
+	mustExec(t, h.DB, `INSERT INTO group_members (id, group_id, user_id, role, status) VALUES (?, ?, ?, 'member', 'accepted')`,
+		uuid.New().String(), groupID, memberID)
+	mustExec(t, h.DB, `INSERT INTO group_posts (id, group_id, user_id, content, image) VALUES (?, ?, ?, 'group post', ?)`,
+		groupPostID, groupID, authorID, groupPostImage)
+
+	commentImage := saveTestImage(t, "comments")
+	mustExec(t, h.DB, `INSERT INTO comments (id, post_id, user_id, content, image) VALUES (?, ?, ?, 'comment', ?)`,
+		uuid.New().String(), groupPostID, memberID, commentImage)
+
+	tests := []struct {
+		name   string
+		image  string
+		token  string
+		signed bool
+		want   int
+	}{
+		{"public post", publicImage, viewerToken, false, http.StatusOK},
+		{"followers post to a follower", followersImage, followerToken, false, http.StatusOK},
+		{"followers post to someone else", followersImage, viewerToken, false, http.StatusNotFound},
+		{"custom post to a chosen viewer", customImage, memberToken, false, http.StatusOK},
+		{"custom post to someone else", customImage, followerToken, false, http.StatusNotFound},
+		{"private post to its author", privateImage, authorToken, false, http.StatusOK},
+		{"private post to someone else", privateImage, followerToken, false, http.StatusNotFound},
+		{"private account's public post to a follower", privateAccountImage, followerToken, false, http.StatusOK},
+		{"private account's public post to someone else", privateAccountImage, viewerToken, false, http.StatusNotFound},
+		{"private group post to a member", groupPostImage, memberToken, false, http.StatusOK},
+		{"private group post to someone else", groupPostImage, viewerToken, false, http.StatusNotFound},
+		{"comment on a private group post to a member", commentImage, memberToken, false, http.StatusOK},
+		{"comment on a private group post to someone else", commentImage, viewerToken, false, http.StatusNotFound},
+		{"public post to a blocked user", publicImage, blockedToken, false, http.StatusNotFound},
+		{"unsigned URL without a session", publicImage, "", false, http.StatusUnauthorized},
+		{"signed URL without a session", privateImage, "", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tt.image
+			if tt.signed {
+				target = signedUploadURL(t, tt.image)
+			}
+			req := withTestDB(h, httptest.NewRequest(http.MethodGet, target, nil))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeUpload(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	t.Run("tampered signature", func(t *testing.T) {
+		target := signedUploadURL(t, privateImage)
+		req := withTestDB(h, httptest.NewRequest(http.MethodGet, target[:len(target)-2]+"xx", nil))
+		rec := httptest.NewRecorder()
+		h.ServeUpload(rec, req)
+
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("Expected status %d, got %d", http.StatusForbidden, rec.Code)
+		}
+	})
+}
+
+// signedUploadURL returns the signed URL an API response hands out for an upload
+func signedUploadURL(t *testing.T, imagePath string) string {
+	t.Helper()
+	target := utils.UploadURL(imagePath)
+	if !strings.Contains(target, "signature=") {
+		t.Fatalf("Expected a signed URL, got %s", target)
+	}
+	return target
+}
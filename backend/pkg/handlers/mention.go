@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+)
+
+// mentionedIn is how a mention notification describes where the user was mentioned
+var mentionedIn = map[models.EntityTargetType]string{
+	models.EntityTargetPost:      "mentioned you in a post",
+	models.EntityTargetGroupPost: "mentioned you in a group post",
+	models.EntityTargetComment:   "mentioned you in a comment",
+	models.EntityTargetMessage:   "mentioned you in a message",
+}
+
+// entityTarget is a post, group post, comment or message whose mentions and
+// hashtags are being indexed, with what decides who can see it
+type entityTarget struct {
+	Type     models.EntityTargetType
+	ID       string
+	AuthorID string
+	// The post a comment is on
+	PostID string
+	// The group a group post, a comment on one or a group message is in
+	GroupID string
+	// The recipient of a direct message
+	ReceiverID string
+}
+
+// messageEntityTarget is the entityTarget for a direct or group message
+func messageEntityTarget(message *models.Message) entityTarget {
+	return entityTarget{
+		Type:       models.EntityTargetMessage,
+		ID:         message.ID,
+		AuthorID:   message.SenderID,
+		GroupID:    message.GroupID,
+		ReceiverID: message.ReceiverID,
+	}
+}
+
+// syncEntities indexes the mentions and hashtags in content that was just
+// created or edited, and notifies the users mentioned in it who can see it
+// and haven't been told yet. It returns the mentions for the response.
+// Failures are only logged, as the content itself has been saved.
+func (h *Handler) syncEntities(target entityTarget, content string) models.Mentions {
+	mentions := models.Mentions{}
+	for _, username := range models.ParseMentions(content) {
+		user, err := h.UserService.GetByUsername(username)
+		if err != nil {
+			if err.Error() != "user not found" {
+				log.Printf("Error resolving mention of %s: %v", username, err)
+			}
+			continue
+		}
+		mentions[username] = user.ID
+	}
+
+	unnotified, err := h.EntityService.Sync(target.Type, target.ID, content, mentions)
+	if err != nil {
+		log.Printf("Error indexing mentions and hashtags of %s %s: %v", target.Type, target.ID, err)
+		return mentions
+	}
+
+	for _, userID := range unnotified {
+		if userID == target.AuthorID {
+			continue
+		}
+
+		// Users who can't see the content aren't told about it, though they
+		// are once an edit lets them see it
+		canSee, err := h.canSeeEntityTarget(target, userID)
+		if err != nil {
+			log.Printf("Error checking whether %s can see %s %s: %v", userID, target.Type, target.ID, err)
+			continue
+		}
+		if !canSee {
+			continue
+		}
+
+		if err := h.EntityService.MarkNotified(target.Type, target.ID, userID); err != nil {
+			log.Printf("Error marking mention as notified: %v", err)
+			continue
+		}
+		h.notifyMention(target, userID)
+	}
+
+	return mentions
+}
+
+// canSeeEntityTarget reports whether a user can see a post, group post,
+// comment or message
+func (h *Handler) canSeeEntityTarget(target entityTarget, userID string) (bool, error) {
+	blocked, err := h.BlockService.IsBlocked(target.AuthorID, userID)
+	if err != nil {
+		return false, err
+	}
+	if blocked {
+		return false, nil
+	}
+
+	switch target.Type {
+	case models.EntityTargetPost:
+		_, err = h.PostService.GetByID(target.ID, userID)
+	case models.EntityTargetGroupPost:
+		_, err = h.GroupPostService.GetByID(target.ID, userID)
+	case models.EntityTargetComment:
+		// Comments are visible wherever the post they are on is
+		if target.GroupID != "" {
+			_, err = h.GroupPostService.GetByID(target.PostID, userID)
+		} else {
+			_, err = h.PostService.GetByID(target.PostID, userID)
+		}
+	case models.EntityTargetMessage:
+		if target.ReceiverID != "" {
+			return userID == target.ReceiverID, nil
+		}
+		return h.GroupMemberService.IsGroupMember(target.GroupID, userID)
+	}
+	if err != nil {
+		switch err.Error() {
+		case "post not found", "group post not found", "not authorized to view this post":
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// notifyMention tells a user they were mentioned
+func (h *Handler) notifyMention(target entityTarget, userID string) {
+	notificationData := map[string]interface{}{
+		"targetType": target.Type,
+	}
+	switch target.Type {
+	case models.EntityTargetPost, models.EntityTargetGroupPost:
+		notificationData["postId"] = target.ID
+	case models.EntityTargetComment:
+		notificationData["postId"] = target.PostID
+		notificationData["commentId"] = target.ID
+	case models.EntityTargetMessage:
+		notificationData["messageId"] = target.ID
+	}
+	if target.GroupID != "" {
+		notificationData["groupId"] = target.GroupID
+	}
+	dataJSON, _ := json.Marshal(notificationData)
+
+	notification := &models.Notification{
+		UserID:   userID,
+		SenderID: target.AuthorID,
+		Type:     models.NotificationTypeMention,
+		Content:  mentionedIn[target.Type],
+		Data:     string(dataJSON),
+	}
+
+	if err := h.NotificationService.Create(notification); err != nil {
+		// Log error but don't fail the request
+		log.Printf("Error creating notification: %v", err)
+	}
+}
@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/bernaotieno/social-network/backend/pkg/websocket"
+	"github.com/gorilla/mux"
+)
+
+// messageActionError is returned by the shared message actions so that REST
+// handlers can pick a status code and WebSocket clients get a readable reason
+type messageActionError struct {
+	Status  int
+	Message string
+}
+
+func (e *messageActionError) Error() string {
+	return e.Message
+}
+
+// EditMessage handles editing the content of a message
+func (h *Handler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get message ID from URL
+	vars := mux.Vars(r)
+	messageID := vars["id"]
+
+	// Parse request body
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	message, err := h.editMessage(userID, messageID, req.Content)
+	if err != nil {
+		respondWithMessageActionError(w, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Message updated successfully", map[string]interface{}{
+		"message": message,
+	})
+}
+
+// DeleteMessage handles deleting a message for everyone in the conversation
+func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get message ID from URL
+	vars := mux.Vars(r)
+	messageID := vars["id"]
+
+	message, err := h.deleteMessage(userID, messageID)
+	if err != nil {
+		respondWithMessageActionError(w, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Message deleted successfully", map[string]interface{}{
+		"message": message,
+	})
+}
+
+// AddMessageReaction handles adding an emoji reaction to a message
+func (h *Handler) AddMessageReaction(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get message ID from URL
+	vars := mux.Vars(r)
+	messageID := vars["id"]
+
+	// Parse request body
+	var req struct {
+		Emoji string `json:"emoji"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	reactions, err := h.reactToMessage(userID, messageID, req.Emoji, true)
+	if err != nil {
+		respondWithMessageActionError(w, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Reaction added successfully", map[string]interface{}{
+		"reactions": reactions,
+	})
+}
+
+// RemoveMessageReaction handles removing the current user's emoji reaction from a message
+func (h *Handler) RemoveMessageReaction(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get message ID from URL and emoji from query
+	vars := mux.Vars(r)
+	messageID := vars["id"]
+	emoji := r.URL.Query().Get("emoji")
+
+	reactions, err := h.reactToMessage(userID, messageID, emoji, false)
+	if err != nil {
+		respondWithMessageActionError(w, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Reaction removed successfully", map[string]interface{}{
+		"reactions": reactions,
+	})
+}
+
+// GetConversations handles listing the current user's direct conversations and group chats
+func (h *Handler) GetConversations(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse query parameters
+	limit := 20
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err == nil && parsedLimit > 0 && parsedLimit <= 100 {
+			limit = parsedLimit
+		}
+	}
+
+	var cursor *models.Cursor
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		cursor, err = models.DecodeCursor(cursorStr)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+	}
+
+	conversations, nextCursor, err := h.MessageService.GetConversationList(userID, cursor, limit)
+	if err != nil {
+		log.Printf("Error getting conversations for user %s: %v", userID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get conversations")
+		return
+	}
+
+	// Online status comes from the hub rather than the database
+	for _, conversation := range conversations {
+		if conversation.Type == models.ConversationTypeDirect && h.Hub != nil {
+			conversation.IsOnline = h.Hub.IsUserOnline(conversation.ID)
+		}
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, "Conversations retrieved successfully", map[string]interface{}{
+		"conversations": conversations,
+	}, nextCursor)
+}
+
+// GetUnreadCounts handles getting the current user's unread message counts per conversation
+func (h *Handler) GetUnreadCounts(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	counts, err := h.MessageService.GetUnreadCounts(userID)
+	if err != nil {
+		log.Printf("Error getting unread counts for user %s: %v", userID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get unread counts")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Unread counts retrieved successfully", counts)
+}
+
+// MarkConversationRead handles marking a direct conversation or group chat as read up to a message
+func (h *Handler) MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body; without upToMessageId the whole conversation is marked read
+	var req struct {
+		UserID        string `json:"userId"`
+		GroupID       string `json:"groupId"`
+		UpToMessageID string `json:"upToMessageId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	receipt, err := h.markConversationRead(userID, req.UserID, req.GroupID, req.UpToMessageID)
+	if err != nil {
+		respondWithMessageActionError(w, err)
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Conversation marked as read", map[string]interface{}{
+		"receipt": receipt,
+	})
+}
+
+// GetGroupReadReceipts handles getting how far each member has read a group chat
+func (h *Handler) GetGroupReadReceipts(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get group ID from URL
+	vars := mux.Vars(r)
+	groupID := vars["id"]
+
+	// Check if user is a member of the group
+	isMember, err := h.GroupMemberService.IsGroupMember(groupID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check group membership")
+		return
+	}
+
+	if !isMember {
+		utils.RespondWithError(w, http.StatusForbidden, "Not a member of this group")
+		return
+	}
+
+	cursors, err := h.MessageService.GetGroupReadCursors(groupID)
+	if err != nil {
+		log.Printf("Error getting read cursors for group %s: %v", groupID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get read receipts")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Read receipts retrieved successfully", map[string]interface{}{
+		"receipts": cursors,
+	})
+}
+
+// markConversationRead marks a conversation read for readerID and broadcasts a
+// read_receipt to its room. Exactly one of otherUserID and groupID must be set.
+func (h *Handler) markConversationRead(readerID, otherUserID, groupID, upToMessageID string) (*models.ReadReceipt, error) {
+	var receipt *models.ReadReceipt
+	var roomID string
+	var err error
+
+	if otherUserID != "" && groupID == "" {
+		receipt, err = h.MessageService.MarkDirectReadUpTo(readerID, otherUserID, upToMessageID)
+		roomID = generateRoomID(readerID, otherUserID)
+	} else if groupID != "" && otherUserID == "" {
+		isMember, memberErr := h.GroupMemberService.IsGroupMember(groupID, readerID)
+		if memberErr != nil {
+			return nil, &messageActionError{http.StatusInternalServerError, "Failed to check group membership"}
+		}
+		if !isMember {
+			return nil, &messageActionError{http.StatusForbidden, "Not a member of this group"}
+		}
+		receipt, err = h.MessageService.MarkGroupReadUpTo(readerID, groupID, upToMessageID)
+		roomID = "group-" + groupID
+	} else {
+		return nil, &messageActionError{http.StatusBadRequest, "Either userId or groupId must be set, but not both"}
+	}
+
+	if err != nil {
+		if err.Error() == "message not found" {
+			return nil, &messageActionError{http.StatusNotFound, "Message not found"}
+		}
+		log.Printf("Error marking conversation read for user %s: %v", readerID, err)
+		return nil, &messageActionError{http.StatusInternalServerError, "Failed to mark conversation as read"}
+	}
+
+	if h.Hub != nil {
+		data, err := json.Marshal(map[string]interface{}{
+			"type": "read_receipt",
+			"payload": map[string]interface{}{
+				"roomId":        roomID,
+				"readerId":      receipt.ReaderID,
+				"userId":        receipt.UserID,
+				"groupId":       receipt.GroupID,
+				"upToMessageId": receipt.UpToMessageID,
+				"readAt":        receipt.ReadAt,
+			},
+		})
+		if err != nil {
+			log.Printf("Error marshaling read receipt: %v", err)
+		} else {
+			h.Hub.Broadcast <- &websocket.Broadcast{
+				RoomID:  roomID,
+				Message: data,
+				Sender:  nil, // The reader's other devices clear their badges too
+			}
+		}
+	}
+
+	return receipt, nil
+}
+
+// createMessage checks that the sender may post to the conversation and stores the
+// message. created is false when the message is a retry of one already stored
+// under the same client message ID, which is then returned instead.
+func (h *Handler) createMessage(message *models.Message) (*models.Message, bool, error) {
+	if strings.TrimSpace(message.Content) == "" {
+		return nil, false, &messageActionError{http.StatusBadRequest, "Content is required"}
+	}
+
+	if message.ReceiverID != "" && message.GroupID == "" {
+		// Private message - validate follow relationship or public profile
+		if err := h.validatePrivateMessagePermission(message.SenderID, message.ReceiverID); err != nil {
+			return nil, false, &messageActionError{http.StatusForbidden, err.Error()}
+		}
+	} else if message.GroupID != "" && message.ReceiverID == "" {
+		// Group message - validate group membership
+		isMember, err := h.GroupMemberService.IsGroupMember(message.GroupID, message.SenderID)
+		if err != nil {
+			return nil, false, &messageActionError{http.StatusInternalServerError, "Failed to check group membership"}
+		}
+		if !isMember {
+			return nil, false, &messageActionError{http.StatusForbidden, "You must be a member of this group to send messages"}
+		}
+	} else {
+		return nil, false, &messageActionError{http.StatusBadRequest, "Either receiverId or groupId must be set, but not both"}
+	}
+
+	created, err := h.MessageService.CreateOnce(message)
+	if err != nil {
+		log.Printf("Error creating message: %v", err)
+		return nil, false, &messageActionError{http.StatusInternalServerError, "Failed to send message"}
+	}
+	if created {
+		message.Mentions = h.syncEntities(messageEntityTarget(message), message.Content)
+		message.LinkPreviews = h.syncLinkPreviews(models.EntityTargetMessage, message.ID, message.Content)
+	}
+
+	return message, created, nil
+}
+
+// editMessage updates a message sent by userID and broadcasts the change to its room
+func (h *Handler) editMessage(userID, messageID, content string) (*models.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, &messageActionError{http.StatusBadRequest, "Content is required"}
+	}
+
+	message, err := h.getAccessibleMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID != userID {
+		return nil, &messageActionError{http.StatusForbidden, "You can only edit your own messages"}
+	}
+	if message.IsDeleted {
+		return nil, &messageActionError{http.StatusConflict, "Cannot edit a deleted message"}
+	}
+
+	message, err = h.MessageService.Update(messageID, userID, content)
+	if err != nil {
+		log.Printf("Error updating message %s: %v", messageID, err)
+		return nil, &messageActionError{http.StatusInternalServerError, "Failed to update message"}
+	}
+	message.Mentions = h.syncEntities(messageEntityTarget(message), message.Content)
+	message.LinkPreviews = h.syncLinkPreviews(models.EntityTargetMessage, message.ID, message.Content)
+
+	h.broadcastMessageEvent(message, "message_edited", map[string]interface{}{
+		"messageId":    message.ID,
+		"content":      message.Content,
+		"editedAt":     message.EditedAt,
+		"linkPreviews": message.LinkPreviews,
+	})
+
+	return message, nil
+}
+
+// deleteMessage tombstones a message sent by userID and broadcasts the change to its room
+func (h *Handler) deleteMessage(userID, messageID string) (*models.Message, error) {
+	message, err := h.getAccessibleMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.SenderID != userID {
+		return nil, &messageActionError{http.StatusForbidden, "You can only delete your own messages"}
+	}
+	if message.IsDeleted {
+		return nil, &messageActionError{http.StatusConflict, "Message is already deleted"}
+	}
+
+	message, err = h.MessageService.Delete(messageID, userID)
+	if err != nil {
+		log.Printf("Error deleting message %s: %v", messageID, err)
+		return nil, &messageActionError{http.StatusInternalServerError, "Failed to delete message"}
+	}
+
+	h.broadcastMessageEvent(message, "message_deleted", map[string]interface{}{
+		"messageId": message.ID,
+		"deletedAt": message.DeletedAt,
+	})
+
+	return message, nil
+}
+
+// reactToMessage adds or removes a reaction and broadcasts the updated reactions to the message's room
+func (h *Handler) reactToMessage(userID, messageID, emoji string, add bool) ([]*models.MessageReactionSummary, error) {
+	if emoji == "" {
+		return nil, &messageActionError{http.StatusBadRequest, "Emoji is required"}
+	}
+
+	message, err := h.getAccessibleMessage(userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.IsDeleted {
+		return nil, &messageActionError{http.StatusConflict, "Cannot react to a deleted message"}
+	}
+
+	action := "remove"
+	if add {
+		action = "add"
+		if err := h.MessageService.AddReaction(messageID, userID, emoji); err != nil {
+			if strings.HasPrefix(err.Error(), "invalid reaction") || strings.HasPrefix(err.Error(), "reaction is too long") {
+				return nil, &messageActionError{http.StatusBadRequest, "Invalid reaction"}
+			}
+			log.Printf("Error adding reaction to message %s: %v", messageID, err)
+			return nil, &messageActionError{http.StatusInternalServerError, "Failed to add reaction"}
+		}
+	} else if err := h.MessageService.RemoveReaction(messageID, userID, emoji); err != nil {
+		if err.Error() == "reaction not found" {
+			return nil, &messageActionError{http.StatusNotFound, "Reaction not found"}
+		}
+		log.Printf("Error removing reaction from message %s: %v", messageID, err)
+		return nil, &messageActionError{http.StatusInternalServerError, "Failed to remove reaction"}
+	}
+
+	reactions, err := h.MessageService.GetReactions(messageID)
+	if err != nil {
+		log.Printf("Error getting reactions for message %s: %v", messageID, err)
+		return nil, &messageActionError{http.StatusInternalServerError, "Failed to get reactions"}
+	}
+
+	h.broadcastMessageEvent(message, "message_reaction", map[string]interface{}{
+		"messageId": message.ID,
+		"userId":    userID,
+		"emoji":     emoji,
+		"action":    action,
+		"reactions": reactions,
+	})
+
+	return reactions, nil
+}
+
+// getAccessibleMessage loads a message the user is allowed to see: either a
+// participant of the direct conversation or a member of the group
+func (h *Handler) getAccessibleMessage(userID, messageID string) (*models.Message, error) {
+	message, err := h.MessageService.GetByID(messageID)
+	if err != nil {
+		if err.Error() == "message not found" {
+			return nil, &messageActionError{http.StatusNotFound, "Message not found"}
+		}
+		log.Printf("Error getting message %s: %v", messageID, err)
+		return nil, &messageActionError{http.StatusInternalServerError, "Failed to get message"}
+	}
+
+	if message.GroupID != "" {
+		isMember, err := h.GroupMemberService.IsGroupMember(message.GroupID, userID)
+		if err != nil {
+			return nil, &messageActionError{http.StatusInternalServerError, "Failed to check group membership"}
+		}
+		if !isMember {
+			return nil, &messageActionError{http.StatusForbidden, "Not a member of this group"}
+		}
+	} else if message.SenderID != userID && message.ReceiverID != userID {
+		// Don't reveal that the message exists
+		return nil, &messageActionError{http.StatusNotFound, "Message not found"}
+	}
+
+	return message, nil
+}
+
+// broadcastMessageEvent sends a message update to the chat room the message belongs to
+func (h *Handler) broadcastMessageEvent(message *models.Message, eventType string, payload map[string]interface{}) {
+	if h.Hub == nil {
+		return
+	}
+
+	var roomID string
+	if message.GroupID != "" {
+		roomID = "group-" + message.GroupID
+	} else {
+		roomID = generateRoomID(message.SenderID, message.ReceiverID)
+	}
+	payload["roomId"] = roomID
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":    eventType,
+		"payload": payload,
+	})
+	if err != nil {
+		log.Printf("Error marshaling %s event: %v", eventType, err)
+		return
+	}
+
+	h.Hub.Broadcast <- &websocket.Broadcast{
+		RoomID:  roomID,
+		Message: data,
+		Sender:  nil, // Echo to the acting client too so all of its devices stay in sync
+	}
+}
+
+// respondWithMessageActionError writes the error from a shared message action
+func respondWithMessageActionError(w http.ResponseWriter, err error) {
+	if actionErr, ok := err.(*messageActionError); ok {
+		utils.RespondWithError(w, actionErr.Status, actionErr.Message)
+		return
+	}
+	utils.RespondWithError(w, http.StatusInternalServerError, err.Error())
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"net/http"
-	"strconv"
 
 	"github.com/bernaotieno/social-network/backend/pkg/middleware"
 	"github.com/bernaotieno/social-network/backend/pkg/utils"
@@ -19,30 +18,14 @@ func (h *Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	// Set default values
-	limit := 20
-	offset := 0
-
-	// Parse limit and offset
-	if limitStr != "" {
-		parsedLimit, err := strconv.Atoi(limitStr)
-		if err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	if offsetStr != "" {
-		parsedOffset, err := strconv.Atoi(offsetStr)
-		if err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	page, err := parsePage(r, 20)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
 	}
 
 	// Get notifications
-	notifications, err := h.NotificationService.GetByUser(userID, limit, offset)
+	notifications, nextCursor, err := h.NotificationService.GetByUserPage(userID, page)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get notifications")
 		return
@@ -55,10 +38,10 @@ func (h *Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, "Notifications retrieved successfully", map[string]interface{}{
+	utils.RespondWithPage(w, http.StatusOK, "Notifications retrieved successfully", map[string]interface{}{
 		"notifications": notifications,
 		"unreadCount":   unreadCount,
-	})
+	}, nextCursor)
 }
 
 // MarkNotificationAsRead handles marking a notification as read
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+)
+
+// maxPageLimit caps the number of items a client can ask for in one page
+const maxPageLimit = 100
+
+// parsePage reads the limit, offset and cursor query parameters. Offset is
+// kept for older clients; a cursor from a previous response's nextCursor
+// takes precedence over it.
+func parsePage(r *http.Request, defaultLimit int) (models.Page, error) {
+	page := models.Page{Limit: defaultLimit}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err == nil && parsedLimit > 0 {
+			page.Limit = parsedLimit
+		}
+	}
+	if page.Limit > maxPageLimit {
+		page.Limit = maxPageLimit
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err == nil && parsedOffset >= 0 {
+			page.Offset = parsedOffset
+		}
+	}
+
+	if cursorStr := r.URL.Query().Get("cursor"); cursorStr != "" {
+		cursor, err := models.DecodeCursor(cursorStr)
+		if err != nil {
+			return page, errors.New("invalid cursor")
+		}
+		page.Cursor = cursor
+	}
+
+	return page, nil
+}
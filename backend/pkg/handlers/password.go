@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/auth"
+	"github.com/bernaotieno/social-network/backend/pkg/mail"
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// minPasswordLength is the shortest password accepted for an account
+const minPasswordLength = 6
+
+// passwordResetTokenTTL is how long a password reset link stays valid
+const passwordResetTokenTTL = time.Hour
+
+// ChangePasswordRequest represents a request to change the current user's password
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// ForgotPasswordRequest represents a request for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest represents a request to set a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ChangePassword handles a signed-in user changing their password. Every other
+// session of the user is signed out.
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	// Get user ID and session ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentSessionID, _ := middleware.GetSessionID(r)
+
+	// Parse request body
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Current password and new password are required")
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
+		return
+	}
+
+	user, err := h.UserService.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	// Guessing the current password counts towards the same lockout as login
+	ip := auth.ClientIP(r)
+	if wait := h.loginWait(user.Email, ip); wait > 0 {
+		utils.RespondWithRateLimit(w, wait, "Too many failed attempts, please try again later")
+		return
+	}
+	if !h.UserService.CheckPassword(user, req.CurrentPassword) {
+		h.recordLoginFailure(user.Email, ip)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Current password is incorrect")
+		return
+	}
+
+	if err := h.UserService.UpdatePassword(userID, req.NewPassword); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update password")
+		return
+	}
+
+	// Sign out every other device; this one stays signed in
+	revoked, err := auth.RevokeUserSessions(h.DB, userID, currentSessionID, auth.PasswordChangedReason, h.Hub)
+	if err != nil {
+		log.Printf("Failed to revoke sessions after password change for user %s: %v", userID, err)
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Password changed successfully", map[string]interface{}{
+		"revokedCount": revoked,
+	})
+}
+
+// ForgotPassword handles a request to email a password reset link. The response
+// is the same whether or not the email belongs to an account.
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if strings.TrimSpace(req.Email) == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Email is required")
+		return
+	}
+
+	const message = "If an account exists for that email, a password reset link has been sent"
+
+	// Failures are only logged, as a different response for known emails
+	// would tell which are registered
+	user, err := h.UserService.GetByEmail(strings.TrimSpace(req.Email))
+	if err != nil {
+		log.Printf("Password reset requested for an unknown email")
+		utils.RespondWithSuccess(w, http.StatusOK, message, nil)
+		return
+	}
+
+	token, err := h.PasswordResetService.Create(user.ID, passwordResetTokenTTL)
+	if err != nil {
+		log.Printf("Failed to create password reset token for user %s: %v", user.ID, err)
+		utils.RespondWithSuccess(w, http.StatusOK, message, nil)
+		return
+	}
+
+	resetURL := strings.TrimRight(h.AppURL, "/") + "/reset-password?token=" + url.QueryEscape(token)
+	if err := h.Mailer.Send(&mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you did not ask to reset your password, you can ignore this email.\n",
+			user.FirstName, passwordResetTokenTTL, resetURL),
+	}); err != nil {
+		log.Printf("Failed to send password reset email to user %s: %v", user.ID, err)
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, message, nil)
+}
+
+// ResetPassword handles setting a new password with a reset token. Every
+// session of the user is signed out.
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" || req.Password == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Token and password are required")
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Password must be at least %d characters", minPasswordLength))
+		return
+	}
+
+	userID, err := h.PasswordResetService.Consume(req.Token, req.Password)
+	if err != nil {
+		if err.Error() == "invalid or expired reset token" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to reset password")
+		}
+		return
+	}
+
+	// Whoever held the old password is signed out everywhere
+	if _, err := auth.RevokeUserSessions(h.DB, userID, "", auth.PasswordChangedReason, h.Hub); err != nil {
+		log.Printf("Failed to revoke sessions after password reset for user %s: %v", userID, err)
+	}
+
+	// The owner has proven control of the account, so lift any login lockout
+	if user, err := h.UserService.GetByID(userID); err != nil {
+		log.Printf("Failed to get user %s to reset login failures: %v", userID, err)
+	} else if err := h.RateLimits.AccountLockout.Reset(accountLockoutKey(user.Email)); err != nil {
+		log.Printf("Failed to reset login failures for user %s: %v", userID, err)
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Password reset successfully", nil)
+}
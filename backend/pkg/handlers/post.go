@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
-	"strconv"
 
 	"github.com/bernaotieno/social-network/backend/pkg/middleware"
 	"github.com/bernaotieno/social-network/backend/pkg/models"
@@ -36,7 +35,7 @@ func (h *Handler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse multipart form
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
@@ -73,7 +72,7 @@ func (h *Handler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		// Save image
 		imagePath, err := utils.SaveImage(file, header, "posts")
 		if err != nil {
-			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			utils.RespondWithImageError(w, err)
 			return
 		}
 
@@ -85,6 +84,8 @@ func (h *Handler) CreatePost(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create post")
 		return
 	}
+	post.Mentions = h.syncEntities(entityTarget{Type: models.EntityTargetPost, ID: post.ID, AuthorID: userID}, post.Content)
+	post.LinkPreviews = h.syncLinkPreviews(models.EntityTargetPost, post.ID, post.Content)
 
 	// Get user for response
 	user, err := h.UserService.GetByID(userID)
@@ -221,7 +222,7 @@ func (h *Handler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	postID := vars["id"]
 
 	// Parse multipart form
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
@@ -266,6 +267,8 @@ func (h *Handler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update post")
 		return
 	}
+	post.Mentions = h.syncEntities(entityTarget{Type: models.EntityTargetPost, ID: post.ID, AuthorID: userID}, post.Content)
+	post.LinkPreviews = h.syncLinkPreviews(models.EntityTargetPost, post.ID, post.Content)
 
 	// Get user for response
 	user, err := h.UserService.GetByID(userID)
@@ -293,38 +296,26 @@ func (h *Handler) GetUserPosts(w http.ResponseWriter, r *http.Request) {
 	currentUserID, _ := middleware.GetUserID(r)
 
 	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	// Set default values
-	limit := 20
-	offset := 0
-
-	// Parse limit and offset
-	if limitStr != "" {
-		parsedLimit, err := strconv.Atoi(limitStr)
-		if err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	if offsetStr != "" {
-		parsedOffset, err := strconv.Atoi(offsetStr)
-		if err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	page, err := parsePage(r, 20)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
 	}
 
 	// Get posts
-	posts, err := h.PostService.GetUserPosts(userID, currentUserID, limit, offset)
+	posts, nextCursor, err := h.PostService.GetUserPostsPage(userID, currentUserID, page)
 	if err != nil {
+		if err.Error() == "user not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get posts")
 		return
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, "Posts retrieved successfully", map[string]interface{}{
+	utils.RespondWithPage(w, http.StatusOK, "Posts retrieved successfully", map[string]interface{}{
 		"posts": posts,
-	})
+	}, nextCursor)
 }
 
 // GetFeed handles retrieving posts for a user's feed
@@ -337,38 +328,22 @@ func (h *Handler) GetFeed(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse query parameters
-	limitStr := r.URL.Query().Get("limit")
-	offsetStr := r.URL.Query().Get("offset")
-
-	// Set default values
-	limit := 20
-	offset := 0
-
-	// Parse limit and offset
-	if limitStr != "" {
-		parsedLimit, err := strconv.Atoi(limitStr)
-		if err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	if offsetStr != "" {
-		parsedOffset, err := strconv.Atoi(offsetStr)
-		if err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	page, err := parsePage(r, 20)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
 	}
 
 	// Get feed
-	posts, err := h.PostService.GetFeed(userID, limit, offset)
+	posts, nextCursor, err := h.PostService.GetFeedPage(userID, page)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get feed")
 		return
 	}
 
-	utils.RespondWithSuccess(w, http.StatusOK, "Feed retrieved successfully", map[string]interface{}{
+	utils.RespondWithPage(w, http.StatusOK, "Feed retrieved successfully", map[string]interface{}{
 		"posts": posts,
-	})
+	}, nextCursor)
 }
 
 // LikePost handles liking a post
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/ratelimit"
+)
+
+// RateLimits holds the limiters guarding authentication and content creation
+type RateLimits struct {
+	Login             *ratelimit.Limiter // Login requests per client IP
+	Register          *ratelimit.Limiter // Registrations per client IP
+	Post              *ratelimit.Limiter // Posts per user
+	Comment           *ratelimit.Limiter // Comments per user
+	Message           *ratelimit.Limiter // Chat messages per user
+	PasswordReset     *ratelimit.Limiter // Password reset requests per client IP
+	VerificationEmail *ratelimit.Limiter // Verification email resends per user
+	Report            *ratelimit.Limiter // Reports filed per user
+	DataExport        *ratelimit.Limiter // Data exports requested per user
+	AccountLockout    *ratelimit.Lockout // Failed logins per account
+	IPLockout         *ratelimit.Lockout // Failed logins per client IP
+}
+
+// NewRateLimits creates the default rate limits, keeping their state in store
+func NewRateLimits(store ratelimit.Store) *RateLimits {
+	return &RateLimits{
+		Login:             ratelimit.NewLimiter(store, "login", 20, time.Minute),
+		Register:          ratelimit.NewLimiter(store, "register", 10, time.Hour),
+		Post:              ratelimit.NewLimiter(store, "post", 10, time.Minute),
+		Comment:           ratelimit.NewLimiter(store, "comment", 30, time.Minute),
+		Message:           ratelimit.NewLimiter(store, "message", 60, time.Minute),
+		PasswordReset:     ratelimit.NewLimiter(store, "password-reset", 10, time.Hour),
+		VerificationEmail: ratelimit.NewLimiter(store, "verification-email", 5, time.Hour),
+		Report:            ratelimit.NewLimiter(store, "report", 20, time.Hour),
+		DataExport:        ratelimit.NewLimiter(store, "data-export", 3, 24*time.Hour),
+		AccountLockout: ratelimit.NewLockout(store, "login-account", ratelimit.LockoutPolicy{
+			FreeAttempts:    3,
+			MaxAttempts:     10,
+			BaseDelay:       time.Second,
+			MaxDelay:        30 * time.Second,
+			LockoutDuration: 15 * time.Minute,
+			ResetAfter:      time.Hour,
+		}),
+		// An IP may front many honest users, so it gets more room than one account
+		IPLockout: ratelimit.NewLockout(store, "login-ip", ratelimit.LockoutPolicy{
+			FreeAttempts:    10,
+			MaxAttempts:     50,
+			BaseDelay:       time.Second,
+			MaxDelay:        30 * time.Second,
+			LockoutDuration: 15 * time.Minute,
+			ResetAfter:      time.Hour,
+		}),
+	}
+}
+
+// accountLockoutKey returns the lockout key for the account with the given email
+func accountLockoutKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginWait returns how long a login for email from ip must wait, or zero if it may proceed
+func (h *Handler) loginWait(email, ip string) time.Duration {
+	var wait time.Duration
+	if accountWait, err := h.RateLimits.AccountLockout.Check(accountLockoutKey(email)); err != nil {
+		log.Printf("Failed to check account lockout: %v", err)
+	} else if accountWait > wait {
+		wait = accountWait
+	}
+	if ipWait, err := h.RateLimits.IPLockout.Check(ip); err != nil {
+		log.Printf("Failed to check IP lockout: %v", err)
+	} else if ipWait > wait {
+		wait = ipWait
+	}
+	return wait
+}
+
+// recordLoginFailure counts a failed login against both the account and the IP
+func (h *Handler) recordLoginFailure(email, ip string) {
+	if _, locked, err := h.RateLimits.AccountLockout.Fail(accountLockoutKey(email)); err != nil {
+		log.Printf("Failed to record failed login for account: %v", err)
+	} else if locked {
+		log.Printf("Account %s locked after repeated failed logins", email)
+	}
+	if _, locked, err := h.RateLimits.IPLockout.Fail(ip); err != nil {
+		log.Printf("Failed to record failed login for IP: %v", err)
+	} else if locked {
+		log.Printf("IP %s locked after repeated failed logins", ip)
+	}
+}
+
+// allowMessage reports whether a user may send another chat message right now
+func (h *Handler) allowMessage(userID string) bool {
+	allowed, _, err := h.RateLimits.Message.Allow(middleware.UserKeyFor(userID))
+	if err != nil {
+		log.Printf("Rate limit check failed: %v", err)
+		return true
+	}
+	return allowed
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/bernaotieno/social-network/backend/pkg/websocket"
+	"github.com/gorilla/mux"
+)
+
+// ReactRequest represents a request to react to a post or comment
+type ReactRequest struct {
+	Reaction string `json:"reaction"`
+}
+
+// reactionTarget is the post, group post or comment a reaction request is about
+type reactionTarget struct {
+	Type      models.ReactionTargetType
+	ID        string
+	AuthorID  string
+	Content   string
+	PostID    string
+	GroupID   string // Empty outside groups
+	CommentID string // Empty for posts
+}
+
+// ReactToPost handles setting the user's reaction to a post
+func (h *Handler) ReactToPost(w http.ResponseWriter, r *http.Request) {
+	userID, target, ok := h.postReactionTarget(w, r)
+	if !ok {
+		return
+	}
+	h.react(w, r, target, userID)
+}
+
+// RemovePostReaction handles removing the user's reaction to a post
+func (h *Handler) RemovePostReaction(w http.ResponseWriter, r *http.Request) {
+	userID, target, ok := h.postReactionTarget(w, r)
+	if !ok {
+		return
+	}
+	h.removeReaction(w, target, userID)
+}
+
+// GetPostReactions handles listing who reacted to a post
+func (h *Handler) GetPostReactions(w http.ResponseWriter, r *http.Request) {
+	userID, target, ok := h.postReactionTarget(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithReactions(w, r, target, userID)
+}
+
+// ReactToGroupPost handles setting the user's reaction to a group post
+func (h *Handler) ReactToGroupPost(w http.ResponseWriter, r *http.Request) {
+	userID, target, ok := h.groupPostReactionTarget(w, r)
+	if !ok {
+		return
+	}
+	h.react(w, r, target, userID)
+}
+
+// RemoveGroupPostReaction handles removing the user's reaction to a group post
+func (h *Handler) RemoveGroupPostReaction(w http.ResponseWriter, r *http.Request) {
+	userID, target, ok := h.groupPostReactionTarget(w, r)
+	if !ok {
+		return
+	}
+	h.removeReaction(w, target, userID)
+}
+
+// GetGroupPostReactions handles listing who reacted to a group post
+func (h *Handler) GetGroupPostReactions(w http.ResponseWriter, r *http.Request) {
+	userID, target, ok := h.groupPostReactionTarget(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithReactions(w, r, target, userID)
+}
+
+// ReactToComment handles setting the user's reaction to a comment on a post
+// or group post
+func (h *Handler) ReactToComment(w http.ResponseWriter, r *http.Request) {
+	userID, target, ok := h.commentReactionTarget(w, r)
+	if !ok {
+		return
+	}
+	h.react(w, r, target, userID)
+}
+
+// RemoveCommentReaction handles removing the user's reaction to a comment on
+// a post or group post
+func (h *Handler) RemoveCommentReaction(w http.ResponseWriter, r *http.Request) {
+	userID, target, ok := h.commentReactionTarget(w, r)
+	if !ok {
+		return
+	}
+	h.removeReaction(w, target, userID)
+}
+
+// GetCommentReactions handles listing who reacted to a comment on a post or
+// group post
+func (h *Handler) GetCommentReactions(w http.ResponseWriter, r *http.Request) {
+	userID, target, ok := h.commentReactionTarget(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithReactions(w, r, target, userID)
+}
+
+// postReactionTarget finds the post in the URL, responding with an error if
+// the user can't see it
+func (h *Handler) postReactionTarget(w http.ResponseWriter, r *http.Request) (string, *reactionTarget, bool) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", nil, false
+	}
+
+	// Check if post exists and user can view it
+	post, err := h.PostService.GetByID(mux.Vars(r)["id"], userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+		return "", nil, false
+	}
+
+	return userID, &reactionTarget{
+		Type:     models.ReactionTargetPost,
+		ID:       post.ID,
+		AuthorID: post.UserID,
+		Content:  post.Content,
+		PostID:   post.ID,
+	}, true
+}
+
+// groupPostReactionTarget finds the group post in the URL, responding with an
+// error if the user isn't a member of its group
+func (h *Handler) groupPostReactionTarget(w http.ResponseWriter, r *http.Request) (string, *reactionTarget, bool) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", nil, false
+	}
+
+	vars := mux.Vars(r)
+	post, ok := h.getGroupPostForMember(w, vars["groupId"], vars["postId"], userID)
+	if !ok {
+		return "", nil, false
+	}
+
+	return userID, &reactionTarget{
+		Type:     models.ReactionTargetPost,
+		ID:       post.ID,
+		AuthorID: post.UserID,
+		Content:  post.Content,
+		PostID:   post.ID,
+		GroupID:  post.GroupID,
+	}, true
+}
+
+// commentReactionTarget finds the comment in the URL, on a group post when
+// the URL names a group, responding with an error if the user can't see it
+func (h *Handler) commentReactionTarget(w http.ResponseWriter, r *http.Request) (string, *reactionTarget, bool) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return "", nil, false
+	}
+
+	vars := mux.Vars(r)
+	groupID := vars["groupId"]
+	postID := vars["postId"]
+
+	if groupID != "" {
+		if _, ok := h.getGroupPostForMember(w, groupID, postID, userID); !ok {
+			return "", nil, false
+		}
+	} else if _, err := h.PostService.GetByID(postID, userID); err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Post not found")
+		return "", nil, false
+	}
+
+	comment, err := h.getPostComment(postID, vars["commentId"], userID)
+	if err != nil {
+		respondWithCommentError(w, err, "Comment not found")
+		return "", nil, false
+	}
+
+	return userID, &reactionTarget{
+		Type:      models.ReactionTargetComment,
+		ID:        comment.ID,
+		AuthorID:  comment.UserID,
+		Content:   comment.Content,
+		PostID:    postID,
+		GroupID:   groupID,
+		CommentID: comment.ID,
+	}, true
+}
+
+// react sets the user's reaction to a post, group post or comment, notifying
+// its author the first time they react to it
+func (h *Handler) react(w http.ResponseWriter, r *http.Request, target *reactionTarget, userID string) {
+	var req ReactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	reaction := models.ReactionType(req.Reaction)
+	if !reaction.IsValid() {
+		utils.RespondWithError(w, http.StatusBadRequest, "Reaction must be like, love, laugh, wow, sad or angry")
+		return
+	}
+
+	added, err := h.ReactionService.React(target.Type, target.ID, userID, reaction)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to save reaction")
+		return
+	}
+
+	// Changing a reaction isn't worth another notification
+	if added && target.AuthorID != userID {
+		h.notifyReaction(target, userID, reaction)
+	}
+
+	h.respondWithReactionSummary(w, "Reaction saved successfully", target, userID)
+}
+
+// removeReaction removes the user's reaction to a post, group post or comment
+func (h *Handler) removeReaction(w http.ResponseWriter, target *reactionTarget, userID string) {
+	if err := h.ReactionService.Delete(target.Type, target.ID, userID); err != nil {
+		if err.Error() == "reaction not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "No reaction to remove")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to remove reaction")
+		}
+		return
+	}
+
+	h.respondWithReactionSummary(w, "Reaction removed successfully", target, userID)
+}
+
+// respondWithReactions responds with a page of who reacted to a post, group
+// post or comment, optionally only with the reaction given by ?type=
+func (h *Handler) respondWithReactions(w http.ResponseWriter, r *http.Request, target *reactionTarget, userID string) {
+	reaction := models.ReactionType(r.URL.Query().Get("type"))
+	if reaction != "" && !reaction.IsValid() {
+		utils.RespondWithError(w, http.StatusBadRequest, "Reaction must be like, love, laugh, wow, sad or angry")
+		return
+	}
+
+	// Parse query parameters
+	page, err := parsePage(r, 50)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
+	reactions, nextCursor, err := h.ReactionService.GetPage(target.Type, target.ID, reaction, userID, page)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get reactions")
+		return
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, "Reactions retrieved successfully", map[string]interface{}{
+		"reactions": reactions,
+	}, nextCursor)
+}
+
+// respondWithReactionSummary broadcasts a change to the reactions on a post,
+// group post or comment by the user and responds with the new counts
+func (h *Handler) respondWithReactionSummary(w http.ResponseWriter, successMessage string, target *reactionTarget, userID string) {
+	counts, reaction, err := h.ReactionService.GetSummary(target.Type, target.ID, userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get reactions")
+		return
+	}
+
+	likesCount := 0
+	for _, count := range counts {
+		likesCount += count
+	}
+
+	// Broadcast reaction event via WebSocket
+	reactionEvent := map[string]interface{}{
+		"postId":         target.PostID,
+		"userId":         userID,
+		"reaction":       reaction,
+		"reactionCounts": counts,
+		"likesCount":     likesCount,
+	}
+
+	messageType := "post_reaction"
+	if target.CommentID != "" {
+		reactionEvent["commentId"] = target.CommentID
+		messageType = "comment_reaction"
+	}
+
+	// Reactions in groups are only broadcast to group members
+	roomID := "" // Default room (all users)
+	if target.GroupID != "" {
+		reactionEvent["groupId"] = target.GroupID
+		messageType = "group_" + messageType
+		roomID = "group_" + target.GroupID
+	}
+
+	message := map[string]interface{}{
+		"type":    messageType,
+		"payload": reactionEvent,
+	}
+
+	messageData, _ := json.Marshal(message)
+
+	h.Hub.Broadcast <- &websocket.Broadcast{
+		RoomID:  roomID,
+		Message: messageData,
+		Sender:  nil, // No specific sender for server events
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, successMessage, map[string]interface{}{
+		"reaction":       reaction,
+		"reactionCounts": counts,
+		"likesCount":     likesCount,
+		"isLiked":        reaction != "",
+	})
+}
+
+// notifyReaction notifies the author of a post, group post or comment that
+// the user reacted to it, as a like notification
+func (h *Handler) notifyReaction(target *reactionTarget, userID string, reaction models.ReactionType) {
+	content := target.Content
+	if len(content) > 50 {
+		content = content[:50] + "..."
+	}
+
+	notificationData := map[string]interface{}{
+		"postId":   target.PostID,
+		"reaction": reaction,
+	}
+	if target.GroupID != "" {
+		notificationData["groupId"] = target.GroupID
+	}
+
+	notificationType := models.NotificationTypePostLike
+	noun := "post"
+	if target.CommentID != "" {
+		notificationData["commentId"] = target.CommentID
+		notificationData["comment"] = content
+		notificationType = models.NotificationTypeCommentLike
+		noun = "comment"
+	} else {
+		notificationData["postContent"] = content
+		if target.GroupID != "" {
+			noun = "group post"
+		}
+	}
+
+	verb := "reacted to"
+	if reaction == models.ReactionLike {
+		verb = "liked"
+	}
+
+	dataJSON, _ := json.Marshal(notificationData)
+
+	notification := &models.Notification{
+		UserID:   target.AuthorID,
+		SenderID: userID,
+		Type:     notificationType,
+		Content:  verb + " your " + noun,
+		Data:     string(dataJSON),
+	}
+
+	if err := h.NotificationService.Create(notification); err != nil {
+		// Log error but don't fail the request
+		log.Printf("Error creating notification: %v", err)
+	}
+}
@@ -0,0 +1,476 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// maxReportDetailsLength caps the free text a reporter can add
+const maxReportDetailsLength = 1000
+
+// Account actions a moderator can take when resolving a report
+const (
+	reportAccountActionSuspend = "suspend"
+	reportAccountActionBan     = "ban"
+)
+
+// reportDeleteActions names the audit log action for deleting each kind of reported content
+var reportDeleteActions = map[models.ModerationTarget]models.ModerationAction{
+	models.ModerationTargetPost:    models.ModerationActionDeletePost,
+	models.ModerationTargetComment: models.ModerationActionDeleteComment,
+	models.ModerationTargetMessage: models.ModerationActionDeleteMessage,
+	models.ModerationTargetGroup:   models.ModerationActionDeleteGroup,
+}
+
+// CreateReportRequest represents a user's report of a post, comment, message, group or profile
+type CreateReportRequest struct {
+	TargetType string `json:"targetType"`
+	TargetID   string `json:"targetId"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+}
+
+// ResolveReportRequest represents a moderator closing a report, optionally acting on it
+type ResolveReportRequest struct {
+	Note          string `json:"note"`
+	DeleteContent bool   `json:"deleteContent"`
+	AccountAction string `json:"accountAction"` // "suspend" or "ban" the target's owner
+	Until         string `json:"until"`         // RFC 3339; suspensions only
+	DurationHours int    `json:"durationHours"` // Alternative to Until; suspensions only
+}
+
+// reportTarget finds what a user is reporting, checking they can see it, and
+// returns who owns it and a snapshot of its content for reviewers
+func (h *Handler) reportTarget(reporterID string, targetType models.ModerationTarget, targetID string) (ownerID, snapshot string, err error) {
+	switch targetType {
+	case models.ModerationTargetPost:
+		if post, err := h.PostService.GetByID(targetID, reporterID); err == nil {
+			return post.UserID, post.Content, nil
+		}
+		groupPost, err := h.GroupPostService.GetByID(targetID, reporterID)
+		if err != nil {
+			return "", "", errContentNotFound
+		}
+		return groupPost.UserID, groupPost.Content, nil
+	case models.ModerationTargetComment:
+		comment, err := h.CommentService.GetByID(targetID)
+		if err != nil {
+			return "", "", errContentNotFound
+		}
+		return comment.UserID, comment.Content, nil
+	case models.ModerationTargetMessage:
+		message, err := h.getAccessibleMessage(reporterID, targetID)
+		if err != nil || message.IsDeleted {
+			return "", "", errContentNotFound
+		}
+		return message.SenderID, message.Content, nil
+	case models.ModerationTargetGroup:
+		group, err := h.GroupService.GetByID(targetID, reporterID)
+		if err != nil {
+			return "", "", errContentNotFound
+		}
+		return group.CreatorID, group.Name + "\n" + group.Description, nil
+	case models.ModerationTargetUser:
+		user, err := h.UserService.GetByID(targetID)
+		if err != nil {
+			return "", "", errContentNotFound
+		}
+		return user.ID, user.Username + "\n" + user.FullName + "\n" + user.Bio, nil
+	}
+	return "", "", fmt.Errorf("cannot report %s", targetType)
+}
+
+// GetReportReasons handles listing the reasons a user can give for a report
+func (h *Handler) GetReportReasons(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithSuccess(w, http.StatusOK, "Report reasons retrieved successfully", map[string]interface{}{
+		"reasons": models.ReportReasons,
+	})
+}
+
+// CreateReport handles a user reporting a post, comment, message, group or profile
+func (h *Handler) CreateReport(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req CreateReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	targetType := models.ModerationTarget(req.TargetType)
+	switch targetType {
+	case models.ModerationTargetPost, models.ModerationTargetComment, models.ModerationTargetMessage,
+		models.ModerationTargetGroup, models.ModerationTargetUser:
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "Target type must be post, comment, message, group or user")
+		return
+	}
+	if req.TargetID == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Target ID is required")
+		return
+	}
+	reason := models.ReportReason(req.Reason)
+	if !reason.Valid() {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid report reason")
+		return
+	}
+	req.Details = strings.TrimSpace(req.Details)
+	if len(req.Details) > maxReportDetailsLength {
+		utils.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Details must be at most %d characters", maxReportDetailsLength))
+		return
+	}
+
+	ownerID, snapshot, err := h.reportTarget(userID, targetType, req.TargetID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if ownerID == userID {
+		utils.RespondWithError(w, http.StatusBadRequest, "You cannot report yourself or your own content")
+		return
+	}
+
+	report := &models.Report{
+		TargetType:      targetType,
+		TargetID:        req.TargetID,
+		TargetOwnerID:   ownerID,
+		ContentSnapshot: snapshot,
+	}
+	submission := &models.ReportSubmission{
+		ReporterID: userID,
+		Reason:     reason,
+		Details:    req.Details,
+	}
+	if err := h.ReportService.Submit(report, submission); err != nil {
+		if err.Error() == "already reported" {
+			utils.RespondWithError(w, http.StatusConflict, "You have already reported this")
+			return
+		}
+		log.Printf("Failed to submit report of %s %s: %v", targetType, req.TargetID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to submit report")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusCreated, "Report submitted successfully", map[string]interface{}{
+		"reportId": report.ID,
+	})
+}
+
+// ListReports handles listing the moderation queue
+func (h *Handler) ListReports(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	page, err := parsePage(r, 20)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.ReportFilter{
+		Status:     models.ReportStatus(query.Get("status")),
+		TargetType: models.ModerationTarget(query.Get("targetType")),
+		AssigneeID: query.Get("assignee"),
+	}
+	if filter.AssigneeID == "me" {
+		filter.AssigneeID = userID
+	}
+
+	reports, nextCursor, err := h.ReportService.List(filter, page)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get reports")
+		return
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, "Reports retrieved successfully", map[string]interface{}{
+		"reports": reports,
+	}, nextCursor)
+}
+
+// GetReport handles getting a report with every user's submission
+func (h *Handler) GetReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	report, err := h.ReportService.GetByID(vars["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+
+	report.Submissions, err = h.ReportService.GetSubmissions(report.ID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get report submissions")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Report retrieved successfully", map[string]interface{}{
+		"report": report,
+	})
+}
+
+// respondWithReportError maps a ReportService error to a response
+func respondWithReportError(w http.ResponseWriter, err error, action string) {
+	switch err.Error() {
+	case "report not found":
+		utils.RespondWithError(w, http.StatusNotFound, "Report not found")
+	case "report already closed":
+		utils.RespondWithError(w, http.StatusConflict, "Report has already been closed")
+	case "report claimed by another moderator":
+		utils.RespondWithError(w, http.StatusConflict, "Report is claimed by another moderator")
+	default:
+		log.Printf("Failed to %s report: %v", action, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to "+action+" report")
+	}
+}
+
+// ClaimReport handles a moderator taking a report off the open queue
+func (h *Handler) ClaimReport(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	vars := mux.Vars(r)
+	reportID := vars["id"]
+
+	err = h.ModerationLogService.Moderate(func(tx *sql.Tx) error {
+		if err := h.ReportService.Claim(tx, reportID, userID); err != nil {
+			return err
+		}
+		return h.recordModeration(tx, r, models.ModerationActionClaimReport, models.ModerationTargetReport, reportID, "", "")
+	})
+	if err != nil {
+		respondWithReportError(w, err, "claim")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Report claimed successfully", nil)
+}
+
+// DismissReport handles a moderator closing a report without taking action
+func (h *Handler) DismissReport(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req ResolveReportRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+	}
+
+	vars := mux.Vars(r)
+	reportID := vars["id"]
+	note := strings.TrimSpace(req.Note)
+
+	err = h.ModerationLogService.Moderate(func(tx *sql.Tx) error {
+		if err := h.ReportService.Close(tx, reportID, userID, models.ReportStatusDismissed, "none", note); err != nil {
+			return err
+		}
+		return h.recordModeration(tx, r, models.ModerationActionDismissReport, models.ModerationTargetReport, reportID, note, "")
+	})
+	if err != nil {
+		respondWithReportError(w, err, "dismiss")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Report dismissed successfully", nil)
+}
+
+// ResolveReport handles a moderator closing a report, optionally removing
+// the reported content and suspending or banning its owner
+func (h *Handler) ResolveReport(w http.ResponseWriter, r *http.Request) {
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var req ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+
+	vars := mux.Vars(r)
+	report, err := h.ReportService.GetByID(vars["id"])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusNotFound, "Report not found")
+		return
+	}
+
+	// Check the report can be closed before acting on anything
+	if report.Status == models.ReportStatusResolved || report.Status == models.ReportStatusDismissed {
+		utils.RespondWithError(w, http.StatusConflict, "Report has already been closed")
+		return
+	}
+	if report.Status == models.ReportStatusClaimed && report.AssigneeID != userID {
+		utils.RespondWithError(w, http.StatusConflict, "Report is claimed by another moderator")
+		return
+	}
+	if req.DeleteContent && report.TargetType == models.ModerationTargetUser {
+		utils.RespondWithError(w, http.StatusBadRequest, "Profiles cannot be deleted; suspend or ban the account instead")
+		return
+	}
+
+	var owner *models.User
+	var accountStatus models.AccountStatus
+	switch req.AccountAction {
+	case "":
+	case reportAccountActionSuspend, reportAccountActionBan:
+		accountStatus = models.AccountStatusBanned
+		if req.AccountAction == reportAccountActionSuspend {
+			accountStatus = models.AccountStatusSuspended
+		}
+		if owner = h.moderatableUser(w, r, report.TargetOwnerID); owner == nil {
+			return
+		}
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "Account action must be suspend or ban")
+		return
+	}
+	until, err := parseSuspensionEnd(req.Until, req.DurationHours)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reason := "report " + report.ID
+	if req.Note != "" {
+		reason += ": " + req.Note
+	}
+
+	// Everything done about the report is kept, and logged, only if all of it
+	// succeeds and the report is closed
+	var action string
+	var contentRemoved bool
+	err = h.ModerationLogService.Moderate(func(tx *sql.Tx) error {
+		var actions []string
+		if req.DeleteContent {
+			err := h.removeReportedContent(tx, report)
+			switch {
+			case err == nil:
+				contentRemoved = true
+				if err := h.recordModeration(tx, r, reportDeleteActions[report.TargetType], report.TargetType, report.TargetID, reason, ""); err != nil {
+					return err
+				}
+			case err != errContentNotFound:
+				return fmt.Errorf("failed to delete reported %s %s: %w", report.TargetType, report.TargetID, err)
+			}
+			actions = append(actions, "delete_content")
+		}
+		if owner != nil {
+			if err := h.restrictAccount(tx, r, owner, accountStatus, until, reason); err != nil {
+				return fmt.Errorf("failed to %s user %s: %w", req.AccountAction, owner.ID, err)
+			}
+			actions = append(actions, req.AccountAction+"_user")
+		}
+		if len(actions) == 0 {
+			actions = append(actions, "none")
+		}
+		action = strings.Join(actions, ",")
+
+		if err := h.ReportService.Close(tx, report.ID, userID, models.ReportStatusResolved, action, req.Note); err != nil {
+			return err
+		}
+		return h.recordModeration(tx, r, models.ModerationActionResolveReport, models.ModerationTargetReport, report.ID, req.Note, action)
+	})
+	if err != nil {
+		respondWithReportError(w, err, "resolve")
+		return
+	}
+
+	if contentRemoved && report.TargetType == models.ModerationTargetMessage {
+		h.broadcastReportedMessageDeleted(report.TargetID)
+	}
+	if owner != nil {
+		h.signOutRestricted(owner.ID, accountStatus)
+	}
+	h.notifyReportersResolved(report)
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Report resolved successfully", map[string]interface{}{
+		"resolutionAction": action,
+	})
+}
+
+// removeReportedContent deletes reported content in tx. Messages are left
+// as a tombstone, as when their sender deletes them; the message_deleted
+// event is sent with broadcastReportedMessageDeleted once tx is committed.
+func (h *Handler) removeReportedContent(tx *sql.Tx, report *models.Report) error {
+	if report.TargetType != models.ModerationTargetMessage {
+		return h.removeContent(tx, report.TargetType, report.TargetID)
+	}
+
+	err := h.MessageService.DeleteTx(tx, report.TargetID, report.TargetOwnerID)
+	if err != nil && err.Error() == "message not found or not authorized to delete" {
+		return errContentNotFound
+	}
+	return err
+}
+
+// broadcastReportedMessageDeleted tells a conversation that a reported
+// message was removed
+func (h *Handler) broadcastReportedMessageDeleted(messageID string) {
+	message, err := h.MessageService.GetByID(messageID)
+	if err != nil {
+		log.Printf("Failed to get removed message %s: %v", messageID, err)
+		return
+	}
+	h.broadcastMessageEvent(message, "message_deleted", map[string]interface{}{
+		"messageId": message.ID,
+		"deletedAt": message.DeletedAt,
+	})
+}
+
+// notifyReportersResolved tells everyone who reported a target that staff acted on it
+func (h *Handler) notifyReportersResolved(report *models.Report) {
+	reporterIDs, err := h.ReportService.GetReporterIDs(report.ID)
+	if err != nil {
+		log.Printf("Failed to get reporters of report %s: %v", report.ID, err)
+		return
+	}
+
+	dataJSON, _ := json.Marshal(map[string]interface{}{
+		"reportId":   report.ID,
+		"targetType": report.TargetType,
+		"targetId":   report.TargetID,
+	})
+
+	for _, reporterID := range reporterIDs {
+		// Staff stay anonymous to reporters, so the notification comes from the reporter themselves
+		notification := &models.Notification{
+			UserID:   reporterID,
+			SenderID: reporterID,
+			Type:     models.NotificationTypeReportResolved,
+			Content:  fmt.Sprintf("Thanks for your report. We reviewed the %s you reported and took action.", report.TargetType),
+			Data:     string(dataJSON),
+		}
+		if err := h.NotificationService.Create(notification); err != nil {
+			log.Printf("Error creating notification: %v", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// GetPostRevisions handles retrieving the edit history of a post
+func (h *Handler) GetPostRevisions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get post ID from URL
+	postID := mux.Vars(r)["id"]
+
+	h.respondWithRevisions(w, r, models.RevisionTargetPost, postID, "", userID)
+}
+
+// GetCommentRevisions handles retrieving the edit history of a comment on a post
+func (h *Handler) GetCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get post ID and comment ID from URL
+	vars := mux.Vars(r)
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	h.respondWithRevisions(w, r, models.RevisionTargetComment, commentID, postID, userID)
+}
+
+// GetGroupPostRevisions handles retrieving the edit history of a group post
+func (h *Handler) GetGroupPostRevisions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get group ID and post ID from URL
+	vars := mux.Vars(r)
+	groupID := vars["groupId"]
+	postID := vars["postId"]
+
+	h.respondWithRevisions(w, r, models.RevisionTargetGroupPost, postID, groupID, userID)
+}
+
+// GetGroupPostCommentRevisions handles retrieving the edit history of a
+// comment on a group post
+func (h *Handler) GetGroupPostCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get group ID, post ID, and comment ID from URL
+	vars := mux.Vars(r)
+	groupID := vars["groupId"]
+	postID := vars["postId"]
+	commentID := vars["commentId"]
+
+	// Verify post belongs to the group
+	if _, err := h.RevisionService.GetAuthorID(models.RevisionTargetGroupPost, postID, groupID); err != nil {
+		if err.Error() == "content not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "Post not found in this group")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get post")
+		}
+		return
+	}
+
+	h.respondWithRevisions(w, r, models.RevisionTargetComment, commentID, postID, userID)
+}
+
+// respondWithRevisions responds with a page of the edit history of a post,
+// group post or comment. Only its author and platform staff may see it,
+// whoever else can see the content itself.
+func (h *Handler) respondWithRevisions(w http.ResponseWriter, r *http.Request, targetType models.RevisionTargetType, targetID, parentID, userID string) {
+	authorID, err := h.RevisionService.GetAuthorID(targetType, targetID, parentID)
+	if err != nil {
+		if err.Error() == "content not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "Content not found")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get content")
+		}
+		return
+	}
+
+	// Staff can settle disputes over content they couldn't otherwise see
+	if authorID != userID {
+		user, err := h.UserService.GetByID(userID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user")
+			return
+		}
+		if !models.UserRole(user.Role).IsStaff() {
+			utils.RespondWithError(w, http.StatusForbidden, "Not authorized to view this edit history")
+			return
+		}
+	}
+
+	// Parse query parameters
+	page, err := parsePage(r, 50)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
+	revisions, nextCursor, err := h.RevisionService.GetByTargetPage(targetType, targetID, page)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get edit history")
+		return
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, "Edit history retrieved successfully", map[string]interface{}{
+		"revisions": revisions,
+	}, nextCursor)
+}
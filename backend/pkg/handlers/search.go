@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// Search handles searching posts, group posts, comments, messages, users and groups
+func (h *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse query parameters
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Search query is required")
+		return
+	}
+
+	limit := 10
+	offset := 0
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err == nil && parsedLimit > 0 && parsedLimit <= 50 {
+			limit = parsedLimit
+		}
+	}
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		parsedOffset, err := strconv.Atoi(offsetStr)
+		if err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	// Restrict to the requested types, e.g. type=post,user; all types by default
+	types := models.SearchResultTypes
+	if typeStr := r.URL.Query().Get("type"); typeStr != "" {
+		types = nil
+		for _, name := range strings.Split(typeStr, ",") {
+			resultType := models.SearchResultType(strings.TrimSpace(name))
+			if !isSearchResultType(resultType) {
+				utils.RespondWithError(w, http.StatusBadRequest, "Invalid search type: "+string(resultType))
+				return
+			}
+			types = append(types, resultType)
+		}
+	}
+
+	results, err := h.SearchService.Search(userID, query, types, limit, offset)
+	if err != nil {
+		if err.Error() == "search query is required" {
+			utils.RespondWithError(w, http.StatusBadRequest, "Search query must contain letters or numbers")
+			return
+		}
+		log.Printf("Error searching for %q: %v", query, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to search")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Search completed successfully", map[string]interface{}{
+		"query":   query,
+		"results": results,
+	})
+}
+
+// isSearchResultType reports whether t is a searchable type
+func isSearchResultType(t models.SearchResultType) bool {
+	for _, resultType := range models.SearchResultTypes {
+		if t == resultType {
+			return true
+		}
+	}
+	return false
+}
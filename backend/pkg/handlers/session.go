@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/bernaotieno/social-network/backend/pkg/auth"
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// GetSessions handles listing the current user's active sessions across devices
+func (h *Handler) GetSessions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID and session ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentSessionID, _ := middleware.GetSessionID(r)
+
+	// Get active sessions
+	sessions, err := h.SessionService.GetActiveByUser(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get sessions")
+		return
+	}
+
+	// Flag the session making this request
+	for _, session := range sessions {
+		session.Current = session.ID == currentSessionID
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Sessions retrieved successfully", map[string]interface{}{
+		"sessions": sessions,
+	})
+}
+
+// RevokeSession handles signing out one of the current user's sessions
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	// Get user ID and session ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentSessionID, _ := middleware.GetSessionID(r)
+
+	// Get the session's public ID from URL; the token itself never appears in it
+	vars := mux.Vars(r)
+	session, err := h.SessionService.GetByPublicID(vars["id"], userID)
+	if err != nil {
+		if err.Error() == "session not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "Session not found")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get session")
+		}
+		return
+	}
+
+	// Revoke the session and disconnect its devices
+	if err := auth.RevokeSession(h.DB, userID, session.ID, h.Hub); err != nil {
+		if err.Error() == "session not found" {
+			utils.RespondWithError(w, http.StatusNotFound, "Session not found")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke session")
+		}
+		return
+	}
+
+	// Revoking the current session also clears its cookie
+	if session.ID == currentSessionID {
+		if err := auth.ClearSession(r.Context(), h.DB, w, r); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to clear session")
+			return
+		}
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Session revoked successfully", nil)
+}
+
+// RevokeOtherSessions handles signing out every session except the one making the request
+func (h *Handler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	// Get user ID and session ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	currentSessionID, err := middleware.GetSessionID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Revoke the other sessions and disconnect their devices
+	revoked, err := auth.RevokeUserSessions(h.DB, userID, currentSessionID, auth.SessionRevokedReason, h.Hub)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Other sessions revoked successfully", map[string]interface{}{
+		"revokedCount": revoked,
+	})
+}
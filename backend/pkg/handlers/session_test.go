@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/gorilla/mux"
+)
+
+func TestSessionsAreListedAndRevokedByPublicID(t *testing.T) {
+	h := newTestHandler(t)
+	userID, token := createTestUser(t, h, "user", false)
+	other, err := h.SessionService.CreateForDevice(userID, time.Hour, "other device", "127.0.0.2")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	req := withTestDB(h, httptest.NewRequest(http.MethodGet, "/api/auth/sessions", nil))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	middleware.AuthMiddleware(h.GetSessions)(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d listing sessions, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), token) || strings.Contains(rec.Body.String(), other.ID) {
+		t.Fatalf("Expected no session tokens in the session list, got %s", rec.Body.String())
+	}
+
+	var response struct {
+		Data struct {
+			Sessions []struct {
+				ID      string `json:"id"`
+				Current bool   `json:"current"`
+			} `json:"sessions"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Data.Sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(response.Data.Sessions))
+	}
+
+	revoke := func(id string) int {
+		req := withTestDB(h, httptest.NewRequest(http.MethodDelete, "/api/auth/sessions/"+id, nil))
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		middleware.AuthMiddleware(h.RevokeSession)(rec, req)
+		return rec.Code
+	}
+
+	// The token doesn't identify a session in the URL
+	if code := revoke(other.ID); code != http.StatusNotFound {
+		t.Errorf("Expected status %d revoking by token, got %d", http.StatusNotFound, code)
+	}
+	if code := revoke(other.PublicID); code != http.StatusOK {
+		t.Fatalf("Expected status %d revoking by public ID, got %d", http.StatusOK, code)
+	}
+	if _, err := h.SessionService.GetByID(other.ID); err == nil || err.Error() != "session not found" {
+		t.Errorf("Expected the revoked session to be gone, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+	"github.com/gorilla/mux"
+)
+
+// GetTagPosts handles retrieving the posts using a hashtag
+func (h *Handler) GetTagPosts(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get tag from URL
+	tag := models.NormalizeTag(mux.Vars(r)["tag"])
+	if tag == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid hashtag")
+		return
+	}
+
+	// Parse query parameters
+	page, err := parsePage(r, 20)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
+	posts, nextCursor, err := h.PostService.GetByTagPage(tag, userID, page)
+	if err != nil {
+		log.Printf("Error getting posts tagged #%s: %v", tag, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get posts")
+		return
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, "Posts retrieved successfully", map[string]interface{}{
+		"tag":   tag,
+		"posts": posts,
+	}, nextCursor)
+}
+
+// GetTagGroupPosts handles retrieving the group posts using a hashtag
+func (h *Handler) GetTagGroupPosts(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Get tag from URL
+	tag := models.NormalizeTag(mux.Vars(r)["tag"])
+	if tag == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid hashtag")
+		return
+	}
+
+	// Parse query parameters
+	page, err := parsePage(r, 20)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+
+	posts, nextCursor, err := h.GroupPostService.GetByTagPage(tag, userID, page)
+	if err != nil {
+		log.Printf("Error getting group posts tagged #%s: %v", tag, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get posts")
+		return
+	}
+
+	utils.RespondWithPage(w, http.StatusOK, "Posts retrieved successfully", map[string]interface{}{
+		"tag":   tag,
+		"posts": posts,
+	}, nextCursor)
+}
+
+// GetTrendingTags handles retrieving the hashtags used most in the last day,
+// or the last ?hours=, among the posts and group posts the user can see
+func (h *Handler) GetTrendingTags(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse query parameters
+	hours := 24
+	if hoursStr := r.URL.Query().Get("hours"); hoursStr != "" {
+		parsedHours, err := strconv.Atoi(hoursStr)
+		if err == nil && parsedHours > 0 && parsedHours <= 7*24 {
+			hours = parsedHours
+		}
+	}
+	limit := 10
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err == nil && parsedLimit > 0 && parsedLimit <= 50 {
+			limit = parsedLimit
+		}
+	}
+
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	tags, err := h.EntityService.GetTrendingTags(userID, since, limit)
+	if err != nil {
+		log.Printf("Error getting trending tags: %v", err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get trending tags")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Trending tags retrieved successfully", map[string]interface{}{
+		"tags": tags,
+	})
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/auth"
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// twoFactorChallengeTTL is how long a user has to enter their code after giving their password
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// totpIssuer names the site in authenticator apps
+const totpIssuer = "Social Network"
+
+// recoveryCodeCount is how many recovery codes a user is given at a time
+const recoveryCodeCount = 10
+
+// TwoFactorCodeRequest represents a request carrying a TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// TwoFactorLoginRequest represents the second step of a two-factor login
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+}
+
+// DisableTwoFactorRequest represents a request to turn two-factor authentication off
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// checkTwoFactorCode checks a TOTP code, or failing that a recovery code, for
+// a user with two-factor authentication enabled. Each code works only once.
+func (h *Handler) checkTwoFactorCode(twoFactor *models.TwoFactor, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false, nil
+	}
+
+	if step, ok := auth.ValidateTOTP(twoFactor.Secret, code, time.Now()); ok {
+		return h.TwoFactorService.UseStep(twoFactor.UserID, step)
+	}
+
+	return h.TwoFactorService.UseRecoveryCode(twoFactor.UserID, auth.NormalizeRecoveryCode(code))
+}
+
+// newRecoveryCodes generates and stores a fresh set of recovery codes, returning them for the user to save
+func (h *Handler) newRecoveryCodes(userID string) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.TwoFactorService.ReplaceRecoveryCodes(userID, codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// GetTwoFactorStatus handles reporting whether two-factor authentication is on for the current user
+func (h *Handler) GetTwoFactorStatus(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	twoFactor, err := h.TwoFactorService.GetByUserID(userID)
+	if err != nil || !twoFactor.Enabled() {
+		utils.RespondWithSuccess(w, http.StatusOK, "Two-factor status retrieved successfully", map[string]interface{}{
+			"enabled": false,
+		})
+		return
+	}
+
+	remaining, err := h.TwoFactorService.CountRecoveryCodes(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get two-factor status")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor status retrieved successfully", map[string]interface{}{
+		"enabled":                true,
+		"enabledAt":              twoFactor.EnabledAt,
+		"recoveryCodesRemaining": remaining,
+	})
+}
+
+// SetupTwoFactor handles starting two-factor setup. It returns a new secret and
+// the provisioning URI to show as a QR code; nothing changes at login until
+// EnableTwoFactor confirms a code from the app.
+func (h *Handler) SetupTwoFactor(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.UserService.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to set up two-factor authentication")
+		return
+	}
+
+	if err := h.TwoFactorService.SetPending(userID, secret); err != nil {
+		if err.Error() == "two-factor authentication is already enabled" {
+			utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		} else {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to set up two-factor authentication")
+		}
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Scan the QR code with your authenticator app, then confirm a code", map[string]interface{}{
+		"secret":          secret,
+		"provisioningUri": auth.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// EnableTwoFactor handles confirming two-factor setup with a code from the
+// authenticator app. It returns the recovery codes, which are shown only once.
+func (h *Handler) EnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	twoFactor, err := h.TwoFactorService.GetByUserID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Start two-factor setup first")
+		return
+	}
+	if twoFactor.Enabled() {
+		utils.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	// Only a TOTP code proves the app was set up; there are no recovery codes yet
+	step, ok := auth.ValidateTOTP(twoFactor.Secret, req.Code, time.Now())
+	if !ok {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid two-factor code")
+		return
+	}
+	if _, err := h.TwoFactorService.UseStep(userID, step); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	codes, err := h.newRecoveryCodes(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+	if err := h.TwoFactorService.Enable(userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+	log.Printf("Two-factor authentication enabled for user %s", userID)
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication enabled", map[string]interface{}{
+		"recoveryCodes": codes,
+	})
+}
+
+// DisableTwoFactor handles turning two-factor authentication off, which needs
+// both the password and a current code
+func (h *Handler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req DisableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.UserService.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	twoFactor, err := h.TwoFactorService.GetByUserID(userID)
+	if err != nil || !twoFactor.Enabled() {
+		utils.RespondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	// Wrong guesses count towards the same lockout as login
+	ip := auth.ClientIP(r)
+	if wait := h.loginWait(user.Email, ip); wait > 0 {
+		utils.RespondWithRateLimit(w, wait, "Too many failed attempts, please try again later")
+		return
+	}
+	if !h.UserService.CheckPassword(user, req.Password) {
+		h.recordLoginFailure(user.Email, ip)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Password is incorrect")
+		return
+	}
+	valid, err := h.checkTwoFactorCode(twoFactor, req.Code)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check two-factor code")
+		return
+	}
+	if !valid {
+		h.recordLoginFailure(user.Email, ip)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+
+	if err := h.TwoFactorService.Disable(userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+	log.Printf("Two-factor authentication disabled for user %s", userID)
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Two-factor authentication disabled", nil)
+}
+
+// RegenerateRecoveryCodes handles replacing the current user's recovery codes.
+// The old codes stop working.
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	// Parse request body
+	var req TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.UserService.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	twoFactor, err := h.TwoFactorService.GetByUserID(userID)
+	if err != nil || !twoFactor.Enabled() {
+		utils.RespondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+
+	ip := auth.ClientIP(r)
+	if wait := h.loginWait(user.Email, ip); wait > 0 {
+		utils.RespondWithRateLimit(w, wait, "Too many failed attempts, please try again later")
+		return
+	}
+	valid, err := h.checkTwoFactorCode(twoFactor, req.Code)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check two-factor code")
+		return
+	}
+	if !valid {
+		h.recordLoginFailure(user.Email, ip)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+
+	codes, err := h.newRecoveryCodes(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Recovery codes regenerated", map[string]interface{}{
+		"recoveryCodes": codes,
+	})
+}
+
+// VerifyTwoFactorLogin handles the second step of a two-factor login, trading
+// the challenge token from Login and a code for a session
+func (h *Handler) VerifyTwoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.ChallengeToken == "" || req.Code == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Challenge token and code are required")
+		return
+	}
+
+	fields, err := auth.VerifyToken(auth.TwoFactorChallengePurpose, req.ChallengeToken)
+	if err != nil || len(fields) != 1 {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Login expired, please log in again")
+		return
+	}
+
+	user, err := h.UserService.GetByID(fields[0])
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Login expired, please log in again")
+		return
+	}
+
+	// The account may have been suspended since the password was checked
+	if h.refuseRestrictedAccount(w, user) {
+		return
+	}
+
+	ip := auth.ClientIP(r)
+	if wait := h.loginWait(user.Email, ip); wait > 0 {
+		utils.RespondWithRateLimit(w, wait, "Too many failed login attempts, please try again later")
+		return
+	}
+
+	twoFactor, err := h.TwoFactorService.GetByUserID(user.ID)
+	if err != nil || !twoFactor.Enabled() {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Login expired, please log in again")
+		return
+	}
+
+	valid, err := h.checkTwoFactorCode(twoFactor, req.Code)
+	if err != nil {
+		log.Printf("Failed to check two-factor code for user %s: %v", user.ID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check two-factor code")
+		return
+	}
+	if !valid {
+		h.recordLoginFailure(user.Email, ip)
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+
+	h.completeLogin(w, r, user)
+}
@@ -27,7 +27,7 @@ type UpdateProfileRequest struct {
 // GetUsers handles retrieving a list of users
 func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	// Get current user ID from context (authenticated user required)
-	_, err := middleware.GetUserID(r)
+	currentUserID, err := middleware.GetUserID(r)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
 		return
@@ -57,7 +57,7 @@ func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get users
-	users, err := h.UserService.GetUsers(query, limit, offset)
+	users, err := h.UserService.GetUsers(query, currentUserID, limit, offset)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get users")
 		return
@@ -87,7 +87,7 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user
-	user, err := h.UserService.GetByID(userID)
+	user, err := h.UserService.GetProfile(userID, currentUserID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusNotFound, "User not found")
 		return
@@ -143,50 +143,57 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	if isOwnProfile {
 		// For own profile, always return ALL data including sensitive information
 		userData = map[string]interface{}{
-			"id":             user.ID,
-			"username":       user.Username,
-			"email":          user.Email,
-			"fullName":       user.FullName,
-			"firstName":      user.FirstName,
-			"lastName":       user.LastName,
-			"dateOfBirth":    user.DateOfBirth,
-			"bio":            user.Bio,
-			"profilePicture": user.ProfilePicture,
-			"coverPhoto":     user.CoverPhoto,
-			"isPrivate":      user.IsPrivate,
-			"createdAt":      user.CreatedAt,
-			"updatedAt":      user.UpdatedAt,
-			"isOwnProfile":   true,
+			"id":                user.ID,
+			"username":          user.Username,
+			"email":             user.Email,
+			"emailVerified":     user.EmailVerified,
+			"fullName":          user.FullName,
+			"firstName":         user.FirstName,
+			"lastName":          user.LastName,
+			"dateOfBirth":       user.DateOfBirth,
+			"bio":               user.Bio,
+			"profilePicture":    user.ProfilePicture,
+			"profilePictureSet": utils.NewImageSet(user.ProfilePicture),
+			"coverPhoto":        user.CoverPhoto,
+			"coverPhotoSet":     utils.NewImageSet(user.CoverPhoto),
+			"isPrivate":         user.IsPrivate,
+			"createdAt":         user.CreatedAt,
+			"updatedAt":         user.UpdatedAt,
+			"isOwnProfile":      true,
 		}
 	} else if isAuthorized {
 		// Full profile data for authorized viewers (followers of private profiles, or anyone for public profiles)
 		// But exclude sensitive information like email
 		userData = map[string]interface{}{
-			"id":             user.ID,
-			"username":       user.Username,
-			"fullName":       user.FullName,
-			"firstName":      user.FirstName,
-			"lastName":       user.LastName,
-			"dateOfBirth":    user.DateOfBirth,
-			"bio":            user.Bio,
-			"profilePicture": user.ProfilePicture,
-			"coverPhoto":     user.CoverPhoto,
-			"isPrivate":      user.IsPrivate,
-			"createdAt":      user.CreatedAt,
-			"updatedAt":      user.UpdatedAt,
-			"isOwnProfile":   false,
+			"id":                user.ID,
+			"username":          user.Username,
+			"fullName":          user.FullName,
+			"firstName":         user.FirstName,
+			"lastName":          user.LastName,
+			"dateOfBirth":       user.DateOfBirth,
+			"bio":               user.Bio,
+			"profilePicture":    user.ProfilePicture,
+			"profilePictureSet": utils.NewImageSet(user.ProfilePicture),
+			"coverPhoto":        user.CoverPhoto,
+			"coverPhotoSet":     utils.NewImageSet(user.CoverPhoto),
+			"isPrivate":         user.IsPrivate,
+			"createdAt":         user.CreatedAt,
+			"updatedAt":         user.UpdatedAt,
+			"isOwnProfile":      false,
 		}
 	} else {
 		// Limited profile data for unauthorized viewers of private profiles
 		userData = map[string]interface{}{
-			"id":             user.ID,
-			"username":       user.Username,
-			"fullName":       user.FullName,
-			"profilePicture": user.ProfilePicture,
-			"coverPhoto":     user.CoverPhoto,
-			"isPrivate":      user.IsPrivate,
-			"createdAt":      user.CreatedAt,
-			"isOwnProfile":   false,
+			"id":                user.ID,
+			"username":          user.Username,
+			"fullName":          user.FullName,
+			"profilePicture":    user.ProfilePicture,
+			"profilePictureSet": utils.NewImageSet(user.ProfilePicture),
+			"coverPhoto":        user.CoverPhoto,
+			"coverPhotoSet":     utils.NewImageSet(user.CoverPhoto),
+			"isPrivate":         user.IsPrivate,
+			"createdAt":         user.CreatedAt,
+			"isOwnProfile":      false,
 		}
 	}
 
@@ -222,6 +229,14 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 		response["isFollowedByCurrentUser"] = isFollowing
 		response["hasPendingFollowRequest"] = hasPendingRequest
 		response["followStatus"] = followStatus
+
+		// Only the muter ever learns about a mute
+		isMuted, err := h.BlockService.IsMuted(currentUserID, userID)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check mute status")
+			return
+		}
+		response["isMuted"] = isMuted
 	}
 
 	utils.RespondWithSuccess(w, http.StatusOK, "User retrieved successfully", response)
@@ -270,6 +285,7 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate and update email if provided
+	emailChanged := false
 	if req.Email != "" && req.Email != user.Email {
 		// Trim whitespace and validate
 		req.Email = strings.TrimSpace(req.Email)
@@ -286,6 +302,7 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		}
 
 		user.Email = req.Email
+		emailChanged = true
 	}
 
 	// Validate and update date of birth if provided
@@ -332,6 +349,19 @@ func (h *Handler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A new address has to be verified again
+	if emailChanged {
+		if err := h.UserService.ClearEmailVerified(userID); err != nil {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update profile")
+			return
+		}
+		user.EmailVerified = false
+		user.EmailVerifiedAt = nil
+		if err := h.sendVerificationEmail(user); err != nil {
+			log.Printf("Failed to send verification email to user %s: %v", userID, err)
+		}
+	}
+
 	// Remove password from response
 	user.Password = ""
 
@@ -356,7 +386,7 @@ func (h *Handler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse multipart form
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
@@ -372,7 +402,7 @@ func (h *Handler) UploadAvatar(w http.ResponseWriter, r *http.Request) {
 	// Save image
 	imagePath, err := utils.SaveImage(file, header, "avatars")
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		utils.RespondWithImageError(w, err)
 		return
 	}
 
@@ -413,7 +443,7 @@ func (h *Handler) UploadCoverPhoto(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse multipart form
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := r.ParseMultipartForm(utils.MaxUploadRequestSize); err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
@@ -429,7 +459,7 @@ func (h *Handler) UploadCoverPhoto(w http.ResponseWriter, r *http.Request) {
 	// Save image
 	imagePath, err := utils.SaveImage(file, header, "covers")
 	if err != nil {
-		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		utils.RespondWithImageError(w, err)
 		return
 	}
 
@@ -480,7 +510,7 @@ func (h *Handler) FollowUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get target user
-	followingUser, err := h.UserService.GetByID(followingID)
+	followingUser, err := h.UserService.GetProfile(followingID, followerID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusNotFound, "User not found")
 		return
@@ -503,6 +533,10 @@ func (h *Handler) FollowUser(w http.ResponseWriter, r *http.Request) {
 	// Create follow relationship
 	follow, err := h.FollowService.Create(followerID, followingID, followingUser.IsPrivate)
 	if err != nil {
+		if err.Error() == "user blocked" {
+			utils.RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to follow user")
 		return
 	}
@@ -599,7 +633,7 @@ func (h *Handler) GetFollowers(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if the target user has a private profile
-	targetUser, err := h.UserService.GetByID(userID)
+	targetUser, err := h.UserService.GetProfile(userID, currentUserID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusNotFound, "User not found")
 		return
@@ -640,7 +674,9 @@ func (h *Handler) GetFollowers(w http.ResponseWriter, r *http.Request) {
 			"fullName":                user.FullName,
 			"bio":                     user.Bio,
 			"profilePicture":          user.ProfilePicture,
+			"profilePictureSet":       utils.NewImageSet(user.ProfilePicture),
 			"coverPhoto":              user.CoverPhoto,
+			"coverPhotoSet":           utils.NewImageSet(user.CoverPhoto),
 			"isPrivate":               user.IsPrivate,
 			"createdAt":               user.CreatedAt,
 			"updatedAt":               user.UpdatedAt,
@@ -709,7 +745,7 @@ func (h *Handler) GetFollowing(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if the target user has a private profile
-	targetUser, err := h.UserService.GetByID(userID)
+	targetUser, err := h.UserService.GetProfile(userID, currentUserID)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusNotFound, "User not found")
 		return
@@ -750,7 +786,9 @@ func (h *Handler) GetFollowing(w http.ResponseWriter, r *http.Request) {
 			"fullName":                user.FullName,
 			"bio":                     user.Bio,
 			"profilePicture":          user.ProfilePicture,
+			"profilePictureSet":       utils.NewImageSet(user.ProfilePicture),
 			"coverPhoto":              user.CoverPhoto,
+			"coverPhotoSet":           utils.NewImageSet(user.CoverPhoto),
 			"isPrivate":               user.IsPrivate,
 			"createdAt":               user.CreatedAt,
 			"updatedAt":               user.UpdatedAt,
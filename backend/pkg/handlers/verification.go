@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/auth"
+	"github.com/bernaotieno/social-network/backend/pkg/mail"
+	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// emailVerificationTokenTTL is how long an email verification link stays valid
+const emailVerificationTokenTTL = 48 * time.Hour
+
+// VerifyEmailRequest represents a request to verify an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// sendVerificationEmail emails a user a signed link proving they own their address.
+// The token carries the address, so it stops working if the email is changed.
+func (h *Handler) sendVerificationEmail(user *models.User) error {
+	token := auth.SignToken(auth.EmailVerificationPurpose, emailVerificationTokenTTL, user.ID, user.Email)
+	verifyURL := strings.TrimRight(h.AppURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+
+	return h.Mailer.Send(&mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm this is your email address by opening the link below. It expires in %s.\n\n%s\n\nUntil you do, you will not be able to post or send messages.\n",
+			user.FirstName, emailVerificationTokenTTL, verifyURL),
+	})
+}
+
+// VerifyEmail handles confirming an email address with a verification token
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	// Parse request body
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Token == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	fields, err := auth.VerifyToken(auth.EmailVerificationPurpose, req.Token)
+	if err != nil || len(fields) != 2 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+	userID, email := fields[0], fields[1]
+
+	user, err := h.UserService.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+
+	// A link sent to a previous address does not verify the current one
+	if !strings.EqualFold(user.Email, email) {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+
+	if err := h.UserService.MarkEmailVerified(userID); err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+	log.Printf("Email verified for user %s", userID)
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Email verified successfully", nil)
+}
+
+// ResendVerificationEmail handles sending the current user a new verification link
+func (h *Handler) ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(r)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	user, err := h.UserService.GetByID(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	if user.EmailVerified {
+		utils.RespondWithError(w, http.StatusBadRequest, "Email is already verified")
+		return
+	}
+
+	if err := h.sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %v", userID, err)
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+
+	utils.RespondWithSuccess(w, http.StatusOK, "Verification email sent", nil)
+}
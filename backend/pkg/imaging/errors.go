@@ -0,0 +1,78 @@
+package imaging
+
+import "fmt"
+
+// Validation error codes
+const (
+	CodeUnsupportedFormat = "unsupported_format"
+	CodeInvalidImage      = "invalid_image"
+	CodeLimitExceeded     = "limit_exceeded"
+)
+
+// The limits an image can exceed
+const (
+	LimitSize   = "size"
+	LimitWidth  = "width"
+	LimitHeight = "height"
+	LimitPixels = "pixels"
+	LimitFrames = "frames"
+)
+
+// limitUnits describes what each limit counts, for error messages
+var limitUnits = map[string]string{
+	LimitSize:   "bytes",
+	LimitWidth:  "pixels wide",
+	LimitHeight: "pixels tall",
+	LimitPixels: "pixels",
+	LimitFrames: "frames",
+}
+
+// ValidationError explains why an uploaded image was refused. It is safe to
+// show to the client that uploaded it.
+type ValidationError struct {
+	Code    string `json:"code"`
+	Format  string `json:"format,omitempty"`
+	Limit   string `json:"limit,omitempty"`
+	Max     int64  `json:"max,omitempty"`
+	Actual  int64  `json:"actual,omitempty"`
+	message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.message
+}
+
+// Errors returned for images that are refused regardless of limits
+var (
+	ErrUnsupportedFormat = &ValidationError{
+		Code:    CodeUnsupportedFormat,
+		message: "invalid file type, only JPEG, PNG, GIF, and WebP are allowed",
+	}
+	ErrInvalidImage = &ValidationError{
+		Code:    CodeInvalidImage,
+		message: "file is not a valid image",
+	}
+)
+
+// limitError reports that an image in format is actual units over a limit of max.
+// An empty format means the limit applies to every format.
+func limitError(format, limit string, max, actual int64) *ValidationError {
+	subject := "image"
+	if format != "" {
+		subject = format + " image"
+	}
+	return &ValidationError{
+		Code:    CodeLimitExceeded,
+		Format:  format,
+		Limit:   limit,
+		Max:     max,
+		Actual:  actual,
+		message: fmt.Sprintf("%s exceeds the %s limit: %d %s, at most %d allowed", subject, limit, actual, limitUnits[limit], max),
+	}
+}
+
+// SizeError reports that an upload is larger than any image is allowed to be,
+// before its format is known
+func SizeError(max, actual int64) *ValidationError {
+	return limitError("", LimitSize, max, actual)
+}
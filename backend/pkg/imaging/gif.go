@@ -0,0 +1,77 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// gifFrames counts the frames of a GIF and the pixels in all of them, which
+// is what decoding it allocates, by walking its blocks without decompressing
+// any image data
+func gifFrames(data []byte) (frames int, pixels int64, err error) {
+	malformed := errors.New("malformed GIF")
+
+	// Header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, 0, malformed
+	}
+	offset := 13
+	if flags := data[10]; flags&0x80 != 0 {
+		offset += 3 << (flags&0x07 + 1) // Global color table
+	}
+
+	for offset < len(data) {
+		switch data[offset] {
+		case 0x2C: // Image descriptor
+			if offset+10 > len(data) {
+				return 0, 0, malformed
+			}
+			width := binary.LittleEndian.Uint16(data[offset+5:])
+			height := binary.LittleEndian.Uint16(data[offset+7:])
+			pixels += int64(width) * int64(height)
+			flags := data[offset+9]
+			offset += 10
+			if flags&0x80 != 0 {
+				offset += 3 << (flags&0x07 + 1) // Local color table
+			}
+			offset++ // LZW minimum code size
+			end, err := skipSubBlocks(data, offset)
+			if err != nil {
+				return 0, 0, err
+			}
+			offset = end
+			frames++
+
+		case 0x21: // Extension
+			end, err := skipSubBlocks(data, offset+2)
+			if err != nil {
+				return 0, 0, err
+			}
+			offset = end
+
+		case 0x3B: // Trailer
+			return frames, pixels, nil
+
+		default:
+			return 0, 0, malformed
+		}
+	}
+
+	// Decoders accept a GIF that is missing its trailer
+	return frames, pixels, nil
+}
+
+// skipSubBlocks returns the offset just past the data sub-blocks starting at offset
+func skipSubBlocks(data []byte, offset int) (int, error) {
+	for {
+		if offset >= len(data) {
+			return 0, errors.New("malformed GIF")
+		}
+		size := int(data[offset])
+		offset++
+		if size == 0 {
+			return offset, nil
+		}
+		offset += size
+	}
+}
@@ -0,0 +1,389 @@
+// Package imaging turns uploaded image bytes into the files that are stored:
+// it checks the bytes really are an image within the limits for its format,
+// drops any metadata by decoding and re-encoding them, and resizes them into
+// the variants served to clients.
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register WebP with image.DecodeConfig
+)
+
+// Variant is one of the sizes an uploaded image is stored in
+type Variant struct {
+	Name    string
+	MaxSize int // Longest side in pixels
+}
+
+// Variants are the sizes images are stored in, smallest first. An image is
+// only stored in a smaller variant when it is larger than that variant.
+var Variants = []Variant{
+	{Name: "thumb", MaxSize: 160},
+	{Name: "medium", MaxSize: 640},
+	{Name: "full", MaxSize: 1600},
+}
+
+// Full is the variant every image is stored in
+var Full = Variants[len(Variants)-1]
+
+// Still is the first frame of an animation, stored alongside it for previews
+// that shouldn't move. The smaller variants of an animation are stills too.
+var Still = Variant{Name: "still", MaxSize: Full.MaxSize}
+
+// StillExtension is the extension the stills of animations are stored with
+const StillExtension = ".png"
+
+// jpegQuality is the quality JPEG variants are encoded with
+const jpegQuality = 85
+
+// formats maps the content types images are accepted in onto their format name
+var formats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// AnimatedExtensions are the extensions only animations are stored with.
+// Still GIFs and WebPs are converted to PNG or JPEG.
+var AnimatedExtensions = map[string]bool{
+	".gif":  true,
+	".webp": true,
+}
+
+// Limits bounds the images accepted in one format
+type Limits struct {
+	MaxSize   int64 `json:"maxSize"`             // Largest file, in bytes
+	MaxWidth  int   `json:"maxWidth"`            // Widest image, in pixels
+	MaxHeight int   `json:"maxHeight"`           // Tallest image, in pixels
+	MaxPixels int   `json:"maxPixels"`           // Most pixels in one frame
+	MaxFrames int   `json:"maxFrames,omitempty"` // Most frames in an animation
+	// Most pixels in all the frames of an animation together, as decoding
+	// them costs memory and time for every frame
+	MaxTotalPixels int64 `json:"maxTotalPixels,omitempty"`
+}
+
+// DefaultLimits returns the limits used for each format when none are configured
+func DefaultLimits() map[string]Limits {
+	return map[string]Limits{
+		"jpeg": {MaxSize: 5 << 20, MaxWidth: 8192, MaxHeight: 8192, MaxPixels: 40_000_000},
+		"png":  {MaxSize: 5 << 20, MaxWidth: 8192, MaxHeight: 8192, MaxPixels: 40_000_000},
+		"gif":  {MaxSize: 5 << 20, MaxWidth: 2048, MaxHeight: 2048, MaxPixels: 2_500_000, MaxFrames: 300, MaxTotalPixels: 50_000_000},
+		"webp": {MaxSize: 5 << 20, MaxWidth: 8192, MaxHeight: 8192, MaxPixels: 40_000_000, MaxFrames: 300, MaxTotalPixels: 100_000_000},
+	}
+}
+
+// FormatLimits are the limits Process enforces, keyed by format name. They
+// are checked before an image is decoded, so a small file can't expand into
+// gigabytes of memory.
+var FormatLimits = DefaultLimits()
+
+// MaxPreviewSize is the largest preview image accepted, in bytes
+const MaxPreviewSize = 1 << 20
+
+// DefaultPreviewLimits returns the limits ProcessPreview enforces by default.
+// Preview images come from other sites and are only shown small, so they are
+// held to less than uploads.
+func DefaultPreviewLimits() map[string]Limits {
+	return map[string]Limits{
+		"jpeg": {MaxSize: MaxPreviewSize, MaxWidth: 4096, MaxHeight: 4096, MaxPixels: 16_000_000},
+		"png":  {MaxSize: MaxPreviewSize, MaxWidth: 4096, MaxHeight: 4096, MaxPixels: 16_000_000},
+		"gif":  {MaxSize: MaxPreviewSize, MaxWidth: 2048, MaxHeight: 2048, MaxPixels: 2_500_000},
+		"webp": {MaxSize: MaxPreviewSize, MaxWidth: 4096, MaxHeight: 4096, MaxPixels: 16_000_000},
+	}
+}
+
+// PreviewLimits are the limits ProcessPreview enforces, keyed by format name
+var PreviewLimits = DefaultPreviewLimits()
+
+// Formats returns the names of the accepted formats
+func Formats() []string {
+	return []string{"jpeg", "png", "gif", "webp"}
+}
+
+// IsSupported reports whether images with a content type are accepted
+func IsSupported(contentType string) bool {
+	_, ok := formats[contentType]
+	return ok
+}
+
+// Output is an encoded variant of an image
+type Output struct {
+	Variant   Variant
+	Extension string
+	Width     int
+	Height    int
+	Data      []byte
+}
+
+// Result is a processed image
+type Result struct {
+	Extension string // Extension of the full variant
+	Width     int    // Size of the full variant
+	Height    int
+	Animated  bool
+	Outputs   []Output
+}
+
+// Process checks that data is an image in one of the accepted formats and
+// within that format's limits, and encodes it into its variants. The format
+// is sniffed from the bytes rather than trusted from the client. Refused
+// images return a *ValidationError.
+func Process(data []byte) (*Result, error) {
+	return process(data, FormatLimits, false)
+}
+
+// ProcessPreview does what Process does for an image fetched from another
+// site, such as a link's preview image. It enforces PreviewLimits, and only
+// the first frame of an animation is decoded and stored, as a still image.
+func ProcessPreview(data []byte) (*Result, error) {
+	return process(data, PreviewLimits, true)
+}
+
+// process checks data against the limits for its format and encodes it.
+// With firstFrameOnly, animations are stored as a still of their first frame.
+func process(data []byte, formatLimits map[string]Limits, firstFrameOnly bool) (*Result, error) {
+	format, ok := formats[http.DetectContentType(data)]
+	if !ok {
+		return nil, ErrUnsupportedFormat
+	}
+
+	limits := formatLimits[format]
+	if int64(len(data)) > limits.MaxSize {
+		return nil, limitError(format, LimitSize, limits.MaxSize, int64(len(data)))
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return nil, ErrInvalidImage
+	}
+	if err := checkDimensions(format, limits, config.Width, config.Height); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "gif":
+		if firstFrameOnly {
+			return processGIFFrame(data, config.Width, config.Height)
+		}
+		return processGIF(data, limits)
+
+	case "webp":
+		return processWebP(data, limits, firstFrameOnly)
+
+	case "jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		// The orientation is lost with the rest of the metadata, so apply it
+		return processStill(orient(img, exifOrientation(data)), ".jpg")
+
+	default:
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		return processStill(img, ".png")
+	}
+}
+
+// checkDimensions checks the size of an image, or of one animation frame,
+// against its format's limits
+func checkDimensions(format string, limits Limits, width, height int) error {
+	if width > limits.MaxWidth {
+		return limitError(format, LimitWidth, int64(limits.MaxWidth), int64(width))
+	}
+	if height > limits.MaxHeight {
+		return limitError(format, LimitHeight, int64(limits.MaxHeight), int64(height))
+	}
+	if width*height > limits.MaxPixels {
+		return limitError(format, LimitPixels, int64(limits.MaxPixels), int64(width*height))
+	}
+	return nil
+}
+
+// checkFrames checks the frame count of an animation, and the pixels in all
+// its frames, against its format's limits
+func checkFrames(format string, limits Limits, frames int, pixels int64) error {
+	if frames > limits.MaxFrames {
+		return limitError(format, LimitFrames, int64(limits.MaxFrames), int64(frames))
+	}
+	if pixels > limits.MaxTotalPixels {
+		return limitError(format, LimitPixels, limits.MaxTotalPixels, pixels)
+	}
+	return nil
+}
+
+// processGIF handles still and animated GIFs. Still ones are stored as PNG.
+func processGIF(data []byte, limits Limits) (*Result, error) {
+	// Count the frames before decoding them all, since each one is allocated
+	frames, pixels, err := gifFrames(data)
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if err := checkFrames("gif", limits, frames, pixels); err != nil {
+		return nil, err
+	}
+
+	animation, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(animation.Image) == 0 {
+		return nil, ErrInvalidImage
+	}
+
+	first := onCanvas(animation.Image[0], animation.Config.Width, animation.Config.Height)
+	if len(animation.Image) == 1 {
+		return processStill(first, ".png")
+	}
+
+	// Re-encoding drops the comments and application extensions
+	var buffer bytes.Buffer
+	if err := gif.EncodeAll(&buffer, animation); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return processAnimation(buffer.Bytes(), ".gif", first)
+}
+
+// processGIFFrame decodes only the first frame of a GIF, stored as a PNG.
+// The other frames are never decoded, so they aren't counted either.
+func processGIFFrame(data []byte, width, height int) (*Result, error) {
+	frame, err := gif.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	return processStill(onCanvas(frame, width, height), ".png")
+}
+
+// onCanvas draws an animation frame where it sits on the animation's canvas
+func onCanvas(frame image.Image, width, height int) image.Image {
+	canvas := image.Rect(0, 0, width, height)
+	img := image.NewNRGBA(canvas)
+	bounds := frame.Bounds().Intersect(canvas)
+	draw.Draw(img, bounds, frame, bounds.Min, draw.Src)
+	return img
+}
+
+// processStill resizes an image into each variant it is larger than
+func processStill(img image.Image, extension string) (*Result, error) {
+	bounds := img.Bounds()
+	width, height := Fit(bounds.Dx(), bounds.Dy(), Full.MaxSize)
+	result := &Result{Extension: extension, Width: width, Height: height}
+
+	for _, variant := range Variants {
+		if !HasVariant(width, height, variant) {
+			continue
+		}
+		output, err := encodeVariant(img, variant, extension)
+		if err != nil {
+			return nil, err
+		}
+		result.Outputs = append(result.Outputs, *output)
+	}
+
+	return result, nil
+}
+
+// processAnimation stores an already stripped animation as the full variant.
+// Animations are not resized; the smaller variants are stills of the first
+// frame, and a full size still is stored for previews.
+func processAnimation(data []byte, extension string, first image.Image) (*Result, error) {
+	bounds := first.Bounds()
+	result := &Result{Extension: extension, Width: bounds.Dx(), Height: bounds.Dy(), Animated: true}
+
+	variants := append(append([]Variant{}, Variants...), Still)
+	for _, variant := range variants {
+		if !HasVariant(result.Width, result.Height, variant) {
+			continue
+		}
+		if variant == Full {
+			result.Outputs = append(result.Outputs, Output{
+				Variant:   Full,
+				Extension: extension,
+				Width:     result.Width,
+				Height:    result.Height,
+				Data:      data,
+			})
+			continue
+		}
+
+		output, err := encodeVariant(first, variant, StillExtension)
+		if err != nil {
+			return nil, err
+		}
+		result.Outputs = append(result.Outputs, *output)
+	}
+
+	return result, nil
+}
+
+// Fit scales width and height down so neither is larger than maxSize,
+// keeping the aspect ratio
+func Fit(width, height, maxSize int) (int, int) {
+	if width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if width >= height {
+		return maxSize, max(1, (height*maxSize+width/2)/width)
+	}
+	return max(1, (width*maxSize+height/2)/height), maxSize
+}
+
+// HasVariant reports whether an image whose full variant is width by height
+// is stored in variant as well
+func HasVariant(width, height int, variant Variant) bool {
+	return variant == Full || variant == Still || width > variant.MaxSize || height > variant.MaxSize
+}
+
+// encodeVariant resizes an image to fit a variant and encodes it
+func encodeVariant(img image.Image, variant Variant, extension string) (*Output, error) {
+	bounds := img.Bounds()
+	width, height := Fit(bounds.Dx(), bounds.Dy(), variant.MaxSize)
+	data, err := encode(resize(img, width, height), extension)
+	if err != nil {
+		return nil, err
+	}
+	return &Output{Variant: variant, Extension: extension, Width: width, Height: height, Data: data}, nil
+}
+
+func resize(img image.Image, width, height int) image.Image {
+	bounds := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if bounds.Dx() == width && bounds.Dy() == height {
+		draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+		return dst
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, extension string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var err error
+	switch extension {
+	case ".jpg":
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: jpegQuality})
+	default:
+		err = png.Encode(&buffer, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// isOpaque reports whether an image has no transparent pixels, so it can be
+// stored as a JPEG
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// manyFrameGIF builds an animated GIF of the given number of full canvas frames
+func manyFrameGIF(t *testing.T, width, height, frames int) []byte {
+	palette := color.Palette{color.Black, color.White}
+	animation := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		animation.Image = append(animation.Image, image.NewPaletted(image.Rect(0, 0, width, height), palette))
+		animation.Delay = append(animation.Delay, 10)
+	}
+
+	var buffer bytes.Buffer
+	if err := gif.EncodeAll(&buffer, animation); err != nil {
+		t.Fatalf("Failed to encode GIF: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+// manyFrameWebP builds an animated WebP whose frame headers claim the given
+// number of full canvas frames. The frame data is not a valid bitstream, as
+// the limits must refuse it before anything is decoded.
+func manyFrameWebP(width, height, frames int) []byte {
+	var body bytes.Buffer
+	header := make([]byte, 10)
+	header[0] = webpFlagAnimation
+	putUint24(header[4:], width-1)
+	putUint24(header[7:], height-1)
+	writeChunk(&body, "VP8X", header)
+	writeChunk(&body, "ANIM", make([]byte, 6))
+
+	for i := 0; i < frames; i++ {
+		frame := make([]byte, 16)
+		putUint24(frame[6:], width-1)
+		putUint24(frame[9:], height-1)
+		var anmf bytes.Buffer
+		anmf.Write(frame)
+		writeChunk(&anmf, "VP8L", []byte{0x2f, 0, 0, 0, 0})
+		writeChunk(&body, "ANMF", anmf.Bytes())
+	}
+
+	return riffWebP(body.Bytes())
+}
+
+func TestProcessRefusesTooManyAnimationPixels(t *testing.T) {
+	defaults := FormatLimits
+	t.Cleanup(func() { FormatLimits = defaults })
+
+	tests := []struct {
+		format string
+		data   []byte
+	}{
+		{"gif", manyFrameGIF(t, 200, 100, 60)},
+		{"webp", manyFrameWebP(200, 100, 60)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			FormatLimits = DefaultLimits()
+			limits := FormatLimits[tt.format]
+			limits.MaxTotalPixels = 1_000_000
+			FormatLimits[tt.format] = limits
+
+			_, err := Process(tt.data)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected a *ValidationError, got %v", err)
+			}
+			if validationErr.Code != CodeLimitExceeded || validationErr.Limit != LimitPixels || validationErr.Format != tt.format {
+				t.Errorf("Expected the %s pixel limit to be exceeded, got %+v", tt.format, validationErr)
+			}
+			if validationErr.Max != 1_000_000 || validationErr.Actual != 200*100*60 {
+				t.Errorf("Expected 1200000 of at most 1000000 pixels, got %d of %d", validationErr.Actual, validationErr.Max)
+			}
+		})
+	}
+}
+
+func TestProcessAcceptsAnimationWithinLimits(t *testing.T) {
+	result, err := Process(manyFrameGIF(t, 200, 100, 60))
+	if err != nil {
+		t.Fatalf("Process failed: %v", err)
+	}
+	if !result.Animated || result.Extension != ".gif" {
+		t.Errorf("Expected an animated GIF, got %+v", result)
+	}
+}
+
+func TestProcessPreviewKeepsOnlyTheFirstFrame(t *testing.T) {
+	result, err := ProcessPreview(manyFrameGIF(t, 200, 100, 60))
+	if err != nil {
+		t.Fatalf("ProcessPreview failed: %v", err)
+	}
+	if result.Animated || result.Extension != ".png" {
+		t.Errorf("Expected a still PNG, got %+v", result)
+	}
+	for _, output := range result.Outputs {
+		if output.Variant == Still || output.Extension != ".png" {
+			t.Errorf("Expected only still PNG variants, got %s%s", output.Variant.Name, output.Extension)
+		}
+	}
+}
+
+func TestProcessPreviewEnforcesPreviewLimits(t *testing.T) {
+	defaults := PreviewLimits
+	t.Cleanup(func() { PreviewLimits = defaults })
+	PreviewLimits = DefaultPreviewLimits()
+	limits := PreviewLimits["gif"]
+	limits.MaxSize = 64
+	PreviewLimits["gif"] = limits
+
+	data := manyFrameGIF(t, 200, 100, 2)
+	if _, err := Process(data); err != nil {
+		t.Fatalf("Expected the upload limits to accept the image, got %v", err)
+	}
+
+	_, err := ProcessPreview(data)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a *ValidationError, got %v", err)
+	}
+	if validationErr.Code != CodeLimitExceeded || validationErr.Limit != LimitSize || validationErr.Max != 64 {
+		t.Errorf("Expected the preview size limit to be exceeded, got %+v", validationErr)
+	}
+}
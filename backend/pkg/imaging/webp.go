@@ -0,0 +1,224 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// VP8X feature flags
+const (
+	webpFlagAnimation = 0x02
+	webpFlagAlpha     = 0x10
+)
+
+// riffChunk is one chunk of a WebP file
+type riffChunk struct {
+	id   string
+	data []byte
+}
+
+// processWebP handles still and animated WebPs. Still ones are decoded and
+// stored as JPEG, or PNG when they have transparency. There is no WebP
+// encoder to resize animations with, so those are rebuilt from their frames
+// with every metadata chunk left out.
+func processWebP(data []byte, limits Limits, firstFrameOnly bool) (*Result, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrInvalidImage
+	}
+	chunks, err := parseChunks(data[12:])
+	if err != nil || len(chunks) == 0 {
+		return nil, ErrInvalidImage
+	}
+
+	if chunks[0].id != "VP8X" || len(chunks[0].data) < 10 || chunks[0].data[0]&webpFlagAnimation == 0 {
+		img, err := decodeWebP(data)
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		return processStillWebP(img)
+	}
+
+	return processAnimatedWebP(chunks, limits, firstFrameOnly)
+}
+
+// processStillWebP stores a still WebP, or the first frame of an animated
+// one, as a JPEG when it is opaque and as a PNG otherwise
+func processStillWebP(img image.Image) (*Result, error) {
+	if isOpaque(img) {
+		return processStill(img, ".jpg")
+	}
+	return processStill(img, ".png")
+}
+
+// processAnimatedWebP handles animated WebPs. With firstFrameOnly, only the
+// first frame is decoded and it is stored as a still.
+func processAnimatedWebP(chunks []riffChunk, limits Limits, firstFrameOnly bool) (*Result, error) {
+	header := chunks[0].data
+	canvas := image.Rect(0, 0, uint24(header[4:])+1, uint24(header[7:])+1)
+
+	var animation []byte
+	var frames []riffChunk
+	for _, chunk := range chunks[1:] {
+		switch chunk.id {
+		case "ANIM":
+			animation = chunk.data
+		case "ANMF":
+			frames = append(frames, chunk)
+		}
+	}
+	if len(animation) < 6 || len(frames) == 0 {
+		return nil, ErrInvalidImage
+	}
+
+	// Every frame is decoded, so add up their sizes from the frame headers first
+	var pixels int64
+	for _, frame := range frames {
+		if len(frame.data) < 16 {
+			return nil, ErrInvalidImage
+		}
+		pixels += int64(uint24(frame.data[6:])+1) * int64(uint24(frame.data[9:])+1)
+	}
+	if !firstFrameOnly {
+		if err := checkFrames("webp", limits, len(frames), pixels); err != nil {
+			return nil, err
+		}
+	}
+
+	var first image.Image
+	var body bytes.Buffer
+	writeChunk(&body, "VP8X", append([]byte{header[0] & (webpFlagAnimation | webpFlagAlpha)}, header[1:10]...))
+	writeChunk(&body, "ANIM", animation[:6])
+
+	for i, frame := range frames {
+		x, y := 2*uint24(frame.data[0:]), 2*uint24(frame.data[3:])
+		bounds := image.Rect(x, y, x+uint24(frame.data[6:])+1, y+uint24(frame.data[9:])+1)
+		if !bounds.In(canvas) {
+			return nil, ErrInvalidImage
+		}
+
+		alpha, bitstream, err := frameChunks(frame.data[16:])
+		if err != nil {
+			return nil, err
+		}
+
+		// Decoding every frame makes sure the whole animation is valid
+		img, err := decodeWebP(standaloneWebP(bounds.Dx(), bounds.Dy(), alpha, bitstream))
+		if err != nil || img.Bounds().Dx() != bounds.Dx() || img.Bounds().Dy() != bounds.Dy() {
+			return nil, ErrInvalidImage
+		}
+		if i == 0 {
+			still := image.NewNRGBA(canvas)
+			draw.Draw(still, bounds, img, img.Bounds().Min, draw.Src)
+			first = still
+			if firstFrameOnly {
+				return processStillWebP(first)
+			}
+		}
+
+		// Keep only the frame header and its image data
+		var anmf bytes.Buffer
+		anmf.Write(frame.data[:16])
+		if alpha != nil {
+			writeChunk(&anmf, alpha.id, alpha.data)
+		}
+		writeChunk(&anmf, bitstream.id, bitstream.data)
+		writeChunk(&body, "ANMF", anmf.Bytes())
+	}
+
+	return processAnimation(riffWebP(body.Bytes()), ".webp", first)
+}
+
+// frameChunks finds the image data of an animation frame: its bitstream and,
+// for a lossy one, an optional alpha channel
+func frameChunks(data []byte) (*riffChunk, *riffChunk, error) {
+	chunks, err := parseChunks(data)
+	if err != nil {
+		return nil, nil, ErrInvalidImage
+	}
+
+	var alpha, bitstream *riffChunk
+	for i := range chunks {
+		switch chunks[i].id {
+		case "ALPH":
+			alpha = &chunks[i]
+		case "VP8 ", "VP8L":
+			bitstream = &chunks[i]
+		}
+	}
+	if bitstream == nil {
+		return nil, nil, ErrInvalidImage
+	}
+	if bitstream.id == "VP8L" {
+		// Lossless frames carry their own alpha
+		alpha = nil
+	}
+	return alpha, bitstream, nil
+}
+
+// standaloneWebP wraps the image data of one animation frame into a WebP file
+func standaloneWebP(width, height int, alpha, bitstream *riffChunk) []byte {
+	var body bytes.Buffer
+	if alpha != nil {
+		header := make([]byte, 10)
+		header[0] = webpFlagAlpha
+		putUint24(header[4:], width-1)
+		putUint24(header[7:], height-1)
+		writeChunk(&body, "VP8X", header)
+		writeChunk(&body, alpha.id, alpha.data)
+	}
+	writeChunk(&body, bitstream.id, bitstream.data)
+	return riffWebP(body.Bytes())
+}
+
+// decodeWebP decodes a still WebP image
+func decodeWebP(data []byte) (image.Image, error) {
+	return webp.Decode(bytes.NewReader(data))
+}
+
+// parseChunks splits the body of a RIFF file into its chunks
+func parseChunks(data []byte) ([]riffChunk, error) {
+	var chunks []riffChunk
+	for offset := 0; offset < len(data); {
+		if offset+8 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		size := int(binary.LittleEndian.Uint32(data[offset+4:]))
+		if size < 0 || size > len(data)-offset-8 {
+			return nil, ErrInvalidImage
+		}
+		chunks = append(chunks, riffChunk{id: string(data[offset : offset+4]), data: data[offset+8 : offset+8+size]})
+		// Chunks are padded to an even size
+		offset += 8 + size + size%2
+	}
+	return chunks, nil
+}
+
+func writeChunk(buffer *bytes.Buffer, id string, data []byte) {
+	buffer.WriteString(id)
+	binary.Write(buffer, binary.LittleEndian, uint32(len(data)))
+	buffer.Write(data)
+	if len(data)%2 == 1 {
+		buffer.WriteByte(0)
+	}
+}
+
+func riffWebP(body []byte) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("RIFF")
+	binary.Write(&buffer, binary.LittleEndian, uint32(4+len(body)))
+	buffer.WriteString("WEBP")
+	buffer.Write(body)
+	return buffer.Bytes()
+}
+
+func uint24(b []byte) int {
+	return int(b[0]) | int(b[1])<<8 | int(b[2])<<16
+}
+
+func putUint24(b []byte, v int) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,62 @@
+package linkpreview
+
+import (
+	"net"
+	"net/netip"
+)
+
+// nonPublicPrefixes are the address ranges that aren't routed on the public
+// internet: private networks, loopback, link-local, shared address space,
+// documentation and benchmarking ranges, multicast and reserved ranges, and
+// the IPv6 translation ranges that can embed any of them
+var nonPublicPrefixes = func() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, prefix := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.0.0.0/24",
+		"192.0.2.0/24",
+		"192.88.99.0/24",
+		"192.168.0.0/16",
+		"198.18.0.0/15",
+		"198.51.100.0/24",
+		"203.0.113.0/24",
+		"224.0.0.0/4",
+		"240.0.0.0/4",
+		"::/128",
+		"::1/128",
+		"64:ff9b::/96",
+		"64:ff9b:1::/48",
+		"100::/64",
+		"2001::/32",
+		"2001:db8::/32",
+		"2002::/16",
+		"fc00::/7",
+		"fe80::/10",
+		"fec0::/10",
+		"ff00::/8",
+	} {
+		prefixes = append(prefixes, netip.MustParsePrefix(prefix))
+	}
+	return prefixes
+}()
+
+// IsPublicIP reports whether ip is routed on the public internet. IPv4
+// addresses written as IPv6 are checked as IPv4.
+func IsPublicIP(ip net.IP) bool {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
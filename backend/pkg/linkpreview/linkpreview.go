@@ -0,0 +1,352 @@
+// Package linkpreview unfurls the links people paste into posts and
+// messages, fetching the title, description and image the linked page
+// describes itself with in its OpenGraph and Twitter card tags.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits on what is kept from a page, in characters
+const (
+	MaxURLLength         = 2048
+	MaxTitleLength       = 300
+	MaxDescriptionLength = 1000
+	MaxSiteNameLength    = 100
+)
+
+var (
+	// ErrInvalidURL is returned for URLs that aren't absolute http or https URLs
+	ErrInvalidURL = errors.New("not an http or https URL")
+	// ErrBlockedAddress is returned for pages on addresses that aren't
+	// publicly routable, so links can't be used to probe internal services
+	ErrBlockedAddress = errors.New("address is not publicly routable")
+	// ErrNotHTML is returned for links to anything but a web page
+	ErrNotHTML = errors.New("not an HTML page")
+	// ErrNoPreview is returned for pages that don't describe themselves
+	ErrNoPreview = errors.New("page has no preview")
+)
+
+// Preview is what a page says about itself
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	SiteName    string
+	ImageURL    string
+	// Image is the preview image as fetched, or nil if the page has none or
+	// it couldn't be fetched. It hasn't been checked to be an image.
+	Image []byte
+}
+
+// Fetcher fetches the preview of a page
+type Fetcher interface {
+	Fetch(ctx context.Context, pageURL string) (*Preview, error)
+}
+
+// HTTPFetcher fetches previews over the internet. It only connects to
+// publicly routable addresses, checking every address it dials, including
+// those redirects lead to and those hostnames resolve to.
+type HTTPFetcher struct {
+	Timeout      time.Duration // How long fetching a page and its image may take in all
+	MaxPageSize  int64         // How much of a page is read looking for its tags, in bytes
+	MaxImageSize int64         // Largest preview image fetched, in bytes
+	UserAgent    string
+	// AllowPrivateNetworks lets pages on loopback and private addresses be
+	// fetched, for tests against a local server. Never set it in production.
+	AllowPrivateNetworks bool
+
+	client *http.Client
+}
+
+// maxRedirects is how many redirects are followed from a link
+const maxRedirects = 5
+
+// NewHTTPFetcher creates a new HTTPFetcher
+func NewHTTPFetcher(timeout time.Duration, maxPageSize, maxImageSize int64) *HTTPFetcher {
+	f := &HTTPFetcher{
+		Timeout:      timeout,
+		MaxPageSize:  maxPageSize,
+		MaxImageSize: maxImageSize,
+		UserAgent:    "SocialNetworkBot/1.0 (link preview)",
+	}
+
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: f.checkAddress,
+	}
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			// Proxies from the environment would dial on the fetcher's behalf,
+			// out of reach of the address check
+			Proxy:                  nil,
+			DialContext:            dialer.DialContext,
+			TLSHandshakeTimeout:    timeout,
+			ResponseHeaderTimeout:  timeout,
+			MaxResponseHeaderBytes: 64 << 10,
+			MaxIdleConns:           10,
+			IdleConnTimeout:        30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidURL
+			}
+			return nil
+		},
+	}
+
+	return f
+}
+
+// checkAddress refuses connections to addresses that aren't publicly
+// routable. It runs once the hostname has been resolved, so a name can't
+// resolve to a public address when checked and a private one when dialed.
+func (f *HTTPFetcher) checkAddress(network, address string, _ syscall.RawConn) error {
+	if f.AllowPrivateNetworks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !IsPublicIP(ip) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// Fetch fetches a page and its preview image and reads its preview
+func (f *HTTPFetcher) Fetch(ctx context.Context, pageURL string) (*Preview, error) {
+	page, err := NormalizeURL(pageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.Timeout)
+	defer cancel()
+
+	resp, err := f.get(ctx, page, "text/html,application/xhtml+xml")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	// The tags are in the head, so a truncated page still has them
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxPageSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	preview := ParseHTML(strings.ToValidUTF8(string(body), ""), resp.Request.URL)
+	if preview.Title == "" {
+		return nil, ErrNoPreview
+	}
+	preview.URL = page
+
+	// A page is still worth a preview without its image
+	if preview.ImageURL != "" {
+		preview.Image, _ = f.fetchImage(ctx, preview.ImageURL)
+	}
+
+	return preview, nil
+}
+
+// fetchImage fetches a preview image, refusing anything larger than
+// MaxImageSize or not served as an image
+func (f *HTTPFetcher) fetchImage(ctx context.Context, imageURL string) ([]byte, error) {
+	resp, err := f.get(ctx, imageURL, "image/*")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "image/") {
+		return nil, errors.New("not an image")
+	}
+	if resp.ContentLength > f.MaxImageSize {
+		return nil, errors.New("image is too large")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxImageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(data)) > f.MaxImageSize {
+		return nil, errors.New("image is too large")
+	}
+
+	return data, nil
+}
+
+// get sends a GET request, failing for anything but a 200 response
+func (f *HTTPFetcher) get(ctx context.Context, target, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	req.Header.Set("Accept", accept)
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBlockedAddress) {
+			return nil, ErrBlockedAddress
+		}
+		return nil, fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s: %s", target, resp.Status)
+	}
+
+	return resp, nil
+}
+
+var (
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attributePattern = regexp.MustCompile(`(?s)([A-Za-z_:.-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titlePattern     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// ParseHTML reads the preview of a page from its OpenGraph and Twitter card
+// tags, falling back to its title and description. Relative image URLs are
+// resolved against base, the URL the page was fetched from.
+func ParseHTML(page string, base *url.URL) *Preview {
+	// Tags by their property or name, keeping the first of each
+	tags := make(map[string]string)
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		var key, content string
+		for _, attribute := range attributePattern.FindAllStringSubmatch(tag, -1) {
+			value := attribute[2] + attribute[3] + attribute[4]
+			switch strings.ToLower(attribute[1]) {
+			case "property", "name":
+				key = strings.ToLower(strings.TrimSpace(value))
+			case "content":
+				content = value
+			}
+		}
+		if _, seen := tags[key]; key != "" && !seen {
+			tags[key] = cleanText(content)
+		}
+	}
+	if match := titlePattern.FindStringSubmatch(page); match != nil {
+		tags["<title>"] = cleanText(match[1])
+	}
+
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if value := tags[key]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	preview := &Preview{
+		Title:       truncate(first("og:title", "twitter:title", "<title>"), MaxTitleLength),
+		Description: truncate(first("og:description", "twitter:description", "description"), MaxDescriptionLength),
+		SiteName:    truncate(first("og:site_name", "application-name"), MaxSiteNameLength),
+	}
+
+	if image := first("og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src"); image != "" {
+		if imageURL, err := base.Parse(image); err == nil && (imageURL.Scheme == "http" || imageURL.Scheme == "https") {
+			preview.ImageURL = imageURL.String()
+		}
+	}
+
+	return preview
+}
+
+// cleanText unescapes the text of a tag and collapses its whitespace
+func cleanText(text string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// truncate shortens text to at most max characters
+func truncate(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return strings.TrimSpace(string([]rune(text)[:max-1])) + "…"
+}
+
+// urlPattern matches the links in text. What ends them is settled by FindURLs.
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"'` + "`" + `]+`)
+
+// FindURLs returns the links in text, normalized, each once, in the order
+// they first appear, stopping after max
+func FindURLs(text string, max int) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, match := range urlPattern.FindAllString(text, -1) {
+		if len(urls) == max {
+			break
+		}
+		link, err := NormalizeURL(trimURL(match))
+		if err != nil || seen[link] {
+			continue
+		}
+		seen[link] = true
+		urls = append(urls, link)
+	}
+	return urls
+}
+
+// trimURL drops the punctuation a sentence puts after a link, and closing
+// brackets that aren't part of it
+func trimURL(link string) string {
+	for {
+		trimmed := strings.TrimRight(link, ".,:;!?*")
+		for _, pair := range []string{"()", "[]"} {
+			if strings.HasSuffix(trimmed, pair[1:]) && strings.Count(trimmed, pair[:1]) < strings.Count(trimmed, pair[1:]) {
+				trimmed = trimmed[:len(trimmed)-1]
+			}
+		}
+		if trimmed == link {
+			return link
+		}
+		link = trimmed
+	}
+}
+
+// NormalizeURL checks that link is an absolute http or https URL and puts
+// it in the form previews are cached by, without its fragment
+func NormalizeURL(link string) (string, error) {
+	if len(link) > MaxURLLength {
+		return "", ErrInvalidURL
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" || parsed.User != nil {
+		return "", ErrInvalidURL
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", ErrInvalidURL
+	}
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	return parsed.String(), nil
+}
@@ -0,0 +1,76 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Message is an email to send
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(message *Message) error
+}
+
+// LogMailer writes emails to the application log instead of sending them
+type LogMailer struct {
+	From string
+}
+
+// NewLogMailer creates a new LogMailer
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{From: from}
+}
+
+// Send logs the email
+func (m *LogMailer) Send(message *Message) error {
+	log.Printf("Mail from %s to %s: %s\n%s", m.From, message.To, message.Subject, message.Body)
+	return nil
+}
+
+// FileMailer drops each email into a directory as a .eml file, for testing
+// mail flows locally without a mail server
+type FileMailer struct {
+	From string
+	Dir  string
+}
+
+// NewFileMailer creates a new FileMailer writing into dir
+func NewFileMailer(from, dir string) *FileMailer {
+	return &FileMailer{From: from, Dir: dir}
+}
+
+// Send writes the email to a new file in the drop directory
+func (m *FileMailer) Send(message *Message) error {
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	now := time.Now()
+	var content strings.Builder
+	fmt.Fprintf(&content, "From: %s\r\n", m.From)
+	fmt.Fprintf(&content, "To: %s\r\n", message.To)
+	fmt.Fprintf(&content, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&content, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	content.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	content.WriteString(message.Body)
+
+	// Timestamped names keep the drop directory in the order mail was sent
+	filename := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000"), uuid.New().String())
+	if err := os.WriteFile(filepath.Join(m.Dir, filename), []byte(content.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+
+	return nil
+}
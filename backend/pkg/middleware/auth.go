@@ -18,6 +18,9 @@ type contextKey string
 // UserIDKey is the key used to store the user ID in the request context
 const UserIDKey contextKey = "userID"
 
+// SessionIDKey is the key used to store the authenticated session ID in the request context
+const SessionIDKey contextKey = "sessionID"
+
 // DBKey is the key used to store the database connection in the request context
 const DBKey contextKey = "db"
 
@@ -38,8 +41,8 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			userID, err := auth.ValidateSession(r.Context(), db, sessionID)
 			if err == nil {
 				log.Printf("Auth middleware: Session valid for user %s", userID)
-				// Add user ID to request context
-				ctx := context.WithValue(r.Context(), UserIDKey, userID)
+				// Add user ID and session ID to request context
+				ctx := withSession(r.Context(), userID, sessionID)
 				next(w, r.WithContext(ctx))
 				return
 			} else {
@@ -76,8 +79,8 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		}
 		log.Printf("Auth middleware - Token validation successful for user: %s", userID)
 
-		// Add user ID to request context
-		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		// Add user ID and session ID to request context
+		ctx := withSession(r.Context(), userID, parts[1])
 		next(w, r.WithContext(ctx))
 	}
 }
@@ -103,8 +106,8 @@ func WebSocketAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			userID, err := auth.ValidateSession(r.Context(), db, sessionID)
 			if err == nil {
 				log.Printf("WebSocket auth: Session valid for user %s", userID)
-				// Add user ID to request context
-				ctx := context.WithValue(r.Context(), UserIDKey, userID)
+				// Add user ID and session ID to request context
+				ctx := withSession(r.Context(), userID, sessionID)
 				next(w, r.WithContext(ctx))
 				return
 			}
@@ -137,8 +140,8 @@ func WebSocketAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 				userID, err := auth.ValidateSession(r.Context(), db, parts[1])
 				if err == nil {
 					log.Printf("WebSocket auth: Token valid for user %s", userID)
-					// Add user ID to request context
-					ctx := context.WithValue(r.Context(), UserIDKey, userID)
+					// Add user ID and session ID to request context
+					ctx := withSession(r.Context(), userID, parts[1])
 					next(w, r.WithContext(ctx))
 					return
 				}
@@ -163,6 +166,21 @@ func GetUserID(r *http.Request) (string, error) {
 	return userID, nil
 }
 
+// GetSessionID extracts the authenticated session ID from the request context
+func GetSessionID(r *http.Request) (string, error) {
+	sessionID, ok := r.Context().Value(SessionIDKey).(string)
+	if !ok {
+		return "", errors.New("session ID not found in context")
+	}
+	return sessionID, nil
+}
+
+// withSession stores the authenticated user and session IDs in a context
+func withSession(ctx context.Context, userID, sessionID string) context.Context {
+	ctx = context.WithValue(ctx, UserIDKey, userID)
+	return context.WithValue(ctx, SessionIDKey, sessionID)
+}
+
 // DBMiddleware adds the database connection to the request context
 func DBMiddleware(db *sql.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
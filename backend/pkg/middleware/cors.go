@@ -4,46 +4,46 @@ import (
 	"net/http"
 )
 
-// CORSMiddleware adds CORS headers to responses
-func CORSMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		origin := r.Header.Get("Origin")
+// CORS returns a middleware adding CORS headers for requests from the given origins
+func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+
+			// For Electron apps, origin might be null or empty, so allow those too
+			if origin == "" || origin == "null" {
+				w.Header().Set("Access-Control-Allow-Origin", "*") // Allow all for desktop app
+			} else if OriginAllowed(allowedOrigins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Add("Vary", "Origin")
+			}
 
-		// Allow requests from frontend and desktop-messenger
-		allowedOrigins := []string{
-			"http://localhost:3000", // Frontend URL
-			"file://",               // Electron app
-			"http://localhost",      // Local development
-			"https://localhost",     // HTTPS local development
-		}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
-		// Check if the origin is allowed or if it's a file:// protocol request
-		isAllowed := false
-		for _, allowedOrigin := range allowedOrigins {
-			if origin == allowedOrigin || (allowedOrigin == "file://" && (origin == "" || origin == "null")) {
-				isAllowed = true
-				break
+			// Handle preflight requests
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
 			}
-		}
-
-		// For Electron apps, origin might be null or empty, so allow those too
-		if origin == "" || origin == "null" || isAllowed {
-			w.Header().Set("Access-Control-Allow-Origin", "*") // Allow all for desktop app
-		} else {
-			w.Header().Set("Access-Control-Allow-Origin", origin)
-		}
 
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+			// Call the next handler
+			next.ServeHTTP(w, r)
+		})
+	}
+}
 
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+// OriginAllowed reports whether origin is one of allowedOrigins. A "*" entry
+// allows every origin, and "file://" allows the desktop app's null origin.
+func OriginAllowed(allowedOrigins []string, origin string) bool {
+	for _, allowedOrigin := range allowedOrigins {
+		if allowedOrigin == "*" || origin == allowedOrigin {
+			return true
 		}
-
-		// Call the next handler
-		next.ServeHTTP(w, r)
-	})
+		if allowedOrigin == "file://" && (origin == "" || origin == "null") {
+			return true
+		}
+	}
+	return false
 }
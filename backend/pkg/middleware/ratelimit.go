@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/bernaotieno/social-network/backend/pkg/auth"
+	"github.com/bernaotieno/social-network/backend/pkg/ratelimit"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// KeyFunc picks the key a request is rate limited under
+type KeyFunc func(r *http.Request) string
+
+// ClientIPKey rate limits requests by the client's IP address
+func ClientIPKey(r *http.Request) string {
+	return "ip:" + auth.ClientIP(r)
+}
+
+// UserKey rate limits requests by the authenticated user, falling back to the
+// client's IP address when there is none. Wrap it inside AuthMiddleware.
+func UserKey(r *http.Request) string {
+	if userID, err := GetUserID(r); err == nil {
+		return UserKeyFor(userID)
+	}
+	return ClientIPKey(r)
+}
+
+// UserKeyFor returns the key UserKey uses for userID, for rate limiting
+// actions that do not arrive as HTTP requests
+func UserKeyFor(userID string) string {
+	return "user:" + userID
+}
+
+// RateLimitMiddleware refuses requests beyond the limiter's limit with a 429 response
+func RateLimitMiddleware(limiter *ratelimit.Limiter, key KeyFunc) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			allowed, retryAfter, err := limiter.Allow(key(r))
+			if err != nil {
+				// Fail open so a broken store does not take the API down
+				log.Printf("Rate limit check failed: %v", err)
+			} else if !allowed {
+				utils.RespondWithRateLimit(w, retryAfter, "Too many requests, please try again later")
+				return
+			}
+
+			next(w, r)
+		}
+	}
+}
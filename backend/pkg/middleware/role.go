@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// UserRoleKey is the key used to store the authenticated user's platform role in the request context
+const UserRoleKey contextKey = "userRole"
+
+// RequireRole only lets users holding one of roles through, and stores the
+// user's role in the request context. Wrap it inside AuthMiddleware.
+func RequireRole(roles ...models.UserRole) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Get database connection from context
+			db, ok := r.Context().Value(DBKey).(*sql.DB)
+			if !ok {
+				utils.RespondWithError(w, http.StatusInternalServerError, "Database connection not found")
+				return
+			}
+
+			userID, err := GetUserID(r)
+			if err != nil {
+				utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+
+			// The role is read on every request so a demotion takes effect at once
+			user, err := models.NewUserService(db).GetByID(userID)
+			if err != nil {
+				log.Printf("Failed to get role for user %s: %v", userID, err)
+				utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user")
+				return
+			}
+
+			role := models.UserRole(user.Role)
+			for _, allowed := range roles {
+				if role == allowed {
+					ctx := context.WithValue(r.Context(), UserRoleKey, role)
+					next(w, r.WithContext(ctx))
+					return
+				}
+			}
+
+			utils.RespondWithError(w, http.StatusForbidden, "Forbidden: insufficient permissions")
+		}
+	}
+}
+
+// GetUserRole extracts the platform role stored by RequireRole from the request context
+func GetUserRole(r *http.Request) (models.UserRole, error) {
+	role, ok := r.Context().Value(UserRoleKey).(models.UserRole)
+	if !ok {
+		return "", errors.New("user role not found in context")
+	}
+	return role, nil
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// VerifiedEmailMiddleware only lets users who have verified their email address
+// through. Wrap it inside AuthMiddleware.
+func VerifiedEmailMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Get database connection from context
+		db, ok := r.Context().Value(DBKey).(*sql.DB)
+		if !ok {
+			utils.RespondWithError(w, http.StatusInternalServerError, "Database connection not found")
+			return
+		}
+
+		userID, err := GetUserID(r)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		verified, err := models.NewUserService(db).IsEmailVerified(userID)
+		if err != nil {
+			log.Printf("Failed to check email verification for user %s: %v", userID, err)
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to check email verification")
+			return
+		}
+		if !verified {
+			utils.RespondWithError(w, http.StatusForbidden, "Please verify your email address first")
+			return
+		}
+
+		next(w, r)
+	}
+}
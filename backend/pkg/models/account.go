@@ -0,0 +1,415 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// AccountService handles deleting accounts and gathering a user's data for export
+type AccountService struct {
+	DB *sql.DB
+}
+
+// NewAccountService creates a new AccountService
+func NewAccountService(db *sql.DB) *AccountService {
+	return &AccountService{DB: db}
+}
+
+// ScheduleDeletion marks an account to be deleted at the given time
+func (s *AccountService) ScheduleDeletion(userID string, at time.Time) error {
+	result, err := s.DB.Exec(`
+		UPDATE users SET deletion_scheduled_at = ?, updated_at = ?
+		WHERE id = ? AND deletion_scheduled_at IS NULL
+	`, at, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to schedule account deletion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("deletion already scheduled")
+	}
+
+	return nil
+}
+
+// CancelDeletion keeps an account that was scheduled for deletion
+func (s *AccountService) CancelDeletion(userID string) error {
+	result, err := s.DB.Exec(`
+		UPDATE users SET deletion_scheduled_at = NULL, updated_at = ?
+		WHERE id = ? AND deletion_scheduled_at IS NOT NULL
+	`, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel account deletion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("no deletion scheduled")
+	}
+
+	return nil
+}
+
+// GetDeletionSchedule returns when an account will be deleted, or nil if it won't be
+func (s *AccountService) GetDeletionSchedule(userID string) (*time.Time, error) {
+	var scheduledAt sql.NullTime
+	err := s.DB.QueryRow("SELECT deletion_scheduled_at FROM users WHERE id = ?", userID).Scan(&scheduledAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("user not found")
+		}
+		return nil, fmt.Errorf("failed to get deletion schedule: %w", err)
+	}
+
+	if !scheduledAt.Valid {
+		return nil, nil
+	}
+	return &scheduledAt.Time, nil
+}
+
+// DueForDeletion lists the accounts whose grace period ended by now
+func (s *AccountService) DueForDeletion(now time.Time) ([]string, error) {
+	rows, err := s.DB.Query(`
+		SELECT id FROM users
+		WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?
+		ORDER BY deletion_scheduled_at
+	`, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts due for deletion: %w", err)
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("failed to scan user ID: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating accounts due for deletion: %w", err)
+	}
+
+	return userIDs, nil
+}
+
+// Delete removes an account and everything that belongs to it. Groups the
+// user created are handed to another member first, the way TransferOwnership
+// picks one, and are only deleted when nobody else is left in them.
+func (s *AccountService) Delete(userID string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT id FROM groups WHERE creator_id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to get created groups: %w", err)
+	}
+	var groupIDs []string
+	for rows.Next() {
+		var groupID string
+		if err := rows.Scan(&groupID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan group ID: %w", err)
+		}
+		groupIDs = append(groupIDs, groupID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating created groups: %w", err)
+	}
+
+	now := time.Now()
+	for _, groupID := range groupIDs {
+		// Admins come first, then whoever posted most, then the longest-standing member
+		var successorID string
+		err := tx.QueryRow(`
+			SELECT gm.user_id
+			FROM group_members gm
+			LEFT JOIN group_posts gp ON gm.user_id = gp.user_id AND gm.group_id = gp.group_id
+			WHERE gm.group_id = ? AND gm.user_id != ? AND gm.status = 'accepted'
+			GROUP BY gm.user_id
+			ORDER BY gm.role IN ('creator', 'admin') DESC, COUNT(gp.id) DESC, gm.created_at ASC
+			LIMIT 1
+		`, groupID, userID).Scan(&successorID)
+		if err == sql.ErrNoRows {
+			// Nobody left to hand it to; the group goes with its creator
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find a new group owner: %w", err)
+		}
+
+		if _, err := tx.Exec("UPDATE groups SET creator_id = ?, updated_at = ? WHERE id = ?", successorID, now, groupID); err != nil {
+			return fmt.Errorf("failed to transfer group: %w", err)
+		}
+		_, err = tx.Exec(`
+			UPDATE group_members SET role = ?, updated_at = ?
+			WHERE group_id = ? AND user_id = ?
+		`, GroupMemberRoleCreator, now, groupID, successorID)
+		if err != nil {
+			return fmt.Errorf("failed to promote new group owner: %w", err)
+		}
+	}
+
+	// Foreign keys cascade to the rest of the user's rows, and the post
+	// triggers take the comments and likes on deleted posts with them
+	result, err := tx.Exec("DELETE FROM users WHERE id = ?", userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// uploadColumns are the columns that hold paths of uploaded images, with
+// the column holding who uploaded them. Images copied from elsewhere have
+// no owner.
+var uploadColumns = []struct{ table, column, owner string }{
+	{"users", "profile_picture", "id"},
+	{"users", "cover_photo", "id"},
+	{"posts", "image", "user_id"},
+	{"group_posts", "image", "user_id"},
+	{"comments", "image", "user_id"},
+	{"groups", "cover_photo", "creator_id"},
+	{"link_previews", "image", ""},
+}
+
+// ReferencedUploads returns the path of every uploaded image still in use
+func (s *AccountService) ReferencedUploads() (map[string]bool, error) {
+	return s.uploads("")
+}
+
+// UserUploads returns the paths of the images a user uploaded
+func (s *AccountService) UserUploads(userID string) ([]string, error) {
+	uploads, err := s.uploads(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(uploads))
+	for path := range uploads {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// uploads returns the uploaded image paths in use, only counting userID's
+// when it is not empty
+func (s *AccountService) uploads(userID string) (map[string]bool, error) {
+	var selects []string
+	var args []interface{}
+	for _, c := range uploadColumns {
+		if userID != "" && c.owner == "" {
+			continue
+		}
+		query := fmt.Sprintf("SELECT %[1]s FROM %[2]s WHERE %[1]s IS NOT NULL AND %[1]s != ''", c.column, c.table)
+		if userID != "" {
+			query += fmt.Sprintf(" AND %s = ?", c.owner)
+			args = append(args, userID)
+		}
+		selects = append(selects, query)
+	}
+
+	rows, err := s.DB.Query(strings.Join(selects, " UNION "), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uploads: %w", err)
+	}
+	defer rows.Close()
+
+	uploads := make(map[string]bool)
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan upload: %w", err)
+		}
+		uploads[path] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating uploads: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// ExportSection is one file of a data export: a named list of records, or a
+// single record when Single is set
+type ExportSection struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	Single      bool                     `json:"-"`
+	Records     []map[string]interface{} `json:"-"`
+}
+
+// exportQueries are the sections of a data export, each taking the user's ID
+// once per placeholder
+var exportQueries = []struct {
+	name, description string
+	single            bool
+	query             string
+	userArgs          int
+}{
+	{"profile", "Your profile", true, `
+		SELECT id, username, email, full_name, first_name, last_name, date_of_birth, bio,
+			profile_picture, cover_photo, is_private, role, email_verified_at, created_at, updated_at
+		FROM users WHERE id = ?`, 1},
+	{"posts", "Posts you published on your profile", false, `
+		SELECT id, content, image, visibility, created_at, updated_at, edited_at
+		FROM posts WHERE user_id = ? ORDER BY created_at`, 1},
+	{"group_posts", "Posts you published in groups", false, `
+		SELECT gp.id, gp.group_id, g.name AS group_name, gp.content, gp.image, gp.created_at, gp.updated_at, gp.edited_at
+		FROM group_posts gp JOIN groups g ON gp.group_id = g.id
+		WHERE gp.user_id = ? ORDER BY gp.created_at`, 1},
+	{"comments", "Comments you wrote", false, `
+		SELECT id, post_id, content, image, created_at, updated_at, edited_at
+		FROM comments WHERE user_id = ? ORDER BY created_at`, 1},
+	{"revisions", "What your posts and comments said before you edited them", false, `
+		SELECT target_type, target_id, content, replaced_at
+		FROM content_revisions
+		WHERE (target_type = 'post' AND target_id IN (SELECT id FROM posts WHERE user_id = ?))
+			OR (target_type = 'group_post' AND target_id IN (SELECT id FROM group_posts WHERE user_id = ?))
+			OR (target_type = 'comment' AND target_id IN (SELECT id FROM comments WHERE user_id = ?))
+		ORDER BY replaced_at`, 3},
+	{"likes", "Your reactions to posts", false, `
+		SELECT post_id, reaction, created_at
+		FROM likes WHERE user_id = ? ORDER BY created_at`, 1},
+	{"followers", "People who follow you or asked to", false, `
+		SELECT u.id AS user_id, u.username, f.status, f.created_at
+		FROM follows f JOIN users u ON f.follower_id = u.id
+		WHERE f.following_id = ? ORDER BY f.created_at`, 1},
+	{"following", "People you follow or asked to follow", false, `
+		SELECT u.id AS user_id, u.username, f.status, f.created_at
+		FROM follows f JOIN users u ON f.following_id = u.id
+		WHERE f.follower_id = ? ORDER BY f.created_at`, 1},
+	{"groups", "Groups you created", false, `
+		SELECT id, name, description, cover_photo, privacy, created_at, updated_at
+		FROM groups WHERE creator_id = ? ORDER BY created_at`, 1},
+	{"group_memberships", "Groups you belong to, were invited to or asked to join", false, `
+		SELECT gm.group_id, g.name AS group_name, gm.role, gm.status, gm.created_at
+		FROM group_members gm JOIN groups g ON gm.group_id = g.id
+		WHERE gm.user_id = ? ORDER BY gm.created_at`, 1},
+	{"events", "Group events you created", false, `
+		SELECT id, group_id, title, description, location, start_time, end_time, created_at, updated_at
+		FROM events WHERE creator_id = ? ORDER BY created_at`, 1},
+	{"event_responses", "Your answers to group events", false, `
+		SELECT er.event_id, e.title AS event_title, er.response, er.created_at, er.updated_at
+		FROM event_responses er JOIN events e ON er.event_id = e.id
+		WHERE er.user_id = ? ORDER BY er.created_at`, 1},
+	{"messages", "Direct messages you sent or received and group messages you sent", false, `
+		SELECT id, sender_id, receiver_id, group_id, content, created_at, read_at, edited_at
+		FROM messages
+		WHERE (sender_id = ? OR receiver_id = ?) AND deleted_at IS NULL
+		ORDER BY created_at`, 2},
+}
+
+// CollectExport gathers everything a data export contains for a user
+func (s *AccountService) CollectExport(userID string) ([]*ExportSection, error) {
+	sections := make([]*ExportSection, 0, len(exportQueries))
+	for _, q := range exportQueries {
+		args := make([]interface{}, q.userArgs)
+		for i := range args {
+			args[i] = userID
+		}
+
+		records, err := s.queryRecords(q.query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", q.name, err)
+		}
+		if q.single && len(records) == 0 {
+			return nil, errors.New("user not found")
+		}
+
+		sections = append(sections, &ExportSection{
+			Name:        q.name,
+			Description: q.description,
+			Single:      q.single,
+			Records:     records,
+		})
+	}
+
+	return sections, nil
+}
+
+// queryRecords runs a query and returns each row keyed by its camelCased column names
+func (s *AccountService) queryRecords(query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(columns))
+	for i, column := range columns {
+		keys[i] = camelCase(column)
+	}
+
+	records := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				value = string(b)
+			}
+			record[keys[i]] = value
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// camelCase turns a snake_case column name into the camelCase used in JSON
+func camelCase(column string) string {
+	parts := strings.Split(column, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			runes := []rune(parts[i])
+			runes[0] = unicode.ToUpper(runes[0])
+			parts[i] = string(runes)
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// BlockService handles blocking and muting between users
+type BlockService struct {
+	DB *sql.DB
+}
+
+// NewBlockService creates a new BlockService
+func NewBlockService(db *sql.DB) *BlockService {
+	return &BlockService{DB: db}
+}
+
+// notBlockedCondition matches rows whose userColumn is on neither side of a
+// block with viewerID
+func notBlockedCondition(userColumn, viewerID string) (string, []interface{}) {
+	return `NOT EXISTS (
+		SELECT 1 FROM user_blocks ub
+		WHERE (ub.blocker_id = ? AND ub.blocked_id = ` + userColumn + `)
+			OR (ub.blocker_id = ` + userColumn + ` AND ub.blocked_id = ?)
+	)`, []interface{}{viewerID, viewerID}
+}
+
+// notMutedCondition matches rows whose userColumn viewerID has not muted
+func notMutedCondition(userColumn, viewerID string) (string, []interface{}) {
+	return `NOT EXISTS (
+		SELECT 1 FROM user_mutes um
+		WHERE um.muter_id = ? AND um.muted_id = ` + userColumn + `
+	)`, []interface{}{viewerID}
+}
+
+// isBlockedBetween reports whether either user has blocked the other
+func isBlockedBetween(db *sql.DB, userID, otherUserID string) (bool, error) {
+	var exists bool
+	err := db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+		)
+	`, userID, otherUserID, otherUserID, userID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %w", err)
+	}
+	return exists, nil
+}
+
+// Block blocks blockedID on behalf of blockerID and removes any follow
+// relationship between the two, in either direction
+func (s *BlockService) Block(blockerID, blockedID string) error {
+	if blockerID == blockedID {
+		return errors.New("cannot block yourself")
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+	`, blockerID, blockedID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("user already blocked")
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM follows
+		WHERE (follower_id = ? AND following_id = ?) OR (follower_id = ? AND following_id = ?)
+	`, blockerID, blockedID, blockedID, blockerID)
+	if err != nil {
+		return fmt.Errorf("failed to remove follows: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Unblock lifts a block blockerID placed on blockedID
+func (s *BlockService) Unblock(blockerID, blockedID string) error {
+	result, err := s.DB.Exec(`
+		DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?
+	`, blockerID, blockedID)
+	if err != nil {
+		return fmt.Errorf("failed to unblock user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("user not blocked")
+	}
+
+	return nil
+}
+
+// IsBlocked reports whether either user has blocked the other
+func (s *BlockService) IsBlocked(userID, otherUserID string) (bool, error) {
+	return isBlockedBetween(s.DB, userID, otherUserID)
+}
+
+// HasBlocked reports whether blockerID has blocked blockedID
+func (s *BlockService) HasBlocked(blockerID, blockedID string) (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?)
+	`, blockerID, blockedID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %w", err)
+	}
+	return exists, nil
+}
+
+// GetBlockedUsers retrieves the users userID has blocked, most recent first
+func (s *BlockService) GetBlockedUsers(userID string) ([]*User, error) {
+	return s.listUsers(`
+		SELECT u.id, u.username, u.full_name, u.profile_picture
+		FROM user_blocks b
+		JOIN users u ON b.blocked_id = u.id
+		WHERE b.blocker_id = ?
+		ORDER BY b.created_at DESC
+	`, userID)
+}
+
+// Mute hides mutedID's content from muterID's feed. The muted user is not told.
+func (s *BlockService) Mute(muterID, mutedID string) error {
+	if muterID == mutedID {
+		return errors.New("cannot mute yourself")
+	}
+
+	result, err := s.DB.Exec(`
+		INSERT INTO user_mutes (muter_id, muted_id, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (muter_id, muted_id) DO NOTHING
+	`, muterID, mutedID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to mute user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("user already muted")
+	}
+
+	return nil
+}
+
+// Unmute lifts a mute muterID placed on mutedID
+func (s *BlockService) Unmute(muterID, mutedID string) error {
+	result, err := s.DB.Exec(`
+		DELETE FROM user_mutes WHERE muter_id = ? AND muted_id = ?
+	`, muterID, mutedID)
+	if err != nil {
+		return fmt.Errorf("failed to unmute user: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("user not muted")
+	}
+
+	return nil
+}
+
+// IsMuted reports whether muterID has muted mutedID
+func (s *BlockService) IsMuted(muterID, mutedID string) (bool, error) {
+	var exists bool
+	err := s.DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM user_mutes WHERE muter_id = ? AND muted_id = ?)
+	`, muterID, mutedID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check mute: %w", err)
+	}
+	return exists, nil
+}
+
+// GetMutedUsers retrieves the users userID has muted, most recent first
+func (s *BlockService) GetMutedUsers(userID string) ([]*User, error) {
+	return s.listUsers(`
+		SELECT u.id, u.username, u.full_name, u.profile_picture
+		FROM user_mutes m
+		JOIN users u ON m.muted_id = u.id
+		WHERE m.muter_id = ?
+		ORDER BY m.created_at DESC
+	`, userID)
+}
+
+func (s *BlockService) listUsers(query, userID string) ([]*User, error) {
+	rows, err := s.DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user := &User{}
+		var profilePicture sql.NullString
+		if err := rows.Scan(&user.ID, &user.Username, &user.FullName, &profilePicture); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		user.ProfilePicture = profilePicture.String
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %w", err)
+	}
+
+	return users, nil
+}
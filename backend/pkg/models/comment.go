@@ -2,28 +2,60 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
 	"github.com/google/uuid"
 	// Import the models package to access GroupPrivacy and PostVisibility
 	//  "social-network/backend/pkg/models"
 	// "backend/pkg/models"
 )
 
-// Comment represents a comment on a post
+// MaxCommentDepth is how deep replies nest below a top-level comment, which
+// has depth 0. Replies to a comment at this depth become its siblings.
+const MaxCommentDepth = 3
+
+// Comment represents a comment on a post, or a reply to another comment
 type Comment struct {
-	ID        string    `json:"id"`
-	PostID    string    `json:"postId"`
-	UserID    string    `json:"userId"`
-	Content   string    `json:"content"`
-	Image     string    `json:"image,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID              string     `json:"id"`
+	PostID          string     `json:"postId"`
+	UserID          string     `json:"userId"`
+	ParentCommentID string     `json:"parentCommentId,omitempty"`
+	Depth           int        `json:"depth"`
+	Content         string     `json:"content"`
+	Image           string     `json:"image,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+	EditedAt        *time.Time `json:"editedAt,omitempty"` // When the content was last edited, if ever
 	// Additional fields for API responses
-	Author *User `json:"author,omitempty"`
+	Author         *User          `json:"author,omitempty"`
+	RepliesCount   int            `json:"repliesCount"`
+	LikesCount     int            `json:"likesCount"` // Reactions of any type
+	IsLiked        bool           `json:"isLiked"`    // Whether the viewer reacted at all
+	ReactionCounts ReactionCounts `json:"reactionCounts,omitempty"`
+	Reaction       ReactionType   `json:"reaction,omitempty"` // The viewer's reaction
+	Mentions       Mentions       `json:"-"`                  // Sent as entities
+}
+
+// MarshalJSON adds the size variants of the comment's image, whether it was
+// edited, and the mentions and hashtags in it to its JSON
+func (c Comment) MarshalJSON() ([]byte, error) {
+	type comment Comment
+	return json.Marshal(struct {
+		comment
+		ImageSet *utils.ImageSet `json:"imageSet,omitempty"`
+		Edited   bool            `json:"edited"`
+		Entities []Entity        `json:"entities,omitempty"`
+	}{
+		comment:  comment(c),
+		ImageSet: utils.NewImageSet(c.Image),
+		Edited:   c.EditedAt != nil,
+		Entities: ContentEntities(c.Content, c.Mentions),
+	})
 }
 
 // CommentService handles comment-related operations
@@ -36,17 +68,48 @@ func NewCommentService(db *sql.DB) *CommentService {
 	return &CommentService{DB: db}
 }
 
-// Create creates a new comment
+// Create creates a new comment. A reply must be on the same post as the
+// comment it answers; past MaxCommentDepth it is attached to that comment's
+// parent instead.
 func (s *CommentService) Create(comment *Comment) error {
+	comment.Depth = 0
+	var parentID sql.NullString
+	if comment.ParentCommentID != "" {
+		var postID string
+		var depth int
+		var grandparentID sql.NullString
+		err := s.DB.QueryRow(
+			"SELECT post_id, depth, parent_comment_id FROM comments WHERE id = ?",
+			comment.ParentCommentID,
+		).Scan(&postID, &depth, &grandparentID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return errors.New("parent comment not found")
+			}
+			return fmt.Errorf("failed to get parent comment: %w", err)
+		}
+		if postID != comment.PostID {
+			return errors.New("parent comment not found")
+		}
+
+		if depth >= MaxCommentDepth && grandparentID.Valid {
+			comment.ParentCommentID = grandparentID.String
+			comment.Depth = depth
+		} else {
+			comment.Depth = depth + 1
+		}
+		parentID = sql.NullString{String: comment.ParentCommentID, Valid: true}
+	}
+
 	comment.ID = uuid.New().String()
 	now := time.Now()
 	comment.CreatedAt = now
 	comment.UpdatedAt = now
 
 	_, err := s.DB.Exec(`
-		INSERT INTO comments (id, post_id, user_id, content, image, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, comment.ID, comment.PostID, comment.UserID, comment.Content, comment.Image, comment.CreatedAt, comment.UpdatedAt)
+		INSERT INTO comments (id, post_id, user_id, parent_comment_id, depth, content, image, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, comment.ID, comment.PostID, comment.UserID, parentID, comment.Depth, comment.Content, comment.Image, comment.CreatedAt, comment.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to create comment: %w", err)
 	}
@@ -54,19 +117,43 @@ func (s *CommentService) Create(comment *Comment) error {
 	return nil
 }
 
+// commentColumns are the columns scanned by scanComment. They take the
+// viewer's ID, for their reaction.
+const commentColumns = `c.id, c.post_id, c.user_id, c.parent_comment_id, c.depth, c.content, c.image, c.created_at, c.updated_at, c.edited_at,
+	u.id, u.username, u.full_name, u.profile_picture,
+	(SELECT COUNT(*) FROM comments r WHERE r.parent_comment_id = c.id) as replies_count,
+	(SELECT COUNT(*) FROM comment_likes cl WHERE cl.comment_id = c.id) as likes_count,
+	(SELECT json_group_object(reaction, reaction_count) FROM (
+		SELECT reaction, COUNT(*) AS reaction_count FROM comment_likes cl WHERE cl.comment_id = c.id GROUP BY reaction
+	)) as reaction_counts,
+	COALESCE((SELECT reaction FROM comment_likes cl WHERE cl.comment_id = c.id AND cl.user_id = ?), '') as reaction,
+	(SELECT json_group_object(username, user_id) FROM mentions WHERE target_type = 'comment' AND target_id = c.id) as mentions`
+
+// scanComment scans a row selected with commentColumns
+func scanComment(row interface{ Scan(...interface{}) error }) (*Comment, error) {
+	comment := &Comment{Author: &User{}}
+	var parentID sql.NullString
+	err := row.Scan(
+		&comment.ID, &comment.PostID, &comment.UserID, &parentID, &comment.Depth, &comment.Content, &comment.Image, &comment.CreatedAt, &comment.UpdatedAt, &comment.EditedAt,
+		&comment.Author.ID, &comment.Author.Username, &comment.Author.FullName, &comment.Author.ProfilePicture,
+		&comment.RepliesCount, &comment.LikesCount, &comment.ReactionCounts, &comment.Reaction, &comment.Mentions,
+	)
+	if err != nil {
+		return nil, err
+	}
+	comment.ParentCommentID = parentID.String
+	comment.IsLiked = comment.Reaction != ""
+	return comment, nil
+}
+
 // GetByID retrieves a comment by ID
 func (s *CommentService) GetByID(id string) (*Comment, error) {
-	comment := &Comment{Author: &User{}}
-	err := s.DB.QueryRow(`
-		SELECT c.id, c.post_id, c.user_id, c.content, c.image, c.created_at, c.updated_at,
-			u.id, u.username, u.full_name, u.profile_picture
+	comment, err := scanComment(s.DB.QueryRow(`
+		SELECT `+commentColumns+`
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
 		WHERE c.id = ?
-	`, id).Scan(
-		&comment.ID, &comment.PostID, &comment.UserID, &comment.Content, &comment.Image, &comment.CreatedAt, &comment.UpdatedAt,
-		&comment.Author.ID, &comment.Author.Username, &comment.Author.FullName, &comment.Author.ProfilePicture,
-	)
+	`, "", id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("comment not found")
@@ -77,30 +164,32 @@ func (s *CommentService) GetByID(id string) (*Comment, error) {
 	return comment, nil
 }
 
-// Update updates a comment
+// Update updates a comment, keeping the content it replaces as a revision
 func (s *CommentService) Update(comment *Comment) error {
 	comment.UpdatedAt = time.Now()
 
-	_, err := s.DB.Exec(`
-		UPDATE comments
-		SET content = ?, image = ?, updated_at = ?
-		WHERE id = ? AND user_id = ?
-	`, comment.Content, comment.Image, comment.UpdatedAt, comment.ID, comment.UserID)
+	editedAt, err := updateContent(s.DB, RevisionTargetComment, comment.ID, comment.UserID, comment.Content, comment.UpdatedAt,
+		", image = ?", comment.Image)
 	if err != nil {
 		return fmt.Errorf("failed to update comment: %w", err)
 	}
+	if editedAt != nil {
+		comment.EditedAt = editedAt
+	}
 
 	return nil
 }
 
 // Delete deletes a comment
 func (s *CommentService) Delete(id, userID string) error {
-	// Check if the user is the comment author or the post owner
+	// Check if the user is the comment author or the post owner; the comment
+	// may be on a regular post or a group post
 	var postOwnerID string
 	err := s.DB.QueryRow(`
-		SELECT p.user_id
+		SELECT COALESCE(p.user_id, gp.user_id, '')
 		FROM comments c
-		JOIN posts p ON c.post_id = p.id
+		LEFT JOIN posts p ON c.post_id = p.id
+		LEFT JOIN group_posts gp ON c.post_id = gp.id
 		WHERE c.id = ?
 	`, id).Scan(&postOwnerID)
 	if err != nil {
@@ -131,8 +220,35 @@ func (s *CommentService) Delete(id, userID string) error {
 	return nil
 }
 
+// ForceDelete deletes a comment regardless of who wrote it, for
+// platform staff, as part of the transaction recording the action
+func (s *CommentService) ForceDelete(tx *sql.Tx, id string) error {
+	result, err := tx.Exec("DELETE FROM comments WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete comment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("comment not found")
+	}
+
+	return nil
+}
+
 // GetCommentsByPost retrieves all comments for a post
 func (s *CommentService) GetCommentsByPost(postID string, currentUserID string, limit, offset int) ([]*Comment, error) {
+	comments, _, err := s.GetCommentsByPostPage(postID, currentUserID, Page{Limit: limit, Offset: offset})
+	return comments, err
+}
+
+// GetCommentsByPostPage retrieves a page of the top-level comments on a post,
+// returning the cursor for the next page. Replies are loaded separately with
+// GetRepliesPage.
+func (s *CommentService) GetCommentsByPostPage(postID string, currentUserID string, page Page) ([]*Comment, string, error) {
 	// First, check if the post exists in the regular posts table
 	var postUserID string
 	var postVisibility PostVisibility
@@ -151,58 +267,92 @@ func (s *CommentService) GetCommentsByPost(postID string, currentUserID string,
 			).Scan(&groupPostUserID)
 			if err != nil {
 				if err == sql.ErrNoRows {
-					return nil, errors.New("post not found for comments")
+					return nil, "", errors.New("post not found for comments")
 				}
 				log.Printf("GetCommentsByPost: Error checking group post %s: %v", postID, err)
-				return nil, fmt.Errorf("failed to check group post: %w", err)
+				return nil, "", fmt.Errorf("failed to check group post: %w", err)
 			}
 
 			// For group posts, we don't need to check visibility here as it's handled by the group membership check in the handler
 			// Just proceed to get the comments
 		} else {
 			log.Printf("GetCommentsByPost: Error checking post %s: %v", postID, err)
-			return nil, fmt.Errorf("failed to check post: %w", err)
+			return nil, "", fmt.Errorf("failed to check post: %w", err)
 		}
 	} else {
 		// Regular post found, check if user can view this post's comments
 		if postVisibility == PostVisibilityPrivate && postUserID != currentUserID {
-			return nil, errors.New("not authorized to view comments on this private post")
+			return nil, "", errors.New("not authorized to view comments on this private post")
 		}
 		// Add more visibility checks if needed (e.g., followers only)
+
+		// Comments on a blocked user's post are as hidden as the post itself
+		if postUserID != currentUserID && currentUserID != "" {
+			blocked, err := isBlockedBetween(s.DB, currentUserID, postUserID)
+			if err != nil {
+				return nil, "", err
+			}
+			if blocked {
+				return nil, "", errors.New("post not found for comments")
+			}
+		}
+	}
+
+	return s.getPage(postID, "", currentUserID, page)
+}
+
+// GetRepliesPage retrieves a page of the direct replies to a comment. The
+// caller checks the viewer can see the post the comment is on.
+func (s *CommentService) GetRepliesPage(commentID string, currentUserID string, page Page) ([]*Comment, string, error) {
+	return s.getPage("", commentID, currentUserID, page)
+}
+
+// getPage retrieves a page of the top-level comments on postID or, when
+// parentID is set, of the replies to that comment, leaving out users on
+// either side of a block with the viewer
+func (s *CommentService) getPage(postID, parentID, currentUserID string, page Page) ([]*Comment, string, error) {
+	parentCondition, parentArgs := "c.post_id = ? AND c.parent_comment_id IS NULL", []interface{}{postID}
+	if parentID != "" {
+		parentCondition, parentArgs = "c.parent_comment_id = ?", []interface{}{parentID}
+	}
+	cursorCondition, cursorArgs := page.where("c.created_at", "c.id")
+	orderAndLimit, limitArgs := page.orderAndLimit("c.created_at", "c.id")
+	notBlocked, notBlockedArgs := notBlockedCondition("c.user_id", currentUserID)
+
+	args := []interface{}{currentUserID}
+	for _, conditionArgs := range [][]interface{}{parentArgs, notBlockedArgs, cursorArgs, limitArgs} {
+		args = append(args, conditionArgs...)
 	}
 
 	rows, err := s.DB.Query(`
-		SELECT c.id, c.post_id, c.user_id, c.content, c.image, c.created_at, c.updated_at,
-			u.id, u.username, u.full_name, u.profile_picture
+		SELECT `+commentColumns+`
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.post_id = ?
-		ORDER BY c.created_at DESC
-		LIMIT ? OFFSET ?
-	`, postID, limit, offset)
+		WHERE `+parentCondition+` AND `+notBlocked+` AND `+cursorCondition+`
+		`+orderAndLimit+`
+	`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get comments: %w", err)
+		return nil, "", fmt.Errorf("failed to get comments: %w", err)
 	}
 	defer rows.Close()
 
 	var comments []*Comment
 	for rows.Next() {
-		comment := &Comment{Author: &User{}}
-		err := rows.Scan(
-			&comment.ID, &comment.PostID, &comment.UserID, &comment.Content, &comment.Image, &comment.CreatedAt, &comment.UpdatedAt,
-			&comment.Author.ID, &comment.Author.Username, &comment.Author.FullName, &comment.Author.ProfilePicture,
-		)
+		comment, err := scanComment(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan comment: %w", err)
+			return nil, "", fmt.Errorf("failed to scan comment: %w", err)
 		}
 		comments = append(comments, comment)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating comments: %w", err)
+		return nil, "", fmt.Errorf("error iterating comments: %w", err)
 	}
 
-	return comments, nil
+	comments, nextCursor := nextPage(comments, page.Limit, func(comment *Comment) (time.Time, string) {
+		return comment.CreatedAt, comment.ID
+	})
+	return comments, nextCursor, nil
 }
 
 // GetCommentCount returns the number of comments for a post
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CommentLikeService handles likes on comments
+type CommentLikeService struct {
+	DB *sql.DB
+}
+
+// NewCommentLikeService creates a new CommentLikeService
+func NewCommentLikeService(db *sql.DB) *CommentLikeService {
+	return &CommentLikeService{DB: db}
+}
+
+// Create likes a comment on behalf of a user
+func (s *CommentLikeService) Create(commentID, userID string) error {
+	result, err := s.DB.Exec(`
+		INSERT INTO comment_likes (id, comment_id, user_id, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (comment_id, user_id) DO NOTHING
+	`, uuid.New().String(), commentID, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to like comment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("comment already liked by user")
+	}
+
+	return nil
+}
+
+// Delete removes a user's like from a comment
+func (s *CommentLikeService) Delete(commentID, userID string) error {
+	result, err := s.DB.Exec("DELETE FROM comment_likes WHERE comment_id = ? AND user_id = ?", commentID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to unlike comment: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("like not found")
+	}
+
+	return nil
+}
+
+// GetLikeCount returns the number of likes on a comment
+func (s *CommentLikeService) GetLikeCount(commentID string) (int, error) {
+	var count int
+	err := s.DB.QueryRow("SELECT COUNT(*) FROM comment_likes WHERE comment_id = ?", commentID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get comment like count: %w", err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ConversationType is the kind of chat a conversation is
+type ConversationType string
+
+const (
+	// ConversationTypeDirect is a one-to-one conversation
+	ConversationTypeDirect ConversationType = "direct"
+	// ConversationTypeGroup is a group chat
+	ConversationTypeGroup ConversationType = "group"
+)
+
+// messagePreviewLength is the number of characters kept in a last message preview
+const messagePreviewLength = 100
+
+// Conversation is an entry in a user's conversation list
+type Conversation struct {
+	Type           ConversationType `json:"type"`
+	ID             string           `json:"id"` // Other user's ID or group ID
+	RoomID         string           `json:"roomId"`
+	User           *User            `json:"user,omitempty"`
+	Group          *Group           `json:"group,omitempty"`
+	LastMessage    *MessagePreview  `json:"lastMessage,omitempty"`
+	LastActivityAt time.Time        `json:"lastActivityAt"`
+	UnreadCount    int              `json:"unreadCount"`
+	IsOnline       bool             `json:"isOnline"`
+}
+
+// MessagePreview is a shortened message shown in the conversation list
+type MessagePreview struct {
+	ID        string    `json:"id"`
+	Content   string    `json:"content"`
+	SenderID  string    `json:"senderId"`
+	Sender    *User     `json:"sender,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	IsDeleted bool      `json:"isDeleted"`
+}
+
+// GetConversationList returns a user's direct conversations and group chats
+// together, most recently active first. Group chats without messages are
+// ordered by when the user joined. Pass the cursor from the previous page
+// to continue; the returned cursor is empty on the last page.
+func (s *MessageService) GetConversationList(userID string, cursor *Cursor, limit int) ([]*Conversation, string, error) {
+	query := `
+		WITH direct_messages AS (
+			SELECT
+				CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END AS peer_id,
+				id, created_at,
+				ROW_NUMBER() OVER (
+					PARTITION BY CASE WHEN sender_id = ? THEN receiver_id ELSE sender_id END
+					ORDER BY created_at DESC, id DESC
+				) AS rn
+			FROM messages
+			WHERE receiver_id IS NOT NULL AND (sender_id = ? OR receiver_id = ?)
+		),
+		group_messages AS (
+			SELECT group_id, id, created_at,
+				ROW_NUMBER() OVER (PARTITION BY group_id ORDER BY created_at DESC, id DESC) AS rn
+			FROM messages
+			WHERE group_id IN (SELECT group_id FROM group_members WHERE user_id = ? AND status = 'accepted')
+		),
+		conversations AS (
+			SELECT 'direct' AS kind, peer_id AS conversation_id, id AS last_message_id,
+				created_at AS activity_at, 'direct:' || peer_id AS sort_key
+			FROM direct_messages
+			WHERE rn = 1
+			UNION ALL
+			SELECT 'group', gm.group_id, gl.id,
+				COALESCE(gl.created_at, gm.created_at), 'group:' || gm.group_id
+			FROM group_members gm
+			LEFT JOIN group_messages gl ON gl.group_id = gm.group_id AND gl.rn = 1
+			WHERE gm.user_id = ? AND gm.status = 'accepted'
+		)
+		SELECT kind, conversation_id, last_message_id, activity_at, sort_key
+		FROM conversations
+	`
+	args := []interface{}{userID, userID, userID, userID, userID, userID}
+
+	if cursor != nil {
+		query += " WHERE (activity_at, sort_key) < (?, ?)"
+		args = append(args, cursor.Time, cursor.ID)
+	}
+
+	// Fetch one extra row to know whether there is another page
+	query += " ORDER BY activity_at DESC, sort_key DESC LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get conversations: %w", err)
+	}
+	defer rows.Close()
+
+	type conversationRow struct {
+		conversation  *Conversation
+		lastMessageID string
+		sortKey       string
+	}
+
+	var entries []*conversationRow
+	for rows.Next() {
+		var kind, conversationID, activityAt, sortKey string
+		var lastMessageID sql.NullString
+		if err := rows.Scan(&kind, &conversationID, &lastMessageID, &activityAt, &sortKey); err != nil {
+			return nil, "", fmt.Errorf("failed to scan conversation: %w", err)
+		}
+
+		lastActivityAt, err := parseDBTime(activityAt)
+		if err != nil {
+			return nil, "", err
+		}
+
+		entries = append(entries, &conversationRow{
+			conversation: &Conversation{
+				Type:           ConversationType(kind),
+				ID:             conversationID,
+				LastActivityAt: lastActivityAt,
+			},
+			lastMessageID: lastMessageID.String,
+			sortKey:       sortKey,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating conversations: %w", err)
+	}
+
+	var nextCursor string
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[len(entries)-1]
+		nextCursor = EncodeCursor(last.conversation.LastActivityAt, last.sortKey)
+	}
+
+	unreadCounts, err := s.GetUnreadCounts(userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	conversations := make([]*Conversation, 0, len(entries))
+	for _, entry := range entries {
+		conversation := entry.conversation
+
+		switch conversation.Type {
+		case ConversationTypeDirect:
+			conversation.RoomID = directRoomID(userID, conversation.ID)
+			conversation.UnreadCount = unreadCounts.Direct[conversation.ID]
+			if conversation.User, err = s.getConversationUser(conversation.ID); err != nil {
+				return nil, "", err
+			}
+		case ConversationTypeGroup:
+			conversation.RoomID = "group-" + conversation.ID
+			conversation.UnreadCount = unreadCounts.Groups[conversation.ID]
+			if conversation.Group, err = s.getConversationGroup(conversation.ID); err != nil {
+				return nil, "", err
+			}
+		}
+
+		if entry.lastMessageID != "" {
+			message, err := s.GetByID(entry.lastMessageID)
+			if err != nil {
+				return nil, "", err
+			}
+			conversation.LastMessage = newMessagePreview(message)
+		}
+
+		conversations = append(conversations, conversation)
+	}
+
+	return conversations, nextCursor, nil
+}
+
+// getConversationUser loads the public details of the other user in a direct conversation
+func (s *MessageService) getConversationUser(id string) (*User, error) {
+	user := &User{}
+	var fullName, profilePicture sql.NullString
+	err := s.DB.QueryRow(`
+		SELECT id, username, full_name, profile_picture, is_private
+		FROM users
+		WHERE id = ?
+	`, id).Scan(&user.ID, &user.Username, &fullName, &profilePicture, &user.IsPrivate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation user: %w", err)
+	}
+
+	user.FullName = fullName.String
+	user.ProfilePicture = profilePicture.String
+	return user, nil
+}
+
+// getConversationGroup loads the details of a group chat
+func (s *MessageService) getConversationGroup(id string) (*Group, error) {
+	group := &Group{}
+	var description, coverPhoto sql.NullString
+	err := s.DB.QueryRow(`
+		SELECT id, name, description, creator_id, cover_photo, privacy, created_at, updated_at
+		FROM groups
+		WHERE id = ?
+	`, id).Scan(&group.ID, &group.Name, &description, &group.CreatorID, &coverPhoto, &group.Privacy, &group.CreatedAt, &group.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation group: %w", err)
+	}
+
+	group.Description = description.String
+	group.CoverPhoto = coverPhoto.String
+	return group, nil
+}
+
+// newMessagePreview shortens a message for the conversation list
+func newMessagePreview(message *Message) *MessagePreview {
+	content := message.Content
+	if utf8.RuneCountInString(content) > messagePreviewLength {
+		content = string([]rune(content)[:messagePreviewLength]) + "…"
+	}
+
+	return &MessagePreview{
+		ID:        message.ID,
+		Content:   content,
+		SenderID:  message.SenderID,
+		Sender:    message.Sender,
+		CreatedAt: message.CreatedAt,
+		IsDeleted: message.IsDeleted,
+	}
+}
+
+// directRoomID returns the chat room ID for a direct conversation, matching
+// the IDs the handlers and clients use
+func directRoomID(userID1, userID2 string) string {
+	if userID2 < userID1 {
+		userID1, userID2 = userID2, userID1
+	}
+	return strings.Join([]string{userID1, userID2}, "-")
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Cursor marks a position in a list ordered newest first by (time, id)
+type Cursor struct {
+	Time time.Time
+	ID   string
+}
+
+// dbTimeFormats are the layouts timestamps are stored in, as written by the
+// SQLite driver for time.Time values and by CURRENT_TIMESTAMP defaults
+var dbTimeFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// EncodeCursor returns an opaque cursor pointing at the given list item
+func EncodeCursor(t time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.Format(time.RFC3339Nano) + "|" + id))
+}
+
+// DecodeCursor parses a cursor returned by EncodeCursor
+func DecodeCursor(cursor string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	parts := strings.SplitN(string(data), "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, errors.New("invalid cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+
+	return &Cursor{Time: t, ID: parts[1]}, nil
+}
+
+// parseDBTime parses a timestamp read from a computed column, which the
+// SQLite driver returns as text rather than time.Time
+func parseDBTime(value string) (time.Time, error) {
+	for _, layout := range dbTimeFormats {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid timestamp: " + value)
+}
+
+// Page selects part of a list ordered newest first by (created_at, id).
+// Cursor, when set, takes precedence over Offset, which is kept for clients
+// that still page by position.
+type Page struct {
+	Limit  int
+	Offset int
+	Cursor *Cursor
+}
+
+// where returns the condition keeping only rows after the cursor, or an
+// always-true condition without one
+func (p Page) where(timeColumn, idColumn string) (string, []interface{}) {
+	if p.Cursor == nil {
+		return "1 = 1", nil
+	}
+	return "(" + timeColumn + ", " + idColumn + ") < (?, ?)", []interface{}{p.Cursor.Time, p.Cursor.ID}
+}
+
+// orderAndLimit returns the ORDER BY and LIMIT clause for the page. It asks
+// for one row more than the limit so nextPage can tell if another page follows.
+func (p Page) orderAndLimit(timeColumn, idColumn string) (string, []interface{}) {
+	offset := p.Offset
+	if p.Cursor != nil {
+		offset = 0
+	}
+	return "ORDER BY " + timeColumn + " DESC, " + idColumn + " DESC LIMIT ? OFFSET ?", []interface{}{p.Limit + 1, offset}
+}
+
+// nextPage trims the extra row fetched by orderAndLimit and returns the
+// cursor for the following page, empty when this is the last one
+func nextPage[T any](items []T, limit int, key func(T) (time.Time, string)) ([]T, string) {
+	if len(items) <= limit {
+		return items, ""
+	}
+	items = items[:limit]
+	t, id := key(items[len(items)-1])
+	return items, EncodeCursor(t, id)
+}
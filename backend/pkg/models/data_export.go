@@ -0,0 +1,218 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DataExportStatus represents where a data export job is
+type DataExportStatus string
+
+const (
+	DataExportStatusPending    DataExportStatus = "pending"
+	DataExportStatusProcessing DataExportStatus = "processing"
+	DataExportStatusReady      DataExportStatus = "ready"
+	DataExportStatusFailed     DataExportStatus = "failed"
+)
+
+// DataExport is a request for an archive of everything a user has stored
+type DataExport struct {
+	ID          string           `json:"id"`
+	UserID      string           `json:"userId"`
+	Status      DataExportStatus `json:"status"`
+	FilePath    string           `json:"-"`
+	FileSize    int64            `json:"fileSize,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	CompletedAt *time.Time       `json:"completedAt,omitempty"`
+	ExpiresAt   *time.Time       `json:"expiresAt,omitempty"`
+}
+
+// DataExportService handles data export jobs
+type DataExportService struct {
+	DB *sql.DB
+}
+
+// NewDataExportService creates a new DataExportService
+func NewDataExportService(db *sql.DB) *DataExportService {
+	return &DataExportService{DB: db}
+}
+
+const dataExportColumns = "id, user_id, status, file_path, file_size, error, created_at, completed_at, expires_at"
+
+func scanDataExport(row messageScanner) (*DataExport, error) {
+	export := &DataExport{}
+	var completedAt, expiresAt sql.NullTime
+	err := row.Scan(&export.ID, &export.UserID, &export.Status, &export.FilePath, &export.FileSize, &export.Error, &export.CreatedAt, &completedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		export.CompletedAt = &completedAt.Time
+	}
+	if expiresAt.Valid {
+		export.ExpiresAt = &expiresAt.Time
+	}
+	return export, nil
+}
+
+// Create queues a new export for a user. Only one export per user may be
+// queued or running at a time.
+func (s *DataExportService) Create(userID string) (*DataExport, error) {
+	export := &DataExport{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Status:    DataExportStatusPending,
+		CreatedAt: time.Now(),
+	}
+
+	result, err := s.DB.Exec(`
+		INSERT INTO data_exports (id, user_id, status, created_at)
+		SELECT ?, ?, ?, ?
+		WHERE NOT EXISTS (
+			SELECT 1 FROM data_exports WHERE user_id = ? AND status IN ('pending', 'processing')
+		)
+	`, export.ID, export.UserID, export.Status, export.CreatedAt, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create data export: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, errors.New("export already in progress")
+	}
+
+	return export, nil
+}
+
+// GetByID retrieves one of a user's exports
+func (s *DataExportService) GetByID(id, userID string) (*DataExport, error) {
+	export, err := scanDataExport(s.DB.QueryRow(`
+		SELECT `+dataExportColumns+` FROM data_exports WHERE id = ? AND user_id = ?
+	`, id, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("export not found")
+		}
+		return nil, fmt.Errorf("failed to get data export: %w", err)
+	}
+	return export, nil
+}
+
+// GetByUser retrieves a user's exports, newest first
+func (s *DataExportService) GetByUser(userID string) ([]*DataExport, error) {
+	return s.list(`
+		SELECT `+dataExportColumns+` FROM data_exports WHERE user_id = ? ORDER BY created_at DESC
+	`, userID)
+}
+
+// ClaimNext marks the oldest queued export as processing and returns it, or
+// nil when nothing is queued
+func (s *DataExportService) ClaimNext() (*DataExport, error) {
+	export, err := scanDataExport(s.DB.QueryRow(`
+		UPDATE data_exports SET status = 'processing'
+		WHERE id = (
+			SELECT id FROM data_exports WHERE status = 'pending' ORDER BY created_at LIMIT 1
+		)
+		RETURNING ` + dataExportColumns))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim data export: %w", err)
+	}
+	return export, nil
+}
+
+// RequeueStale puts exports left processing by a server that stopped back in the queue
+func (s *DataExportService) RequeueStale() error {
+	if _, err := s.DB.Exec("UPDATE data_exports SET status = 'pending' WHERE status = 'processing'"); err != nil {
+		return fmt.Errorf("failed to requeue data exports: %w", err)
+	}
+	return nil
+}
+
+// MarkReady records the finished archive of an export
+func (s *DataExportService) MarkReady(id, filePath string, fileSize int64, expiresAt time.Time) error {
+	_, err := s.DB.Exec(`
+		UPDATE data_exports SET status = 'ready', file_path = ?, file_size = ?, completed_at = ?, expires_at = ?
+		WHERE id = ?
+	`, filePath, fileSize, time.Now(), expiresAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update data export: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records why an export could not be built
+func (s *DataExportService) MarkFailed(id, reason string) error {
+	_, err := s.DB.Exec(`
+		UPDATE data_exports SET status = 'failed', error = ?, completed_at = ?
+		WHERE id = ?
+	`, reason, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update data export: %w", err)
+	}
+	return nil
+}
+
+// GetExpired retrieves finished exports whose download window closed by now
+func (s *DataExportService) GetExpired(now time.Time) ([]*DataExport, error) {
+	return s.list(`
+		SELECT `+dataExportColumns+` FROM data_exports WHERE expires_at IS NOT NULL AND expires_at <= ?
+	`, now)
+}
+
+// Delete removes an export record
+func (s *DataExportService) Delete(id string) error {
+	if _, err := s.DB.Exec("DELETE FROM data_exports WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete data export: %w", err)
+	}
+	return nil
+}
+
+// FilePaths returns the archive paths of all of a user's exports
+func (s *DataExportService) FilePaths(userID string) ([]string, error) {
+	exports, err := s.GetByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, export := range exports {
+		if export.FilePath != "" {
+			paths = append(paths, export.FilePath)
+		}
+	}
+	return paths, nil
+}
+
+func (s *DataExportService) list(query string, args ...interface{}) ([]*DataExport, error) {
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data exports: %w", err)
+	}
+	defer rows.Close()
+
+	exports := []*DataExport{}
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan data export: %w", err)
+		}
+		exports = append(exports, export)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating data exports: %w", err)
+	}
+
+	return exports, nil
+}
@@ -0,0 +1,368 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// EntityType is the kind of structured entity found in the text of content
+type EntityType string
+
+const (
+	EntityMention EntityType = "mention"
+	EntityHashtag EntityType = "hashtag"
+)
+
+// MaxTagLength is the maximum length in characters of a hashtag
+const MaxTagLength = 64
+
+var (
+	// Usernames may contain dots and hyphens but don't end with one, so
+	// punctuation after a mention isn't taken as part of it
+	mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_](?:[A-Za-z0-9_.-]*[A-Za-z0-9_])?)`)
+	hashtagPattern = regexp.MustCompile(`#([\p{L}\p{M}\p{N}_]+)`)
+	tagPattern     = regexp.MustCompile(`^[\p{L}\p{M}\p{N}_]+$`)
+)
+
+// Entity is an @mention or #hashtag in the content of a post, group post,
+// comment or message. Offset and Length count Unicode code points.
+type Entity struct {
+	Type   EntityType `json:"type"`
+	Text   string     `json:"text"`
+	Offset int        `json:"offset"`
+	Length int        `json:"length"`
+	UserID string     `json:"userId,omitempty"` // The mentioned user
+	Tag    string     `json:"tag,omitempty"`    // The hashtag, lowercased
+}
+
+// Mentions maps the usernames mentioned in some content, as written, to the
+// IDs of the users they resolved to. It scans the JSON object built by
+// json_group_object.
+type Mentions map[string]string
+
+// Scan implements sql.Scanner
+func (m *Mentions) Scan(src interface{}) error {
+	*m = nil
+	return scanJSON(src, m, "mentions")
+}
+
+// scanJSON scans a JSON column into dest, leaving it untouched for NULL
+func scanJSON(src, dest interface{}, what string) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("cannot scan %T into %s", src, what)
+	}
+
+	if err := json.Unmarshal(data, dest); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", what, err)
+	}
+	return nil
+}
+
+// entityMatch is where an @mention or #hashtag is in content, in bytes, and
+// what follows its @ or #
+type entityMatch struct {
+	entityType EntityType
+	start, end int
+	name       string
+}
+
+// findEntities finds the mentions and hashtags in content, in order. Only
+// those at the start of a word count, so email addresses and URL fragments
+// aren't taken for them.
+func findEntities(content string) []entityMatch {
+	var matches []entityMatch
+	for entityType, pattern := range map[EntityType]*regexp.Regexp{
+		EntityMention: mentionPattern,
+		EntityHashtag: hashtagPattern,
+	} {
+		for _, loc := range pattern.FindAllStringSubmatchIndex(content, -1) {
+			if previous, _ := utf8.DecodeLastRuneInString(content[:loc[0]]); loc[0] > 0 &&
+				(unicode.IsLetter(previous) || unicode.IsNumber(previous) || strings.ContainsRune("_@#&/", previous)) {
+				continue
+			}
+			name := content[loc[2]:loc[3]]
+			if entityType == EntityHashtag && NormalizeTag(name) == "" {
+				continue
+			}
+			matches = append(matches, entityMatch{entityType, loc[0], loc[1], name})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].start < matches[j].start
+	})
+	return matches
+}
+
+// NormalizeTag lowercases a hashtag, with or without its #. It returns ""
+// for anything that isn't a hashtag, which includes bare numbers like #1.
+func NormalizeTag(tag string) string {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if !tagPattern.MatchString(tag) || utf8.RuneCountInString(tag) > MaxTagLength {
+		return ""
+	}
+	if strings.IndexFunc(tag, func(r rune) bool { return !unicode.IsNumber(r) }) < 0 {
+		return ""
+	}
+	return tag
+}
+
+// ParseMentions returns the usernames mentioned in content, each once, in
+// the order they first appear
+func ParseMentions(content string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range findEntities(content) {
+		if match.entityType == EntityMention && !seen[match.name] {
+			seen[match.name] = true
+			usernames = append(usernames, match.name)
+		}
+	}
+	return usernames
+}
+
+// ParseHashtags returns the hashtags used in content, lowercased, each once,
+// in the order they first appear
+func ParseHashtags(content string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, match := range findEntities(content) {
+		if tag := NormalizeTag(match.name); match.entityType == EntityHashtag && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ContentEntities finds the mentions and hashtags in content. Mentions of
+// usernames that aren't in mentions didn't resolve to a user, so are left out.
+func ContentEntities(content string, mentions Mentions) []Entity {
+	var entities []Entity
+	for _, match := range findEntities(content) {
+		entity := Entity{
+			Type:   match.entityType,
+			Text:   content[match.start:match.end],
+			Offset: utf8.RuneCountInString(content[:match.start]),
+			Length: utf8.RuneCountInString(content[match.start:match.end]),
+		}
+		if match.entityType == EntityMention {
+			userID, ok := mentions[match.name]
+			if !ok {
+				continue
+			}
+			entity.UserID = userID
+		} else {
+			entity.Tag = NormalizeTag(match.name)
+		}
+		entities = append(entities, entity)
+	}
+	return entities
+}
+
+// EntityTargetType is the kind of content mentions and hashtags are in
+type EntityTargetType string
+
+const (
+	EntityTargetPost      EntityTargetType = "post"
+	EntityTargetGroupPost EntityTargetType = "group_post"
+	EntityTargetComment   EntityTargetType = "comment"
+	EntityTargetMessage   EntityTargetType = "message"
+)
+
+// TrendingTag is a hashtag and how many posts used it recently
+type TrendingTag struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// EntityService handles the mentions and hashtags in posts, group posts,
+// comments and messages
+type EntityService struct {
+	DB *sql.DB
+}
+
+// NewEntityService creates a new EntityService
+func NewEntityService(db *sql.DB) *EntityService {
+	return &EntityService{DB: db}
+}
+
+// Sync records the users mentioned in content that was just created or
+// edited, and for posts and group posts the hashtags in it, dropping any it
+// no longer has. mentions are the users the usernames in it resolved to. It
+// returns the IDs of the mentioned users who haven't been notified yet.
+func (s *EntityService) Sync(targetType EntityTargetType, targetID, content string, mentions Mentions) ([]string, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	usernames := make(map[string]string, len(mentions))
+	for username, userID := range mentions {
+		usernames[userID] = username
+	}
+	if err := syncRows(tx, "mentions", "user_id", targetType, targetID, usernames, func(userID string) error {
+		_, err := tx.Exec(`
+			INSERT INTO mentions (target_type, target_id, user_id, username, created_at)
+			VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (target_type, target_id, user_id) DO UPDATE SET username = excluded.username
+		`, targetType, targetID, userID, usernames[userID], time.Now())
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to save mentions: %w", err)
+	}
+
+	// Only posts are found by their tags
+	if targetType == EntityTargetPost || targetType == EntityTargetGroupPost {
+		tags := make(map[string]string)
+		for _, tag := range ParseHashtags(content) {
+			tags[tag] = tag
+		}
+		if err := syncRows(tx, "content_tags", "tag", targetType, targetID, tags, func(tag string) error {
+			_, err := tx.Exec(`
+				INSERT INTO content_tags (target_type, target_id, tag, created_at)
+				VALUES (?, ?, ?, ?)
+				ON CONFLICT (target_type, target_id, tag) DO NOTHING
+			`, targetType, targetID, tag, time.Now())
+			return err
+		}); err != nil {
+			return nil, fmt.Errorf("failed to save hashtags: %w", err)
+		}
+	}
+
+	rows, err := tx.Query(`
+		SELECT user_id FROM mentions
+		WHERE target_type = ? AND target_id = ? AND notified_at IS NULL
+	`, targetType, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get mentions: %w", err)
+	}
+	var unnotified []string
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan mention: %w", err)
+		}
+		unnotified = append(unnotified, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating mentions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return unnotified, nil
+}
+
+// syncRows makes the rows of table for a target have exactly the keys of
+// want in keyColumn, deleting the others and calling insert for every key
+func syncRows(tx *sql.Tx, table, keyColumn string, targetType EntityTargetType, targetID string, want map[string]string, insert func(key string) error) error {
+	rows, err := tx.Query("SELECT "+keyColumn+" FROM "+table+" WHERE target_type = ? AND target_id = ?", targetType, targetID)
+	if err != nil {
+		return err
+	}
+	var stale []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, ok := want[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, key := range stale {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE target_type = ? AND target_id = ? AND "+keyColumn+" = ?", targetType, targetID, key); err != nil {
+			return err
+		}
+	}
+	for key := range want {
+		if err := insert(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarkNotified records that a mentioned user has been told about a mention
+func (s *EntityService) MarkNotified(targetType EntityTargetType, targetID, userID string) error {
+	_, err := s.DB.Exec(`
+		UPDATE mentions SET notified_at = ?
+		WHERE target_type = ? AND target_id = ? AND user_id = ?
+	`, time.Now(), targetType, targetID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark mention as notified: %w", err)
+	}
+	return nil
+}
+
+// GetTrendingTags returns the hashtags used in the most posts and group posts
+// since the given time, counting only those the viewer can see
+func (s *EntityService) GetTrendingTags(viewerID string, since time.Time, limit int) ([]*TrendingTag, error) {
+	// The visibility conditions take the viewer's ID nine times
+	args := []interface{}{since}
+	for i := 0; i < 9; i++ {
+		args = append(args, viewerID)
+	}
+	args = append(args, limit)
+
+	rows, err := s.DB.Query(`
+		SELECT t.tag, COUNT(*) AS uses
+		FROM content_tags t
+		LEFT JOIN posts p ON t.target_type = 'post' AND p.id = t.target_id
+		LEFT JOIN group_posts gp ON t.target_type = 'group_post' AND gp.id = t.target_id
+		WHERE t.created_at >= ? AND (
+			(p.id IS NOT NULL AND `+withoutBlocked(postVisibilityCondition, "p.user_id")+`)
+			OR (gp.id IS NOT NULL AND `+withoutBlocked(groupVisibilityCondition("gp.group_id"), "gp.user_id")+`)
+		)
+		GROUP BY t.tag
+		ORDER BY uses DESC, MAX(t.created_at) DESC
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trending tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []*TrendingTag{}
+	for rows.Next() {
+		tag := &TrendingTag{}
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan trending tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating trending tags: %w", err)
+	}
+
+	return tags, nil
+}
@@ -47,6 +47,15 @@ func (s *FollowService) Create(followerID, followingID string, isPrivate bool) (
 		return existing, errors.New("follow relationship already exists")
 	}
 
+	// Blocked users can't follow or request to follow each other
+	blocked, err := isBlockedBetween(s.DB, followerID, followingID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, errors.New("user blocked")
+	}
+
 	// Determine initial status based on target user's privacy setting
 	status := FollowStatusAccepted
 	if isPrivate {
@@ -2,10 +2,12 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
 	"github.com/google/uuid"
 )
 
@@ -54,6 +56,18 @@ type Group struct {
 	RequestStatus string `json:"requestStatus,omitempty"` // pending, accepted, rejected, none
 }
 
+// MarshalJSON adds the size variants of the group's cover photo to its JSON
+func (g Group) MarshalJSON() ([]byte, error) {
+	type group Group
+	return json.Marshal(struct {
+		group
+		CoverPhotoSet *utils.ImageSet `json:"coverPhotoSet,omitempty"`
+	}{
+		group:         group(g),
+		CoverPhotoSet: utils.NewImageSet(g.CoverPhoto),
+	})
+}
+
 // GroupService handles group-related operations
 type GroupService struct {
 	DB *sql.DB
@@ -175,6 +189,25 @@ func (s *GroupService) Delete(id, userID string) error {
 	return nil
 }
 
+// ForceDelete deletes a group regardless of its members' roles, for
+// platform staff, as part of the transaction recording the action
+func (s *GroupService) ForceDelete(tx *sql.Tx, id string) error {
+	result, err := tx.Exec("DELETE FROM groups WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("group not found")
+	}
+
+	return nil
+}
+
 // GetGroups retrieves groups with optional filtering
 func (s *GroupService) GetGroups(currentUserID string, limit, offset int) ([]*Group, error) {
 	rows, err := s.DB.Query(`
@@ -53,6 +53,30 @@ func (s *GroupMemberService) Create(member *GroupMember) error {
 	return nil
 }
 
+// Invite records an invitation from inviterID for userID to join a group.
+// Users on either side of a block can't invite each other.
+func (s *GroupMemberService) Invite(groupID, userID, inviterID string) (*GroupMember, error) {
+	blocked, err := isBlockedBetween(s.DB, inviterID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, errors.New("user blocked")
+	}
+
+	member := &GroupMember{
+		GroupID: groupID,
+		UserID:  userID,
+		Role:    GroupMemberRoleMember,
+		Status:  GroupMemberStatusInvited,
+	}
+	if err := s.Create(member); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
 // GetByID retrieves a group member by ID
 func (s *GroupMemberService) GetByID(id string) (*GroupMember, error) {
 	member := &GroupMember{User: &User{}, Group: &Group{}}
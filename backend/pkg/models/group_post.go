@@ -2,28 +2,52 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
 	"github.com/google/uuid"
 )
 
 // GroupPost represents a post in a group
 type GroupPost struct {
-	ID        string    `json:"id"`
-	GroupID   string    `json:"groupId"`
-	UserID    string    `json:"userId"`
-	Content   string    `json:"content"`
-	Image     string    `json:"image,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        string     `json:"id"`
+	GroupID   string     `json:"groupId"`
+	UserID    string     `json:"userId"`
+	Content   string     `json:"content"`
+	Image     string     `json:"image,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+	EditedAt  *time.Time `json:"editedAt,omitempty"` // When the content was last edited, if ever
 	// Additional fields for API responses
-	User          *User  `json:"author,omitempty"`
-	Group         *Group `json:"group,omitempty"`
-	LikesCount    int    `json:"likesCount,omitempty"`
-	CommentsCount int    `json:"commentsCount,omitempty"`
-	IsLiked       bool   `json:"isLikedByCurrentUser,omitempty"`
+	User           *User          `json:"author,omitempty"`
+	Group          *Group         `json:"group,omitempty"`
+	LikesCount     int            `json:"likesCount,omitempty"` // Reactions of any type
+	CommentsCount  int            `json:"commentsCount,omitempty"`
+	IsLiked        bool           `json:"isLikedByCurrentUser,omitempty"` // Whether the viewer reacted at all
+	ReactionCounts ReactionCounts `json:"reactionCounts,omitempty"`
+	Reaction       ReactionType   `json:"reaction,omitempty"` // The viewer's reaction
+	Mentions       Mentions       `json:"-"`                  // Sent as entities
+	LinkPreviews   LinkPreviews   `json:"linkPreviews,omitempty"`
+}
+
+// MarshalJSON adds the size variants of the group post's image, whether it
+// was edited, and the mentions and hashtags in it to its JSON
+func (p GroupPost) MarshalJSON() ([]byte, error) {
+	type groupPost GroupPost
+	return json.Marshal(struct {
+		groupPost
+		ImageSet *utils.ImageSet `json:"imageSet,omitempty"`
+		Edited   bool            `json:"edited"`
+		Entities []Entity        `json:"entities,omitempty"`
+	}{
+		groupPost: groupPost(p),
+		ImageSet:  utils.NewImageSet(p.Image),
+		Edited:    p.EditedAt != nil,
+		Entities:  ContentEntities(p.Content, p.Mentions),
+	})
 }
 
 // GroupPostService handles group post-related operations
@@ -57,27 +81,31 @@ func (s *GroupPostService) Create(post *GroupPost) error {
 // GetByID retrieves a group post by ID
 func (s *GroupPostService) GetByID(id string, currentUserID string) (*GroupPost, error) {
 	post := &GroupPost{User: &User{}, Group: &Group{}}
-	var isLikedCount int
 	err := s.DB.QueryRow(`
-		SELECT gp.id, gp.group_id, gp.user_id, gp.content, gp.image, gp.created_at, gp.updated_at,
+		SELECT gp.id, gp.group_id, gp.user_id, gp.content, gp.image, gp.created_at, gp.updated_at, gp.edited_at,
 			u.id, u.username, u.full_name, u.profile_picture,
 			g.id, g.name, g.privacy,
 			(SELECT COUNT(*) FROM likes WHERE post_id = gp.id) as likes_count,
 			(SELECT COUNT(*) FROM comments WHERE post_id = gp.id) as comments_count,
-			(SELECT COUNT(*) FROM likes WHERE post_id = gp.id AND user_id = ?) as is_liked
+			(SELECT json_group_object(reaction, reaction_count) FROM (
+				SELECT reaction, COUNT(*) AS reaction_count FROM likes WHERE post_id = gp.id GROUP BY reaction
+			)) as reaction_counts,
+			COALESCE((SELECT reaction FROM likes WHERE post_id = gp.id AND user_id = ?), '') as reaction,
+			(SELECT json_group_object(username, user_id) FROM mentions WHERE target_type = 'group_post' AND target_id = gp.id) as mentions,
+			`+linkPreviewsColumn(EntityTargetGroupPost, "gp.id")+`
 		FROM group_posts gp
 		JOIN users u ON gp.user_id = u.id
 		JOIN groups g ON gp.group_id = g.id
 		WHERE gp.id = ?
 	`, currentUserID, id).Scan(
-		&post.ID, &post.GroupID, &post.UserID, &post.Content, &post.Image, &post.CreatedAt, &post.UpdatedAt,
+		&post.ID, &post.GroupID, &post.UserID, &post.Content, &post.Image, &post.CreatedAt, &post.UpdatedAt, &post.EditedAt,
 		&post.User.ID, &post.User.Username, &post.User.FullName, &post.User.ProfilePicture,
 		&post.Group.ID, &post.Group.Name, &post.Group.Privacy,
-		&post.LikesCount, &post.CommentsCount, &isLikedCount,
+		&post.LikesCount, &post.CommentsCount, &post.ReactionCounts, &post.Reaction, &post.Mentions, &post.LinkPreviews,
 	)
 
 	if err == nil {
-		post.IsLiked = isLikedCount > 0
+		post.IsLiked = post.Reaction != ""
 	}
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -86,6 +114,17 @@ func (s *GroupPostService) GetByID(id string, currentUserID string) (*GroupPost,
 		return nil, fmt.Errorf("failed to get group post: %w", err)
 	}
 
+	// Neither side of a block can see the other's posts
+	if post.UserID != currentUserID && currentUserID != "" {
+		blocked, err := isBlockedBetween(s.DB, currentUserID, post.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, errors.New("group post not found")
+		}
+	}
+
 	// Check if the current user can view this post
 	if post.Group.Privacy == GroupPrivacyPrivate {
 		// Check if the current user is a member of the group
@@ -107,18 +146,18 @@ func (s *GroupPostService) GetByID(id string, currentUserID string) (*GroupPost,
 	return post, nil
 }
 
-// Update updates a group post
+// Update updates a group post, keeping the content it replaces as a revision
 func (s *GroupPostService) Update(post *GroupPost) error {
 	post.UpdatedAt = time.Now()
 
-	_, err := s.DB.Exec(`
-		UPDATE group_posts
-		SET content = ?, image = ?, updated_at = ?
-		WHERE id = ? AND user_id = ?
-	`, post.Content, post.Image, post.UpdatedAt, post.ID, post.UserID)
+	editedAt, err := updateContent(s.DB, RevisionTargetGroupPost, post.ID, post.UserID, post.Content, post.UpdatedAt,
+		", image = ?", post.Image)
 	if err != nil {
 		return fmt.Errorf("failed to update group post: %w", err)
 	}
+	if editedAt != nil {
+		post.EditedAt = editedAt
+	}
 
 	return nil
 }
@@ -200,6 +239,25 @@ func (s *GroupPostService) Delete(id, userID string) error {
 	return errors.New("not authorized to delete this post")
 }
 
+// ForceDelete deletes a group post regardless of who wrote it, for
+// platform staff, as part of the transaction recording the action
+func (s *GroupPostService) ForceDelete(tx *sql.Tx, id string) error {
+	result, err := tx.Exec("DELETE FROM group_posts WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete group post: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("group post not found")
+	}
+
+	return nil
+}
+
 // GetByGroup retrieves posts for a group
 func (s *GroupPostService) GetByGroup(groupID, currentUserID string, limit, offset int) ([]*GroupPost, error) {
 	// Check if the current user can view posts in this group
@@ -229,19 +287,26 @@ func (s *GroupPostService) GetByGroup(groupID, currentUserID string, limit, offs
 		}
 	}
 
-	// Get posts
+	// Get posts, leaving out those by blocked users
+	notBlocked, notBlockedArgs := notBlockedCondition("gp.user_id", currentUserID)
+	args := append(append([]interface{}{currentUserID, groupID}, notBlockedArgs...), limit, offset)
 	rows, err := s.DB.Query(`
-		SELECT gp.id, gp.group_id, gp.user_id, gp.content, gp.image, gp.created_at, gp.updated_at,
+		SELECT gp.id, gp.group_id, gp.user_id, gp.content, gp.image, gp.created_at, gp.updated_at, gp.edited_at,
 			u.id, u.username, u.full_name, u.profile_picture,
 			(SELECT COUNT(*) FROM likes WHERE post_id = gp.id) as likes_count,
 			(SELECT COUNT(*) FROM comments WHERE post_id = gp.id) as comments_count,
-			(SELECT COUNT(*) FROM likes WHERE post_id = gp.id AND user_id = ?) as is_liked
+			(SELECT json_group_object(reaction, reaction_count) FROM (
+				SELECT reaction, COUNT(*) AS reaction_count FROM likes WHERE post_id = gp.id GROUP BY reaction
+			)) as reaction_counts,
+			COALESCE((SELECT reaction FROM likes WHERE post_id = gp.id AND user_id = ?), '') as reaction,
+			(SELECT json_group_object(username, user_id) FROM mentions WHERE target_type = 'group_post' AND target_id = gp.id) as mentions,
+			`+linkPreviewsColumn(EntityTargetGroupPost, "gp.id")+`
 		FROM group_posts gp
 		JOIN users u ON gp.user_id = u.id
-		WHERE gp.group_id = ?
+		WHERE gp.group_id = ? AND `+notBlocked+`
 		ORDER BY gp.created_at DESC
 		LIMIT ? OFFSET ?
-	`, currentUserID, groupID, limit, offset)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get group posts: %w", err)
 	}
@@ -250,16 +315,15 @@ func (s *GroupPostService) GetByGroup(groupID, currentUserID string, limit, offs
 	var posts []*GroupPost
 	for rows.Next() {
 		post := &GroupPost{User: &User{}}
-		var isLikedCount int
 		err := rows.Scan(
-			&post.ID, &post.GroupID, &post.UserID, &post.Content, &post.Image, &post.CreatedAt, &post.UpdatedAt,
+			&post.ID, &post.GroupID, &post.UserID, &post.Content, &post.Image, &post.CreatedAt, &post.UpdatedAt, &post.EditedAt,
 			&post.User.ID, &post.User.Username, &post.User.FullName, &post.User.ProfilePicture,
-			&post.LikesCount, &post.CommentsCount, &isLikedCount,
+			&post.LikesCount, &post.CommentsCount, &post.ReactionCounts, &post.Reaction, &post.Mentions, &post.LinkPreviews,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan group post: %w", err)
 		}
-		post.IsLiked = isLikedCount > 0
+		post.IsLiked = post.Reaction != ""
 		posts = append(posts, post)
 	}
 
@@ -269,3 +333,66 @@ func (s *GroupPostService) GetByGroup(groupID, currentUserID string, limit, offs
 
 	return posts, nil
 }
+
+// GetByTagPage retrieves a page of the group posts using a hashtag in public
+// groups and groups the viewer belongs to, newest first, returning the cursor
+// for the next page. Posts by users on either side of a block with the viewer
+// are left out.
+func (s *GroupPostService) GetByTagPage(tag, viewerID string, page Page) ([]*GroupPost, string, error) {
+	cursorCondition, cursorArgs := page.where("gp.created_at", "gp.id")
+	orderAndLimit, limitArgs := page.orderAndLimit("gp.created_at", "gp.id")
+
+	// The visibility condition takes the viewer's ID three times
+	args := []interface{}{viewerID, tag, viewerID, viewerID, viewerID}
+	args = append(append(args, cursorArgs...), limitArgs...)
+
+	rows, err := s.DB.Query(`
+		SELECT gp.id, gp.group_id, gp.user_id, gp.content, gp.image, gp.created_at, gp.updated_at, gp.edited_at,
+			u.id, u.username, u.full_name, u.profile_picture,
+			g.id, g.name, g.privacy,
+			(SELECT COUNT(*) FROM likes WHERE post_id = gp.id) as likes_count,
+			(SELECT COUNT(*) FROM comments WHERE post_id = gp.id) as comments_count,
+			(SELECT json_group_object(reaction, reaction_count) FROM (
+				SELECT reaction, COUNT(*) AS reaction_count FROM likes WHERE post_id = gp.id GROUP BY reaction
+			)) as reaction_counts,
+			COALESCE((SELECT reaction FROM likes WHERE post_id = gp.id AND user_id = ?), '') as reaction,
+			(SELECT json_group_object(username, user_id) FROM mentions WHERE target_type = 'group_post' AND target_id = gp.id) as mentions,
+			`+linkPreviewsColumn(EntityTargetGroupPost, "gp.id")+`
+		FROM group_posts gp
+		JOIN users u ON gp.user_id = u.id
+		JOIN groups g ON gp.group_id = g.id
+		WHERE gp.id IN (SELECT target_id FROM content_tags WHERE target_type = 'group_post' AND tag = ?)
+		AND `+withoutBlocked(groupVisibilityCondition("gp.group_id"), "gp.user_id")+`
+		AND `+cursorCondition+`
+		`+orderAndLimit+`
+	`, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get group posts by tag: %w", err)
+	}
+	defer rows.Close()
+
+	var posts []*GroupPost
+	for rows.Next() {
+		post := &GroupPost{User: &User{}, Group: &Group{}}
+		err := rows.Scan(
+			&post.ID, &post.GroupID, &post.UserID, &post.Content, &post.Image, &post.CreatedAt, &post.UpdatedAt, &post.EditedAt,
+			&post.User.ID, &post.User.Username, &post.User.FullName, &post.User.ProfilePicture,
+			&post.Group.ID, &post.Group.Name, &post.Group.Privacy,
+			&post.LikesCount, &post.CommentsCount, &post.ReactionCounts, &post.Reaction, &post.Mentions, &post.LinkPreviews,
+		)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan group post: %w", err)
+		}
+		post.IsLiked = post.Reaction != ""
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating group posts: %w", err)
+	}
+
+	posts, nextCursor := nextPage(posts, page.Limit, func(post *GroupPost) (time.Time, string) {
+		return post.CreatedAt, post.ID
+	})
+	return posts, nextCursor, nil
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// LinkPreview is the preview of a page linked from a post, group post or
+// message
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	SiteName    string `json:"siteName,omitempty"`
+	Image       string `json:"image,omitempty"` // Copy of the page's preview image in the uploads
+	// Why the page couldn't be previewed the last time it was fetched
+	Error     string    `json:"-"`
+	FetchedAt time.Time `json:"-"`
+}
+
+// MarshalJSON adds the size variants of the preview image to its JSON
+func (p LinkPreview) MarshalJSON() ([]byte, error) {
+	type linkPreview LinkPreview
+	return json.Marshal(struct {
+		linkPreview
+		ImageSet *utils.ImageSet `json:"imageSet,omitempty"`
+	}{
+		linkPreview: linkPreview(p),
+		ImageSet:    utils.NewImageSet(p.Image),
+	})
+}
+
+// LinkPreviews are the previews of the links in some content, in the order
+// they appear. It scans the JSON array built by linkPreviewsColumn.
+type LinkPreviews []*LinkPreview
+
+// Scan implements sql.Scanner
+func (p *LinkPreviews) Scan(src interface{}) error {
+	*p = nil
+	return scanJSON(src, p, "link previews")
+}
+
+// linkPreviewsColumn selects the previews of the links in the content of
+// type targetType whose ID is in idColumn, leaving out pages that couldn't
+// be previewed
+func linkPreviewsColumn(targetType EntityTargetType, idColumn string) string {
+	return `(SELECT json_group_array(json_object('url', url, 'title', title, 'description', description, 'siteName', site_name, 'image', image)) FROM (
+				SELECT lp.* FROM content_links cl
+				JOIN link_previews lp ON lp.url = cl.url
+				WHERE cl.target_type = '` + string(targetType) + `' AND cl.target_id = ` + idColumn + ` AND lp.error = ''
+				ORDER BY cl.position
+			)) as link_previews`
+}
+
+// LinkPreviewService handles the cached previews of linked pages and the
+// links in posts, group posts and messages
+type LinkPreviewService struct {
+	DB *sql.DB
+}
+
+// NewLinkPreviewService creates a new LinkPreviewService
+func NewLinkPreviewService(db *sql.DB) *LinkPreviewService {
+	return &LinkPreviewService{DB: db}
+}
+
+// Get retrieves the cached preview of a page, including a failed one
+func (s *LinkPreviewService) Get(url string) (*LinkPreview, error) {
+	preview := &LinkPreview{}
+	err := s.DB.QueryRow(`
+		SELECT url, title, description, site_name, image, error, fetched_at
+		FROM link_previews
+		WHERE url = ?
+	`, url).Scan(&preview.URL, &preview.Title, &preview.Description, &preview.SiteName, &preview.Image, &preview.Error, &preview.FetchedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("link preview not found")
+		}
+		return nil, fmt.Errorf("failed to get link preview: %w", err)
+	}
+
+	return preview, nil
+}
+
+// Save caches the preview of a page, replacing the one cached before
+func (s *LinkPreviewService) Save(preview *LinkPreview) error {
+	preview.FetchedAt = time.Now()
+	_, err := s.DB.Exec(`
+		INSERT INTO link_previews (url, title, description, site_name, image, error, fetched_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET
+			title = excluded.title, description = excluded.description, site_name = excluded.site_name,
+			image = excluded.image, error = excluded.error, fetched_at = excluded.fetched_at
+	`, preview.URL, preview.Title, preview.Description, preview.SiteName, preview.Image, preview.Error, preview.FetchedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save link preview: %w", err)
+	}
+	return nil
+}
+
+// SyncLinks records the links in content that was just created or edited,
+// in order, dropping any it no longer has. It returns the previews of those
+// that could be previewed.
+func (s *LinkPreviewService) SyncLinks(targetType EntityTargetType, targetID string, urls []string) (LinkPreviews, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	positions := make(map[string]string, len(urls))
+	for i, url := range urls {
+		positions[url] = strconv.Itoa(i)
+	}
+	if err := syncRows(tx, "content_links", "url", targetType, targetID, positions, func(url string) error {
+		_, err := tx.Exec(`
+			INSERT INTO content_links (target_type, target_id, url, position)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (target_type, target_id, url) DO UPDATE SET position = excluded.position
+		`, targetType, targetID, url, positions[url])
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to save links: %w", err)
+	}
+
+	var previews LinkPreviews
+	err = tx.QueryRow(`SELECT `+linkPreviewsColumn(targetType, "?"), targetID).Scan(&previews)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get link previews: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return previews, nil
+}
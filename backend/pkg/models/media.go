@@ -0,0 +1,158 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
+)
+
+// MediaOwnerType is the kind of row an uploaded file belongs to
+type MediaOwnerType string
+
+const (
+	MediaOwnerAvatar    MediaOwnerType = "avatar"
+	MediaOwnerCover     MediaOwnerType = "cover"
+	MediaOwnerPost      MediaOwnerType = "post"
+	MediaOwnerGroupPost MediaOwnerType = "group_post"
+	MediaOwnerComment   MediaOwnerType = "comment"
+	MediaOwnerGroup     MediaOwnerType = "group"
+	// Preview images of linked pages, which don't belong to any user
+	MediaOwnerLinkPreview MediaOwnerType = "link_preview"
+)
+
+// MediaOwner is the row an uploaded file belongs to, with what is needed to
+// decide who may see it
+type MediaOwner struct {
+	Type         MediaOwnerType
+	ID           string
+	UserID       string         // User who uploaded the file, or whose profile it is on
+	UserPrivate  bool           // Whether the author of a post has a private account
+	Visibility   PostVisibility // Visibility of a post
+	GroupID      string         // Group a group post is in
+	GroupPrivacy GroupPrivacy   // Privacy of that group
+	Parent       *MediaOwner    // Post or group post a comment is on
+}
+
+// MediaService finds what uploaded files belong to
+type MediaService struct {
+	DB *sql.DB
+}
+
+// NewMediaService creates a new MediaService
+func NewMediaService(db *sql.DB) *MediaService {
+	return &MediaService{DB: db}
+}
+
+// mediaOwnerQueries find the row holding an image in each upload directory.
+// Each takes the length of the stored path's prefix and the prefix itself.
+var mediaOwnerQueries = map[string]struct {
+	ownerType MediaOwnerType
+	query     string
+}{
+	"avatars":       {MediaOwnerAvatar, `SELECT id, id FROM users WHERE substr(profile_picture, 1, ?) = ?`},
+	"covers":        {MediaOwnerCover, `SELECT id, id FROM users WHERE substr(cover_photo, 1, ?) = ?`},
+	"posts":         {MediaOwnerPost, `SELECT id, user_id FROM posts WHERE substr(image, 1, ?) = ?`},
+	"group_posts":   {MediaOwnerGroupPost, `SELECT id, user_id FROM group_posts WHERE substr(image, 1, ?) = ?`},
+	"comments":      {MediaOwnerComment, `SELECT id, user_id FROM comments WHERE substr(image, 1, ?) = ?`},
+	"groups":        {MediaOwnerGroup, `SELECT id, creator_id FROM groups WHERE substr(cover_photo, 1, ?) = ?`},
+	"link_previews": {MediaOwnerLinkPreview, `SELECT url, '' FROM link_previews WHERE substr(image, 1, ?) = ?`},
+}
+
+// GetOwner finds the row an uploaded file belongs to. The file may be any
+// variant of the image whose path the row stores.
+func (s *MediaService) GetOwner(imagePath string) (*MediaOwner, error) {
+	// Paths look like /uploads/<directory>/<name>
+	parts := strings.Split(strings.TrimPrefix(imagePath, "/uploads/"), "/")
+	if len(parts) != 2 {
+		return nil, errors.New("media not found")
+	}
+	lookup, ok := mediaOwnerQueries[parts[0]]
+	if !ok {
+		return nil, errors.New("media not found")
+	}
+
+	// Variants share the stored path up to its extension
+	prefix := utils.ImageBase(imagePath)
+	owner := &MediaOwner{Type: lookup.ownerType}
+	err := s.DB.QueryRow(lookup.query, len(prefix), prefix).Scan(&owner.ID, &owner.UserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("media not found")
+		}
+		return nil, fmt.Errorf("failed to find media owner: %w", err)
+	}
+
+	switch owner.Type {
+	case MediaOwnerPost:
+		err := s.DB.QueryRow(`
+			SELECT p.visibility, u.is_private
+			FROM posts p
+			JOIN users u ON p.user_id = u.id
+			WHERE p.id = ?
+		`, owner.ID).Scan(&owner.Visibility, &owner.UserPrivate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get post visibility: %w", err)
+		}
+	case MediaOwnerGroupPost:
+		if err := s.scanGroupPost(owner); err != nil {
+			return nil, err
+		}
+	case MediaOwnerComment:
+		parent, err := s.commentParent(owner.ID)
+		if err != nil {
+			return nil, err
+		}
+		owner.Parent = parent
+	}
+
+	return owner, nil
+}
+
+// scanGroupPost fills in the group a group post is in
+func (s *MediaService) scanGroupPost(owner *MediaOwner) error {
+	err := s.DB.QueryRow(`
+		SELECT g.id, g.privacy
+		FROM group_posts gp
+		JOIN groups g ON gp.group_id = g.id
+		WHERE gp.id = ?
+	`, owner.ID).Scan(&owner.GroupID, &owner.GroupPrivacy)
+	if err != nil {
+		return fmt.Errorf("failed to get group post privacy: %w", err)
+	}
+	return nil
+}
+
+// commentParent finds the post or group post a comment is on
+func (s *MediaService) commentParent(commentID string) (*MediaOwner, error) {
+	var postID string
+	if err := s.DB.QueryRow(`SELECT post_id FROM comments WHERE id = ?`, commentID).Scan(&postID); err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+
+	// Comments on posts and on group posts share a table
+	parent := &MediaOwner{Type: MediaOwnerPost, ID: postID}
+	err := s.DB.QueryRow(`
+		SELECT p.user_id, p.visibility, u.is_private
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.id = ?
+	`, postID).Scan(&parent.UserID, &parent.Visibility, &parent.UserPrivate)
+	if err == sql.ErrNoRows {
+		parent.Type = MediaOwnerGroupPost
+		err = s.DB.QueryRow(`SELECT user_id FROM group_posts WHERE id = ?`, postID).Scan(&parent.UserID)
+		if err == nil {
+			err = s.scanGroupPost(parent)
+		}
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("media not found")
+		}
+		return nil, fmt.Errorf("failed to get commented post: %w", err)
+	}
+
+	return parent, nil
+}
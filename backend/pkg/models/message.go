@@ -2,13 +2,19 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
+// MaxReactionLength is the maximum length in characters of a message reaction
+const MaxReactionLength = 16
+
 // Message represents a chat message
 type Message struct {
 	ID         string     `json:"id"`
@@ -18,8 +24,100 @@ type Message struct {
 	Content    string     `json:"content"`
 	CreatedAt  time.Time  `json:"createdAt"`
 	ReadAt     *time.Time `json:"readAt,omitempty"`
+	EditedAt   *time.Time `json:"editedAt,omitempty"`
+	DeletedAt  *time.Time `json:"deletedAt,omitempty"`
+	IsDeleted  bool       `json:"isDeleted"`
+	// ClientMessageID is the sender-supplied idempotency key used to dedupe retries
+	ClientMessageID string `json:"clientMessageId,omitempty"`
 	// Additional fields for API responses
-	Sender *User `json:"sender,omitempty"`
+	Sender       *User                     `json:"sender,omitempty"`
+	Reactions    []*MessageReactionSummary `json:"reactions,omitempty"`
+	Mentions     Mentions                  `json:"-"` // Sent as entities
+	LinkPreviews LinkPreviews              `json:"linkPreviews,omitempty"`
+}
+
+// MarshalJSON adds the mentions and hashtags in the message to its JSON
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	return json.Marshal(struct {
+		message
+		Entities []Entity `json:"entities,omitempty"`
+	}{
+		message:  message(m),
+		Entities: ContentEntities(m.Content, m.Mentions),
+	})
+}
+
+// MessageReactionSummary groups the reactions on a message by emoji
+type MessageReactionSummary struct {
+	Emoji   string   `json:"emoji"`
+	Count   int      `json:"count"`
+	UserIDs []string `json:"userIds"`
+}
+
+// messageColumns is the column list shared by message queries, scanned by scanMessage
+var messageColumns = `m.id, m.sender_id, m.receiver_id, m.group_id, m.content, m.created_at, m.read_at, m.edited_at, m.deleted_at, m.client_message_id,
+			u.id, u.username, u.full_name, u.profile_picture,
+			(SELECT json_group_object(username, user_id) FROM mentions WHERE target_type = 'message' AND target_id = m.id) as mentions,
+			` + linkPreviewsColumn(EntityTargetMessage, "m.id")
+
+// messageScanner is implemented by *sql.Row and *sql.Rows
+type messageScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMessage scans a row selected with messageColumns
+func scanMessage(row messageScanner) (*Message, error) {
+	message := &Message{Sender: &User{}}
+	var receiverID, groupID, clientMessageID, profilePicture sql.NullString
+	var readAt, editedAt, deletedAt sql.NullTime
+
+	err := row.Scan(
+		&message.ID, &message.SenderID, &receiverID, &groupID, &message.Content, &message.CreatedAt, &readAt, &editedAt, &deletedAt, &clientMessageID,
+		&message.Sender.ID, &message.Sender.Username, &message.Sender.FullName, &profilePicture, &message.Mentions, &message.LinkPreviews,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	message.ReceiverID = receiverID.String
+	message.GroupID = groupID.String
+	message.ClientMessageID = clientMessageID.String
+	message.Sender.ProfilePicture = profilePicture.String
+	if readAt.Valid {
+		message.ReadAt = &readAt.Time
+	}
+	if editedAt.Valid {
+		message.EditedAt = &editedAt.Time
+	}
+	if deletedAt.Valid {
+		message.DeletedAt = &deletedAt.Time
+		message.IsDeleted = true
+	}
+
+	return message, nil
+}
+
+// scanMessages scans all rows selected with messageColumns and attaches their reactions
+func (s *MessageService) scanMessages(rows *sql.Rows) ([]*Message, error) {
+	var messages []*Message
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan message: %w", err)
+		}
+		messages = append(messages, message)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating messages: %w", err)
+	}
+
+	if err := s.attachReactions(messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
 }
 
 // MessageService handles message-related operations
@@ -34,16 +132,35 @@ func NewMessageService(db *sql.DB) *MessageService {
 
 // Create creates a new message
 func (s *MessageService) Create(message *Message) error {
-	message.ID = uuid.New().String()
-	message.CreatedAt = time.Now()
+	_, err := s.CreateOnce(message)
+	return err
+}
 
+// CreateOnce creates a new message unless its sender already sent one with the
+// same client message ID. For such a retry nothing is inserted, message is filled
+// in from the stored copy and created is false.
+func (s *MessageService) CreateOnce(message *Message) (created bool, err error) {
 	// Validate that either receiverId or groupId is set, but not both
 	if (message.ReceiverID == "" && message.GroupID == "") || (message.ReceiverID != "" && message.GroupID != "") {
-		return errors.New("either receiverId or groupId must be set, but not both")
+		return false, errors.New("either receiverId or groupId must be set, but not both")
+	}
+
+	// Blocked users can't message each other
+	if message.ReceiverID != "" {
+		blocked, err := isBlockedBetween(s.DB, message.SenderID, message.ReceiverID)
+		if err != nil {
+			return false, err
+		}
+		if blocked {
+			return false, errors.New("user blocked")
+		}
 	}
 
+	message.ID = uuid.New().String()
+	message.CreatedAt = time.Now()
+
 	// Prepare SQL values - use NULL for empty strings to satisfy CHECK constraint
-	var receiverID, groupID interface{}
+	var receiverID, groupID, clientMessageID interface{}
 	if message.ReceiverID != "" {
 		receiverID = message.ReceiverID
 		groupID = nil // Explicitly set to NULL for private messages
@@ -51,33 +168,66 @@ func (s *MessageService) Create(message *Message) error {
 		receiverID = nil // Explicitly set to NULL for group messages
 		groupID = message.GroupID
 	}
+	if message.ClientMessageID != "" {
+		clientMessageID = message.ClientMessageID
+	}
 
-	_, err := s.DB.Exec(`
-		INSERT INTO messages (id, sender_id, receiver_id, group_id, content, created_at)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, message.ID, message.SenderID, receiverID, groupID, message.Content, message.CreatedAt)
+	result, err := s.DB.Exec(`
+		INSERT INTO messages (id, sender_id, receiver_id, group_id, content, created_at, client_message_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (sender_id, client_message_id) WHERE client_message_id IS NOT NULL DO NOTHING
+	`, message.ID, message.SenderID, receiverID, groupID, message.Content, message.CreatedAt, clientMessageID)
 	if err != nil {
-		return fmt.Errorf("failed to create message: %w", err)
+		return false, fmt.Errorf("failed to create message: %w", err)
 	}
 
-	return nil
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		existing, err := s.GetByClientMessageID(message.SenderID, message.ClientMessageID)
+		if err != nil {
+			return false, err
+		}
+		*message = *existing
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// GetByClientMessageID retrieves a message by its sender and client message ID
+func (s *MessageService) GetByClientMessageID(senderID, clientMessageID string) (*Message, error) {
+	message, err := scanMessage(s.DB.QueryRow(`
+		SELECT `+messageColumns+`
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		WHERE m.sender_id = ? AND m.client_message_id = ?
+	`, senderID, clientMessageID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("message not found")
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	if err := s.attachReactions([]*Message{message}); err != nil {
+		return nil, err
+	}
+
+	return message, nil
 }
 
 // GetByID retrieves a message by ID
 func (s *MessageService) GetByID(id string) (*Message, error) {
-	message := &Message{Sender: &User{}}
-	var readAt sql.NullTime
-
-	err := s.DB.QueryRow(`
-		SELECT m.id, m.sender_id, m.receiver_id, m.group_id, m.content, m.created_at, m.read_at,
-			u.id, u.username, u.full_name, u.profile_picture
+	message, err := scanMessage(s.DB.QueryRow(`
+		SELECT `+messageColumns+`
 		FROM messages m
 		JOIN users u ON m.sender_id = u.id
 		WHERE m.id = ?
-	`, id).Scan(
-		&message.ID, &message.SenderID, &message.ReceiverID, &message.GroupID, &message.Content, &message.CreatedAt, &readAt,
-		&message.Sender.ID, &message.Sender.Username, &message.Sender.FullName, &message.Sender.ProfilePicture,
-	)
+	`, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("message not found")
@@ -85,8 +235,8 @@ func (s *MessageService) GetByID(id string) (*Message, error) {
 		return nil, fmt.Errorf("failed to get message: %w", err)
 	}
 
-	if readAt.Valid {
-		message.ReadAt = &readAt.Time
+	if err := s.attachReactions([]*Message{message}); err != nil {
+		return nil, err
 	}
 
 	return message, nil
@@ -94,88 +244,304 @@ func (s *MessageService) GetByID(id string) (*Message, error) {
 
 // GetPrivateMessages retrieves private messages between two users
 func (s *MessageService) GetPrivateMessages(user1ID, user2ID string, limit, offset int) ([]*Message, error) {
+	messages, _, err := s.GetPrivateMessagesPage(user1ID, user2ID, Page{Limit: limit, Offset: offset})
+	return messages, err
+}
+
+// GetPrivateMessagesPage retrieves a page of private messages between two users, newest
+// first, returning the cursor for the next (older) page
+func (s *MessageService) GetPrivateMessagesPage(user1ID, user2ID string, page Page) ([]*Message, string, error) {
+	cursorCondition, cursorArgs := page.where("m.created_at", "m.id")
+	orderAndLimit, limitArgs := page.orderAndLimit("m.created_at", "m.id")
+	args := append(append([]interface{}{user1ID, user2ID, user2ID, user1ID}, cursorArgs...), limitArgs...)
+
 	rows, err := s.DB.Query(`
-		SELECT m.id, m.sender_id, m.receiver_id, m.content, m.created_at, m.read_at,
-			u.id, u.username, u.full_name, u.profile_picture
+		SELECT `+messageColumns+`
 		FROM messages m
 		JOIN users u ON m.sender_id = u.id
-		WHERE (m.sender_id = ? AND m.receiver_id = ?) OR (m.sender_id = ? AND m.receiver_id = ?)
-		ORDER BY m.created_at DESC
-		LIMIT ? OFFSET ?
-	`, user1ID, user2ID, user2ID, user1ID, limit, offset)
+		WHERE ((m.sender_id = ? AND m.receiver_id = ?) OR (m.sender_id = ? AND m.receiver_id = ?))
+		AND `+cursorCondition+`
+		`+orderAndLimit+`
+	`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get private messages: %w", err)
+		return nil, "", fmt.Errorf("failed to get private messages: %w", err)
 	}
 	defer rows.Close()
 
-	var messages []*Message
-	for rows.Next() {
-		message := &Message{Sender: &User{}}
-		var readAt sql.NullTime
+	return s.scanMessagePage(rows, page)
+}
 
-		err := rows.Scan(
-			&message.ID, &message.SenderID, &message.ReceiverID, &message.Content, &message.CreatedAt, &readAt,
-			&message.Sender.ID, &message.Sender.Username, &message.Sender.FullName, &message.Sender.ProfilePicture,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan message: %w", err)
-		}
+// GetGroupMessages retrieves messages for a group
+func (s *MessageService) GetGroupMessages(groupID string, limit, offset int) ([]*Message, error) {
+	messages, _, err := s.GetGroupMessagesPage(groupID, Page{Limit: limit, Offset: offset})
+	return messages, err
+}
 
-		if readAt.Valid {
-			message.ReadAt = &readAt.Time
-		}
+// GetGroupMessagesPage retrieves a page of messages for a group, newest first,
+// returning the cursor for the next (older) page
+func (s *MessageService) GetGroupMessagesPage(groupID string, page Page) ([]*Message, string, error) {
+	cursorCondition, cursorArgs := page.where("m.created_at", "m.id")
+	orderAndLimit, limitArgs := page.orderAndLimit("m.created_at", "m.id")
+	args := append(append([]interface{}{groupID}, cursorArgs...), limitArgs...)
 
-		messages = append(messages, message)
+	rows, err := s.DB.Query(`
+		SELECT `+messageColumns+`
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		WHERE m.group_id = ? AND `+cursorCondition+`
+		`+orderAndLimit+`
+	`, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get group messages: %w", err)
 	}
+	defer rows.Close()
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating messages: %w", err)
+	return s.scanMessagePage(rows, page)
+}
+
+// scanMessagePage scans a page of messages selected with Page clauses
+func (s *MessageService) scanMessagePage(rows *sql.Rows, page Page) ([]*Message, string, error) {
+	messages, err := s.scanMessages(rows)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return messages, nil
+	messages, nextCursor := nextPage(messages, page.Limit, func(message *Message) (time.Time, string) {
+		return message.CreatedAt, message.ID
+	})
+	return messages, nextCursor, nil
 }
 
-// GetGroupMessages retrieves messages for a group
-func (s *MessageService) GetGroupMessages(groupID string, limit, offset int) ([]*Message, error) {
+// GetMessagesSince retrieves, oldest first, the messages a user can see that were
+// sent after the given message: their direct messages, including ones they sent
+// from other devices, and messages in groups they belong to
+func (s *MessageService) GetMessagesSince(userID, sinceMessageID string, limit int) ([]*Message, error) {
+	var exists bool
+	err := s.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM messages WHERE id = ?)", sinceMessageID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check message: %w", err)
+	}
+	if !exists {
+		return nil, errors.New("message not found")
+	}
+
 	rows, err := s.DB.Query(`
-		SELECT m.id, m.sender_id, m.group_id, m.content, m.created_at, m.read_at,
-			u.id, u.username, u.full_name, u.profile_picture
+		SELECT `+messageColumns+`
 		FROM messages m
 		JOIN users u ON m.sender_id = u.id
-		WHERE m.group_id = ?
-		ORDER BY m.created_at DESC
-		LIMIT ? OFFSET ?
-	`, groupID, limit, offset)
+		WHERE (m.sender_id = ? OR m.receiver_id = ? OR m.group_id IN (
+			SELECT group_id FROM group_members WHERE user_id = ? AND status = 'accepted'
+		))
+		AND (m.created_at, m.id) > (SELECT created_at, id FROM messages WHERE id = ?)
+		ORDER BY m.created_at ASC, m.id ASC
+		LIMIT ?
+	`, userID, userID, userID, sinceMessageID, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get group messages: %w", err)
+		return nil, fmt.Errorf("failed to get messages since %s: %w", sinceMessageID, err)
 	}
 	defer rows.Close()
 
-	var messages []*Message
-	for rows.Next() {
-		message := &Message{Sender: &User{}}
-		var readAt sql.NullTime
+	return s.scanMessages(rows)
+}
 
-		err := rows.Scan(
-			&message.ID, &message.SenderID, &message.GroupID, &message.Content, &message.CreatedAt, &readAt,
-			&message.Sender.ID, &message.Sender.Username, &message.Sender.FullName, &message.Sender.ProfilePicture,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan message: %w", err)
+// Update edits the content of a message, which only its sender may do
+func (s *MessageService) Update(id, senderID, content string) (*Message, error) {
+	now := time.Now()
+	result, err := s.DB.Exec(`
+		UPDATE messages
+		SET content = ?, edited_at = ?
+		WHERE id = ? AND sender_id = ? AND deleted_at IS NULL
+	`, content, now, id, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update message: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return nil, errors.New("message not found or not authorized to edit")
+	}
+
+	return s.GetByID(id)
+}
+
+// Delete deletes a message for everyone, leaving a tombstone in its place
+func (s *MessageService) Delete(id, senderID string) (*Message, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := s.DeleteTx(tx, id, senderID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return s.GetByID(id)
+}
+
+// DeleteTx is Delete as part of a larger transaction, for staff removing a
+// reported message along with recording it
+func (s *MessageService) DeleteTx(tx *sql.Tx, id, senderID string) error {
+	result, err := tx.Exec(`
+		UPDATE messages
+		SET content = '', deleted_at = ?
+		WHERE id = ? AND sender_id = ? AND deleted_at IS NULL
+	`, time.Now(), id, senderID)
+	if err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("message not found or not authorized to delete")
+	}
+
+	// Reactions don't survive the tombstone
+	if _, err := tx.Exec("DELETE FROM message_reactions WHERE message_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete message reactions: %w", err)
+	}
+
+	return nil
+}
+
+// AddReaction adds an emoji reaction from a user to a message
+func (s *MessageService) AddReaction(messageID, userID, emoji string) error {
+	if err := validateReaction(emoji); err != nil {
+		return err
+	}
+
+	var deletedAt sql.NullTime
+	err := s.DB.QueryRow("SELECT deleted_at FROM messages WHERE id = ?", messageID).Scan(&deletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("message not found")
 		}
+		return fmt.Errorf("failed to check message: %w", err)
+	}
 
-		if readAt.Valid {
-			message.ReadAt = &readAt.Time
+	if deletedAt.Valid {
+		return errors.New("cannot react to a deleted message")
+	}
+
+	_, err = s.DB.Exec(`
+		INSERT INTO message_reactions (id, message_id, user_id, emoji, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (message_id, user_id, emoji) DO NOTHING
+	`, uuid.New().String(), messageID, userID, emoji, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to add reaction: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveReaction removes a user's emoji reaction from a message
+func (s *MessageService) RemoveReaction(messageID, userID, emoji string) error {
+	result, err := s.DB.Exec(`
+		DELETE FROM message_reactions
+		WHERE message_id = ? AND user_id = ? AND emoji = ?
+	`, messageID, userID, emoji)
+	if err != nil {
+		return fmt.Errorf("failed to remove reaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("reaction not found")
+	}
+
+	return nil
+}
+
+// GetReactions returns the reactions on a message grouped by emoji
+func (s *MessageService) GetReactions(messageID string) ([]*MessageReactionSummary, error) {
+	message := &Message{ID: messageID}
+	if err := s.attachReactions([]*Message{message}); err != nil {
+		return nil, err
+	}
+
+	return message.Reactions, nil
+}
+
+// attachReactions loads the grouped reactions for a batch of messages
+func (s *MessageService) attachReactions(messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	byID := make(map[string]*Message, len(messages))
+	placeholders := make([]string, 0, len(messages))
+	args := make([]interface{}, 0, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+		placeholders = append(placeholders, "?")
+		args = append(args, message.ID)
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT message_id, emoji, user_id
+		FROM message_reactions
+		WHERE message_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY created_at ASC
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get message reactions: %w", err)
+	}
+	defer rows.Close()
+
+	summaries := make(map[string]map[string]*MessageReactionSummary)
+	for rows.Next() {
+		var messageID, emoji, userID string
+		if err := rows.Scan(&messageID, &emoji, &userID); err != nil {
+			return fmt.Errorf("failed to scan message reaction: %w", err)
 		}
 
-		messages = append(messages, message)
+		if summaries[messageID] == nil {
+			summaries[messageID] = make(map[string]*MessageReactionSummary)
+		}
+		summary, ok := summaries[messageID][emoji]
+		if !ok {
+			summary = &MessageReactionSummary{Emoji: emoji}
+			summaries[messageID][emoji] = summary
+			// Keep emojis in the order they were first used
+			byID[messageID].Reactions = append(byID[messageID].Reactions, summary)
+		}
+		summary.Count++
+		summary.UserIDs = append(summary.UserIDs, userID)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating messages: %w", err)
+		return fmt.Errorf("error iterating message reactions: %w", err)
 	}
 
-	return messages, nil
+	return nil
+}
+
+// validateReaction checks that a reaction is a short emoji-like token
+func validateReaction(emoji string) error {
+	if emoji == "" || strings.TrimSpace(emoji) != emoji || strings.ContainsAny(emoji, " \t\n") {
+		return errors.New("invalid reaction")
+	}
+	if utf8.RuneCountInString(emoji) > MaxReactionLength {
+		return errors.New("reaction is too long")
+	}
+	return nil
 }
 
 // MarkAsRead marks a message as read
@@ -226,44 +592,3 @@ func (s *MessageService) GetUnreadCount(userID string) (int, error) {
 
 	return count, nil
 }
-
-// GetConversations returns a list of users the current user has conversations with
-func (s *MessageService) GetConversations(userID string) ([]*User, error) {
-	rows, err := s.DB.Query(`
-		SELECT DISTINCT u.id, u.username, u.email, u.password, u.full_name, u.bio, u.profile_picture, u.cover_photo, u.is_private, u.created_at, u.updated_at
-		FROM users u
-		JOIN messages m ON (m.sender_id = u.id AND m.receiver_id = ?) OR (m.receiver_id = u.id AND m.sender_id = ?)
-		WHERE u.id != ?
-		ORDER BY (
-			SELECT MAX(created_at)
-			FROM messages
-			WHERE (sender_id = u.id AND receiver_id = ?) OR (receiver_id = u.id AND sender_id = ?)
-		) DESC
-	`, userID, userID, userID, userID, userID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get conversations: %w", err)
-	}
-	defer rows.Close()
-
-	var users []*User
-	for rows.Next() {
-		user := &User{}
-		err := rows.Scan(
-			&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.CreatedAt, &user.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan user: %w", err)
-		}
-
-		// Remove password from response
-		user.Password = ""
-
-		users = append(users, user)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating users: %w", err)
-	}
-
-	return users, nil
-}
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ReadReceipt records that a user has read a conversation up to a message
+type ReadReceipt struct {
+	ReaderID      string    `json:"readerId"`
+	UserID        string    `json:"userId,omitempty"`  // Other participant, for direct messages
+	GroupID       string    `json:"groupId,omitempty"` // For group messages
+	UpToMessageID string    `json:"upToMessageId"`
+	ReadAt        time.Time `json:"readAt"`
+}
+
+// GroupReadCursor is how far a group member has read the group chat
+type GroupReadCursor struct {
+	GroupID           string    `json:"groupId"`
+	UserID            string    `json:"userId"`
+	LastReadMessageID string    `json:"lastReadMessageId"`
+	LastReadMessageAt time.Time `json:"lastReadMessageAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// UnreadCounts holds a user's unread message counts per conversation
+type UnreadCounts struct {
+	Total  int            `json:"total"`
+	Direct map[string]int `json:"direct"` // Keyed by the other user's ID
+	Groups map[string]int `json:"groups"` // Keyed by group ID
+}
+
+// MarkDirectReadUpTo marks the messages otherUserID sent to readerID as read, up to
+// and including upToMessageID. An empty upToMessageID marks the whole conversation.
+func (s *MessageService) MarkDirectReadUpTo(readerID, otherUserID, upToMessageID string) (*ReadReceipt, error) {
+	var err error
+	if upToMessageID == "" {
+		err = s.DB.QueryRow(`
+			SELECT id FROM messages
+			WHERE (sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		`, readerID, otherUserID, otherUserID, readerID).Scan(&upToMessageID)
+	} else {
+		err = s.DB.QueryRow(`
+			SELECT id FROM messages
+			WHERE id = ? AND ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))
+		`, upToMessageID, readerID, otherUserID, otherUserID, readerID).Scan(&upToMessageID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("message not found")
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	now := time.Now()
+	_, err = s.DB.Exec(`
+		UPDATE messages
+		SET read_at = ?
+		WHERE sender_id = ? AND receiver_id = ? AND read_at IS NULL
+		AND (created_at, id) <= (SELECT created_at, id FROM messages WHERE id = ?)
+	`, now, otherUserID, readerID, upToMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to mark messages as read: %w", err)
+	}
+
+	return &ReadReceipt{
+		ReaderID:      readerID,
+		UserID:        otherUserID,
+		UpToMessageID: upToMessageID,
+		ReadAt:        now,
+	}, nil
+}
+
+// MarkGroupReadUpTo moves a member's read cursor for a group chat forward to
+// upToMessageID. An empty upToMessageID marks the whole chat. The cursor never
+// moves backwards.
+func (s *MessageService) MarkGroupReadUpTo(readerID, groupID, upToMessageID string) (*ReadReceipt, error) {
+	var err error
+	if upToMessageID == "" {
+		err = s.DB.QueryRow(`
+			SELECT id FROM messages
+			WHERE group_id = ?
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		`, groupID).Scan(&upToMessageID)
+	} else {
+		err = s.DB.QueryRow("SELECT id FROM messages WHERE id = ? AND group_id = ?", upToMessageID, groupID).Scan(&upToMessageID)
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("message not found")
+		}
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+
+	now := time.Now()
+	_, err = s.DB.Exec(`
+		INSERT INTO group_message_reads (group_id, user_id, last_read_message_id, last_read_message_at, updated_at)
+		SELECT ?, ?, id, created_at, ? FROM messages WHERE id = ?
+		ON CONFLICT (group_id, user_id) DO UPDATE SET
+			last_read_message_id = excluded.last_read_message_id,
+			last_read_message_at = excluded.last_read_message_at,
+			updated_at = excluded.updated_at
+		WHERE (excluded.last_read_message_at, excluded.last_read_message_id) >
+			(group_message_reads.last_read_message_at, group_message_reads.last_read_message_id)
+	`, groupID, readerID, now, upToMessageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update read cursor: %w", err)
+	}
+
+	return &ReadReceipt{
+		ReaderID:      readerID,
+		GroupID:       groupID,
+		UpToMessageID: upToMessageID,
+		ReadAt:        now,
+	}, nil
+}
+
+// GetGroupReadCursors returns how far each member has read a group chat
+func (s *MessageService) GetGroupReadCursors(groupID string) ([]*GroupReadCursor, error) {
+	rows, err := s.DB.Query(`
+		SELECT r.group_id, r.user_id, r.last_read_message_id, r.last_read_message_at, r.updated_at
+		FROM group_message_reads r
+		JOIN group_members gm ON gm.group_id = r.group_id AND gm.user_id = r.user_id AND gm.status = 'accepted'
+		WHERE r.group_id = ?
+		ORDER BY r.last_read_message_at DESC
+	`, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get read cursors: %w", err)
+	}
+	defer rows.Close()
+
+	var cursors []*GroupReadCursor
+	for rows.Next() {
+		cursor := &GroupReadCursor{}
+		err := rows.Scan(&cursor.GroupID, &cursor.UserID, &cursor.LastReadMessageID, &cursor.LastReadMessageAt, &cursor.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan read cursor: %w", err)
+		}
+		cursors = append(cursors, cursor)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating read cursors: %w", err)
+	}
+
+	return cursors, nil
+}
+
+// GetUnreadCounts returns a user's unread message counts for each direct
+// conversation and group chat that has any. Group messages count as unread
+// when they are newer than the member's read cursor, or than when they joined
+// if they haven't read the chat yet.
+func (s *MessageService) GetUnreadCounts(userID string) (*UnreadCounts, error) {
+	counts := &UnreadCounts{
+		Direct: make(map[string]int),
+		Groups: make(map[string]int),
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT sender_id, COUNT(*)
+		FROM messages
+		WHERE receiver_id = ? AND read_at IS NULL AND deleted_at IS NULL
+		GROUP BY sender_id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get direct unread counts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var senderID string
+		var count int
+		if err := rows.Scan(&senderID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan unread count: %w", err)
+		}
+		counts.Direct[senderID] = count
+		counts.Total += count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating unread counts: %w", err)
+	}
+
+	groupRows, err := s.DB.Query(`
+		SELECT gm.group_id, COUNT(m.id)
+		FROM group_members gm
+		LEFT JOIN group_message_reads r ON r.group_id = gm.group_id AND r.user_id = gm.user_id
+		JOIN messages m ON m.group_id = gm.group_id
+			AND m.sender_id != gm.user_id
+			AND m.deleted_at IS NULL
+			AND (m.created_at, m.id) > (COALESCE(r.last_read_message_at, gm.created_at), COALESCE(r.last_read_message_id, ''))
+		WHERE gm.user_id = ? AND gm.status = 'accepted'
+		GROUP BY gm.group_id
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group unread counts: %w", err)
+	}
+	defer groupRows.Close()
+
+	for groupRows.Next() {
+		var groupID string
+		var count int
+		if err := groupRows.Scan(&groupID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan unread count: %w", err)
+		}
+		counts.Groups[groupID] = count
+		counts.Total += count
+	}
+
+	if err := groupRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating unread counts: %w", err)
+	}
+
+	return counts, nil
+}
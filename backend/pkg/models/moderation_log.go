@@ -0,0 +1,178 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ModerationAction names something platform staff did
+type ModerationAction string
+
+const (
+	ModerationActionSuspendUser   ModerationAction = "suspend_user"
+	ModerationActionBanUser       ModerationAction = "ban_user"
+	ModerationActionReinstateUser ModerationAction = "reinstate_user"
+	ModerationActionChangeRole    ModerationAction = "change_role"
+	ModerationActionUnlockAccount ModerationAction = "unlock_account"
+	ModerationActionDeletePost    ModerationAction = "delete_post"
+	ModerationActionDeleteComment ModerationAction = "delete_comment"
+	ModerationActionDeleteGroup   ModerationAction = "delete_group"
+	ModerationActionDeleteMessage ModerationAction = "delete_message"
+	ModerationActionClaimReport   ModerationAction = "claim_report"
+	ModerationActionResolveReport ModerationAction = "resolve_report"
+	ModerationActionDismissReport ModerationAction = "dismiss_report"
+)
+
+// ModerationTarget names the kind of thing a moderation action applied to
+type ModerationTarget string
+
+const (
+	ModerationTargetUser    ModerationTarget = "user"
+	ModerationTargetPost    ModerationTarget = "post"
+	ModerationTargetComment ModerationTarget = "comment"
+	ModerationTargetGroup   ModerationTarget = "group"
+	ModerationTargetMessage ModerationTarget = "message"
+	ModerationTargetReport  ModerationTarget = "report"
+)
+
+// ModerationLogEntry records one staff action. Entries are never changed or
+// deleted, and keep their IDs even after the actor or target is gone.
+type ModerationLogEntry struct {
+	ID         string           `json:"id"`
+	ActorID    string           `json:"actorId"`
+	Action     ModerationAction `json:"action"`
+	TargetType ModerationTarget `json:"targetType"`
+	TargetID   string           `json:"targetId"`
+	Reason     string           `json:"reason,omitempty"`
+	Details    string           `json:"details,omitempty"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	Actor      *User            `json:"actor,omitempty"`
+}
+
+// ModerationLogFilter narrows the audit log
+type ModerationLogFilter struct {
+	ActorID    string
+	TargetType ModerationTarget
+	TargetID   string
+}
+
+// ModerationLogService handles the moderation audit log
+type ModerationLogService struct {
+	DB *sql.DB
+}
+
+// NewModerationLogService creates a new ModerationLogService
+func NewModerationLogService(db *sql.DB) *ModerationLogService {
+	return &ModerationLogService{DB: db}
+}
+
+// Moderate takes a staff action in one transaction with the entries that
+// record it, so the log never misses an action or lists one that failed.
+// act makes the changes and calls Record with tx; nothing is kept unless it
+// returns nil.
+func (s *ModerationLogService) Moderate(act func(tx *sql.Tx) error) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := act(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Record appends an entry to the audit log as part of the transaction
+// taking the action
+func (s *ModerationLogService) Record(tx *sql.Tx, entry *ModerationLogEntry) error {
+	entry.ID = uuid.New().String()
+	entry.CreatedAt = time.Now()
+
+	_, err := tx.Exec(`
+		INSERT INTO moderation_audit_log (id, actor_id, action, target_type, target_id, reason, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.ID, entry.ActorID, entry.Action, entry.TargetType, entry.TargetID, entry.Reason, entry.Details, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record moderation action: %w", err)
+	}
+
+	return nil
+}
+
+// List retrieves a page of audit log entries, newest first, returning the cursor for the next page
+func (s *ModerationLogService) List(filter ModerationLogFilter, page Page) ([]*ModerationLogEntry, string, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.ActorID != "" {
+		conditions = append(conditions, "m.actor_id = ?")
+		args = append(args, filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "m.target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		conditions = append(conditions, "m.target_id = ?")
+		args = append(args, filter.TargetID)
+	}
+
+	cursorCondition, cursorArgs := page.where("m.created_at", "m.id")
+	conditions = append(conditions, cursorCondition)
+	args = append(args, cursorArgs...)
+	orderAndLimit, limitArgs := page.orderAndLimit("m.created_at", "m.id")
+	args = append(args, limitArgs...)
+
+	// Actors are joined loosely so entries outlive deleted accounts
+	rows, err := s.DB.Query(`
+		SELECT m.id, m.actor_id, m.action, m.target_type, m.target_id, m.reason, m.details, m.created_at,
+			u.id, u.username, u.full_name, u.profile_picture
+		FROM moderation_audit_log m
+		LEFT JOIN users u ON m.actor_id = u.id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		`+orderAndLimit, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get moderation log: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*ModerationLogEntry
+	for rows.Next() {
+		entry := &ModerationLogEntry{}
+		var actorID, actorUsername, actorFullName, actorPicture sql.NullString
+		err := rows.Scan(
+			&entry.ID, &entry.ActorID, &entry.Action, &entry.TargetType, &entry.TargetID, &entry.Reason, &entry.Details, &entry.CreatedAt,
+			&actorID, &actorUsername, &actorFullName, &actorPicture,
+		)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan moderation log entry: %w", err)
+		}
+		if actorID.Valid {
+			entry.Actor = &User{
+				ID:             actorID.String,
+				Username:       actorUsername.String,
+				FullName:       actorFullName.String,
+				ProfilePicture: actorPicture.String,
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating moderation log: %w", err)
+	}
+
+	entries, nextCursor := nextPage(entries, page.Limit, func(entry *ModerationLogEntry) (time.Time, string) {
+		return entry.CreatedAt, entry.ID
+	})
+	return entries, nextCursor, nil
+}
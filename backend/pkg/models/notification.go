@@ -22,12 +22,16 @@ const (
 	NotificationTypeNewFollower       NotificationType = "new_follower"
 	NotificationTypePostLike          NotificationType = "post_like"
 	NotificationTypePostComment       NotificationType = "post_comment"
+	NotificationTypeCommentReply      NotificationType = "comment_reply"
+	NotificationTypeCommentLike       NotificationType = "comment_like"
 	NotificationTypeGroupInvite       NotificationType = "group_invite"
 	NotificationTypeGroupJoinRequest  NotificationType = "group_join_request"
 	NotificationTypeGroupJoinApproved NotificationType = "group_join_approved"
 	NotificationTypeGroupJoinRejected NotificationType = "group_join_rejected"
 	NotificationTypeEventInvite       NotificationType = "event_invite"
 	NotificationTypeGroupEventCreated NotificationType = "group_event_created"
+	NotificationTypeReportResolved    NotificationType = "report_resolved"
+	NotificationTypeMention           NotificationType = "mention"
 )
 
 const (
@@ -75,8 +79,17 @@ func (s *NotificationService) SetBroadcastFunction(fn func(interface{})) {
 	s.BroadcastFunc = fn
 }
 
-// Create creates a new notification
+// Create creates a new notification. Nothing is created between users on
+// either side of a block.
 func (s *NotificationService) Create(notification *Notification) error {
+	blocked, err := isBlockedBetween(s.DB, notification.UserID, notification.SenderID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return nil
+	}
+
 	notification.ID = uuid.New().String()
 	notification.CreatedAt = time.Now()
 
@@ -85,7 +98,7 @@ func (s *NotificationService) Create(notification *Notification) error {
 		notification.Status = NotificationStatusPending
 	}
 
-	_, err := s.DB.Exec(`
+	_, err = s.DB.Exec(`
 		INSERT INTO notifications (id, user_id, sender_id, type, content, data, status, created_at)
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`, notification.ID, notification.UserID, notification.SenderID, notification.Type, notification.Content, notification.Data, notification.Status, notification.CreatedAt)
@@ -101,6 +114,19 @@ func (s *NotificationService) Create(notification *Notification) error {
 
 // CreateBatch creates multiple notifications in a single transaction
 func (s *NotificationService) CreateBatch(notifications []*Notification) error {
+	// Leave out any between users on either side of a block
+	deliverable := make([]*Notification, 0, len(notifications))
+	for _, notification := range notifications {
+		blocked, err := isBlockedBetween(s.DB, notification.UserID, notification.SenderID)
+		if err != nil {
+			return err
+		}
+		if !blocked {
+			deliverable = append(deliverable, notification)
+		}
+	}
+	notifications = deliverable
+
 	if len(notifications) == 0 {
 		return nil
 	}
@@ -180,17 +206,27 @@ func (s *NotificationService) GetByID(id string) (*Notification, error) {
 
 // GetByUser retrieves notifications for a user
 func (s *NotificationService) GetByUser(userID string, limit, offset int) ([]*Notification, error) {
+	notifications, _, err := s.GetByUserPage(userID, Page{Limit: limit, Offset: offset})
+	return notifications, err
+}
+
+// GetByUserPage retrieves a page of notifications for a user, newest first,
+// returning the cursor for the next (older) page
+func (s *NotificationService) GetByUserPage(userID string, page Page) ([]*Notification, string, error) {
+	cursorCondition, cursorArgs := page.where("n.created_at", "n.id")
+	orderAndLimit, limitArgs := page.orderAndLimit("n.created_at", "n.id")
+	args := append(append([]interface{}{userID}, cursorArgs...), limitArgs...)
+
 	rows, err := s.DB.Query(`
 		SELECT n.id, n.user_id, n.sender_id, n.type, n.content, n.data, n.status, n.read_at, n.created_at,
 			u.id, u.username, u.full_name, u.profile_picture
 		FROM notifications n
 		JOIN users u ON n.sender_id = u.id
-		WHERE n.user_id = ?
-		ORDER BY n.created_at DESC
-		LIMIT ? OFFSET ?
-	`, userID, limit, offset)
+		WHERE n.user_id = ? AND `+cursorCondition+`
+		`+orderAndLimit+`
+	`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get notifications: %w", err)
+		return nil, "", fmt.Errorf("failed to get notifications: %w", err)
 	}
 	defer rows.Close()
 
@@ -204,7 +240,7 @@ func (s *NotificationService) GetByUser(userID string, limit, offset int) ([]*No
 			&notification.Sender.ID, &notification.Sender.Username, &notification.Sender.FullName, &notification.Sender.ProfilePicture,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan notification: %w", err)
+			return nil, "", fmt.Errorf("failed to scan notification: %w", err)
 		}
 
 		if readAt.Valid {
@@ -221,10 +257,13 @@ func (s *NotificationService) GetByUser(userID string, limit, offset int) ([]*No
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating notifications: %w", err)
+		return nil, "", fmt.Errorf("error iterating notifications: %w", err)
 	}
 
-	return notifications, nil
+	notifications, nextCursor := nextPage(notifications, page.Limit, func(notification *Notification) (time.Time, string) {
+		return notification.CreatedAt, notification.ID
+	})
+	return notifications, nextCursor, nil
 }
 
 // MarkAsRead marks a notification as read
@@ -358,7 +397,7 @@ func (s *NotificationService) enhanceNotificationData(notification *Notification
 	switch notification.Type {
 	case NotificationTypePostLike:
 		return s.enhancePostLikeNotification(notification)
-	case NotificationTypePostComment:
+	case NotificationTypePostComment, NotificationTypeCommentReply, NotificationTypeCommentLike, NotificationTypeMention:
 		return s.enhancePostCommentNotification(notification)
 	case NotificationTypeGroupEventCreated:
 		return s.enhanceGroupEventNotification(notification)
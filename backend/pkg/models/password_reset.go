@@ -0,0 +1,127 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordResetService handles single-use password reset tokens. Only a hash
+// of each token is stored, so a leaked database cannot be used to reset passwords.
+type PasswordResetService struct {
+	DB *sql.DB
+}
+
+// NewPasswordResetService creates a new PasswordResetService
+func NewPasswordResetService(db *sql.DB) *PasswordResetService {
+	return &PasswordResetService{DB: db}
+}
+
+// Create issues a reset token for a user valid for ttl and returns the raw token to send them
+func (s *PasswordResetService) Create(userID string, ttl time.Duration) (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	now := time.Now()
+
+	// Drop the user's spent tokens so the table does not grow without bound
+	if _, err := s.DB.Exec(`
+		DELETE FROM password_reset_tokens WHERE user_id = ? AND (expires_at < ? OR used_at IS NOT NULL)
+	`, userID, now); err != nil {
+		return "", fmt.Errorf("failed to delete old reset tokens: %w", err)
+	}
+
+	_, err := s.DB.Exec(`
+		INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, uuid.New().String(), userID, hashToken(token), now.Add(ttl), now)
+	if err != nil {
+		return "", fmt.Errorf("failed to create reset token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Consume checks a reset token and sets the password of the user it was
+// issued to, returning their ID. The token, and any other outstanding tokens
+// for the user, are used up in the same transaction as the password is
+// changed, so a token is only spent if the password changed.
+func (s *PasswordResetService) Consume(token, newPassword string) (string, error) {
+	// Hash before taking the write lock, as bcrypt is slow
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id, userID string
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRow(`
+		SELECT id, user_id, expires_at, used_at
+		FROM password_reset_tokens
+		WHERE token_hash = ?
+	`, hashToken(token)).Scan(&id, &userID, &expiresAt, &usedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.New("invalid or expired reset token")
+		}
+		return "", fmt.Errorf("failed to get reset token: %w", err)
+	}
+
+	now := time.Now()
+	if usedAt.Valid || expiresAt.Before(now) {
+		return "", errors.New("invalid or expired reset token")
+	}
+
+	// Claim the token; a concurrent reset with the same token finds it already used
+	result, err := tx.Exec(`
+		UPDATE password_reset_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL
+	`, now, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to use reset token: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return "", errors.New("invalid or expired reset token")
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL
+	`, now, userID); err != nil {
+		return "", fmt.Errorf("failed to revoke other reset tokens: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE users SET password = ?, updated_at = ? WHERE id = ?
+	`, string(hashedPassword), now, userID); err != nil {
+		return "", fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userID, nil
+}
+
+// hashToken returns the stored form of a secret token or code
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,10 +2,12 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
 	"github.com/google/uuid"
 	// Import the models package to access GroupPrivacy
 	// _ "social-network/backend/pkg/models"
@@ -31,11 +33,33 @@ type Post struct {
 	Visibility PostVisibility `json:"visibility"`
 	CreatedAt  time.Time      `json:"createdAt"`
 	UpdatedAt  time.Time      `json:"updatedAt"`
+	EditedAt   *time.Time     `json:"editedAt,omitempty"` // When the content was last edited, if ever
 	// Additional fields for API responses
-	User          *User `json:"author,omitempty"`
-	LikesCount    int   `json:"likesCount,omitempty"`
-	CommentsCount int   `json:"commentsCount,omitempty"`
-	IsLiked       bool  `json:"isLikedByCurrentUser,omitempty"`
+	User           *User          `json:"author,omitempty"`
+	LikesCount     int            `json:"likesCount,omitempty"` // Reactions of any type
+	CommentsCount  int            `json:"commentsCount,omitempty"`
+	IsLiked        bool           `json:"isLikedByCurrentUser,omitempty"` // Whether the viewer reacted at all
+	ReactionCounts ReactionCounts `json:"reactionCounts,omitempty"`
+	Reaction       ReactionType   `json:"reaction,omitempty"` // The viewer's reaction
+	Mentions       Mentions       `json:"-"`                  // Sent as entities
+	LinkPreviews   LinkPreviews   `json:"linkPreviews,omitempty"`
+}
+
+// MarshalJSON adds the size variants of the post's image, whether it was
+// edited, and the mentions and hashtags in it to its JSON
+func (p Post) MarshalJSON() ([]byte, error) {
+	type post Post
+	return json.Marshal(struct {
+		post
+		ImageSet *utils.ImageSet `json:"imageSet,omitempty"`
+		Edited   bool            `json:"edited"`
+		Entities []Entity        `json:"entities,omitempty"`
+	}{
+		post:     post(p),
+		ImageSet: utils.NewImageSet(p.Image),
+		Edited:   p.EditedAt != nil,
+		Entities: ContentEntities(p.Content, p.Mentions),
+	})
 }
 
 // PostService handles post-related operations
@@ -72,18 +96,23 @@ func (s *PostService) GetByID(id string, currentUserID string) (*Post, error) {
 	var image sql.NullString
 	var profilePicture sql.NullString
 	err := s.DB.QueryRow(`
-		SELECT p.id, p.user_id, p.content, p.image, p.visibility, p.created_at, p.updated_at,
+		SELECT p.id, p.user_id, p.content, p.image, p.visibility, p.created_at, p.updated_at, p.edited_at,
 			u.id, u.username, u.full_name, u.profile_picture,
 			(SELECT COUNT(*) FROM likes WHERE post_id = p.id) as likes_count,
 			(SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comments_count,
-			(SELECT COUNT(*) FROM likes WHERE post_id = p.id AND user_id = ?) as is_liked
+			(SELECT json_group_object(reaction, reaction_count) FROM (
+				SELECT reaction, COUNT(*) AS reaction_count FROM likes WHERE post_id = p.id GROUP BY reaction
+			)) as reaction_counts,
+			COALESCE((SELECT reaction FROM likes WHERE post_id = p.id AND user_id = ?), '') as reaction,
+			(SELECT json_group_object(username, user_id) FROM mentions WHERE target_type = 'post' AND target_id = p.id) as mentions,
+			`+linkPreviewsColumn(EntityTargetPost, "p.id")+`
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
 		WHERE p.id = ?
 	`, currentUserID, id).Scan(
-		&post.ID, &post.UserID, &post.Content, &image, &post.Visibility, &post.CreatedAt, &post.UpdatedAt,
+		&post.ID, &post.UserID, &post.Content, &image, &post.Visibility, &post.CreatedAt, &post.UpdatedAt, &post.EditedAt,
 		&post.User.ID, &post.User.Username, &post.User.FullName, &profilePicture,
-		&post.LikesCount, &post.CommentsCount, &post.IsLiked,
+		&post.LikesCount, &post.CommentsCount, &post.ReactionCounts, &post.Reaction, &post.Mentions, &post.LinkPreviews,
 	)
 
 	// Handle nullable fields
@@ -93,6 +122,7 @@ func (s *PostService) GetByID(id string, currentUserID string) (*Post, error) {
 	if profilePicture.Valid {
 		post.User.ProfilePicture = profilePicture.String
 	}
+	post.IsLiked = post.Reaction != ""
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("post not found")
@@ -100,6 +130,17 @@ func (s *PostService) GetByID(id string, currentUserID string) (*Post, error) {
 		return nil, fmt.Errorf("failed to get post: %w", err)
 	}
 
+	// Neither side of a block can see the other's posts
+	if post.UserID != currentUserID && currentUserID != "" {
+		blocked, err := isBlockedBetween(s.DB, currentUserID, post.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, errors.New("post not found")
+		}
+	}
+
 	// Check if the current user can view this post
 	// Regular posts don't have group associations, so we only check post visibility
 	if post.Visibility != PostVisibilityPublic && post.UserID != currentUserID {
@@ -142,18 +183,18 @@ func (s *PostService) GetByID(id string, currentUserID string) (*Post, error) {
 	return post, nil
 }
 
-// Update updates a post
+// Update updates a post, keeping the content it replaces as a revision
 func (s *PostService) Update(post *Post) error {
 	post.UpdatedAt = time.Now()
 
-	_, err := s.DB.Exec(`
-		UPDATE posts
-		SET content = ?, image = ?, visibility = ?, updated_at = ?
-		WHERE id = ? AND user_id = ?
-	`, post.Content, post.Image, post.Visibility, post.UpdatedAt, post.ID, post.UserID)
+	editedAt, err := updateContent(s.DB, RevisionTargetPost, post.ID, post.UserID, post.Content, post.UpdatedAt,
+		", image = ?, visibility = ?", post.Image, post.Visibility)
 	if err != nil {
 		return fmt.Errorf("failed to update post: %w", err)
 	}
+	if editedAt != nil {
+		post.EditedAt = editedAt
+	}
 
 	return nil
 }
@@ -180,16 +221,41 @@ func (s *PostService) Delete(id, userID string) error {
 	return nil
 }
 
+// ForceDelete deletes a post regardless of who wrote it, for
+// platform staff, as part of the transaction recording the action
+func (s *PostService) ForceDelete(tx *sql.Tx, id string) error {
+	result, err := tx.Exec("DELETE FROM posts WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete post: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("post not found")
+	}
+
+	return nil
+}
+
 // GetUserPosts retrieves posts by a user with proper visibility filtering
 func (s *PostService) GetUserPosts(userID, currentUserID string, limit, offset int) ([]*Post, error) {
+	posts, _, err := s.GetUserPostsPage(userID, currentUserID, Page{Limit: limit, Offset: offset})
+	return posts, err
+}
+
+// GetUserPostsPage retrieves a page of posts by a specific user, returning the cursor for the next page
+func (s *PostService) GetUserPostsPage(userID, currentUserID string, page Page) ([]*Post, string, error) {
 	// First, check if the user has a private profile
 	var isPrivate bool
 	err := s.DB.QueryRow("SELECT is_private FROM users WHERE id = ?", userID).Scan(&isPrivate)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("user not found")
+			return nil, "", errors.New("user not found")
 		}
-		return nil, fmt.Errorf("failed to check user privacy: %w", err)
+		return nil, "", fmt.Errorf("failed to check user privacy: %w", err)
 	}
 
 	// Handle empty currentUserID (unauthenticated users)
@@ -200,19 +266,28 @@ func (s *PostService) GetUserPosts(userID, currentUserID string, limit, offset i
 	// Check if the current user is following the profile owner
 	var isFollowing bool
 	if currentUserID != userID && currentUserID != "00000000-0000-0000-0000-000000000000" {
-		err := s.DB.QueryRow(`
+		// Blocked users don't exist to each other
+		blocked, err := isBlockedBetween(s.DB, currentUserID, userID)
+		if err != nil {
+			return nil, "", err
+		}
+		if blocked {
+			return nil, "", errors.New("user not found")
+		}
+
+		err = s.DB.QueryRow(`
 			SELECT COUNT(*) > 0
 			FROM follows
 			WHERE follower_id = ? AND following_id = ? AND status = 'accepted'
 		`, currentUserID, userID).Scan(&isFollowing)
 		if err != nil {
-			return nil, fmt.Errorf("failed to check follow status: %w", err)
+			return nil, "", fmt.Errorf("failed to check follow status: %w", err)
 		}
 	}
 
 	// If viewing someone else's private profile and not following, return empty
 	if userID != currentUserID && isPrivate && !isFollowing {
-		return []*Post{}, nil
+		return []*Post{}, "", nil
 	}
 
 	// Build the WHERE clause for post visibility filtering
@@ -222,7 +297,7 @@ func (s *PostService) GetUserPosts(userID, currentUserID string, limit, offset i
 	if userID == currentUserID {
 		// User viewing their own posts - can see all posts
 		whereClause = "WHERE p.user_id = ?"
-		args = []interface{}{currentUserID, userID, limit, offset}
+		args = []interface{}{currentUserID, userID}
 	} else {
 		// User viewing someone else's posts - apply visibility filtering
 		whereClause = `WHERE p.user_id = ? AND (
@@ -242,30 +317,49 @@ func (s *PostService) GetUserPosts(userID, currentUserID string, limit, offset i
 			isFollowingStr = "true"
 		}
 
-		args = []interface{}{currentUserID, userID, isFollowingStr, currentUserID, limit, offset}
+		args = []interface{}{currentUserID, userID, isFollowingStr, currentUserID}
 	}
 
+	cursorCondition, cursorArgs := page.where("p.created_at", "p.id")
+	orderAndLimit, limitArgs := page.orderAndLimit("p.created_at", "p.id")
+	args = append(append(args, cursorArgs...), limitArgs...)
+
 	// Execute the query with proper visibility filtering
 	query := fmt.Sprintf(`
-		SELECT p.id, p.user_id, p.content, p.image, p.visibility, p.created_at, p.updated_at,
+		SELECT p.id, p.user_id, p.content, p.image, p.visibility, p.created_at, p.updated_at, p.edited_at,
 			u.id, u.username, u.full_name, u.profile_picture,
 			(SELECT COUNT(*) FROM likes WHERE post_id = p.id) as likes_count,
 			(SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comments_count,
-			COALESCE((SELECT COUNT(*) FROM likes WHERE post_id = p.id AND user_id = ?), 0) as is_liked
+			(SELECT json_group_object(reaction, reaction_count) FROM (
+				SELECT reaction, COUNT(*) AS reaction_count FROM likes WHERE post_id = p.id GROUP BY reaction
+			)) as reaction_counts,
+			COALESCE((SELECT reaction FROM likes WHERE post_id = p.id AND user_id = ?), '') as reaction,
+			(SELECT json_group_object(username, user_id) FROM mentions WHERE target_type = 'post' AND target_id = p.id) as mentions,
+			`+linkPreviewsColumn(EntityTargetPost, "p.id")+`
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
+		%s AND %s
 		%s
-		ORDER BY p.created_at DESC
-		LIMIT ? OFFSET ?
-	`, whereClause)
+	`, whereClause, cursorCondition, orderAndLimit)
 
 	rows, err := s.DB.Query(query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user posts: %w", err)
+		return nil, "", fmt.Errorf("failed to get user posts: %w", err)
 	}
 	defer rows.Close()
 
-	return s.scanPosts(rows)
+	posts, err := s.scanPosts(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	posts, nextCursor := nextPage(posts, page.Limit, postCursorKey)
+	return posts, nextCursor, nil
+}
+
+// postCursorKey is the (created_at, id) position of a post in a paged list
+func postCursorKey(post *Post) (time.Time, string) {
+	return post.CreatedAt, post.ID
 }
 
 // Helper method to scan posts from rows
@@ -276,9 +370,9 @@ func (s *PostService) scanPosts(rows *sql.Rows) ([]*Post, error) {
 		var image sql.NullString
 		var profilePicture sql.NullString
 		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Content, &image, &post.Visibility, &post.CreatedAt, &post.UpdatedAt,
+			&post.ID, &post.UserID, &post.Content, &image, &post.Visibility, &post.CreatedAt, &post.UpdatedAt, &post.EditedAt,
 			&post.User.ID, &post.User.Username, &post.User.FullName, &profilePicture,
-			&post.LikesCount, &post.CommentsCount, &post.IsLiked,
+			&post.LikesCount, &post.CommentsCount, &post.ReactionCounts, &post.Reaction, &post.Mentions, &post.LinkPreviews,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan post: %w", err)
@@ -291,6 +385,7 @@ func (s *PostService) scanPosts(rows *sql.Rows) ([]*Post, error) {
 		if profilePicture.Valid {
 			post.User.ProfilePicture = profilePicture.String
 		}
+		post.IsLiked = post.Reaction != ""
 
 		posts = append(posts, post)
 	}
@@ -304,15 +399,36 @@ func (s *PostService) scanPosts(rows *sql.Rows) ([]*Post, error) {
 
 // GetFeed retrieves posts for a user's feed
 func (s *PostService) GetFeed(userID string, limit, offset int) ([]*Post, error) {
+	posts, _, err := s.GetFeedPage(userID, Page{Limit: limit, Offset: offset})
+	return posts, err
+}
+
+// GetFeedPage retrieves a page of a user's feed, returning the cursor for the next page
+func (s *PostService) GetFeedPage(userID string, page Page) ([]*Post, string, error) {
+	cursorCondition, cursorArgs := page.where("p.created_at", "p.id")
+	orderAndLimit, limitArgs := page.orderAndLimit("p.created_at", "p.id")
+
+	notBlocked, notBlockedArgs := notBlockedCondition("p.user_id", userID)
+	notMuted, notMutedArgs := notMutedCondition("p.user_id", userID)
+
+	args := []interface{}{userID, userID, PostVisibilityPublic, userID, PostVisibilityFollowers, userID, PostVisibilityPublic, userID, PostVisibilityCustom, userID, userID}
+	args = append(append(args, notBlockedArgs...), notMutedArgs...)
+	args = append(append(args, cursorArgs...), limitArgs...)
+
 	rows, err := s.DB.Query(`
-		SELECT p.id, p.user_id, p.content, p.image, p.visibility, p.created_at, p.updated_at,
+		SELECT p.id, p.user_id, p.content, p.image, p.visibility, p.created_at, p.updated_at, p.edited_at,
 			u.id, u.username, u.full_name, u.profile_picture,
 			(SELECT COUNT(*) FROM likes WHERE post_id = p.id) as likes_count,
 			(SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comments_count,
-			(SELECT COUNT(*) FROM likes WHERE post_id = p.id AND user_id = ?) as is_liked
+			(SELECT json_group_object(reaction, reaction_count) FROM (
+				SELECT reaction, COUNT(*) AS reaction_count FROM likes WHERE post_id = p.id GROUP BY reaction
+			)) as reaction_counts,
+			COALESCE((SELECT reaction FROM likes WHERE post_id = p.id AND user_id = ?), '') as reaction,
+			(SELECT json_group_object(username, user_id) FROM mentions WHERE target_type = 'post' AND target_id = p.id) as mentions,
+			`+linkPreviewsColumn(EntityTargetPost, "p.id")+`
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
-		WHERE
+		WHERE (
 			-- Include user's own posts (all visibility levels)
 			p.user_id = ?
 			-- Include public posts from users the user is following
@@ -335,42 +451,72 @@ func (s *PostService) GetFeed(userID string, limit, offset int) ([]*Post, error)
 			))
 		-- Explicitly exclude private posts from other users
 		AND (p.user_id = ? OR p.visibility != 'private')
-		ORDER BY p.created_at DESC
-		LIMIT ? OFFSET ?
-	`, userID, userID, PostVisibilityPublic, userID, PostVisibilityFollowers, userID, PostVisibilityPublic, userID, PostVisibilityCustom, userID, userID, limit, offset)
+		)
+		-- Leave out blocked and muted authors
+		AND `+notBlocked+` AND `+notMuted+`
+		AND `+cursorCondition+`
+		`+orderAndLimit+`
+	`, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get feed: %w", err)
+		return nil, "", fmt.Errorf("failed to get feed: %w", err)
 	}
 	defer rows.Close()
 
-	var posts []*Post
-	for rows.Next() {
-		post := &Post{User: &User{}}
-		var image sql.NullString
-		var profilePicture sql.NullString
-		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Content, &image, &post.Visibility, &post.CreatedAt, &post.UpdatedAt,
-			&post.User.ID, &post.User.Username, &post.User.FullName, &profilePicture,
-			&post.LikesCount, &post.CommentsCount, &post.IsLiked,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan post: %w", err)
-		}
+	posts, err := s.scanPosts(rows)
+	if err != nil {
+		return nil, "", err
+	}
 
-		// Handle nullable fields
-		if image.Valid {
-			post.Image = image.String
-		}
-		if profilePicture.Valid {
-			post.User.ProfilePicture = profilePicture.String
-		}
+	posts, nextCursor := nextPage(posts, page.Limit, postCursorKey)
+	return posts, nextCursor, nil
+}
 
-		posts = append(posts, post)
+// GetByTagPage retrieves a page of the posts using a hashtag that the viewer
+// can see, newest first, returning the cursor for the next page. Blocked and
+// muted authors are left out, as in the feed.
+func (s *PostService) GetByTagPage(tag, viewerID string, page Page) ([]*Post, string, error) {
+	cursorCondition, cursorArgs := page.where("p.created_at", "p.id")
+	orderAndLimit, limitArgs := page.orderAndLimit("p.created_at", "p.id")
+	notMuted, notMutedArgs := notMutedCondition("p.user_id", viewerID)
+
+	// The visibility condition takes the viewer's ID six times
+	args := []interface{}{viewerID, tag}
+	for i := 0; i < 6; i++ {
+		args = append(args, viewerID)
+	}
+	for _, conditionArgs := range [][]interface{}{notMutedArgs, cursorArgs, limitArgs} {
+		args = append(args, conditionArgs...)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating feed posts: %w", err)
+	rows, err := s.DB.Query(`
+		SELECT p.id, p.user_id, p.content, p.image, p.visibility, p.created_at, p.updated_at, p.edited_at,
+			u.id, u.username, u.full_name, u.profile_picture,
+			(SELECT COUNT(*) FROM likes WHERE post_id = p.id) as likes_count,
+			(SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comments_count,
+			(SELECT json_group_object(reaction, reaction_count) FROM (
+				SELECT reaction, COUNT(*) AS reaction_count FROM likes WHERE post_id = p.id GROUP BY reaction
+			)) as reaction_counts,
+			COALESCE((SELECT reaction FROM likes WHERE post_id = p.id AND user_id = ?), '') as reaction,
+			(SELECT json_group_object(username, user_id) FROM mentions WHERE target_type = 'post' AND target_id = p.id) as mentions,
+			`+linkPreviewsColumn(EntityTargetPost, "p.id")+`
+		FROM posts p
+		JOIN users u ON p.user_id = u.id
+		WHERE p.id IN (SELECT target_id FROM content_tags WHERE target_type = 'post' AND tag = ?)
+		AND `+withoutBlocked(postVisibilityCondition, "p.user_id")+`
+		AND `+notMuted+`
+		AND `+cursorCondition+`
+		`+orderAndLimit+`
+	`, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get posts by tag: %w", err)
 	}
+	defer rows.Close()
 
-	return posts, nil
+	posts, err := s.scanPosts(rows)
+	if err != nil {
+		return nil, "", err
+	}
+
+	posts, nextCursor := nextPage(posts, page.Limit, postCursorKey)
+	return posts, nextCursor, nil
 }
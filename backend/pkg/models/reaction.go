@@ -0,0 +1,222 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReactionType is the kind of reaction a user gave to a post or comment
+type ReactionType string
+
+const (
+	ReactionLike  ReactionType = "like"
+	ReactionLove  ReactionType = "love"
+	ReactionLaugh ReactionType = "laugh"
+	ReactionWow   ReactionType = "wow"
+	ReactionSad   ReactionType = "sad"
+	ReactionAngry ReactionType = "angry"
+)
+
+// IsValid reports whether t is one of the reactions users can give
+func (t ReactionType) IsValid() bool {
+	switch t {
+	case ReactionLike, ReactionLove, ReactionLaugh, ReactionWow, ReactionSad, ReactionAngry:
+		return true
+	}
+	return false
+}
+
+// ReactionCounts is how many reactions of each type something has. It scans
+// the JSON object built by json_group_object.
+type ReactionCounts map[ReactionType]int
+
+// Scan implements sql.Scanner
+func (c *ReactionCounts) Scan(src interface{}) error {
+	*c = nil
+	return scanJSON(src, c, "reaction counts")
+}
+
+// ReactionTargetType is the kind of content a reaction is on
+type ReactionTargetType string
+
+const (
+	// Reactions on posts and group posts share a table, as their likes did
+	ReactionTargetPost    ReactionTargetType = "post"
+	ReactionTargetComment ReactionTargetType = "comment"
+)
+
+// reactionTables are the table reactions on each kind of content are kept
+// in, and the column holding what they are on. Both started out as tables of
+// likes, which became reactions of type like.
+var reactionTables = map[ReactionTargetType]struct {
+	table, column string
+}{
+	ReactionTargetPost:    {"likes", "post_id"},
+	ReactionTargetComment: {"comment_likes", "comment_id"},
+}
+
+// Reaction is a user's reaction to a post, group post or comment
+type Reaction struct {
+	ID        string       `json:"id"`
+	UserID    string       `json:"userId"`
+	Reaction  ReactionType `json:"reaction"`
+	CreatedAt time.Time    `json:"createdAt"`
+	// Additional fields for API responses
+	User *User `json:"user,omitempty"`
+}
+
+// ReactionService handles typed reactions to posts, group posts and comments
+type ReactionService struct {
+	DB *sql.DB
+}
+
+// NewReactionService creates a new ReactionService
+func NewReactionService(db *sql.DB) *ReactionService {
+	return &ReactionService{DB: db}
+}
+
+// React sets a user's reaction to something, replacing any reaction they
+// gave before. It reports whether the user hadn't reacted to it yet.
+func (s *ReactionService) React(targetType ReactionTargetType, targetID, userID string, reaction ReactionType) (bool, error) {
+	target, ok := reactionTables[targetType]
+	if !ok {
+		return false, fmt.Errorf("unknown reaction target type %q", targetType)
+	}
+
+	result, err := s.DB.Exec(`
+		INSERT INTO `+target.table+` (id, `+target.column+`, user_id, reaction, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (`+target.column+`, user_id) DO NOTHING
+	`, uuid.New().String(), targetID, userID, reaction, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to add reaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected > 0 {
+		return true, nil
+	}
+
+	// The user already reacted, so change their reaction
+	_, err = s.DB.Exec(`
+		UPDATE `+target.table+` SET reaction = ? WHERE `+target.column+` = ? AND user_id = ?
+	`, reaction, targetID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to change reaction: %w", err)
+	}
+
+	return false, nil
+}
+
+// Delete removes a user's reaction, of whatever type, from something
+func (s *ReactionService) Delete(targetType ReactionTargetType, targetID, userID string) error {
+	target, ok := reactionTables[targetType]
+	if !ok {
+		return fmt.Errorf("unknown reaction target type %q", targetType)
+	}
+
+	result, err := s.DB.Exec("DELETE FROM "+target.table+" WHERE "+target.column+" = ? AND user_id = ?", targetID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to remove reaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("reaction not found")
+	}
+
+	return nil
+}
+
+// GetSummary returns how many reactions of each type something has, and the
+// viewer's own reaction, which is empty if they haven't reacted
+func (s *ReactionService) GetSummary(targetType ReactionTargetType, targetID, viewerID string) (ReactionCounts, ReactionType, error) {
+	target, ok := reactionTables[targetType]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown reaction target type %q", targetType)
+	}
+
+	var counts ReactionCounts
+	var reaction ReactionType
+	err := s.DB.QueryRow(`
+		SELECT
+			(SELECT json_group_object(reaction, reaction_count) FROM (
+				SELECT reaction, COUNT(*) AS reaction_count FROM `+target.table+` WHERE `+target.column+` = ? GROUP BY reaction
+			)),
+			COALESCE((SELECT reaction FROM `+target.table+` WHERE `+target.column+` = ? AND user_id = ?), '')
+	`, targetID, targetID, viewerID).Scan(&counts, &reaction)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get reactions: %w", err)
+	}
+
+	return counts, reaction, nil
+}
+
+// GetPage retrieves a page of who reacted to something, newest first, with
+// only reactions of type reaction unless it is empty. Users on either side
+// of a block with the viewer are left out.
+func (s *ReactionService) GetPage(targetType ReactionTargetType, targetID string, reaction ReactionType, viewerID string, page Page) ([]*Reaction, string, error) {
+	target, ok := reactionTables[targetType]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown reaction target type %q", targetType)
+	}
+
+	reactionCondition, reactionArgs := "1 = 1", []interface{}{}
+	if reaction != "" {
+		reactionCondition, reactionArgs = "r.reaction = ?", []interface{}{reaction}
+	}
+	notBlocked, notBlockedArgs := notBlockedCondition("r.user_id", viewerID)
+	cursorCondition, cursorArgs := page.where("r.created_at", "r.id")
+	orderAndLimit, limitArgs := page.orderAndLimit("r.created_at", "r.id")
+
+	args := []interface{}{targetID}
+	for _, conditionArgs := range [][]interface{}{reactionArgs, notBlockedArgs, cursorArgs, limitArgs} {
+		args = append(args, conditionArgs...)
+	}
+
+	rows, err := s.DB.Query(`
+		SELECT r.id, r.user_id, r.reaction, r.created_at,
+			u.id, u.username, u.full_name, u.profile_picture
+		FROM `+target.table+` r
+		JOIN users u ON r.user_id = u.id
+		WHERE r.`+target.column+` = ? AND `+reactionCondition+` AND `+notBlocked+` AND `+cursorCondition+`
+		`+orderAndLimit, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get reactions: %w", err)
+	}
+	defer rows.Close()
+
+	var reactions []*Reaction
+	for rows.Next() {
+		reaction := &Reaction{User: &User{}}
+		var profilePicture sql.NullString
+		err := rows.Scan(
+			&reaction.ID, &reaction.UserID, &reaction.Reaction, &reaction.CreatedAt,
+			&reaction.User.ID, &reaction.User.Username, &reaction.User.FullName, &profilePicture,
+		)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan reaction: %w", err)
+		}
+		reaction.User.ProfilePicture = profilePicture.String
+		reactions = append(reactions, reaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating reactions: %w", err)
+	}
+
+	reactions, nextCursor := nextPage(reactions, page.Limit, func(reaction *Reaction) (time.Time, string) {
+		return reaction.CreatedAt, reaction.ID
+	})
+	return reactions, nextCursor, nil
+}
@@ -0,0 +1,389 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReportReason is why a user flagged something
+type ReportReason string
+
+const (
+	ReportReasonSpam           ReportReason = "spam"
+	ReportReasonHarassment     ReportReason = "harassment"
+	ReportReasonHateSpeech     ReportReason = "hate_speech"
+	ReportReasonViolence       ReportReason = "violence"
+	ReportReasonSexualContent  ReportReason = "sexual_content"
+	ReportReasonMisinformation ReportReason = "misinformation"
+	ReportReasonImpersonation  ReportReason = "impersonation"
+	ReportReasonSelfHarm       ReportReason = "self_harm"
+	ReportReasonOther          ReportReason = "other"
+)
+
+// ReportReasons lists every reason a user can pick, in display order
+var ReportReasons = []ReportReason{
+	ReportReasonSpam,
+	ReportReasonHarassment,
+	ReportReasonHateSpeech,
+	ReportReasonViolence,
+	ReportReasonSexualContent,
+	ReportReasonMisinformation,
+	ReportReasonImpersonation,
+	ReportReasonSelfHarm,
+	ReportReasonOther,
+}
+
+// Valid reports whether r is one of ReportReasons
+func (r ReportReason) Valid() bool {
+	for _, reason := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportStatus represents where a report is in the moderation queue
+type ReportStatus string
+
+const (
+	ReportStatusOpen      ReportStatus = "open"
+	ReportStatusClaimed   ReportStatus = "claimed"
+	ReportStatusResolved  ReportStatus = "resolved"
+	ReportStatusDismissed ReportStatus = "dismissed"
+)
+
+// Report is a reported post, comment, message, group or profile awaiting or
+// having had staff review. Repeat reports of the same target while it awaits
+// review are collapsed into one report.
+type Report struct {
+	ID               string           `json:"id"`
+	TargetType       ModerationTarget `json:"targetType"`
+	TargetID         string           `json:"targetId"`
+	TargetOwnerID    string           `json:"targetOwnerId"`
+	ContentSnapshot  string           `json:"contentSnapshot,omitempty"` // The content as the first reporter saw it
+	Reason           ReportReason     `json:"reason"`
+	Status           ReportStatus     `json:"status"`
+	ReportCount      int              `json:"reportCount"`
+	AssigneeID       string           `json:"assigneeId,omitempty"`
+	ResolutionAction string           `json:"resolutionAction,omitempty"`
+	ResolutionNote   string           `json:"resolutionNote,omitempty"`
+	ResolvedBy       string           `json:"resolvedBy,omitempty"`
+	ResolvedAt       *time.Time       `json:"resolvedAt,omitempty"`
+	CreatedAt        time.Time        `json:"createdAt"`
+	UpdatedAt        time.Time        `json:"updatedAt"`
+	// Additional fields for API responses
+	Submissions []*ReportSubmission `json:"submissions,omitempty"`
+}
+
+// ReportSubmission is one user's report of a target
+type ReportSubmission struct {
+	ID         string       `json:"id"`
+	ReportID   string       `json:"reportId"`
+	ReporterID string       `json:"reporterId"`
+	Reason     ReportReason `json:"reason"`
+	Details    string       `json:"details,omitempty"`
+	CreatedAt  time.Time    `json:"createdAt"`
+	Reporter   *User        `json:"reporter,omitempty"`
+}
+
+// ReportFilter narrows the moderation queue
+type ReportFilter struct {
+	Status     ReportStatus
+	TargetType ModerationTarget
+	AssigneeID string
+}
+
+// ReportService handles user reports and the moderation queue
+type ReportService struct {
+	DB *sql.DB
+}
+
+// NewReportService creates a new ReportService
+func NewReportService(db *sql.DB) *ReportService {
+	return &ReportService{DB: db}
+}
+
+// Submit files a user's report of a target, adding it to the report already
+// awaiting review for that target if there is one
+func (s *ReportService) Submit(report *Report, submission *ReportSubmission) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var reportID string
+	err = tx.QueryRow(`
+		SELECT id FROM reports
+		WHERE target_type = ? AND target_id = ? AND status IN (?, ?)
+	`, report.TargetType, report.TargetID, ReportStatusOpen, ReportStatusClaimed).Scan(&reportID)
+	switch {
+	case err == sql.ErrNoRows:
+		report.ID = uuid.New().String()
+		report.Reason = submission.Reason
+		report.Status = ReportStatusOpen
+		report.ReportCount = 0
+		report.CreatedAt = now
+		_, err = tx.Exec(`
+			INSERT INTO reports (id, target_type, target_id, target_owner_id, content_snapshot, reason, status, report_count, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?)
+		`, report.ID, report.TargetType, report.TargetID, report.TargetOwnerID, report.ContentSnapshot, report.Reason, report.Status, now, now)
+		if err != nil {
+			return fmt.Errorf("failed to create report: %w", err)
+		}
+		reportID = report.ID
+	case err != nil:
+		return fmt.Errorf("failed to check existing reports: %w", err)
+	}
+
+	submission.ID = uuid.New().String()
+	submission.ReportID = reportID
+	submission.CreatedAt = now
+	_, err = tx.Exec(`
+		INSERT INTO report_submissions (id, report_id, reporter_id, reason, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, submission.ID, submission.ReportID, submission.ReporterID, submission.Reason, submission.Details, submission.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return errors.New("already reported")
+		}
+		return fmt.Errorf("failed to add report: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE reports SET report_count = report_count + 1, updated_at = ? WHERE id = ?
+	`, now, reportID); err != nil {
+		return fmt.Errorf("failed to update report: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	report.ID = reportID
+	return nil
+}
+
+// reportColumns is the column list scanned by scanReport
+const reportColumns = `id, target_type, target_id, target_owner_id, content_snapshot, reason, status, report_count,
+	assignee_id, resolution_action, resolution_note, resolved_by, resolved_at, created_at, updated_at`
+
+// scanReport scans a row selected with reportColumns
+func scanReport(row interface{ Scan(...interface{}) error }) (*Report, error) {
+	report := &Report{}
+	var assigneeID, resolvedBy sql.NullString
+	var resolvedAt sql.NullTime
+	err := row.Scan(
+		&report.ID, &report.TargetType, &report.TargetID, &report.TargetOwnerID, &report.ContentSnapshot, &report.Reason, &report.Status, &report.ReportCount,
+		&assigneeID, &report.ResolutionAction, &report.ResolutionNote, &resolvedBy, &resolvedAt, &report.CreatedAt, &report.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	report.AssigneeID = assigneeID.String
+	report.ResolvedBy = resolvedBy.String
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+	return report, nil
+}
+
+// GetByID retrieves a report by ID
+func (s *ReportService) GetByID(id string) (*Report, error) {
+	report, err := scanReport(s.DB.QueryRow(`SELECT `+reportColumns+` FROM reports WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("report not found")
+		}
+		return nil, fmt.Errorf("failed to get report: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetSubmissions retrieves every user's report collapsed into a report, oldest first
+func (s *ReportService) GetSubmissions(reportID string) ([]*ReportSubmission, error) {
+	rows, err := s.DB.Query(`
+		SELECT rs.id, rs.report_id, rs.reporter_id, rs.reason, rs.details, rs.created_at,
+			u.id, u.username, u.full_name, u.profile_picture
+		FROM report_submissions rs
+		JOIN users u ON rs.reporter_id = u.id
+		WHERE rs.report_id = ?
+		ORDER BY rs.created_at ASC
+	`, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report submissions: %w", err)
+	}
+	defer rows.Close()
+
+	var submissions []*ReportSubmission
+	for rows.Next() {
+		submission := &ReportSubmission{Reporter: &User{}}
+		err := rows.Scan(
+			&submission.ID, &submission.ReportID, &submission.ReporterID, &submission.Reason, &submission.Details, &submission.CreatedAt,
+			&submission.Reporter.ID, &submission.Reporter.Username, &submission.Reporter.FullName, &submission.Reporter.ProfilePicture,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan report submission: %w", err)
+		}
+		submissions = append(submissions, submission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating report submissions: %w", err)
+	}
+
+	return submissions, nil
+}
+
+// GetReporterIDs returns the users who reported a target as part of a report
+func (s *ReportService) GetReporterIDs(reportID string) ([]string, error) {
+	rows, err := s.DB.Query(`SELECT reporter_id FROM report_submissions WHERE report_id = ?`, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reporters: %w", err)
+	}
+	defer rows.Close()
+
+	var reporterIDs []string
+	for rows.Next() {
+		var reporterID string
+		if err := rows.Scan(&reporterID); err != nil {
+			return nil, fmt.Errorf("failed to scan reporter: %w", err)
+		}
+		reporterIDs = append(reporterIDs, reporterID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reporters: %w", err)
+	}
+
+	return reporterIDs, nil
+}
+
+// List retrieves a page of the moderation queue, newest first, returning the cursor for the next page
+func (s *ReportService) List(filter ReportFilter, page Page) ([]*Report, string, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.TargetType != "" {
+		conditions = append(conditions, "target_type = ?")
+		args = append(args, filter.TargetType)
+	}
+	if filter.AssigneeID != "" {
+		conditions = append(conditions, "assignee_id = ?")
+		args = append(args, filter.AssigneeID)
+	}
+
+	cursorCondition, cursorArgs := page.where("created_at", "id")
+	conditions = append(conditions, cursorCondition)
+	args = append(args, cursorArgs...)
+	orderAndLimit, limitArgs := page.orderAndLimit("created_at", "id")
+	args = append(args, limitArgs...)
+
+	rows, err := s.DB.Query(`
+		SELECT `+reportColumns+`
+		FROM reports
+		WHERE `+strings.Join(conditions, " AND ")+`
+		`+orderAndLimit, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get reports: %w", err)
+	}
+	defer rows.Close()
+
+	var reports []*Report
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating reports: %w", err)
+	}
+
+	reports, nextCursor := nextPage(reports, page.Limit, func(report *Report) (time.Time, string) {
+		return report.CreatedAt, report.ID
+	})
+	return reports, nextCursor, nil
+}
+
+// Claim assigns an open report to a moderator so others know it is being
+// handled. A moderator may re-claim their own report. It runs in tx, the
+// transaction recording the action.
+func (s *ReportService) Claim(tx *sql.Tx, id, moderatorID string) error {
+	result, err := tx.Exec(`
+		UPDATE reports
+		SET status = ?, assignee_id = ?, updated_at = ?
+		WHERE id = ? AND (status = ? OR (status = ? AND assignee_id = ?))
+	`, ReportStatusClaimed, moderatorID, time.Now(), id, ReportStatusOpen, ReportStatusClaimed, moderatorID)
+	if err != nil {
+		return fmt.Errorf("failed to claim report: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return closedOrTaken(tx, id)
+	}
+
+	return nil
+}
+
+// Close resolves or dismisses a report awaiting review. A report claimed by
+// another moderator can only be closed by them. It runs in tx, the
+// transaction recording the action.
+func (s *ReportService) Close(tx *sql.Tx, id, moderatorID string, status ReportStatus, action, note string) error {
+	if status != ReportStatusResolved && status != ReportStatusDismissed {
+		return fmt.Errorf("invalid closing status %q", status)
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE reports
+		SET status = ?, assignee_id = COALESCE(assignee_id, ?), resolution_action = ?, resolution_note = ?, resolved_by = ?, resolved_at = ?, updated_at = ?
+		WHERE id = ? AND (status = ? OR (status = ? AND assignee_id = ?))
+	`, status, moderatorID, action, note, moderatorID, now, now, id, ReportStatusOpen, ReportStatusClaimed, moderatorID)
+	if err != nil {
+		return fmt.Errorf("failed to close report: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return closedOrTaken(tx, id)
+	}
+
+	return nil
+}
+
+// closedOrTaken explains why a report could not be claimed or closed
+func closedOrTaken(tx *sql.Tx, id string) error {
+	var status ReportStatus
+	err := tx.QueryRow("SELECT status FROM reports WHERE id = ?", id).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("report not found")
+		}
+		return fmt.Errorf("failed to get report: %w", err)
+	}
+	if status == ReportStatusResolved || status == ReportStatusDismissed {
+		return errors.New("report already closed")
+	}
+	return errors.New("report claimed by another moderator")
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RevisionTargetType is the kind of content a revision belongs to
+type RevisionTargetType string
+
+const (
+	RevisionTargetPost      RevisionTargetType = "post"
+	RevisionTargetGroupPost RevisionTargetType = "group_post"
+	RevisionTargetComment   RevisionTargetType = "comment"
+)
+
+// revisionTargets are the table each kind of content is stored in, and the
+// column holding what it belongs to
+var revisionTargets = map[RevisionTargetType]struct {
+	table        string
+	parentColumn string
+}{
+	RevisionTargetPost:      {"posts", ""},
+	RevisionTargetGroupPost: {"group_posts", "group_id"},
+	RevisionTargetComment:   {"comments", "post_id"},
+}
+
+// Revision is the content of a post, group post or comment as it was before
+// an edit replaced it
+type Revision struct {
+	ID         string             `json:"id"`
+	TargetType RevisionTargetType `json:"targetType"`
+	TargetID   string             `json:"targetId"`
+	Content    string             `json:"content"`
+	ReplacedAt time.Time          `json:"replacedAt"`
+}
+
+// RevisionService handles the edit history of posts, group posts and comments
+type RevisionService struct {
+	DB *sql.DB
+}
+
+// NewRevisionService creates a new RevisionService
+func NewRevisionService(db *sql.DB) *RevisionService {
+	return &RevisionService{DB: db}
+}
+
+// GetAuthorID returns who wrote a post, group post or comment, regardless of
+// who can see it. parentID is the group of a group post or the post of a
+// comment, and is ignored for posts.
+func (s *RevisionService) GetAuthorID(targetType RevisionTargetType, targetID, parentID string) (string, error) {
+	target, ok := revisionTargets[targetType]
+	if !ok {
+		return "", fmt.Errorf("unknown revision target type %q", targetType)
+	}
+
+	query := "SELECT user_id FROM " + target.table + " WHERE id = ?"
+	args := []interface{}{targetID}
+	if target.parentColumn != "" {
+		query += " AND " + target.parentColumn + " = ?"
+		args = append(args, parentID)
+	}
+
+	var authorID string
+	if err := s.DB.QueryRow(query, args...).Scan(&authorID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", errors.New("content not found")
+		}
+		return "", fmt.Errorf("failed to get content author: %w", err)
+	}
+
+	return authorID, nil
+}
+
+// GetByTargetPage retrieves a page of the revisions of a post, group post or
+// comment, newest first
+func (s *RevisionService) GetByTargetPage(targetType RevisionTargetType, targetID string, page Page) ([]*Revision, string, error) {
+	cursorCondition, cursorArgs := page.where("replaced_at", "id")
+	orderAndLimit, limitArgs := page.orderAndLimit("replaced_at", "id")
+
+	args := []interface{}{targetType, targetID}
+	args = append(append(args, cursorArgs...), limitArgs...)
+
+	rows, err := s.DB.Query(`
+		SELECT id, target_type, target_id, content, replaced_at
+		FROM content_revisions
+		WHERE target_type = ? AND target_id = ? AND `+cursorCondition+`
+		`+orderAndLimit, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []*Revision
+	for rows.Next() {
+		revision := &Revision{}
+		if err := rows.Scan(&revision.ID, &revision.TargetType, &revision.TargetID, &revision.Content, &revision.ReplacedAt); err != nil {
+			return nil, "", fmt.Errorf("failed to scan revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating revisions: %w", err)
+	}
+
+	revisions, nextCursor := nextPage(revisions, page.Limit, func(revision *Revision) (time.Time, string) {
+		return revision.ReplacedAt, revision.ID
+	})
+	return revisions, nextCursor, nil
+}
+
+// updateContent updates the content of a post, group post or comment by its
+// author, first keeping the content it replaces as a revision. set and
+// setArgs update any other columns. It returns when the content was edited,
+// which is nil if it wasn't.
+func updateContent(db *sql.DB, targetType RevisionTargetType, targetID, userID, content string, now time.Time, set string, setArgs ...interface{}) (*time.Time, error) {
+	table := revisionTargets[targetType].table
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Only a change to the content counts as an edit
+	result, err := tx.Exec(`
+		INSERT INTO content_revisions (id, target_type, target_id, content, replaced_at)
+		SELECT ?, ?, id, content, ?
+		FROM `+table+`
+		WHERE id = ? AND user_id = ? AND content != ?
+	`, uuid.New().String(), targetType, now, targetID, userID, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save revision: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	var editedAt *time.Time
+	if rowsAffected > 0 {
+		editedAt = &now
+	}
+
+	args := append([]interface{}{content, now, editedAt}, setArgs...)
+	args = append(args, targetID, userID)
+	if _, err := tx.Exec(`
+		UPDATE `+table+`
+		SET content = ?, updated_at = ?, edited_at = COALESCE(?, edited_at)`+set+`
+		WHERE id = ? AND user_id = ?
+	`, args...); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return editedAt, nil
+}
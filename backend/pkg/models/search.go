@@ -0,0 +1,527 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchResultType is the kind of item a search result points at
+type SearchResultType string
+
+const (
+	SearchResultPost      SearchResultType = "post"
+	SearchResultGroupPost SearchResultType = "group_post"
+	SearchResultComment   SearchResultType = "comment"
+	SearchResultMessage   SearchResultType = "message"
+	SearchResultUser      SearchResultType = "user"
+	SearchResultGroup     SearchResultType = "group"
+)
+
+// SearchResultTypes lists every searchable type, in the order results are returned
+var SearchResultTypes = []SearchResultType{
+	SearchResultPost,
+	SearchResultGroupPost,
+	SearchResultComment,
+	SearchResultMessage,
+	SearchResultUser,
+	SearchResultGroup,
+}
+
+// Markers FTS5 wraps matches in; they can't appear in escaped HTML, so they
+// are swapped for <mark> tags after the snippet has been escaped
+const (
+	searchMatchStart = "\x02"
+	searchMatchEnd   = "\x03"
+)
+
+// searchSnippetRunes is roughly how many characters of context a LIKE fallback snippet keeps
+const searchSnippetRunes = 80
+
+// SearchResult is one match from SearchService.Search. Snippet is HTML-escaped
+// with matches wrapped in <mark> tags.
+type SearchResult struct {
+	Type       SearchResultType `json:"type"`
+	ID         string           `json:"id"`
+	Title      string           `json:"title,omitempty"`
+	Snippet    string           `json:"snippet"`
+	Rank       float64          `json:"rank"`
+	UserID     string           `json:"userId,omitempty"`
+	GroupID    string           `json:"groupId,omitempty"`
+	PostID     string           `json:"postId,omitempty"`
+	ReceiverID string           `json:"receiverId,omitempty"`
+	CreatedAt  time.Time        `json:"createdAt"`
+}
+
+// searchSource describes how one type of content is indexed and which rows a user may see
+type searchSource struct {
+	resultType SearchResultType
+	// Base table and alias, e.g. "posts p"
+	table string
+	// FTS5 table mirroring the base table's text columns
+	ftsTable   string
+	ftsColumns []string
+	// Columns copied into the FTS table, in ftsColumns order
+	textColumns []string
+	// Expressions for title, user_id, group_id, post_id and receiver_id
+	selects [5]string
+	// Visibility condition for the searching user, with one ? per entry in visibilityArgs
+	visibility     string
+	visibilityArgs int
+}
+
+// searchSources are the searchable types. Visibility rules follow
+// PostService.GetFeed for posts and group membership for group content, and
+// nothing by a user on either side of a block with the searcher is found.
+var searchSources = map[SearchResultType]*searchSource{
+	SearchResultPost: {
+		resultType:     SearchResultPost,
+		table:          "posts p",
+		ftsTable:       "search_posts",
+		ftsColumns:     []string{"content"},
+		textColumns:    []string{"content"},
+		selects:        [5]string{"NULL", "p.user_id", "NULL", "NULL", "NULL"},
+		visibility:     withoutBlocked(postVisibilityCondition, "p.user_id"),
+		visibilityArgs: 6,
+	},
+	SearchResultGroupPost: {
+		resultType:     SearchResultGroupPost,
+		table:          "group_posts gp",
+		ftsTable:       "search_group_posts",
+		ftsColumns:     []string{"content"},
+		textColumns:    []string{"content"},
+		selects:        [5]string{"NULL", "gp.user_id", "gp.group_id", "NULL", "NULL"},
+		visibility:     withoutBlocked(groupVisibilityCondition("gp.group_id"), "gp.user_id"),
+		visibilityArgs: 3,
+	},
+	SearchResultComment: {
+		resultType:  SearchResultComment,
+		table:       "comments c",
+		ftsTable:    "search_comments",
+		ftsColumns:  []string{"content"},
+		textColumns: []string{"content"},
+		selects:     [5]string{"NULL", "c.user_id", "(SELECT group_id FROM group_posts WHERE id = c.post_id)", "c.post_id", "NULL"},
+		// Comments are visible wherever the post or group post they belong to is
+		visibility: withoutBlocked(`(
+			EXISTS (SELECT 1 FROM posts p WHERE p.id = c.post_id AND `+postVisibilityCondition+`)
+			OR EXISTS (SELECT 1 FROM group_posts gp WHERE gp.id = c.post_id AND `+groupVisibilityCondition("gp.group_id")+`)
+		)`, "c.user_id"),
+		visibilityArgs: 7,
+	},
+	SearchResultMessage: {
+		resultType:  SearchResultMessage,
+		table:       "messages m",
+		ftsTable:    "search_messages",
+		ftsColumns:  []string{"content"},
+		textColumns: []string{"content"},
+		selects:     [5]string{"NULL", "m.sender_id", "m.group_id", "NULL", "m.receiver_id"},
+		// Only the user's own direct messages and chats of groups they belong to
+		visibility: withoutBlocked(`m.deleted_at IS NULL AND (
+			m.sender_id = ? OR m.receiver_id = ?
+			OR m.group_id IN (SELECT group_id FROM group_members WHERE user_id = ? AND status = 'accepted')
+		)`, "m.sender_id"),
+		visibilityArgs: 5,
+	},
+	SearchResultUser: {
+		resultType:     SearchResultUser,
+		table:          "users u",
+		ftsTable:       "search_users",
+		ftsColumns:     []string{"username", "full_name", "first_name", "last_name"},
+		textColumns:    []string{"username", "full_name", "first_name", "last_name"},
+		selects:        [5]string{"u.username", "u.id", "NULL", "NULL", "NULL"},
+		visibility:     withoutBlocked("1 = 1", "u.id"),
+		visibilityArgs: 2,
+	},
+	SearchResultGroup: {
+		resultType:     SearchResultGroup,
+		table:          "groups g",
+		ftsTable:       "search_groups",
+		ftsColumns:     []string{"name", "description"},
+		textColumns:    []string{"name", "description"},
+		selects:        [5]string{"g.name", "g.creator_id", "g.id", "NULL", "NULL"},
+		visibility:     "1 = 1",
+		visibilityArgs: 0,
+	},
+}
+
+// postVisibilityCondition is the PostService.GetFeed visibility rule for the
+// posts table aliased as p. It takes the searching user's ID four times.
+const postVisibilityCondition = `(
+		-- The user's own posts (all visibility levels)
+		p.user_id = ?
+		-- Public and followers-only posts from users the user is following
+		OR (p.visibility IN ('public', 'followers') AND p.user_id IN (
+			SELECT following_id FROM follows WHERE follower_id = ? AND status = 'accepted'
+		))
+		-- Public posts from users with public profiles
+		OR (p.visibility = 'public' AND p.user_id IN (
+			SELECT id FROM users WHERE is_private = FALSE
+		))
+		-- Custom visibility posts where the user is in the viewers list
+		OR (p.visibility = 'custom' AND p.id IN (
+			SELECT post_id FROM post_viewers WHERE user_id = ?
+		))
+	) AND (p.user_id = ? OR p.visibility != 'private')`
+
+// withoutBlocked extends a visibility condition to leave out rows whose
+// userColumn is on either side of a block with the searching user. It takes
+// the searching user's ID twice more.
+func withoutBlocked(visibility, userColumn string) string {
+	notBlocked, _ := notBlockedCondition(userColumn, "")
+	return visibility + " AND " + notBlocked
+}
+
+// groupVisibilityCondition allows content of public groups and of groups the
+// user has joined. It takes the searching user's ID once.
+func groupVisibilityCondition(groupIDColumn string) string {
+	return `(
+		` + groupIDColumn + ` IN (SELECT id FROM groups WHERE privacy = 'public')
+		OR ` + groupIDColumn + ` IN (SELECT group_id FROM group_members WHERE user_id = ? AND status = 'accepted')
+	)`
+}
+
+// SearchService handles full-text search
+type SearchService struct {
+	DB *sql.DB
+	// fts is set once EnsureIndex has found FTS5 and built the index
+	fts bool
+}
+
+// NewSearchService creates a new SearchService
+func NewSearchService(db *sql.DB) *SearchService {
+	return &SearchService{DB: db}
+}
+
+// EnsureIndex creates the FTS5 tables and the triggers that keep them in
+// sync, filling any table that is new. FTS5 is only compiled into the SQLite
+// driver with the sqlite_fts5 build tag, so the index lives outside the
+// migrations; without it search falls back to LIKE queries.
+func (s *SearchService) EnsureIndex() error {
+	var hasFTS5 bool
+	if err := s.DB.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&hasFTS5); err != nil {
+		return fmt.Errorf("failed to check for FTS5: %w", err)
+	}
+	if !hasFTS5 {
+		log.Printf("SQLite was built without FTS5, search will use LIKE queries")
+		// Triggers left by an FTS5 build would make every write fail with
+		// "no such module"; the index is rebuilt when FTS5 is back
+		for _, source := range searchSources {
+			for _, suffix := range []string{"_ai", "_au", "_ad"} {
+				if _, err := s.DB.Exec("DROP TRIGGER IF EXISTS " + source.ftsTable + suffix); err != nil {
+					return fmt.Errorf("failed to drop search trigger: %w", err)
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, resultType := range SearchResultTypes {
+		if err := s.ensureSourceIndex(searchSources[resultType]); err != nil {
+			return err
+		}
+	}
+
+	s.fts = true
+	return nil
+}
+
+// ensureSourceIndex creates the FTS table and triggers for one source. The
+// table is (re)filled whenever its triggers are missing, since it can't have
+// been kept in sync without them.
+func (s *SearchService) ensureSourceIndex(source *searchSource) error {
+	baseTable := strings.Fields(source.table)[0]
+
+	var synced bool
+	err := s.DB.QueryRow("SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'trigger' AND name = ?", source.ftsTable+"_ai").Scan(&synced)
+	if err != nil {
+		return fmt.Errorf("failed to check search index %s: %w", source.ftsTable, err)
+	}
+
+	columns := strings.Join(source.ftsColumns, ", ")
+	newValues := "new." + strings.Join(source.textColumns, ", new.")
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING fts5(
+			id UNINDEXED, %s, tokenize = 'unicode61 remove_diacritics 2'
+		)`, source.ftsTable, columns),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_ai AFTER INSERT ON %[2]s BEGIN
+			INSERT INTO %[1]s (id, %[3]s) VALUES (new.id, %[4]s);
+		END`, source.ftsTable, baseTable, columns, newValues),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_au AFTER UPDATE OF %[3]s ON %[2]s BEGIN
+			DELETE FROM %[1]s WHERE id = old.id;
+			INSERT INTO %[1]s (id, %[3]s) VALUES (new.id, %[4]s);
+		END`, source.ftsTable, baseTable, columns, newValues),
+		fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_ad AFTER DELETE ON %[2]s BEGIN
+			DELETE FROM %[1]s WHERE id = old.id;
+		END`, source.ftsTable, baseTable),
+	}
+	if !synced {
+		statements = append(statements,
+			"DELETE FROM "+source.ftsTable,
+			fmt.Sprintf(
+				"INSERT INTO %s (id, %s) SELECT id, %s FROM %s",
+				source.ftsTable, columns, strings.Join(source.textColumns, ", "), baseTable,
+			),
+		)
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to set up search index %s: %w", source.ftsTable, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Search finds the items of the given types matching query that userID may
+// see, best matches first, returning up to limit results per type
+func (s *SearchService) Search(userID, query string, types []SearchResultType, limit, offset int) (map[SearchResultType][]*SearchResult, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, errors.New("search query is required")
+	}
+
+	results := make(map[SearchResultType][]*SearchResult, len(types))
+	for _, resultType := range types {
+		source, ok := searchSources[resultType]
+		if !ok {
+			return nil, fmt.Errorf("unknown search type: %s", resultType)
+		}
+
+		var sourceResults []*SearchResult
+		var err error
+		if s.fts {
+			sourceResults, err = s.searchFTS(source, userID, terms, limit, offset)
+		} else {
+			sourceResults, err = s.searchLike(source, userID, terms, limit, offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[resultType] = sourceResults
+	}
+
+	return results, nil
+}
+
+// searchFTS searches one source through its FTS5 table, ranked by bm25
+func (s *SearchService) searchFTS(source *searchSource, userID string, terms []string, limit, offset int) ([]*SearchResult, error) {
+	// Quote every term so user input can't inject FTS5 query syntax, and
+	// prefix-match each one so results show up while the user is typing
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"*`
+	}
+
+	alias := strings.Fields(source.table)[1]
+	query := fmt.Sprintf(`
+		SELECT %[1]s.id, snippet(%[2]s, -1, ?, ?, '…', 16), bm25(%[2]s), %[1]s.created_at,
+			%[3]s
+		FROM %[2]s
+		JOIN %[4]s ON %[1]s.id = %[2]s.id
+		WHERE %[2]s MATCH ? AND %[5]s
+		ORDER BY bm25(%[2]s)
+		LIMIT ? OFFSET ?
+	`, alias, source.ftsTable, strings.Join(source.selects[:], ", "), source.table, source.visibility)
+
+	args := []interface{}{searchMatchStart, searchMatchEnd, strings.Join(quoted, " ")}
+	args = append(args, visibilityArgs(source, userID)...)
+	args = append(args, limit, offset)
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %ss: %w", source.resultType, err)
+	}
+	defer rows.Close()
+
+	return scanSearchResults(source, rows, func(result *SearchResult, text string) {
+		result.Snippet = highlightSnippet(text)
+		// bm25 scores are negative, lower being better; flip them so higher is better
+		result.Rank = -result.Rank
+	})
+}
+
+// searchLike searches one source with LIKE when FTS5 isn't available,
+// newest first
+func (s *SearchService) searchLike(source *searchSource, userID string, terms []string, limit, offset int) ([]*SearchResult, error) {
+	alias := strings.Fields(source.table)[1]
+
+	// Every term has to appear in one of the text columns
+	var conditions []string
+	var termArgs []interface{}
+	for _, term := range terms {
+		var columnConditions []string
+		for _, column := range source.textColumns {
+			columnConditions = append(columnConditions, fmt.Sprintf("%s.%s LIKE ? ESCAPE '\\'", alias, column))
+			termArgs = append(termArgs, "%"+escapeLike(term)+"%")
+		}
+		conditions = append(conditions, "("+strings.Join(columnConditions, " OR ")+")")
+	}
+
+	textColumns := make([]string, len(source.textColumns))
+	for i, column := range source.textColumns {
+		textColumns[i] = fmt.Sprintf("COALESCE(%s.%s, '')", alias, column)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s.id, %[2]s, 0, %[1]s.created_at,
+			%[3]s
+		FROM %[4]s
+		WHERE %[5]s AND %[6]s
+		ORDER BY %[1]s.created_at DESC
+		LIMIT ? OFFSET ?
+	`, alias, strings.Join(textColumns, " || ' ' || "), strings.Join(source.selects[:], ", "), source.table,
+		strings.Join(conditions, " AND "), source.visibility)
+
+	args := append(termArgs, visibilityArgs(source, userID)...)
+	args = append(args, limit, offset)
+
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %ss: %w", source.resultType, err)
+	}
+	defer rows.Close()
+
+	return scanSearchResults(source, rows, func(result *SearchResult, text string) {
+		result.Snippet = likeSnippet(strings.TrimSpace(text), terms)
+	})
+}
+
+// scanSearchResults scans rows selected by searchFTS or searchLike
+func scanSearchResults(source *searchSource, rows *sql.Rows, finish func(result *SearchResult, text string)) ([]*SearchResult, error) {
+	results := []*SearchResult{}
+	for rows.Next() {
+		result := &SearchResult{Type: source.resultType}
+		var text string
+		var title, userID, groupID, postID, receiverID sql.NullString
+		err := rows.Scan(&result.ID, &text, &result.Rank, &result.CreatedAt, &title, &userID, &groupID, &postID, &receiverID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan search result: %w", err)
+		}
+
+		result.Title = title.String
+		result.UserID = userID.String
+		result.GroupID = groupID.String
+		result.PostID = postID.String
+		result.ReceiverID = receiverID.String
+		finish(result, text)
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+
+	return results, nil
+}
+
+// visibilityArgs repeats the searching user's ID once per placeholder in the source's visibility condition
+func visibilityArgs(source *searchSource, userID string) []interface{} {
+	args := make([]interface{}, source.visibilityArgs)
+	for i := range args {
+		args[i] = userID
+	}
+	return args
+}
+
+// searchTerms splits a query into lowercase words
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// escapeLike escapes LIKE wildcards in a search term
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+}
+
+// highlightSnippet escapes an FTS5 snippet and turns its match markers into <mark> tags
+func highlightSnippet(snippet string) string {
+	return strings.NewReplacer(searchMatchStart, "<mark>", searchMatchEnd, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// likeSnippet cuts a snippet around the first matching term and highlights every term in it
+func likeSnippet(text string, terms []string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// Lowercasing changed the length, so positions wouldn't line up
+		lower = runes
+	}
+
+	// Find where the first term matches
+	start := 0
+	for _, term := range terms {
+		if i := strings.Index(string(lower), term); i >= 0 {
+			start = len([]rune(string(lower)[:i]))
+			break
+		}
+	}
+
+	from := start - searchSnippetRunes/2
+	if from < 0 {
+		from = 0
+	}
+	to := from + searchSnippetRunes
+	if to > len(runes) {
+		to = len(runes)
+	}
+
+	// Mark matches, working on the lowercased copy so positions line up
+	window := runes[from:to]
+	lowerWindow := string(lower[from:to])
+	marked := make([]bool, len(window))
+	for _, term := range terms {
+		termLength := len([]rune(term))
+		for offset := 0; ; {
+			i := strings.Index(lowerWindow[offset:], term)
+			if i < 0 {
+				break
+			}
+			position := len([]rune(lowerWindow[:offset+i]))
+			for j := position; j < position+termLength && j < len(marked); j++ {
+				marked[j] = true
+			}
+			offset += i + len(term)
+		}
+	}
+
+	var snippet strings.Builder
+	if from > 0 {
+		snippet.WriteString("…")
+	}
+	inMatch := false
+	for i, r := range window {
+		if marked[i] != inMatch {
+			if marked[i] {
+				snippet.WriteString(searchMatchStart)
+			} else {
+				snippet.WriteString(searchMatchEnd)
+			}
+			inMatch = marked[i]
+		}
+		snippet.WriteRune(r)
+	}
+	if inMatch {
+		snippet.WriteString(searchMatchEnd)
+	}
+	if to < len(runes) {
+		snippet.WriteString("…")
+	}
+
+	return highlightSnippet(snippet.String())
+}
@@ -10,12 +10,21 @@ import (
 	"github.com/google/uuid"
 )
 
+// sessionTouchInterval limits how often last_seen_at is written for an active session
+const sessionTouchInterval = time.Minute
+
 // Session represents a user session
 type Session struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"userId"`
-	ExpiresAt time.Time `json:"expiresAt"`
-	CreatedAt time.Time `json:"createdAt"`
+	ID         string    `json:"-"`  // The bearer token; never sent back to clients
+	PublicID   string    `json:"id"` // Identifies the session when listing and revoking it
+	UserID     string    `json:"userId"`
+	UserAgent  string    `json:"userAgent"`
+	IPAddress  string    `json:"ipAddress"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// Additional fields for API responses
+	Current bool `json:"current"`
 }
 
 // SessionService handles session-related operations
@@ -30,19 +39,29 @@ func NewSessionService(db *sql.DB) *SessionService {
 
 // Create creates a new session for a user
 func (s *SessionService) Create(userID string, duration time.Duration) (*Session, error) {
+	return s.CreateForDevice(userID, duration, "", "")
+}
+
+// CreateForDevice creates a new session for a user, recording the device it was opened from
+func (s *SessionService) CreateForDevice(userID string, duration time.Duration, userAgent, ipAddress string) (*Session, error) {
+	now := time.Now()
 	session := &Session{
-		ID:        uuid.New().String(),
-		UserID:    userID,
-		ExpiresAt: time.Now().Add(duration),
-		CreatedAt: time.Now(),
+		ID:         uuid.New().String(),
+		PublicID:   uuid.New().String(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(duration),
+		CreatedAt:  now,
 	}
 
 	log.Printf("Creating session with ID: %s for user: %s", session.ID, userID)
 
 	_, err := s.DB.Exec(`
-		INSERT INTO sessions (id, user_id, expires_at, created_at)
-		VALUES (?, ?, ?, ?)
-	`, session.ID, session.UserID, session.ExpiresAt, session.CreatedAt)
+		INSERT INTO sessions (id, public_id, user_id, user_agent, ip_address, last_seen_at, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, session.ID, session.PublicID, session.UserID, session.UserAgent, session.IPAddress, session.LastSeenAt, session.ExpiresAt, session.CreatedAt)
 	if err != nil {
 		log.Printf("Failed to insert session into database: %v", err)
 		return nil, fmt.Errorf("failed to create session: %w", err)
@@ -55,11 +74,12 @@ func (s *SessionService) Create(userID string, duration time.Duration) (*Session
 // GetByID retrieves a session by ID
 func (s *SessionService) GetByID(id string) (*Session, error) {
 	session := &Session{}
+	var lastSeenAt sql.NullTime
 	err := s.DB.QueryRow(`
-		SELECT id, user_id, expires_at, created_at
+		SELECT id, public_id, user_id, user_agent, ip_address, last_seen_at, expires_at, created_at
 		FROM sessions
 		WHERE id = ?
-	`, id).Scan(&session.ID, &session.UserID, &session.ExpiresAt, &session.CreatedAt)
+	`, id).Scan(&session.ID, &session.PublicID, &session.UserID, &session.UserAgent, &session.IPAddress, &lastSeenAt, &session.ExpiresAt, &session.CreatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("session not found")
@@ -77,9 +97,89 @@ func (s *SessionService) GetByID(id string) (*Session, error) {
 		return nil, errors.New("session has expired")
 	}
 
+	session.LastSeenAt = session.CreatedAt
+	if lastSeenAt.Valid {
+		session.LastSeenAt = lastSeenAt.Time
+	}
+
 	return session, nil
 }
 
+// GetByPublicID retrieves an unexpired session of a user by its public ID.
+// Sessions of other users are not found.
+func (s *SessionService) GetByPublicID(publicID, userID string) (*Session, error) {
+	var id string
+	err := s.DB.QueryRow(`
+		SELECT id
+		FROM sessions
+		WHERE public_id = ? AND user_id = ?
+	`, publicID, userID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("session not found")
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	session, err := s.GetByID(id)
+	if err != nil && err.Error() == "session has expired" {
+		return nil, errors.New("session not found")
+	}
+	return session, err
+}
+
+// GetActiveByUser retrieves all unexpired sessions for a user, most recently used first
+func (s *SessionService) GetActiveByUser(userID string) ([]*Session, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, public_id, user_id, user_agent, ip_address, last_seen_at, expires_at, created_at
+		FROM sessions
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY COALESCE(last_seen_at, created_at) DESC
+	`, userID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		session := &Session{}
+		var lastSeenAt sql.NullTime
+		err := rows.Scan(&session.ID, &session.PublicID, &session.UserID, &session.UserAgent, &session.IPAddress, &lastSeenAt, &session.ExpiresAt, &session.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+
+		session.LastSeenAt = session.CreatedAt
+		if lastSeenAt.Valid {
+			session.LastSeenAt = lastSeenAt.Time
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// Touch records activity on a session, writing at most once per sessionTouchInterval
+func (s *SessionService) Touch(id string) error {
+	now := time.Now()
+	_, err := s.DB.Exec(`
+		UPDATE sessions
+		SET last_seen_at = ?
+		WHERE id = ? AND (last_seen_at IS NULL OR last_seen_at < ?)
+	`, now, id, now.Add(-sessionTouchInterval))
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+
+	return nil
+}
+
 // Delete deletes a session
 func (s *SessionService) Delete(id string) error {
 	result, err := s.DB.Exec("DELETE FROM sessions WHERE id = ?", id)
@@ -97,6 +197,25 @@ func (s *SessionService) Delete(id string) error {
 	return nil
 }
 
+// DeleteForUser deletes a session only if it belongs to the given user
+func (s *SessionService) DeleteForUser(id, userID string) error {
+	result, err := s.DB.Exec("DELETE FROM sessions WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return errors.New("session not found")
+	}
+
+	return nil
+}
+
 // DeleteAllForUser deletes all sessions for a user
 func (s *SessionService) DeleteAllForUser(userID string) error {
 	result, err := s.DB.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
@@ -130,28 +249,3 @@ func (s *SessionService) CleanupExpiredSessions() error {
 
 	return nil
 }
-
-// IsLatestSession checks if the given session ID is the most recent one for the user
-func (s *SessionService) IsLatestSession(userID string, sessionID string) (bool, error) {
-	var latestSessionID string
-	err := s.DB.QueryRow(`
-        SELECT id FROM sessions 
-        WHERE user_id = ? 
-        ORDER BY created_at DESC 
-        LIMIT 1
-    `, userID).Scan(&latestSessionID)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			log.Printf("No sessions found for user: %s", userID)
-			return false, nil
-		}
-		return false, fmt.Errorf("failed to get latest session: %w", err)
-	}
-
-	isLatest := latestSessionID == sessionID
-	log.Printf("Session validation check - User: %s, Current: %s, Latest: %s, IsLatest: %v",
-		userID, sessionID, latestSessionID, isLatest)
-
-	return isLatest, nil
-}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TwoFactor represents a user's TOTP two-factor settings
+type TwoFactor struct {
+	UserID       string     `json:"-"`
+	Secret       string     `json:"-"`
+	EnabledAt    *time.Time `json:"enabledAt,omitempty"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// Enabled reports whether setup was confirmed and codes are required at login
+func (t *TwoFactor) Enabled() bool {
+	return t.EnabledAt != nil
+}
+
+// TwoFactorService handles TOTP secrets and recovery codes
+type TwoFactorService struct {
+	DB *sql.DB
+}
+
+// NewTwoFactorService creates a new TwoFactorService
+func NewTwoFactorService(db *sql.DB) *TwoFactorService {
+	return &TwoFactorService{DB: db}
+}
+
+// GetByUserID retrieves a user's two-factor settings
+func (s *TwoFactorService) GetByUserID(userID string) (*TwoFactor, error) {
+	twoFactor := &TwoFactor{}
+	var enabledAt sql.NullTime
+	err := s.DB.QueryRow(`
+		SELECT user_id, secret, enabled_at, last_used_step, created_at
+		FROM user_two_factor
+		WHERE user_id = ?
+	`, userID).Scan(&twoFactor.UserID, &twoFactor.Secret, &enabledAt, &twoFactor.LastUsedStep, &twoFactor.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("two-factor authentication not set up")
+		}
+		return nil, fmt.Errorf("failed to get two-factor settings: %w", err)
+	}
+	if enabledAt.Valid {
+		twoFactor.EnabledAt = &enabledAt.Time
+	}
+
+	return twoFactor, nil
+}
+
+// IsEnabled reports whether a user must give a TOTP code to log in
+func (s *TwoFactorService) IsEnabled(userID string) (bool, error) {
+	var count int
+	err := s.DB.QueryRow(`
+		SELECT COUNT(*) FROM user_two_factor WHERE user_id = ? AND enabled_at IS NOT NULL
+	`, userID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check two-factor status: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// SetPending stores a new secret awaiting confirmation, replacing any earlier unconfirmed one.
+// It fails if two-factor authentication is already enabled.
+func (s *TwoFactorService) SetPending(userID, secret string) error {
+	result, err := s.DB.Exec(`
+		INSERT INTO user_two_factor (user_id, secret, created_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, last_used_step = 0, created_at = excluded.created_at
+		WHERE user_two_factor.enabled_at IS NULL
+	`, userID, secret, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to store two-factor secret: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to store two-factor secret: %w", err)
+	}
+	if rows == 0 {
+		return errors.New("two-factor authentication is already enabled")
+	}
+
+	return nil
+}
+
+// Enable confirms a pending setup, after the user proved their app produces valid codes
+func (s *TwoFactorService) Enable(userID string) error {
+	_, err := s.DB.Exec(`
+		UPDATE user_two_factor SET enabled_at = ? WHERE user_id = ? AND enabled_at IS NULL
+	`, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+
+	return nil
+}
+
+// Disable removes a user's two-factor secret and recovery codes
+func (s *TwoFactorService) Disable(userID string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM two_factor_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM user_two_factor WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UseStep records that the code for a TOTP time step was used. It returns false
+// if that step or a later one was already used, so each code works only once.
+func (s *TwoFactorService) UseStep(userID string, step int64) (bool, error) {
+	result, err := s.DB.Exec(`
+		UPDATE user_two_factor SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?
+	`, step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("failed to record two-factor code use: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record two-factor code use: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for new ones
+func (s *TwoFactorService) ReplaceRecoveryCodes(userID string, codes []string) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM two_factor_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	now := time.Now()
+	for _, code := range codes {
+		if _, err := tx.Exec(`
+			INSERT INTO two_factor_recovery_codes (id, user_id, code_hash, created_at)
+			VALUES (?, ?, ?, ?)
+		`, uuid.New().String(), userID, hashToken(code), now); err != nil {
+			return fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks one of a user's recovery codes used, returning false if it is not a valid unused code
+func (s *TwoFactorService) UseRecoveryCode(userID, code string) (bool, error) {
+	result, err := s.DB.Exec(`
+		UPDATE two_factor_recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`, time.Now(), userID, hashToken(code))
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (s *TwoFactorService) CountRecoveryCodes(userID string) (int, error) {
+	var count int
+	err := s.DB.QueryRow(`
+		SELECT COUNT(*) FROM two_factor_recovery_codes WHERE user_id = ? AND used_at IS NULL
+	`, userID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+
+	return count, nil
+}
@@ -2,10 +2,13 @@ package models
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/bernaotieno/social-network/backend/pkg/utils"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -19,23 +22,69 @@ const (
 	UserRoleModerator UserRole = "moderator"
 )
 
+// IsStaff reports whether the role may use the moderation console
+func (r UserRole) IsStaff() bool {
+	return r == UserRoleAdmin || r == UserRoleModerator
+}
+
+// AccountStatus defines whether a user may use the platform
+type AccountStatus string
+
+const (
+	AccountStatusActive    AccountStatus = "active"
+	AccountStatusSuspended AccountStatus = "suspended"
+	AccountStatusBanned    AccountStatus = "banned"
+)
+
 // User represents a user in the system
 type User struct {
-	ID             string     `json:"id"`
-	Username       string     `json:"username"`
-	Email          string     `json:"email"`
-	Password       string     `json:"-"` // Never expose password in JSON
-	FullName       string     `json:"fullName"`
-	FirstName      string     `json:"firstName"`
-	LastName       string     `json:"lastName"`
-	DateOfBirth    *time.Time `json:"dateOfBirth,omitempty"`
-	Bio            string     `json:"bio,omitempty"`
-	ProfilePicture string     `json:"profilePicture,omitempty"`
-	CoverPhoto     string     `json:"coverPhoto,omitempty"`
-	IsPrivate      bool       `json:"isPrivate"`
-	Role           string     `json:"role"` // Added Role field
-	CreatedAt      time.Time  `json:"createdAt"`
-	UpdatedAt      time.Time  `json:"updatedAt"`
+	ID              string     `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"` // Never expose password in JSON
+	FullName        string     `json:"fullName"`
+	FirstName       string     `json:"firstName"`
+	LastName        string     `json:"lastName"`
+	DateOfBirth     *time.Time `json:"dateOfBirth,omitempty"`
+	Bio             string     `json:"bio,omitempty"`
+	ProfilePicture  string     `json:"profilePicture,omitempty"`
+	CoverPhoto      string     `json:"coverPhoto,omitempty"`
+	IsPrivate       bool       `json:"isPrivate"`
+	Role            string     `json:"role"` // Added Role field
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt,omitempty"`
+	EmailVerified   bool       `json:"emailVerified"`
+	// Account standing is only shown to staff, through the admin API
+	Status         AccountStatus `json:"-"`
+	SuspendedUntil *time.Time    `json:"-"` // Nil while suspended means until reinstated
+	StatusReason   string        `json:"-"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	UpdatedAt      time.Time     `json:"updatedAt"`
+}
+
+// MarshalJSON adds the size variants of the user's avatar and cover photo to its JSON
+func (u User) MarshalJSON() ([]byte, error) {
+	type user User
+	return json.Marshal(struct {
+		user
+		ProfilePictureSet *utils.ImageSet `json:"profilePictureSet,omitempty"`
+		CoverPhotoSet     *utils.ImageSet `json:"coverPhotoSet,omitempty"`
+	}{
+		user:              user(u),
+		ProfilePictureSet: utils.NewImageSet(u.ProfilePicture),
+		CoverPhotoSet:     utils.NewImageSet(u.CoverPhoto),
+	})
+}
+
+// EffectiveStatus returns the user's account status, treating a suspension
+// that has run out as active
+func (u *User) EffectiveStatus() AccountStatus {
+	if u.Status == AccountStatusSuspended && u.SuspendedUntil != nil && !u.SuspendedUntil.After(time.Now()) {
+		return AccountStatusActive
+	}
+	if u.Status == "" {
+		return AccountStatusActive
+	}
+	return u.Status
 }
 
 // UserService handles user-related operations
@@ -59,6 +108,12 @@ func (s *UserService) Create(user *User) error {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
+	// New accounts are regular members unless a role was given
+	if user.Role == "" {
+		user.Role = string(UserRoleMember)
+	}
+	user.Status = AccountStatusActive
+
 	// Set timestamps
 	now := time.Now()
 	user.CreatedAt = now
@@ -80,34 +135,52 @@ func (s *UserService) Create(user *User) error {
 func (s *UserService) GetByID(id string) (*User, error) {
 	user := &User{}
 	err := s.DB.QueryRow(`
-		SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, created_at, updated_at
+		SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, email_verified_at, status, suspended_until, status_reason, created_at, updated_at
 	FROM users
 	WHERE id = ?
-	`, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.FirstName, &user.LastName, &user.DateOfBirth, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	`, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.FirstName, &user.LastName, &user.DateOfBirth, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.Role, &user.EmailVerifiedAt, &user.Status, &user.SuspendedUntil, &user.StatusReason, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
+	user.EmailVerified = user.EmailVerifiedAt != nil
 
 	return user, nil
 }
 
+// GetProfile retrieves a user by ID as seen by currentUserID. Users on either
+// side of a block are not found.
+func (s *UserService) GetProfile(id, currentUserID string) (*User, error) {
+	if currentUserID != "" && currentUserID != id {
+		blocked, err := isBlockedBetween(s.DB, currentUserID, id)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, errors.New("user not found")
+		}
+	}
+
+	return s.GetByID(id)
+}
+
 // GetByEmail retrieves a user by email
 func (s *UserService) GetByEmail(email string) (*User, error) {
 	user := &User{}
 	err := s.DB.QueryRow(`
-		SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, created_at, updated_at
+		SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, email_verified_at, status, suspended_until, status_reason, created_at, updated_at
 	FROM users
 	WHERE email = ?
-	`, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.FirstName, &user.LastName, &user.DateOfBirth, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	`, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.FirstName, &user.LastName, &user.DateOfBirth, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.Role, &user.EmailVerifiedAt, &user.Status, &user.SuspendedUntil, &user.StatusReason, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
+	user.EmailVerified = user.EmailVerifiedAt != nil
 
 	return user, nil
 }
@@ -116,16 +189,17 @@ func (s *UserService) GetByEmail(email string) (*User, error) {
 func (s *UserService) GetByUsername(username string) (*User, error) {
 	user := &User{}
 	err := s.DB.QueryRow(`
-		SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, created_at, updated_at
+		SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, email_verified_at, status, suspended_until, status_reason, created_at, updated_at
 	FROM users
 	WHERE username = ?
-	`, username).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.FirstName, &user.LastName, &user.DateOfBirth, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+	`, username).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.FirstName, &user.LastName, &user.DateOfBirth, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.Role, &user.EmailVerifiedAt, &user.Status, &user.SuspendedUntil, &user.StatusReason, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("user not found")
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
+	user.EmailVerified = user.EmailVerifiedAt != nil
 
 	return user, nil
 }
@@ -167,30 +241,188 @@ func (s *UserService) UpdatePassword(userID, newPassword string) error {
 	return nil
 }
 
+// MarkEmailVerified records that a user has proven they own their email address
+func (s *UserService) MarkEmailVerified(userID string) error {
+	_, err := s.DB.Exec(`
+		UPDATE users
+		SET email_verified_at = COALESCE(email_verified_at, ?)
+		WHERE id = ?
+	`, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+
+	return nil
+}
+
+// ClearEmailVerified puts a user back into the pending verification state, e.g. after changing their email
+func (s *UserService) ClearEmailVerified(userID string) error {
+	_, err := s.DB.Exec(`
+		UPDATE users SET email_verified_at = NULL WHERE id = ?
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to clear email verification: %w", err)
+	}
+
+	return nil
+}
+
+// IsEmailVerified reports whether a user has verified their email address
+func (s *UserService) IsEmailVerified(userID string) (bool, error) {
+	var verifiedAt sql.NullTime
+	err := s.DB.QueryRow(`
+		SELECT email_verified_at FROM users WHERE id = ?
+	`, userID).Scan(&verifiedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, errors.New("user not found")
+		}
+		return false, fmt.Errorf("failed to check email verification: %w", err)
+	}
+
+	return verifiedAt.Valid, nil
+}
+
+// SetStatus changes a user's account standing, as part of the transaction
+// recording the action. until only applies to suspensions.
+func (s *UserService) SetStatus(tx *sql.Tx, userID string, status AccountStatus, until *time.Time, reason string) error {
+	if status != AccountStatusSuspended {
+		until = nil
+	}
+
+	result, err := tx.Exec(`
+		UPDATE users
+		SET status = ?, suspended_until = ?, status_reason = ?, updated_at = ?
+		WHERE id = ?
+	`, status, until, reason, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update account status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// UpdateRole changes a user's platform role, as part of the transaction
+// recording the action
+func (s *UserService) UpdateRole(tx *sql.Tx, userID string, role UserRole) error {
+	result, err := tx.Exec(`
+		UPDATE users SET role = ?, updated_at = ? WHERE id = ?
+	`, role, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return errors.New("user not found")
+	}
+
+	return nil
+}
+
+// AdminUserFilter narrows the user list shown to staff
+type AdminUserFilter struct {
+	Query  string // Matches username, name or email
+	Status AccountStatus
+	Role   UserRole
+}
+
+// ListForAdmin retrieves a page of users for the moderation console, newest
+// first, returning the cursor for the next page
+func (s *UserService) ListForAdmin(filter AdminUserFilter, page Page) ([]*User, string, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.Query != "" {
+		like := "%" + filter.Query + "%"
+		conditions = append(conditions, "(username LIKE ? OR full_name LIKE ? OR email LIKE ?)")
+		args = append(args, like, like, like)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.Role != "" {
+		conditions = append(conditions, "role = ?")
+		args = append(args, filter.Role)
+	}
+
+	cursorCondition, cursorArgs := page.where("created_at", "id")
+	conditions = append(conditions, cursorCondition)
+	args = append(args, cursorArgs...)
+	orderAndLimit, limitArgs := page.orderAndLimit("created_at", "id")
+	args = append(args, limitArgs...)
+
+	rows, err := s.DB.Query(`
+		SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, email_verified_at, status, suspended_until, status_reason, created_at, updated_at
+		FROM users
+		WHERE `+strings.Join(conditions, " AND ")+`
+		`+orderAndLimit, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		user := &User{}
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.FirstName, &user.LastName, &user.DateOfBirth, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.Role, &user.EmailVerifiedAt, &user.Status, &user.SuspendedUntil, &user.StatusReason, &user.CreatedAt, &user.UpdatedAt)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan user: %w", err)
+		}
+		user.EmailVerified = user.EmailVerifiedAt != nil
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("error iterating users: %w", err)
+	}
+
+	users, nextCursor := nextPage(users, page.Limit, func(user *User) (time.Time, string) {
+		return user.CreatedAt, user.ID
+	})
+	return users, nextCursor, nil
+}
+
 // CheckPassword checks if the provided password matches the user's password
 func (s *UserService) CheckPassword(user *User, password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	return err == nil
 }
 
-// GetUsers retrieves users with optional filtering
-func (s *UserService) GetUsers(query string, limit, offset int) ([]*User, error) {
+// GetUsers retrieves users with optional filtering, leaving out anyone on
+// either side of a block with currentUserID
+func (s *UserService) GetUsers(query, currentUserID string, limit, offset int) ([]*User, error) {
+	notBlocked, notBlockedArgs := notBlockedCondition("users.id", currentUserID)
+
 	var rows *sql.Rows
 	var err error
 
 	if query != "" {
+		args := append([]interface{}{"%" + query + "%", "%" + query + "%", "%" + query + "%", "%" + query + "%"}, notBlockedArgs...)
 		rows, err = s.DB.Query(`
-			SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, created_at, updated_at
+			SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, email_verified_at, status, suspended_until, status_reason, created_at, updated_at
 			FROM users
-			WHERE username LIKE ? OR full_name LIKE ? OR first_name LIKE ? OR last_name LIKE ?
+			WHERE (username LIKE ? OR full_name LIKE ? OR first_name LIKE ? OR last_name LIKE ?) AND `+notBlocked+`
 			LIMIT ? OFFSET ?
-		`, "%"+query+"%", "%"+query+"%", "%"+query+"%", "%"+query+"%", limit, offset)
+		`, append(args, limit, offset)...)
 	} else {
 		rows, err = s.DB.Query(`
-			SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, created_at, updated_at
+			SELECT id, username, email, password, full_name, first_name, last_name, date_of_birth, bio, profile_picture, cover_photo, is_private, role, email_verified_at, status, suspended_until, status_reason, created_at, updated_at
 			FROM users
+			WHERE `+notBlocked+`
 			LIMIT ? OFFSET ?
-		`, limit, offset)
+		`, append(notBlockedArgs, limit, offset)...)
 	}
 
 	if err != nil {
@@ -201,10 +433,11 @@ func (s *UserService) GetUsers(query string, limit, offset int) ([]*User, error)
 	var users []*User
 	for rows.Next() {
 		user := &User{}
-		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.FirstName, &user.LastName, &user.DateOfBirth, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.FullName, &user.FirstName, &user.LastName, &user.DateOfBirth, &user.Bio, &user.ProfilePicture, &user.CoverPhoto, &user.IsPrivate, &user.Role, &user.EmailVerifiedAt, &user.Status, &user.SuspendedUntil, &user.StatusReason, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
+		user.EmailVerified = user.EmailVerifiedAt != nil
 		users = append(users, user)
 	}
 
@@ -0,0 +1,51 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// Limiter allows up to a fixed number of attempts per key in each time window
+type Limiter struct {
+	store  Store
+	name   string
+	limit  int
+	window time.Duration
+}
+
+// NewLimiter creates a Limiter allowing limit attempts per window. The name
+// namespaces its keys so several limiters can share one store.
+func NewLimiter(store Store, name string, limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		store:  store,
+		name:   name,
+		limit:  limit,
+		window: window,
+	}
+}
+
+// Allow counts an attempt for key and reports whether it is within the limit.
+// When it is not, it also returns how long until the window resets.
+func (l *Limiter) Allow(key string) (bool, time.Duration, error) {
+	now := time.Now()
+	entry, err := l.store.Update(l.name+":"+key, l.window, func(entry *Entry) {
+		// Start a new window once the previous one has passed
+		if now.Sub(entry.WindowStart) >= l.window {
+			entry.Count = 0
+			entry.WindowStart = now
+		}
+		entry.Count++
+	})
+	if err != nil {
+		return false, 0, err
+	}
+
+	if entry.Count > l.limit {
+		return false, entry.WindowStart.Add(l.window).Sub(now), nil
+	}
+	return true, 0, nil
+}
+
+// Reset forgets the attempts counted for key
+func (l *Limiter) Reset(key string) error {
+	return l.store.Delete(l.name + ":" + key)
+}
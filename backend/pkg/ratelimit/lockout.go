@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// LockoutPolicy configures how a Lockout reacts to repeated failures
+type LockoutPolicy struct {
+	FreeAttempts    int           // Failures allowed before any delay is imposed
+	MaxAttempts     int           // Failures that lock the key out
+	BaseDelay       time.Duration // Delay after the first failure past FreeAttempts, doubled for each further failure
+	MaxDelay        time.Duration // Longest progressive delay
+	LockoutDuration time.Duration // How long a key stays locked after MaxAttempts failures
+	ResetAfter      time.Duration // Failures are forgotten after this long without another one
+}
+
+// Lockout tracks failed attempts per key, imposing progressively longer delays
+// between attempts and then a temporary lockout
+type Lockout struct {
+	store  Store
+	name   string
+	policy LockoutPolicy
+}
+
+// NewLockout creates a Lockout. The name namespaces its keys so several
+// lockouts can share one store.
+func NewLockout(store Store, name string, policy LockoutPolicy) *Lockout {
+	return &Lockout{
+		store:  store,
+		name:   name,
+		policy: policy,
+	}
+}
+
+// Check returns how long key must wait before its next attempt, or zero if it may try now
+func (l *Lockout) Check(key string) (time.Duration, error) {
+	entry, err := l.store.Get(l.name + ":" + key)
+	if err != nil {
+		return 0, err
+	}
+	return waitUntil(entry.BlockedUntil), nil
+}
+
+// Fail records a failed attempt for key. It returns how long key must wait
+// before its next attempt and whether the failure locked the key out.
+func (l *Lockout) Fail(key string) (time.Duration, bool, error) {
+	now := time.Now()
+	locked := false
+	entry, err := l.store.Update(l.name+":"+key, l.policy.ResetAfter, func(entry *Entry) {
+		entry.Count++
+		switch {
+		case entry.Count >= l.policy.MaxAttempts:
+			// Lock out, then start counting afresh once the lockout ends
+			entry.Count = 0
+			entry.BlockedUntil = now.Add(l.policy.LockoutDuration)
+			locked = true
+		case entry.Count > l.policy.FreeAttempts:
+			entry.BlockedUntil = now.Add(l.delay(entry.Count - l.policy.FreeAttempts))
+		}
+	})
+	if err != nil {
+		return 0, false, err
+	}
+
+	return waitUntil(entry.BlockedUntil), locked, nil
+}
+
+// Reset clears the failures and any lockout recorded for key
+func (l *Lockout) Reset(key string) error {
+	return l.store.Delete(l.name + ":" + key)
+}
+
+// delay returns the progressive delay for the nth failure past the free attempts
+func (l *Lockout) delay(n int) time.Duration {
+	delay := l.policy.BaseDelay
+	for i := 1; i < n && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.policy.MaxDelay {
+		delay = l.policy.MaxDelay
+	}
+	return delay
+}
+
+// waitUntil returns the time left until t, or zero if t has passed
+func waitUntil(t time.Time) time.Duration {
+	if wait := time.Until(t); wait > 0 {
+		return wait
+	}
+	return 0
+}
@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Entry is the state kept for one rate limited key
+type Entry struct {
+	Count        int       // Attempts counted so far
+	WindowStart  time.Time // Start of the current counting window
+	BlockedUntil time.Time // Attempts are refused until this time
+}
+
+// Store keeps rate limit entries. Implementations must apply Update atomically
+// so that concurrent requests for the same key are all counted.
+type Store interface {
+	// Get returns the entry for key, or a zero Entry if there is none
+	Get(key string) (Entry, error)
+	// Update applies update to the entry for key (a zero Entry if there is none),
+	// stores the result for ttl and returns it
+	Update(key string, ttl time.Duration, update func(entry *Entry)) (Entry, error)
+	// Delete removes the entry for key
+	Delete(key string) error
+}
+
+// sweepInterval is how often the memory store drops expired entries
+const sweepInterval = time.Minute
+
+// memoryEntry is an Entry with the time the memory store may forget it
+type memoryEntry struct {
+	Entry
+	expiresAt time.Time
+}
+
+// MemoryStore is a Store held in process memory
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates a new MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries:   make(map[string]*memoryEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// Get returns the entry for key, or a zero Entry if there is none
+func (s *MemoryStore) Get(key string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return Entry{}, nil
+	}
+	return entry.Entry, nil
+}
+
+// Update applies update to the entry for key and stores the result for ttl
+func (s *MemoryStore) Update(key string, ttl time.Duration, update func(entry *Entry)) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	entry, ok := s.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		entry = &memoryEntry{}
+		s.entries[key] = entry
+	}
+
+	update(&entry.Entry)
+	entry.expiresAt = now.Add(ttl)
+	if entry.BlockedUntil.After(entry.expiresAt) {
+		entry.expiresAt = entry.BlockedUntil
+	}
+
+	return entry.Entry, nil
+}
+
+// Delete removes the entry for key
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops expired entries, at most once per sweepInterval. The caller must hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	for key, entry := range s.entries {
+		if now.After(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
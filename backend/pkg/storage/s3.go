@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config holds the settings of an S3-compatible bucket
+type S3Config struct {
+	Endpoint        string `json:"endpoint"`        // e.g. https://s3.eu-west-1.amazonaws.com, or http://localhost:9000 for MinIO
+	Region          string `json:"region"`          // Region requests are signed for
+	Bucket          string `json:"bucket"`          // Bucket files are stored in
+	AccessKeyID     string `json:"accessKeyId"`     // Credentials requests are signed with
+	SecretAccessKey string `json:"secretAccessKey"` // Never logged
+	PathStyle       bool   `json:"pathStyle"`       // Put the bucket in the path rather than the host name, as MinIO expects
+	// Serve files straight from here (e.g. a CDN) instead of through the
+	// backend. Anyone can fetch them there, so the backend's checks on who may
+	// see each file no longer apply.
+	PublicURL string `json:"publicUrl"`
+}
+
+// S3 stores files in a bucket of an S3-compatible service such as AWS S3 or MinIO
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// s3RequestTimeout bounds each request to the bucket
+const s3RequestTimeout = 30 * time.Second
+
+// NewS3 creates an S3 store
+func NewS3(config S3Config) (*S3, error) {
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" || config.Region == "" {
+		return nil, errors.New("S3 bucket and region are required")
+	}
+	if config.AccessKeyID == "" || config.SecretAccessKey == "" {
+		return nil, errors.New("S3 credentials are required")
+	}
+
+	return &S3{
+		config:   config,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: s3RequestTimeout},
+	}, nil
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64, contentType string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	resp, err := s.do(http.MethodPut, key, nil, r, size, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s.error(resp, http.MethodPut, key)
+	}
+	return nil
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, *Object, error) {
+	if !ValidKey(key) {
+		return nil, nil, fmt.Errorf("invalid key %q", key)
+	}
+
+	resp, err := s.do(http.MethodGet, key, nil, nil, 0, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, nil, s.error(resp, http.MethodGet, key)
+	}
+
+	return resp.Body, objectFromHeader(key, resp), nil
+}
+
+func (s *S3) Stat(key string) (*Object, error) {
+	if !ValidKey(key) {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+
+	resp, err := s.do(http.MethodHead, key, nil, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.error(resp, http.MethodHead, key)
+	}
+	return objectFromHeader(key, resp), nil
+}
+
+func (s *S3) Delete(key string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid key %q", key)
+	}
+
+	resp, err := s.do(http.MethodDelete, key, nil, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Deleting a missing key succeeds on S3 but not on every compatible service
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s.error(resp, http.MethodDelete, key)
+	}
+	return nil
+}
+
+// listBucketResult is the body of a ListObjectsV2 response
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(prefix string, fn func(Object) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		resp, err := s.do(http.MethodGet, "", query, nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s.error(resp, http.MethodGet, "")
+			resp.Body.Close()
+			return err
+		}
+
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse S3 listing: %w", err)
+		}
+
+		for _, content := range result.Contents {
+			object := Object{Key: content.Key, Size: content.Size, ContentType: ContentType(content.Key), ModTime: content.LastModified}
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *S3) URL(key string) string {
+	if s.config.PublicURL != "" {
+		return strings.TrimSuffix(s.config.PublicURL, "/") + "/" + key
+	}
+	return "/uploads/" + key
+}
+
+// do sends a signed request for key, or for the bucket itself when key is empty
+func (s *S3) do(method, key string, query url.Values, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	target := *s.endpoint
+	objectPath := "/" + key
+	if s.config.PathStyle {
+		objectPath = "/" + s.config.Bucket + objectPath
+	} else {
+		target.Host = s.config.Bucket + "." + target.Host
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/") + objectPath
+	target.RawPath = uriEncode(target.Path, false)
+	target.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, target.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	// Payloads are streamed, so they are sent unsigned; TLS protects them in transit
+	payloadHash := unsignedPayload
+	if body == nil {
+		payloadHash = emptyPayloadHash
+	}
+	signV4(req, payloadHash, s.config.AccessKeyID, s.config.SecretAccessKey, s.config.Region, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("S3 %s request failed: %w", method, err)
+	}
+	return resp, nil
+}
+
+// s3Error is the body of an S3 error response
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// error turns an unsuccessful response for key, or for the bucket when key
+// is empty, into an error: ErrNotFound for missing keys
+func (s *S3) error(resp *http.Response, method, key string) error {
+	target := key
+	if key == "" {
+		target = "bucket " + s.config.Bucket
+	} else if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	var body s3Error
+	if method != http.MethodHead {
+		xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	}
+	if body.Code == "" {
+		body.Code = resp.Status
+	}
+	return fmt.Errorf("S3 %s %s failed: %s", method, target, strings.TrimSpace(body.Code+" "+body.Message))
+}
+
+func objectFromHeader(key string, resp *http.Response) *Object {
+	object := &Object{Key: key, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if object.Size < 0 {
+		object.Size, _ = strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	}
+	if object.ContentType == "" {
+		object.ContentType = ContentType(key)
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		object.ModTime = modified
+	}
+	return object
+}
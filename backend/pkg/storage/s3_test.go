@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// s3StandIn is a bucket served over HTTP the way S3 and MinIO serve one with
+// path-style addressing. It checks requests are signed but not the signatures
+// themselves, which TestSignV4 covers.
+type s3StandIn struct {
+	bucket   string
+	pageSize int // Most keys in one listing, so paging is exercised
+
+	mu      sync.Mutex
+	objects map[string]s3StandInObject
+}
+
+type s3StandInObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// newS3StandIn starts a stand-in bucket, returning an S3 store that uses it
+func newS3StandIn(t *testing.T) (*S3, *s3StandIn) {
+	t.Helper()
+	standIn := &s3StandIn{bucket: "uploads", pageSize: 2, objects: make(map[string]s3StandInObject)}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3(S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          standIn.bucket,
+		AccessKeyID:     "test-key",
+		SecretAccessKey: "test-secret",
+		PathStyle:       true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create S3 store: %v", err)
+	}
+	return store, standIn
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-key/") ||
+		r.Header.Get("X-Amz-Content-Sha256") == "" || r.Header.Get("X-Amz-Date") == "" {
+		s.fail(w, r, http.StatusForbidden, "AccessDenied")
+		return
+	}
+	if r.URL.Path != "/"+s.bucket && !strings.HasPrefix(r.URL.Path, "/"+s.bucket+"/") {
+		s.fail(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+s.bucket), "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r)
+
+	case r.Method == http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != r.ContentLength {
+			s.fail(w, r, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.objects[key] = s3StandInObject{data: data, contentType: r.Header.Get("Content-Type"), modTime: time.Now()}
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		object, ok := s.objects[key]
+		if !ok {
+			s.fail(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		w.Header().Set("Last-Modified", object.modTime.UTC().Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+
+	case r.Method == http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		s.fail(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list answers a ListObjectsV2 request, continuing after the token's key
+func (s *s3StandIn) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" {
+		s.fail(w, r, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, query.Get("prefix")) && key > query.Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var result listBucketResult
+	if len(keys) > s.pageSize {
+		keys = keys[:s.pageSize]
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[len(keys)-1]
+	}
+	for _, key := range keys {
+		object := s.objects[key]
+		result.Contents = append(result.Contents, struct {
+			Key          string    `xml:"Key"`
+			Size         int64     `xml:"Size"`
+			LastModified time.Time `xml:"LastModified"`
+		}{key, int64(len(object.data)), object.modTime.UTC()})
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"ListBucketResult"`
+		listBucketResult
+	}{listBucketResult: result})
+}
+
+func (s *s3StandIn) fail(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"Error"`
+			s3Error
+		}{s3Error: s3Error{Code: code, Message: "refused by the stand-in"}})
+	}
+}
+
+func TestS3(t *testing.T) {
+	store, _ := newS3StandIn(t)
+	testStore(t, store)
+
+	if got := store.URL("posts/a.jpg"); got != "/uploads/posts/a.jpg" {
+		t.Errorf("Expected files to be served through the backend, got %s", got)
+	}
+}
+
+func TestS3ReportsRefusedRequests(t *testing.T) {
+	store, _ := newS3StandIn(t)
+	store.config.AccessKeyID = "wrong-key"
+
+	err := store.Put("posts/a.jpg", strings.NewReader("data"), 4, "image/jpeg")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Errorf("Expected an AccessDenied error, got %v", err)
+	}
+}
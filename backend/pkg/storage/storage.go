@@ -0,0 +1,102 @@
+// Package storage keeps uploaded files in a blob store, so several backend
+// instances can share them. Files are addressed by slash-separated keys such
+// as "posts/<name>.jpg".
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// Backend names
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// ErrNotFound is returned for keys nothing is stored under
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored file
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage is a blob store for uploaded files
+type Storage interface {
+	// Put stores size bytes read from r under key, replacing anything there
+	Put(key string, r io.Reader, size int64, contentType string) error
+	// Get opens the file stored under key. The caller must close it.
+	Get(key string) (io.ReadCloser, *Object, error)
+	// Stat describes the file stored under key without reading it
+	Stat(key string) (*Object, error)
+	// Delete removes the file stored under key; deleting a missing key is not an error
+	Delete(key string) error
+	// List calls fn for every file whose key starts with prefix
+	List(prefix string, fn func(Object) error) error
+	// URL returns the URL clients fetch the file stored under key from
+	URL(key string) string
+}
+
+// ValidKey reports whether key is a clean relative path that can't escape
+// the store it is used with
+func ValidKey(key string) bool {
+	return key != "" && !strings.HasPrefix(key, "/") && path.Clean(key) == key &&
+		key != ".." && !strings.HasPrefix(key, "../") && !strings.Contains(key, "\\")
+}
+
+// contentTypes maps the extensions uploads are stored with onto their content type
+var contentTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// ContentType returns the content type files stored under key are served with
+func ContentType(key string) string {
+	if contentType, ok := contentTypes[path.Ext(key)]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// Copy copies every file from src to dst, skipping files dst already holds
+// with the same size, so an interrupted copy can be run again. It calls
+// progress after each file.
+func Copy(src, dst Storage, progress func(key string, copied bool)) (copied, skipped int, err error) {
+	err = src.List("", func(object Object) error {
+		if existing, err := dst.Stat(object.Key); err == nil && existing.Size == object.Size {
+			skipped++
+			if progress != nil {
+				progress(object.Key, false)
+			}
+			return nil
+		} else if err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("failed to check %s: %w", object.Key, err)
+		}
+
+		reader, info, err := src.Get(object.Key)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", object.Key, err)
+		}
+		defer reader.Close()
+
+		if err := dst.Put(object.Key, reader, info.Size, ContentType(object.Key)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", object.Key, err)
+		}
+		copied++
+		if progress != nil {
+			progress(object.Key, true)
+		}
+		return nil
+	})
+	return copied, skipped, err
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testFiles maps the keys the tests store onto their contents
+var testFiles = map[string]string{
+	"posts/a b.jpg":  "first post",
+	"posts/b.png":    "second post",
+	"posts/c.webp":   "third post",
+	"comments/d.gif": "a comment",
+}
+
+// putTestFiles stores testFiles in store
+func putTestFiles(t *testing.T, store Storage) {
+	t.Helper()
+	for key, data := range testFiles {
+		if err := store.Put(key, strings.NewReader(data), int64(len(data)), ContentType(key)); err != nil {
+			t.Fatalf("Put %s failed: %v", key, err)
+		}
+	}
+}
+
+// testStore checks a Storage backend stores, describes, lists and deletes files
+func testStore(t *testing.T, store Storage) {
+	putTestFiles(t, store)
+
+	reader, object, err := store.Get("posts/a b.jpg")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || string(data) != "first post" {
+		t.Errorf("Expected %q, got %q (%v)", "first post", data, err)
+	}
+	if object.Size != int64(len("first post")) || object.ContentType != "image/jpeg" {
+		t.Errorf("Expected a %d byte image/jpeg, got %+v", len("first post"), object)
+	}
+
+	object, err = store.Stat("posts/b.png")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if object.Size != int64(len("second post")) || object.ContentType != "image/png" || object.ModTime.IsZero() {
+		t.Errorf("Expected a %d byte image/png with a modification time, got %+v", len("second post"), object)
+	}
+
+	var keys []string
+	err = store.List("posts/", func(object Object) error {
+		keys = append(keys, object.Key)
+		if object.Size != int64(len(testFiles[object.Key])) {
+			t.Errorf("Expected %s to be %d bytes, got %d", object.Key, len(testFiles[object.Key]), object.Size)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := []string{"posts/a b.jpg", "posts/b.png", "posts/c.webp"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Expected to list %v, got %v", want, keys)
+	}
+
+	if err := store.Delete("posts/b.png"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Stat("posts/b.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from Stat after Delete, got %v", err)
+	}
+	if _, _, err := store.Get("posts/b.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound from Get after Delete, got %v", err)
+	}
+	if err := store.Delete("posts/b.png"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+
+	if err := store.Put("../escape.jpg", strings.NewReader("x"), 1, "image/jpeg"); err == nil {
+		t.Error("Expected an invalid key to be refused")
+	}
+}
+
+func TestLocal(t *testing.T) {
+	store := NewLocal(t.TempDir())
+	testStore(t, store)
+
+	if got := store.URL("posts/a.jpg"); got != "/uploads/posts/a.jpg" {
+		t.Errorf("Expected /uploads/posts/a.jpg, got %s", got)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	src := NewLocal(t.TempDir())
+	putTestFiles(t, src)
+	dst, _ := newS3StandIn(t)
+
+	copied, skipped, err := Copy(src, dst, nil)
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if copied != len(testFiles) || skipped != 0 {
+		t.Errorf("Expected %d files copied and none skipped, got %d and %d", len(testFiles), copied, skipped)
+	}
+	for key, want := range testFiles {
+		reader, object, err := dst.Get(key)
+		if err != nil {
+			t.Fatalf("Get %s failed: %v", key, err)
+		}
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(data) != want || object.ContentType != ContentType(key) {
+			t.Errorf("Expected %s to hold %q as %s, got %q as %s", key, want, ContentType(key), data, object.ContentType)
+		}
+	}
+
+	// Running it again, as after an interruption, skips what was copied
+	changed := "posts/b.png"
+	if err := dst.Delete(changed); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	var progress []string
+	copied, skipped, err = Copy(src, dst, func(key string, copied bool) {
+		if copied {
+			progress = append(progress, key)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if copied != 1 || skipped != len(testFiles)-1 || !reflect.DeepEqual(progress, []string{changed}) {
+		t.Errorf("Expected only %s to be copied again, got %d copied, %d skipped (%v)", changed, copied, skipped, progress)
+	}
+}
@@ -1,85 +1,281 @@
 package utils
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/bernaotieno/social-network/backend/pkg/imaging"
+	"github.com/bernaotieno/social-network/backend/pkg/storage"
 	"github.com/google/uuid"
 )
 
-// Allowed image MIME types
-var allowedImageTypes = map[string]string{
-	"image/jpeg": ".jpg",
-	"image/png":  ".png",
-	"image/gif":  ".gif",
-}
+// imageDirectories are the folders under the upload root SaveImage is called with
+var imageDirectories = []string{"avatars", "covers", "posts", "comments", "groups", "group_posts", "link_previews"}
+
+// imageNamePattern matches the files SaveImage writes: <uuid>_<width>x<height>
+// for the full variant, followed by _<variant> for smaller ones
+var imageNamePattern = regexp.MustCompile(`^([0-9a-f-]{36})_([0-9]+)x([0-9]+)(?:_([a-z]+))?(\.[a-z]+)$`)
+
+// Upload settings, changed at startup by ConfigureUploads
+var (
+	// UploadStorage is the blob store uploaded files are kept in
+	UploadStorage storage.Storage = storage.NewLocal("uploads")
+	// MaxImageSize is the maximum allowed image size in bytes (5MB by default)
+	MaxImageSize int64 = 5 * 1024 * 1024
+	// MaxUploadRequestSize is the maximum size of a multipart request in bytes (10MB by default)
+	MaxUploadRequestSize int64 = 10 * 1024 * 1024
+)
 
-// MaxImageSize is the maximum allowed image size in bytes (5MB)
-const MaxImageSize = 5 * 1024 * 1024
+// ConfigureUploads sets where uploaded files are stored and how large they may be
+func ConfigureUploads(store storage.Storage, maxImageSize, maxRequestSize int64) {
+	UploadStorage = store
+	MaxImageSize = maxImageSize
+	MaxUploadRequestSize = maxRequestSize
+}
 
-// SaveImage saves an uploaded image to the uploads directory
+// SaveImage checks that an uploaded file is an image, strips its metadata and
+// stores it in each of its size variants. It returns the path of the full
+// variant, under /uploads/; the others are found from it with NewImageSet.
 func SaveImage(file multipart.File, header *multipart.FileHeader, directory string) (string, error) {
 	// Check file size
 	if header.Size > MaxImageSize {
-		return "", errors.New("file size exceeds the limit")
+		return "", imaging.SizeError(MaxImageSize, header.Size)
 	}
 
-	// Check file type
-	contentType := header.Header.Get("Content-Type")
-	extension, ok := allowedImageTypes[contentType]
-	if !ok {
-		return "", errors.New("invalid file type, only JPEG, PNG, and GIF are allowed")
+	// The header size comes from the client, so don't read past the limit either
+	data, err := io.ReadAll(io.LimitReader(file, MaxImageSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Create uploads directory if it doesn't exist
-	uploadDir := filepath.Join("uploads", directory)
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	return SaveImageData(data, directory)
+}
+
+// SaveImageData does what SaveImage does for an image that didn't come from
+// a form
+func SaveImageData(data []byte, directory string) (string, error) {
+	if int64(len(data)) > MaxImageSize {
+		return "", imaging.SizeError(MaxImageSize, int64(len(data)))
 	}
 
-	// Generate a unique filename
-	filename := uuid.New().String() + extension
-	filePath := filepath.Join(uploadDir, filename)
+	// Decode the image, check it against the limits for its format and
+	// re-encode it in each variant
+	result, err := imaging.Process(data)
+	if err != nil {
+		return "", err
+	}
+	return storeImage(result, directory)
+}
 
-	// Create the file
-	dst, err := os.Create(filePath)
+// SavePreviewImage saves an image fetched from another site, such as a
+// link's preview image. It is held to the stricter imaging.PreviewLimits and
+// only the first frame of an animation is kept.
+func SavePreviewImage(data []byte, directory string) (string, error) {
+	result, err := imaging.ProcessPreview(data)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
+		return "", err
 	}
-	defer dst.Close()
+	return storeImage(result, directory)
+}
+
+// storeImage stores the variants of a processed image, returning the path of
+// its full variant
+func storeImage(result *imaging.Result, directory string) (string, error) {
+	// Generate a unique filename that records the image's dimensions
+	base := fmt.Sprintf("%s_%dx%d", uuid.New().String(), result.Width, result.Height)
 
-	// Copy the file content
-	if _, err := io.Copy(dst, file); err != nil {
-		return "", fmt.Errorf("failed to save file: %w", err)
+	var written []string
+	for _, output := range result.Outputs {
+		key := path.Join(directory, imageVariantName(base, output.Variant, output.Extension))
+		if err := UploadStorage.Put(key, bytes.NewReader(output.Data), int64(len(output.Data)), storage.ContentType(key)); err != nil {
+			for _, key := range written {
+				UploadStorage.Delete(key)
+			}
+			return "", fmt.Errorf("failed to save file: %w", err)
+		}
+		written = append(written, key)
 	}
 
-	// Return the relative path to the file
-	return filepath.Join("/uploads", directory, filename), nil
+	// Return the relative path to the full variant
+	return path.Join("/uploads", directory, base+result.Extension), nil
 }
 
-// DeleteImage deletes an image file
-func DeleteImage(imagePath string) error {
+// imageVariantName returns the file name of one variant of an image
+func imageVariantName(base string, variant imaging.Variant, extension string) string {
+	if variant == imaging.Full {
+		return base + extension
+	}
+	return base + "_" + variant.Name + extension
+}
+
+// ImageVariant is one size of an uploaded image
+type ImageVariant struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ImageSet describes the sizes an uploaded image can be fetched in, with a
+// srcset clients can hand straight to an <img> tag. Animations are only
+// served at full size; their other variants, including a full size "still",
+// show the first frame for previews that shouldn't move.
+type ImageSet struct {
+	Src      string                  `json:"src"`
+	Width    int                     `json:"width,omitempty"`
+	Height   int                     `json:"height,omitempty"`
+	Animated bool                    `json:"animated,omitempty"`
+	Srcset   string                  `json:"srcset,omitempty"`
+	Variants map[string]ImageVariant `json:"variants,omitempty"`
+}
+
+// NewImageSet describes the variants of an image saved by SaveImage, with
+// the URLs the upload store serves them from. Images uploaded before
+// variants were stored only have a src. It returns nil for an empty path.
+func NewImageSet(imagePath string) *ImageSet {
+	return newImageSet(imagePath, UploadURL)
+}
+
+// newImageSet describes the variants of an image, mapping the path of each
+// onto its URL with url
+func newImageSet(imagePath string, url func(string) string) *ImageSet {
+	if imagePath == "" {
+		return nil
+	}
+
+	set := &ImageSet{Src: url(imagePath)}
+	dir, name := path.Split(imagePath)
+	match := imageNamePattern.FindStringSubmatch(name)
+	if match == nil || match[4] != "" {
+		return set
+	}
+
+	base := match[1] + "_" + match[2] + "x" + match[3]
+	set.Width, _ = strconv.Atoi(match[2])
+	set.Height, _ = strconv.Atoi(match[3])
+	set.Animated = imaging.AnimatedExtensions[match[5]]
+	set.Variants = make(map[string]ImageVariant, len(imaging.Variants)+1)
+
+	variants := imaging.Variants
+	if set.Animated {
+		variants = append(append([]imaging.Variant{}, variants...), imaging.Still)
+	}
+
+	var srcset []string
+	for _, variant := range variants {
+		if !imaging.HasVariant(set.Width, set.Height, variant) {
+			continue
+		}
+
+		// Animations are stored as they are; every other variant of one is a still
+		width, height := set.Width, set.Height
+		extension := match[5]
+		if !set.Animated || variant != imaging.Full {
+			width, height = imaging.Fit(set.Width, set.Height, variant.MaxSize)
+		}
+		if set.Animated && variant != imaging.Full {
+			extension = imaging.StillExtension
+		}
+
+		variantURL := url(dir + imageVariantName(base, variant, extension))
+		set.Variants[variant.Name] = ImageVariant{URL: variantURL, Width: width, Height: height}
+		if !set.Animated || variant == imaging.Full {
+			srcset = append(srcset, fmt.Sprintf("%s %dw", variantURL, width))
+		}
+	}
+	set.Srcset = strings.Join(srcset, ", ")
+
+	return set
+}
+
+// imageFiles returns the paths of every variant stored for an image
+func imageFiles(imagePath string) []string {
+	set := newImageSet(imagePath, func(variantPath string) string { return variantPath })
+	if set == nil || len(set.Variants) == 0 {
+		return []string{imagePath}
+	}
+
+	files := make([]string, 0, len(set.Variants))
+	for _, variant := range set.Variants {
+		files = append(files, variant.URL)
+	}
+	return files
+}
+
+// ImageBase identifies the image a stored file is a variant of: the path
+// SaveImage returned for it without the extension, which variants can change
+func ImageBase(imagePath string) string {
+	dir, name := path.Split(imagePath)
+	match := imageNamePattern.FindStringSubmatch(name)
+	if match == nil {
+		return imagePath
+	}
+	return dir + match[1] + "_" + match[2] + "x" + match[3]
+}
+
+// ImageKey maps an image path returned by SaveImage onto the key it is
+// stored under, refusing paths that lead outside the upload store
+func ImageKey(imagePath string) (string, error) {
 	// Ensure the path is within the uploads directory
 	if !strings.HasPrefix(imagePath, "/uploads/") {
-		return errors.New("invalid image path")
+		return "", errors.New("invalid image path")
 	}
 
-	// Remove the leading slash
-	localPath := imagePath[1:]
+	key := strings.TrimPrefix(imagePath, "/uploads/")
+	if !storage.ValidKey(key) {
+		return "", errors.New("invalid image path")
+	}
+
+	return key, nil
+}
+
+// UploadURL returns the URL clients fetch an uploaded file from. Files the
+// backend serves get a signed URL, so they can be fetched without credentials.
+func UploadURL(imagePath string) string {
+	key, err := ImageKey(imagePath)
+	if err != nil {
+		return imagePath
+	}
+
+	url := UploadStorage.URL(key)
+	if strings.HasPrefix(url, "/uploads/") {
+		return SignUploadURL(url, key)
+	}
+	return url
+}
+
+// DeleteImage deletes an image file along with its other size variants
+func DeleteImage(imagePath string) error {
+	if _, err := ImageKey(imagePath); err != nil {
+		return err
+	}
 
-	// Check if the file exists
-	if _, err := os.Stat(localPath); os.IsNotExist(err) {
-		return nil // File doesn't exist, nothing to delete
+	for _, file := range imageFiles(imagePath) {
+		if err := deleteFile(file); err != nil {
+			return err
+		}
 	}
 
-	// Delete the file
-	if err := os.Remove(localPath); err != nil {
+	return nil
+}
+
+// deleteFile deletes a single stored file
+func deleteFile(imagePath string) error {
+	key, err := ImageKey(imagePath)
+	if err != nil {
+		return err
+	}
+
+	if err := UploadStorage.Delete(key); err != nil {
 		return fmt.Errorf("failed to delete image: %w", err)
 	}
 
@@ -88,8 +284,7 @@ func DeleteImage(imagePath string) error {
 
 // ValidateImageType validates the content type of an image
 func ValidateImageType(contentType string) bool {
-	_, ok := allowedImageTypes[contentType]
-	return ok
+	return imaging.IsSupported(contentType)
 }
 
 // DetectContentType detects the content type of a file
@@ -110,3 +305,114 @@ func DetectContentType(file multipart.File) (string, error) {
 	contentType := http.DetectContentType(buffer)
 	return contentType, nil
 }
+
+// RemoveOrphanedImages deletes uploaded images whose path is not in referenced.
+// Files modified after cutoff are kept, so an image saved just before the row
+// pointing at it is written survives. It returns how many files were removed.
+func RemoveOrphanedImages(referenced map[string]bool, cutoff time.Time) (int, error) {
+	// Variants belong to the image whose path is stored
+	keys := make(map[string]bool, len(referenced))
+	for imagePath := range referenced {
+		keys[ImageBase(imagePath)] = true
+	}
+
+	removed := 0
+	for _, directory := range imageDirectories {
+		// Collect the orphans first rather than deleting from the store
+		// while it is being listed
+		var orphans []string
+		err := UploadStorage.List(directory+"/", func(object storage.Object) error {
+			if strings.Contains(strings.TrimPrefix(object.Key, directory+"/"), "/") {
+				return nil
+			}
+			imagePath := "/uploads/" + object.Key
+			if !keys[ImageBase(imagePath)] && !object.ModTime.After(cutoff) {
+				orphans = append(orphans, imagePath)
+			}
+			return nil
+		})
+		if err != nil {
+			return removed, fmt.Errorf("failed to list %s uploads: %w", directory, err)
+		}
+
+		for _, imagePath := range orphans {
+			if err := deleteFile(imagePath); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// ServeUpload sends the uploaded file stored under key. Uploads are never
+// changed once stored, so clients may cache them for maxAge; they are only
+// cached privately, as not everyone may see them.
+func ServeUpload(w http.ResponseWriter, r *http.Request, key string, maxAge time.Duration) {
+	reader, object, err := UploadStorage.Get(key)
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Failed to read upload %s: %v", key, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to read file")
+			return
+		}
+		RespondWithError(w, http.StatusNotFound, "File not found")
+		return
+	}
+	defer reader.Close()
+
+	w.Header().Set("Content-Type", object.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, object.ModTime.UnixNano(), object.Size))
+
+	// Local files can be seeked, so ranges and conditional requests work
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, key, object.ModTime, seeker)
+		return
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), w.Header().Get("ETag")) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if !object.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", object.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if object.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	io.Copy(w, reader)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// RespondWithImageError sends the response for an error from SaveImage: a 400
+// saying exactly why the image was refused, or a 500 if it couldn't be stored
+func RespondWithImageError(w http.ResponseWriter, err error) {
+	var validationErr *imaging.ValidationError
+	if errors.As(err, &validationErr) {
+		RespondWithJSON(w, http.StatusBadRequest, Response{
+			Success: false,
+			Error:   validationErr.Error(),
+			Details: validationErr,
+		})
+		return
+	}
+
+	log.Printf("Failed to save image: %v", err)
+	RespondWithError(w, http.StatusInternalServerError, "Failed to save image")
+}
@@ -4,22 +4,29 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
 var logFile *os.File
 
-// setupLogFile ensures the logs directory exists and returns a file for logging.
-func SetupLogFile() (*os.File, error) {
+// StderrLogDestination sends application logs to standard error instead of a file
+const StderrLogDestination = "stderr"
+
+// SetupLogFile ensures the directory of the log file at path exists and returns
+// the file for logging. The StderrLogDestination path logs to standard error.
+func SetupLogFile(path string) (*os.File, error) {
+	if path == StderrLogDestination {
+		logFile = os.Stderr
+		return logFile, nil
+	}
+
 	// Ensure the logs directory exists
-	if _, statErr := os.Stat("logs"); os.IsNotExist(statErr) {
-		mkdirErr := os.Mkdir("logs", 0755)
-		if mkdirErr != nil {
-			return nil, fmt.Errorf("error creating logs directory: %w", mkdirErr)
-		}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating logs directory: %w", err)
 	}
 
-	file, fileErr := os.OpenFile("logs/application.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	file, fileErr := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if fileErr != nil {
 		return nil, fmt.Errorf("error opening log file: %w", fileErr)
 	}
@@ -2,15 +2,20 @@ package utils
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Response represents a standard API response
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	NextCursor string      `json:"nextCursor,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Details    interface{} `json:"details,omitempty"` // Structured detail about the error
 }
 
 // RespondWithJSON sends a JSON response
@@ -43,3 +48,25 @@ func RespondWithSuccess(w http.ResponseWriter, statusCode int, message string, d
 		Data:    data,
 	})
 }
+
+// RespondWithPage sends a success response for one page of a list, with the
+// cursor for the next page (empty on the last page)
+func RespondWithPage(w http.ResponseWriter, statusCode int, message string, data interface{}, nextCursor string) {
+	RespondWithJSON(w, statusCode, Response{
+		Success:    true,
+		Message:    message,
+		Data:       data,
+		NextCursor: nextCursor,
+	})
+}
+
+// RespondWithRateLimit sends a 429 error response with a Retry-After header
+// telling the client how many seconds to wait before trying again
+func RespondWithRateLimit(w http.ResponseWriter, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	RespondWithError(w, http.StatusTooManyRequests, message)
+}
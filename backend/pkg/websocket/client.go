@@ -21,6 +21,13 @@ const (
 
 	// Maximum message size allowed from peer
 	maxMessageSize = 10000
+
+	// Number of missed messages sent per resume_messages frame
+	resumeBatchSize = 100
+
+	// Maximum number of missed messages sent for one resume request; clients
+	// resume again from the last message when hasMore is set
+	maxResumeMessages = 500
 )
 
 // Message represents a WebSocket message
@@ -33,15 +40,33 @@ type Message struct {
 
 // DBMessage represents a message for database storage
 type DBMessage struct {
-	SenderID   string
-	ReceiverID string
-	GroupID    string
-	Content    string
+	SenderID        string
+	ReceiverID      string
+	GroupID         string
+	Content         string
+	ClientMessageID string
+
+	// Set by MessageService.Create
+	ID             string
+	CreatedAt      time.Time
+	Duplicate      bool
+	ProfilePicture string
+	Entities       interface{} // The mentions and hashtags in Content
+	LinkPreviews   interface{} // Previews of the links in Content
 }
 
-// MessageService interface for saving messages
+// MessageService interface for saving and changing messages. Create checks
+// that the sender may post to the conversation and fills in the persisted ID;
+// a retry with a known ClientMessageID is flagged as Duplicate instead of
+// being stored twice. The read, edit, delete and reaction methods check
+// permissions and broadcast the change to the message's room themselves.
 type MessageService interface {
 	Create(message *DBMessage) error
+	MessagesSince(userID, messageID string, limit int) (messages []interface{}, lastMessageID string, err error)
+	MarkRead(userID, receiverID, groupID, upToMessageID string) error
+	Edit(userID, messageID, content string) error
+	Delete(userID, messageID string) error
+	React(userID, messageID, emoji string, add bool) error
 }
 
 // UserInfo represents basic user information for WebSocket clients
@@ -57,18 +82,23 @@ type Client struct {
 	Conn           *websocket.Conn
 	Send           chan []byte
 	UserID         string
+	SessionID      string
 	UserInfo       *UserInfo
 	Rooms          map[string]bool
 	MessageService MessageService
+
+	// closed is set by the hub once Send has been closed
+	closed bool
 }
 
 // NewClient creates a new WebSocket client
-func NewClient(hub *Hub, conn *websocket.Conn, userID string, userInfo *UserInfo, messageService MessageService) *Client {
+func NewClient(hub *Hub, conn *websocket.Conn, userID, sessionID string, userInfo *UserInfo, messageService MessageService) *Client {
 	return &Client{
 		Hub:            hub,
 		Conn:           conn,
 		Send:           make(chan []byte, 256),
 		UserID:         userID,
+		SessionID:      sessionID,
 		UserInfo:       userInfo,
 		Rooms:          make(map[string]bool),
 		MessageService: messageService,
@@ -169,6 +199,9 @@ func (c *Client) ReadPump() {
 					continue
 				}
 
+				// The client's idempotency key, echoed back in acks so it can match them up
+				clientMessageID, _ := messageContent["clientMessageId"].(string)
+
 				// Extract content from the message
 				var content string
 				if contentStr, ok := messageContent["content"].(string); ok {
@@ -179,48 +212,43 @@ func (c *Client) ReadPump() {
 						content = contentStr
 					} else {
 						log.Printf("nested message content is not a string")
+						c.sendMessageAck(msg.RoomID, clientMessageID, nil, "message content is not a string")
 						continue
 					}
 				} else {
 					log.Printf("message content is not a string or object")
+					c.sendMessageAck(msg.RoomID, clientMessageID, nil, "message content is not a string")
 					continue
 				}
 
 				// Parse room ID to determine if it's a direct message or group message
-				roomParts := strings.Split(msg.RoomID, "-")
-				var dbMessage *DBMessage
-
-				if len(roomParts) == 2 && roomParts[0] != "group" {
-					// Direct message format: "userId1-userId2"
-					var receiverID string
-					if roomParts[0] == c.UserID {
-						receiverID = roomParts[1]
-					} else {
-						receiverID = roomParts[0]
-					}
-
-					dbMessage = &DBMessage{
-						SenderID:   c.UserID,
-						ReceiverID: receiverID,
-						Content:    content,
-					}
-				} else if len(roomParts) == 2 && roomParts[0] == "group" {
-					// Group message format: "group-groupId"
-					groupID := roomParts[1]
-					dbMessage = &DBMessage{
-						SenderID: c.UserID,
-						GroupID:  groupID,
-						Content:  content,
-					}
-				} else {
+				receiverID, groupID, ok := parseRoomID(msg.RoomID, c.UserID)
+				if !ok {
 					log.Printf("invalid room ID format: %s", msg.RoomID)
+					c.sendMessageAck(msg.RoomID, clientMessageID, nil, "invalid room ID")
 					continue
 				}
+				dbMessage := &DBMessage{
+					SenderID:        c.UserID,
+					ReceiverID:      receiverID,
+					GroupID:         groupID,
+					Content:         content,
+					ClientMessageID: clientMessageID,
+				}
 
-				// Save message to database
+				// Save message to database; nothing is broadcast unless it was stored
 				if err := c.MessageService.Create(dbMessage); err != nil {
 					log.Printf("error saving message to database: %v", err)
-					// Continue with broadcast even if database save fails
+					c.sendMessageAck(msg.RoomID, clientMessageID, nil, err.Error())
+					continue
+				}
+
+				// Acknowledge with the persisted ID so the client can swap out its pending copy
+				c.sendMessageAck(msg.RoomID, clientMessageID, dbMessage, "")
+
+				// A retry of a message we already stored was broadcast the first time round
+				if dbMessage.Duplicate {
+					continue
 				}
 
 				// Create response message for broadcast
@@ -231,16 +259,19 @@ func (c *Client) ReadPump() {
 					responseMsg = map[string]interface{}{
 						"roomId": msg.RoomID,
 						"message": map[string]interface{}{
-							"id":        "ws-" + time.Now().Format("20060102150405") + "-" + c.UserID, // Temporary ID for WebSocket messages
-							"content":   content,
-							"sender":    c.UserID,
-							"groupId":   dbMessage.GroupID,
-							"timestamp": time.Now().Format(time.RFC3339),
+							"id":              dbMessage.ID,
+							"clientMessageId": clientMessageID,
+							"content":         content,
+							"sender":          c.UserID,
+							"groupId":         dbMessage.GroupID,
+							"timestamp":       dbMessage.CreatedAt.Format(time.RFC3339),
+							"entities":        dbMessage.Entities,
+							"linkPreviews":    dbMessage.LinkPreviews,
 							"senderInfo": map[string]interface{}{
 								"id":             c.UserID,
 								"username":       c.UserInfo.Username,
 								"fullName":       c.UserInfo.FullName,
-								"profilePicture": "", // Profile picture not available in WebSocket context
+								"profilePicture": dbMessage.ProfilePicture,
 							},
 						},
 					}
@@ -249,9 +280,13 @@ func (c *Client) ReadPump() {
 					responseMsg = map[string]interface{}{
 						"roomId": msg.RoomID,
 						"message": map[string]interface{}{
-							"content":   content,
-							"sender":    c.UserID,
-							"timestamp": time.Now().Format(time.RFC3339),
+							"id":              dbMessage.ID,
+							"clientMessageId": clientMessageID,
+							"content":         content,
+							"sender":          c.UserID,
+							"timestamp":       dbMessage.CreatedAt.Format(time.RFC3339),
+							"entities":        dbMessage.Entities,
+							"linkPreviews":    dbMessage.LinkPreviews,
 						},
 					}
 				}
@@ -266,21 +301,78 @@ func (c *Client) ReadPump() {
 					continue
 				}
 
-				// Validate the JSON is properly formatted
-				var testParse interface{}
-				if err := json.Unmarshal(data, &testParse); err != nil {
-					log.Printf("error validating marshaled JSON: %v, data: %s", err, string(data))
-					continue
-				}
-
-				// Broadcast to the room
+				// Broadcast to the room, telling the sender once someone else has it
 				log.Printf("Broadcasting message to room %s: %s", msg.RoomID, string(data))
+				roomID, messageID := msg.RoomID, dbMessage.ID
 				c.Hub.Broadcast <- &Broadcast{
 					RoomID:  msg.RoomID,
 					Message: data,
 					Sender:  c,
+					OnDelivered: func(userIDs []string) {
+						c.sendMessageDelivered(roomID, messageID, clientMessageID, userIDs)
+					},
 				}
 			}
+		case "read_receipt":
+			// Mark the room read up to a message (or entirely, without upToMessageId)
+			if msg.RoomID == "" || c.MessageService == nil {
+				continue
+			}
+			var upToMessageID string
+			if contentMap, ok := msg.Content.(map[string]interface{}); ok {
+				upToMessageID, _ = contentMap["upToMessageId"].(string)
+			}
+			receiverID, groupID, ok := parseRoomID(msg.RoomID, c.UserID)
+			if !ok {
+				c.sendMessageError(msg.Type, upToMessageID, "invalid room ID")
+				continue
+			}
+			if err := c.MessageService.MarkRead(c.UserID, receiverID, groupID, upToMessageID); err != nil {
+				log.Printf("error marking room %s read for user %s: %v", msg.RoomID, c.UserID, err)
+				c.sendMessageError(msg.Type, upToMessageID, err.Error())
+			}
+		case "resume":
+			// Replay everything sent since the last message the client saw
+			var lastMessageID string
+			if contentMap, ok := msg.Content.(map[string]interface{}); ok {
+				lastMessageID, _ = contentMap["lastMessageId"].(string)
+			}
+			if lastMessageID == "" || c.MessageService == nil {
+				c.sendMessageError(msg.Type, "", "lastMessageId is required")
+				continue
+			}
+			c.resume(lastMessageID)
+		case "edit_message", "delete_message", "add_reaction", "remove_reaction":
+			// Handle changes to an existing message
+			if c.MessageService == nil {
+				continue
+			}
+			contentMap, ok := msg.Content.(map[string]interface{})
+			if !ok {
+				c.sendMessageError(msg.Type, "", "invalid message content format")
+				continue
+			}
+			messageID, _ := contentMap["messageId"].(string)
+			if messageID == "" {
+				c.sendMessageError(msg.Type, "", "messageId is required")
+				continue
+			}
+
+			var err error
+			switch msg.Type {
+			case "edit_message":
+				content, _ := contentMap["content"].(string)
+				err = c.MessageService.Edit(c.UserID, messageID, content)
+			case "delete_message":
+				err = c.MessageService.Delete(c.UserID, messageID)
+			case "add_reaction", "remove_reaction":
+				emoji, _ := contentMap["emoji"].(string)
+				err = c.MessageService.React(c.UserID, messageID, emoji, msg.Type == "add_reaction")
+			}
+			if err != nil {
+				log.Printf("error handling %s for message %s: %v", msg.Type, messageID, err)
+				c.sendMessageError(msg.Type, messageID, err.Error())
+			}
 		case "typing_status":
 			// Handle typing status
 			if msg.RoomID != "" {
@@ -346,6 +438,153 @@ func (c *Client) WritePump() {
 	}
 }
 
+// parseRoomID splits a chat room ID into the other participant of a direct
+// conversation ("userId1-userId2") or the group of a group chat ("group-groupId").
+// IDs contain dashes themselves, so direct rooms are matched against the
+// client's own user ID, which also rejects rooms the user isn't part of.
+func parseRoomID(roomID, userID string) (receiverID, groupID string, ok bool) {
+	if strings.HasPrefix(roomID, "group-") {
+		groupID = strings.TrimPrefix(roomID, "group-")
+		return "", groupID, groupID != ""
+	}
+	if otherID := strings.TrimPrefix(roomID, userID+"-"); otherID != roomID && otherID != "" {
+		return otherID, "", true
+	}
+	if otherID := strings.TrimSuffix(roomID, "-"+userID); otherID != roomID && otherID != "" {
+		return otherID, "", true
+	}
+	return "", "", false
+}
+
+// resume sends the client, in order, the messages it missed after lastMessageID,
+// followed by a resume_complete frame
+func (c *Client) resume(lastMessageID string) {
+	sent := 0
+	hasMore := false
+	cursor := lastMessageID
+	for sent < maxResumeMessages {
+		messages, lastID, err := c.MessageService.MessagesSince(c.UserID, cursor, resumeBatchSize)
+		if err != nil {
+			log.Printf("error getting messages since %s for user %s: %v", cursor, c.UserID, err)
+			c.sendMessageError("resume", cursor, err.Error())
+			return
+		}
+
+		// A full batch may be followed by more
+		hasMore = len(messages) == resumeBatchSize
+		if len(messages) == 0 {
+			break
+		}
+
+		data, err := json.Marshal(map[string]interface{}{
+			"type": "resume_messages",
+			"payload": map[string]interface{}{
+				"messages": messages,
+			},
+		})
+		if err != nil {
+			log.Printf("error marshaling resume messages: %v", err)
+			return
+		}
+		if !c.Hub.trySend(c, data) {
+			return
+		}
+
+		sent += len(messages)
+		cursor = lastID
+		if !hasMore {
+			break
+		}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type": "resume_complete",
+		"payload": map[string]interface{}{
+			"lastMessageId": cursor,
+			"count":         sent,
+			"hasMore":       hasMore,
+		},
+	})
+	if err != nil {
+		log.Printf("error marshaling resume completion: %v", err)
+		return
+	}
+	c.Hub.trySend(c, data)
+}
+
+// sendMessageAck tells this client whether a chat_message it sent was stored.
+// message is nil when it wasn't, in which case reason says why.
+func (c *Client) sendMessageAck(roomID, clientMessageID string, message *DBMessage, reason string) {
+	payload := map[string]interface{}{
+		"roomId":          roomID,
+		"clientMessageId": clientMessageID,
+	}
+	if message != nil {
+		payload["status"] = "sent"
+		payload["messageId"] = message.ID
+		payload["createdAt"] = message.CreatedAt.Format(time.RFC3339Nano)
+		payload["duplicate"] = message.Duplicate
+	} else {
+		payload["status"] = "failed"
+		payload["error"] = reason
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type":    "message_ack",
+		"payload": payload,
+	})
+	if err != nil {
+		log.Printf("error marshaling message ack: %v", err)
+		return
+	}
+	c.Hub.trySend(c, data)
+}
+
+// sendMessageDelivered tells this client which other users received one of its messages
+func (c *Client) sendMessageDelivered(roomID, messageID, clientMessageID string, userIDs []string) {
+	recipients := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if userID != c.UserID {
+			recipients = append(recipients, userID)
+		}
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"type": "message_delivered",
+		"payload": map[string]interface{}{
+			"roomId":          roomID,
+			"messageId":       messageID,
+			"clientMessageId": clientMessageID,
+			"deliveredTo":     recipients,
+		},
+	})
+	if err != nil {
+		log.Printf("error marshaling delivery receipt: %v", err)
+		return
+	}
+	c.Hub.trySend(c, data)
+}
+
+// sendMessageError tells this client that one of its message requests failed
+func (c *Client) sendMessageError(requestType, messageID, reason string) {
+	data, err := json.Marshal(map[string]interface{}{
+		"type": "message_error",
+		"payload": map[string]interface{}{
+			"requestType": requestType,
+			"messageId":   messageID,
+			"error":       reason,
+		},
+	})
+	if err != nil {
+		log.Printf("error marshaling message error: %v", err)
+		return
+	}
+	c.Hub.trySend(c, data)
+}
+
 // JoinRoom adds the client to a room
 func (c *Client) JoinRoom(roomID string) {
 	log.Printf("Client %s joining room %s", c.UserID, roomID)
@@ -3,6 +3,7 @@ package websocket
 import (
 	"encoding/json"
 	"log"
+	"sync"
 )
 
 // Hub maintains the set of active clients and broadcasts messages to them
@@ -10,8 +11,11 @@ type Hub struct {
 	// Registered clients by room
 	Rooms map[string]map[*Client]bool
 
-	// Online users by user ID
-	OnlineUsers map[string]*Client
+	// Online users by user ID, with one client per connected device
+	OnlineUsers map[string]map[*Client]bool
+
+	// mu guards OnlineUsers and client send channels, which HTTP handlers touch outside Run
+	mu sync.RWMutex
 
 	// Inbound messages to broadcast
 	Broadcast chan *Broadcast
@@ -31,6 +35,10 @@ type Broadcast struct {
 	RoomID  string
 	Message []byte
 	Sender  *Client
+
+	// OnDelivered, if set, is called from the hub with the IDs of the users
+	// whose clients the message was handed to
+	OnDelivered func(userIDs []string)
 }
 
 // Registration represents a client registration to a room
@@ -49,7 +57,7 @@ type Unregistration struct {
 func NewHub() *Hub {
 	return &Hub{
 		Rooms:              make(map[string]map[*Client]bool),
-		OnlineUsers:        make(map[string]*Client),
+		OnlineUsers:        make(map[string]map[*Client]bool),
 		Broadcast:          make(chan *Broadcast),
 		Register:           make(chan *Registration),
 		Unregister:         make(chan *Client),
@@ -69,14 +77,14 @@ func (h *Hub) Run() {
 			// Add the client to the room
 			h.Rooms[registration.RoomID][registration.Client] = true
 
-			// Track user as online
-			h.OnlineUsers[registration.Client.UserID] = registration.Client
-			h.broadcastUserPresence(registration.Client.UserID, "online")
+			// Track the device as online, announcing the user only when their first device connects
+			if h.addOnlineClient(registration.Client) {
+				h.broadcastUserPresence(registration.Client.UserID, "online")
+			}
 
 		case client := <-h.Unregister:
-			// Remove user from online users
-			if _, ok := h.OnlineUsers[client.UserID]; ok {
-				delete(h.OnlineUsers, client.UserID)
+			// Remove the device from online users, announcing the user offline once no devices remain
+			if h.removeOnlineClient(client) {
 				h.broadcastUserPresence(client.UserID, "offline")
 			}
 
@@ -91,12 +99,7 @@ func (h *Hub) Run() {
 				}
 			}
 			// Close the client's send channel safely
-			select {
-			case <-client.Send:
-				// Channel is already closed
-			default:
-				close(client.Send)
-			}
+			h.closeClient(client)
 
 		case unregistration := <-h.UnregisterFromRoom:
 			// Remove the client from the specific room only
@@ -124,6 +127,7 @@ func (h *Hub) Run() {
 
 			log.Printf("Broadcasting to room %s with %d clients", broadcast.RoomID, len(room))
 			// Broadcast the message to all clients in the room
+			delivered := make(map[string]bool)
 			for client := range room {
 				// Don't send the message back to the sender (unless sender is nil, meaning it's from HTTP API)
 				if broadcast.Sender != nil && client == broadcast.Sender {
@@ -132,17 +136,12 @@ func (h *Hub) Run() {
 				}
 
 				log.Printf("Sending message to client %s in room %s", client.UserID, broadcast.RoomID)
-				select {
-				case client.Send <- broadcast.Message:
+				if h.trySend(client, broadcast.Message) {
 					log.Printf("Message sent successfully to client %s", client.UserID)
-				default:
+					delivered[client.UserID] = true
+				} else {
 					// Client's send buffer is full, remove them
-					select {
-					case <-client.Send:
-						// Channel is already closed
-					default:
-						close(client.Send)
-					}
+					h.closeClient(client)
 					delete(room, client)
 					// Delete the room if it's empty
 					if len(room) == 0 {
@@ -150,6 +149,14 @@ func (h *Hub) Run() {
 					}
 				}
 			}
+
+			if broadcast.OnDelivered != nil {
+				userIDs := make([]string, 0, len(delivered))
+				for userID := range delivered {
+					userIDs = append(userIDs, userID)
+				}
+				broadcast.OnDelivered(userIDs)
+			}
 		}
 	}
 }
@@ -175,16 +182,9 @@ func (h *Hub) broadcastUserPresence(userID, status string) {
 	// Broadcast to all users in the default room (all connected users)
 	if room, ok := h.Rooms[""]; ok {
 		for client := range room {
-			select {
-			case client.Send <- data:
-			default:
+			if !h.trySend(client, data) {
 				// Client's send buffer is full, remove them
-				select {
-				case <-client.Send:
-					// Channel is already closed
-				default:
-					close(client.Send)
-				}
+				h.closeClient(client)
 				delete(room, client)
 			}
 		}
@@ -195,11 +195,11 @@ func (h *Hub) broadcastUserPresence(userID, status string) {
 func (h *Hub) broadcastTypingStatus(roomID, userID string, isTyping bool) {
 	// Find the user's client to get user information
 	var userInfo map[string]interface{}
-	if client, exists := h.OnlineUsers[userID]; exists && client.UserInfo != nil {
+	if info := h.onlineUserInfo(userID); info != nil {
 		userInfo = map[string]interface{}{
-			"id":       client.UserInfo.ID,
-			"username": client.UserInfo.Username,
-			"fullName": client.UserInfo.FullName,
+			"id":       info.ID,
+			"username": info.Username,
+			"fullName": info.FullName,
 		}
 	} else {
 		// Fallback if user info is not available
@@ -233,16 +233,9 @@ func (h *Hub) broadcastTypingStatus(roomID, userID string, isTyping bool) {
 		for client := range room {
 			// Don't send typing status back to the user who is typing
 			if client.UserID != userID {
-				select {
-				case client.Send <- data:
-				default:
+				if !h.trySend(client, data) {
 					// Client's send buffer is full, remove them
-					select {
-					case <-client.Send:
-						// Channel is already closed
-					default:
-						close(client.Send)
-					}
+					h.closeClient(client)
 					delete(room, client)
 				}
 			}
@@ -250,15 +243,16 @@ func (h *Hub) broadcastTypingStatus(roomID, userID string, isTyping bool) {
 	}
 }
 
-// BroadcastSessionInvalidation notifies a user that their session has been invalidated
-func (h *Hub) BroadcastSessionInvalidation(userID string) {
-	log.Printf("BroadcastSessionInvalidation called for user: %s", userID)
+// BroadcastSessionInvalidation notifies the devices bound to a session that it has ended and
+// disconnects them. An empty sessionID targets every device the user has connected.
+func (h *Hub) BroadcastSessionInvalidation(userID, sessionID, reason string) {
+	log.Printf("BroadcastSessionInvalidation called for user: %s, session: %q, reason: %s", userID, sessionID, reason)
 
 	message := map[string]interface{}{
 		"type": "session_invalidated",
 		"payload": map[string]interface{}{
-			"message": "Your session has been invalidated due to a new login from another device",
-			"reason":  "new_login",
+			"message": "Your session on this device has been signed out",
+			"reason":  reason,
 		},
 	}
 
@@ -268,22 +262,137 @@ func (h *Hub) BroadcastSessionInvalidation(userID string) {
 		return
 	}
 
-	log.Printf("Looking for online user %s in %d online users", userID, len(h.OnlineUsers))
+	h.mu.RLock()
+	var targets []*Client
+	for client := range h.OnlineUsers[userID] {
+		if sessionID == "" || client.SessionID == sessionID {
+			targets = append(targets, client)
+		}
+	}
+	h.mu.RUnlock()
 
-	// Send to the specific user if they're online
-	if client, ok := h.OnlineUsers[userID]; ok {
-		log.Printf("Found online user %s, attempting to send session invalidation message", userID)
-		select {
-		case client.Send <- data:
-			log.Printf("✅ Session invalidation message successfully sent to user %s", userID)
-		default:
-			log.Printf("❌ Failed to send session invalidation message to user %s - channel full or blocked", userID)
+	if len(targets) == 0 {
+		log.Printf("User %s has no connected devices for session %q", userID, sessionID)
+		return
+	}
+
+	for _, client := range targets {
+		if !h.trySend(client, data) {
+			log.Printf("Failed to send session invalidation message to user %s - channel full or closed", userID)
 		}
-	} else {
-		log.Printf("⚠️  User %s not found in online users, cannot send session invalidation message", userID)
-		// Log all online users for debugging
-		for onlineUserID := range h.OnlineUsers {
-			log.Printf("   - Online user: %s", onlineUserID)
+		// Unregistering closes the send channel, so the write pump flushes the
+		// invalidation message and then closes the connection
+		h.Unregister <- client
+	}
+}
+
+// IsUserOnline reports whether the user has at least one connected device
+func (h *Hub) IsUserOnline(userID string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.OnlineUsers[userID]) > 0
+}
+
+// OnlineUserIDs returns the IDs of all users with at least one connected device
+func (h *Hub) OnlineUserIDs() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	userIDs := make([]string, 0, len(h.OnlineUsers))
+	for userID := range h.OnlineUsers {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs
+}
+
+// SendToUser queues a message on every connected device of a user and returns how many received it
+func (h *Hub) SendToUser(userID string, data []byte) int {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.OnlineUsers[userID]))
+	for client := range h.OnlineUsers[userID] {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	delivered := 0
+	for _, client := range clients {
+		if h.trySend(client, data) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// addOnlineClient tracks a device for its user and reports whether it is the user's first
+func (h *Hub) addOnlineClient(client *Client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients, ok := h.OnlineUsers[client.UserID]
+	if !ok {
+		clients = make(map[*Client]bool)
+		h.OnlineUsers[client.UserID] = clients
+	}
+	if clients[client] {
+		return false
+	}
+	clients[client] = true
+	return len(clients) == 1
+}
+
+// removeOnlineClient stops tracking a device and reports whether the user has no devices left
+func (h *Hub) removeOnlineClient(client *Client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	clients, ok := h.OnlineUsers[client.UserID]
+	if !ok || !clients[client] {
+		return false
+	}
+	delete(clients, client)
+	if len(clients) == 0 {
+		delete(h.OnlineUsers, client.UserID)
+		return true
+	}
+	return false
+}
+
+// onlineUserInfo returns the user info from any of the user's connected devices
+func (h *Hub) onlineUserInfo(userID string) *UserInfo {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.OnlineUsers[userID] {
+		if client.UserInfo != nil {
+			return client.UserInfo
 		}
 	}
+	return nil
+}
+
+// trySend queues a message for a client without blocking, skipping clients that are already closed
+func (h *Hub) trySend(client *Client, data []byte) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if client.closed {
+		return false
+	}
+	select {
+	case client.Send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeClient closes a client's send channel exactly once
+func (h *Hub) closeClient(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !client.closed {
+		client.closed = true
+		close(client.Send)
+	}
 }
@@ -10,10 +10,17 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/bernaotieno/social-network/backend/pkg/account"
 	"github.com/bernaotieno/social-network/backend/pkg/auth"
+	"github.com/bernaotieno/social-network/backend/pkg/config"
 	"github.com/bernaotieno/social-network/backend/pkg/db/sqlite"
 	"github.com/bernaotieno/social-network/backend/pkg/handlers"
+	"github.com/bernaotieno/social-network/backend/pkg/imaging"
+	"github.com/bernaotieno/social-network/backend/pkg/linkpreview"
+	"github.com/bernaotieno/social-network/backend/pkg/mail"
 	"github.com/bernaotieno/social-network/backend/pkg/middleware"
+	"github.com/bernaotieno/social-network/backend/pkg/models"
+	"github.com/bernaotieno/social-network/backend/pkg/storage"
 	"github.com/bernaotieno/social-network/backend/pkg/utils"
 	"github.com/bernaotieno/social-network/backend/pkg/websocket"
 	"github.com/gorilla/mux"
@@ -22,20 +29,47 @@ import (
 func main() {
 	// Parse command line flags
 	var (
-		port           = flag.String("port", "8080", "Server port")
-		dbPath         = flag.String("db", "./social_network.db", "SQLite database path")
-		migrationsPath = flag.String("migrations", "./pkg/db/migrations/sqlite", "Path to migrations directory")
+		configPath     = flag.String("config", os.Getenv("CONFIG_FILE"), "Path to a JSON config file")
+		port           = flag.String("port", "", "Server port (overrides the config)")
+		dbPath         = flag.String("db", "", "SQLite database path (overrides the config)")
+		migrationsPath = flag.String("migrations", "", "Path to migrations directory (overrides the config)")
+		migrateUploads = flag.String("migrate-uploads-from", "", "Copy uploaded files from this storage backend (local or s3) to the configured one, then exit")
 	)
 	flag.Parse()
 
+	// Load configuration from the config file and environment
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if *port != "" {
+		cfg.Port = *port
+	}
+	if *dbPath != "" {
+		cfg.DBPath = *dbPath
+	}
+	if *migrationsPath != "" {
+		cfg.MigrationsPath = *migrationsPath
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	if *migrateUploads != "" {
+		copyUploads(cfg, *migrateUploads)
+		return
+	}
+	if cfg.IsDevelopment() && cfg.SessionSecret == config.PlaceholderSessionSecret {
+		log.Println("WARNING: using the placeholder session secret; set SESSION_SECRET before deploying")
+	}
+
 	// Initialize logger
-	if logFile, err := utils.SetupLogFile(); err != nil {
+	if logFile, err := utils.SetupLogFile(cfg.Log.File); err != nil {
 		log.Fatalf("Failed to setup logger: %v", err)
-	} else {
+	} else if logFile != os.Stderr {
 		defer logFile.Close()
 	}
 	// Initialize database
-	db, err := sqlite.NewDB(*dbPath)
+	db, err := sqlite.NewDB(cfg.DBPath)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	} else {
@@ -44,14 +78,22 @@ func main() {
 	defer db.Close()
 
 	// Run migrations
-	if err := sqlite.RunMigrations(*dbPath, *migrationsPath); err != nil {
+	if err := sqlite.RunMigrations(cfg.DBPath, cfg.MigrationsPath); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 	log.Println("Migrations completed successfully")
 
-	// Initialize auth package with a secret key
-	// In production, this should be a secure random key stored in environment variables
-	auth.Initialize([]byte("your-secret-key-here"))
+	// Initialize auth package with the configured session secret
+	auth.Initialize([]byte(cfg.SessionSecret), cfg.SecureCookies())
+
+	// Store uploads in the configured backend
+	uploadStorage, err := cfg.UploadStorage(cfg.Upload.Storage)
+	if err != nil {
+		log.Fatalf("Failed to set up upload storage: %v", err)
+	}
+	utils.ConfigureUploads(uploadStorage, cfg.Upload.MaxImageSize, cfg.Upload.MaxRequestSize)
+	utils.ConfigureSignedUploadURLs([]byte(cfg.SessionSecret), time.Duration(cfg.Upload.SignedURLTTL))
+	imaging.FormatLimits = cfg.Upload.Formats
 
 	// Initialize WebSocket hub
 	hub := websocket.NewHub()
@@ -62,9 +104,44 @@ func main() {
 
 	// Initialize handlers
 	h := handlers.NewHandler(db, hub)
+	h.AppURL = cfg.AppURL
+	h.DeletionGracePeriod = time.Duration(cfg.Account.DeletionGracePeriod)
+	if cfg.Mail.Driver == config.MailDriverFile {
+		h.Mailer = mail.NewFileMailer(cfg.Mail.From, cfg.Mail.Dir)
+	} else {
+		h.Mailer = mail.NewLogMailer(cfg.Mail.From)
+	}
+	if cfg.LinkPreview.Enabled {
+		h.LinkPreviewFetcher = linkpreview.NewHTTPFetcher(time.Duration(cfg.LinkPreview.Timeout), cfg.LinkPreview.MaxPageSize, imaging.MaxPreviewSize)
+	}
+	h.LinkPreviewCacheTTL = time.Duration(cfg.LinkPreview.CacheTTL)
+	h.MaxLinkPreviews = cfg.LinkPreview.MaxLinks
+
+	// Only accept browser WebSocket connections from allowed origins; clients
+	// that send no Origin header are not browsers and are let through
+	h.Upgrader.CheckOrigin = func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return origin == "" || middleware.OriginAllowed(cfg.AllowedOrigins, origin)
+	}
+
+	// Build data exports and delete accounts in the background
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	h.AccountJobs = account.NewWorker(h.AccountService, h.DataExportService, cfg.Account.ExportDir,
+		time.Duration(cfg.Account.ExportTTL), time.Duration(cfg.Account.JobInterval))
+	go h.AccountJobs.Run(jobsCtx)
+
+	// Fetch the previews of links in new posts and messages in the background
+	go h.RunLinkPreviewFetches(jobsCtx)
+
+	// Build the full-text search index
+	if err := h.SearchService.EnsureIndex(); err != nil {
+		log.Fatalf("Failed to set up search index: %v", err)
+	}
 
 	// Apply CORS middleware to ALL routes first
-	mainRouter.Use(middleware.CORSMiddleware)
+	corsMiddleware := middleware.CORS(cfg.AllowedOrigins)
+	mainRouter.Use(corsMiddleware)
 
 	// Register WebSocket route BEFORE applying other middleware
 	mainRouter.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
@@ -85,9 +162,8 @@ func main() {
 	// Register other routes on the API subrouter
 	registerRoutes(apiRouter, h)
 
-	// Static file server for uploaded images (on main router)
-	fs := http.FileServer(http.Dir("./uploads"))
-	mainRouter.PathPrefix("/uploads/").Handler(middleware.CORSMiddleware(http.StripPrefix("/uploads/", fs)))
+	// Serve uploaded images to those allowed to see them (on main router)
+	mainRouter.PathPrefix("/uploads/").Handler(corsMiddleware(middleware.DBMiddleware(db)(http.HandlerFunc(h.ServeUpload))))
 
 	// Handle all OPTIONS requests so CORS middleware runs
 	mainRouter.PathPrefix("/").Methods(http.MethodOptions).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -96,17 +172,24 @@ func main() {
 
 	// Create server
 	srv := &http.Server{
-		Addr:         ":" + *port,
+		Addr:         ":" + cfg.Port,
 		Handler:      mainRouter,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  time.Duration(cfg.Timeouts.Read),
+		WriteTimeout: time.Duration(cfg.Timeouts.Write),
+		IdleTimeout:  time.Duration(cfg.Timeouts.Idle),
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server starting on port %s", *port)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.TLSEnabled() {
+			log.Printf("Server starting on port %s (HTTPS)", cfg.Port)
+			err = srv.ListenAndServeTLS(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		} else {
+			log.Printf("Server starting on port %s", cfg.Port)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
@@ -116,8 +199,9 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Server shutting down...")
+	stopJobs()
 	// Create a deadline for server shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeouts.Shutdown))
 	defer cancel()
 
 	// Attempt graceful shutdown
@@ -128,19 +212,80 @@ func main() {
 	log.Println("Server exited properly")
 }
 
+// copyUploads copies every uploaded file from the named storage backend to
+// the configured one, so uploads can be moved between backends. Files already
+// copied are skipped, so it can be run again after an interruption.
+func copyUploads(cfg *config.Config, from string) {
+	if from == cfg.Upload.Storage {
+		log.Fatalf("Uploads are already stored in the %s backend; set UPLOAD_STORAGE to the backend to copy them to", from)
+	}
+
+	src, err := cfg.UploadStorage(from)
+	if err != nil {
+		log.Fatalf("Failed to set up %s upload storage: %v", from, err)
+	}
+	dst, err := cfg.UploadStorage(cfg.Upload.Storage)
+	if err != nil {
+		log.Fatalf("Failed to set up %s upload storage: %v", cfg.Upload.Storage, err)
+	}
+
+	log.Printf("Copying uploads from the %s backend to the %s backend", from, cfg.Upload.Storage)
+	copied, skipped, err := storage.Copy(src, dst, func(key string, copied bool) {
+		if copied {
+			log.Printf("Copied %s", key)
+		}
+	})
+	if err != nil {
+		log.Fatalf("Failed to copy uploads after %d files: %v", copied, err)
+	}
+	log.Printf("Copied %d uploads, skipped %d already present", copied, skipped)
+}
+
 func registerRoutes(api *mux.Router, h *handlers.Handler) {
 	// API routes are already prefixed with /api
 
+	// Content creation is rate limited per user to stop spam floods, and
+	// only open to users who have verified their email address
+	limitPosts := middleware.RateLimitMiddleware(h.RateLimits.Post, middleware.UserKey)
+	limitComments := middleware.RateLimitMiddleware(h.RateLimits.Comment, middleware.UserKey)
+	limitMessages := middleware.RateLimitMiddleware(h.RateLimits.Message, middleware.UserKey)
+
 	// Auth routes
 	auth := api.PathPrefix("/auth").Subrouter()
-	auth.HandleFunc("/register", h.Register).Methods("POST")
-	auth.HandleFunc("/login", h.Login).Methods("POST")
-	auth.HandleFunc("/logout", h.Logout).Methods("POST")
+	auth.HandleFunc("/register", middleware.RateLimitMiddleware(h.RateLimits.Register, middleware.ClientIPKey)(h.Register)).Methods("POST")
+	auth.HandleFunc("/login", middleware.RateLimitMiddleware(h.RateLimits.Login, middleware.ClientIPKey)(h.Login)).Methods("POST")
+	auth.HandleFunc("/password", middleware.AuthMiddleware(h.ChangePassword)).Methods("PUT")
+	auth.HandleFunc("/password/forgot", middleware.RateLimitMiddleware(h.RateLimits.PasswordReset, middleware.ClientIPKey)(h.ForgotPassword)).Methods("POST")
+	auth.HandleFunc("/password/reset", middleware.RateLimitMiddleware(h.RateLimits.PasswordReset, middleware.ClientIPKey)(h.ResetPassword)).Methods("POST")
+	auth.HandleFunc("/verify-email", h.VerifyEmail).Methods("POST")
+	auth.HandleFunc("/verify-email/resend", middleware.AuthMiddleware(middleware.RateLimitMiddleware(h.RateLimits.VerificationEmail, middleware.UserKey)(h.ResendVerificationEmail))).Methods("POST")
+	auth.HandleFunc("/2fa", middleware.AuthMiddleware(h.GetTwoFactorStatus)).Methods("GET")
+	auth.HandleFunc("/2fa/setup", middleware.AuthMiddleware(h.SetupTwoFactor)).Methods("POST")
+	auth.HandleFunc("/2fa/enable", middleware.AuthMiddleware(h.EnableTwoFactor)).Methods("POST")
+	auth.HandleFunc("/2fa/disable", middleware.AuthMiddleware(h.DisableTwoFactor)).Methods("POST")
+	auth.HandleFunc("/2fa/recovery-codes", middleware.AuthMiddleware(h.RegenerateRecoveryCodes)).Methods("POST")
+	auth.HandleFunc("/2fa/verify", middleware.RateLimitMiddleware(h.RateLimits.Login, middleware.ClientIPKey)(h.VerifyTwoFactorLogin)).Methods("POST")
+	auth.HandleFunc("/logout", middleware.AuthMiddleware(h.Logout)).Methods("POST")
+	auth.HandleFunc("/sessions", middleware.AuthMiddleware(h.GetSessions)).Methods("GET")
+	auth.HandleFunc("/sessions", middleware.AuthMiddleware(h.RevokeOtherSessions)).Methods("DELETE")
+	auth.HandleFunc("/sessions/{id}", middleware.AuthMiddleware(h.RevokeSession)).Methods("DELETE")
+
+	// Account routes
+	account := api.PathPrefix("/account").Subrouter()
+	account.HandleFunc("/deletion", middleware.AuthMiddleware(h.GetAccountDeletion)).Methods("GET")
+	account.HandleFunc("/deletion", middleware.AuthMiddleware(h.RequestAccountDeletion)).Methods("POST")
+	account.HandleFunc("/deletion", middleware.AuthMiddleware(h.CancelAccountDeletion)).Methods("DELETE")
+	account.HandleFunc("/exports", middleware.AuthMiddleware(middleware.RateLimitMiddleware(h.RateLimits.DataExport, middleware.UserKey)(h.RequestDataExport))).Methods("POST")
+	account.HandleFunc("/exports", middleware.AuthMiddleware(h.GetDataExports)).Methods("GET")
+	account.HandleFunc("/exports/{id}", middleware.AuthMiddleware(h.GetDataExport)).Methods("GET")
+	account.HandleFunc("/exports/{id}/download", middleware.AuthMiddleware(h.DownloadDataExport)).Methods("GET")
 
 	// User routes
 	users := api.PathPrefix("/users").Subrouter()
 	users.HandleFunc("", middleware.AuthMiddleware(h.GetUsers)).Methods("GET")
 	users.HandleFunc("/search", middleware.AuthMiddleware(h.GetUsers)).Methods("GET") // Reuse GetUsers for search functionality
+	users.HandleFunc("/blocked", middleware.AuthMiddleware(h.GetBlockedUsers)).Methods("GET")
+	users.HandleFunc("/muted", middleware.AuthMiddleware(h.GetMutedUsers)).Methods("GET")
 	users.HandleFunc("/{id}", middleware.AuthMiddleware(h.GetUser)).Methods("GET")
 	users.HandleFunc("/profile", middleware.AuthMiddleware(h.UpdateProfile)).Methods("PUT")
 	users.HandleFunc("/avatar", middleware.AuthMiddleware(h.UploadAvatar)).Methods("POST")
@@ -149,22 +294,38 @@ func registerRoutes(api *mux.Router, h *handlers.Handler) {
 	users.HandleFunc("/{id}/follow", middleware.AuthMiddleware(h.UnfollowUser)).Methods("DELETE")
 	users.HandleFunc("/{id}/followers", middleware.AuthMiddleware(h.GetFollowers)).Methods("GET")
 	users.HandleFunc("/{id}/following", middleware.AuthMiddleware(h.GetFollowing)).Methods("GET")
+	users.HandleFunc("/{id}/block", middleware.AuthMiddleware(h.BlockUser)).Methods("POST")
+	users.HandleFunc("/{id}/block", middleware.AuthMiddleware(h.UnblockUser)).Methods("DELETE")
+	users.HandleFunc("/{id}/mute", middleware.AuthMiddleware(h.MuteUser)).Methods("POST")
+	users.HandleFunc("/{id}/mute", middleware.AuthMiddleware(h.UnmuteUser)).Methods("DELETE")
 	users.HandleFunc("/follow-requests", middleware.AuthMiddleware(h.GetFollowRequests)).Methods("GET")
 	users.HandleFunc("/follow-requests/{id}", middleware.AuthMiddleware(h.RespondToFollowRequest)).Methods("PUT")
 
 	// Post routes
 	posts := api.PathPrefix("/posts").Subrouter()
-	posts.HandleFunc("", middleware.AuthMiddleware(h.CreatePost)).Methods("POST")
+	posts.HandleFunc("", middleware.AuthMiddleware(middleware.VerifiedEmailMiddleware(limitPosts(h.CreatePost)))).Methods("POST")
 	posts.HandleFunc("/feed", middleware.AuthMiddleware(h.GetFeed)).Methods("GET")
 	posts.HandleFunc("/user/{id}", middleware.AuthMiddleware(h.GetUserPosts)).Methods("GET")
 	posts.HandleFunc("/{id}", middleware.AuthMiddleware(h.GetPost)).Methods("GET")
 	posts.HandleFunc("/{id}", middleware.AuthMiddleware(h.UpdatePost)).Methods("PUT")
 	posts.HandleFunc("/{id}", middleware.AuthMiddleware(h.DeletePost)).Methods("DELETE")
+	posts.HandleFunc("/{id}/revisions", middleware.AuthMiddleware(h.GetPostRevisions)).Methods("GET")
 	posts.HandleFunc("/{id}/like", middleware.AuthMiddleware(h.LikePost)).Methods("POST")
 	posts.HandleFunc("/{id}/like", middleware.AuthMiddleware(h.UnlikePost)).Methods("DELETE")
+	posts.HandleFunc("/{id}/reactions", middleware.AuthMiddleware(h.GetPostReactions)).Methods("GET")
+	posts.HandleFunc("/{id}/reactions", middleware.AuthMiddleware(h.ReactToPost)).Methods("PUT")
+	posts.HandleFunc("/{id}/reactions", middleware.AuthMiddleware(h.RemovePostReaction)).Methods("DELETE")
 	posts.HandleFunc("/{id}/comments", middleware.AuthMiddleware(h.GetComments)).Methods("GET")
-	posts.HandleFunc("/{id}/comments", middleware.AuthMiddleware(h.AddComment)).Methods("POST")
+	posts.HandleFunc("/{id}/comments", middleware.AuthMiddleware(middleware.VerifiedEmailMiddleware(limitComments(h.AddComment)))).Methods("POST")
 	posts.HandleFunc("/{postId}/comments/{commentId}", middleware.AuthMiddleware(h.DeleteComment)).Methods("DELETE")
+	posts.HandleFunc("/{postId}/comments/{commentId}", middleware.AuthMiddleware(h.UpdateComment)).Methods("PUT")
+	posts.HandleFunc("/{postId}/comments/{commentId}/revisions", middleware.AuthMiddleware(h.GetCommentRevisions)).Methods("GET")
+	posts.HandleFunc("/{postId}/comments/{commentId}/replies", middleware.AuthMiddleware(h.GetCommentReplies)).Methods("GET")
+	posts.HandleFunc("/{postId}/comments/{commentId}/like", middleware.AuthMiddleware(h.LikeComment)).Methods("POST")
+	posts.HandleFunc("/{postId}/comments/{commentId}/like", middleware.AuthMiddleware(h.UnlikeComment)).Methods("DELETE")
+	posts.HandleFunc("/{postId}/comments/{commentId}/reactions", middleware.AuthMiddleware(h.GetCommentReactions)).Methods("GET")
+	posts.HandleFunc("/{postId}/comments/{commentId}/reactions", middleware.AuthMiddleware(h.ReactToComment)).Methods("PUT")
+	posts.HandleFunc("/{postId}/comments/{commentId}/reactions", middleware.AuthMiddleware(h.RemoveCommentReaction)).Methods("DELETE")
 
 	// Group routes
 	groups := api.PathPrefix("/groups").Subrouter()
@@ -185,20 +346,34 @@ func registerRoutes(api *mux.Router, h *handlers.Handler) {
 	groups.HandleFunc("/{id}/invite", middleware.AuthMiddleware(h.InviteToGroup)).Methods("POST")
 	groups.HandleFunc("/invitations/{id}/respond", middleware.AuthMiddleware(h.RespondToGroupInvitation)).Methods("POST")
 	groups.HandleFunc("/{id}/posts", middleware.AuthMiddleware(h.GetGroupPosts)).Methods("GET")
-	groups.HandleFunc("/{id}/posts", middleware.AuthMiddleware(h.CreateGroupPost)).Methods("POST")
+	groups.HandleFunc("/{id}/posts", middleware.AuthMiddleware(middleware.VerifiedEmailMiddleware(limitPosts(h.CreateGroupPost)))).Methods("POST")
 	groups.HandleFunc("/{groupId}/posts/{postId}", middleware.AuthMiddleware(h.DeleteGroupPost)).Methods("DELETE")
+	groups.HandleFunc("/{groupId}/posts/{postId}", middleware.AuthMiddleware(h.UpdateGroupPost)).Methods("PUT")
+	groups.HandleFunc("/{groupId}/posts/{postId}/revisions", middleware.AuthMiddleware(h.GetGroupPostRevisions)).Methods("GET")
 	groups.HandleFunc("/{groupId}/posts/{postId}/like", middleware.AuthMiddleware(h.LikeGroupPost)).Methods("POST")
 	groups.HandleFunc("/{groupId}/posts/{postId}/like", middleware.AuthMiddleware(h.UnlikeGroupPost)).Methods("DELETE")
+	groups.HandleFunc("/{groupId}/posts/{postId}/reactions", middleware.AuthMiddleware(h.GetGroupPostReactions)).Methods("GET")
+	groups.HandleFunc("/{groupId}/posts/{postId}/reactions", middleware.AuthMiddleware(h.ReactToGroupPost)).Methods("PUT")
+	groups.HandleFunc("/{groupId}/posts/{postId}/reactions", middleware.AuthMiddleware(h.RemoveGroupPostReaction)).Methods("DELETE")
 	groups.HandleFunc("/{groupId}/posts/{postId}/comments", middleware.AuthMiddleware(h.GetGroupPostComments)).Methods("GET")
-	groups.HandleFunc("/{groupId}/posts/{postId}/comments", middleware.AuthMiddleware(h.AddGroupPostComment)).Methods("POST")
+	groups.HandleFunc("/{groupId}/posts/{postId}/comments", middleware.AuthMiddleware(middleware.VerifiedEmailMiddleware(limitComments(h.AddGroupPostComment)))).Methods("POST")
 	groups.HandleFunc("/{groupId}/posts/{postId}/comments/{commentId}", middleware.AuthMiddleware(h.DeleteGroupPostComment)).Methods("DELETE")
+	groups.HandleFunc("/{groupId}/posts/{postId}/comments/{commentId}", middleware.AuthMiddleware(h.UpdateGroupPostComment)).Methods("PUT")
+	groups.HandleFunc("/{groupId}/posts/{postId}/comments/{commentId}/revisions", middleware.AuthMiddleware(h.GetGroupPostCommentRevisions)).Methods("GET")
+	groups.HandleFunc("/{groupId}/posts/{postId}/comments/{commentId}/replies", middleware.AuthMiddleware(h.GetGroupPostCommentReplies)).Methods("GET")
+	groups.HandleFunc("/{groupId}/posts/{postId}/comments/{commentId}/like", middleware.AuthMiddleware(h.LikeGroupPostComment)).Methods("POST")
+	groups.HandleFunc("/{groupId}/posts/{postId}/comments/{commentId}/like", middleware.AuthMiddleware(h.UnlikeGroupPostComment)).Methods("DELETE")
+	groups.HandleFunc("/{groupId}/posts/{postId}/comments/{commentId}/reactions", middleware.AuthMiddleware(h.GetCommentReactions)).Methods("GET")
+	groups.HandleFunc("/{groupId}/posts/{postId}/comments/{commentId}/reactions", middleware.AuthMiddleware(h.ReactToComment)).Methods("PUT")
+	groups.HandleFunc("/{groupId}/posts/{postId}/comments/{commentId}/reactions", middleware.AuthMiddleware(h.RemoveCommentReaction)).Methods("DELETE")
 	groups.HandleFunc("/{id}/events", middleware.AuthMiddleware(h.GetGroupEvents)).Methods("GET")
 	groups.HandleFunc("/{id}/events", middleware.AuthMiddleware(h.CreateGroupEvent)).Methods("POST")
 	groups.HandleFunc("/events/{id}", middleware.AuthMiddleware(h.UpdateGroupEvent)).Methods("PUT")
 	groups.HandleFunc("/events/{id}", middleware.AuthMiddleware(h.DeleteGroupEvent)).Methods("DELETE")
 	groups.HandleFunc("/events/{id}/respond", middleware.AuthMiddleware(h.RespondToEvent)).Methods("POST")
 	groups.HandleFunc("/{id}/messages", middleware.AuthMiddleware(h.GetGroupMessages)).Methods("GET")
-	groups.HandleFunc("/{id}/messages", middleware.AuthMiddleware(h.SendGroupMessage)).Methods("POST")
+	groups.HandleFunc("/{id}/messages", middleware.AuthMiddleware(middleware.VerifiedEmailMiddleware(limitMessages(h.SendGroupMessage)))).Methods("POST")
+	groups.HandleFunc("/{id}/messages/reads", middleware.AuthMiddleware(h.GetGroupReadReceipts)).Methods("GET")
 
 	// Notification routes
 	notifications := api.PathPrefix("/notifications").Subrouter()
@@ -210,9 +385,55 @@ func registerRoutes(api *mux.Router, h *handlers.Handler) {
 
 	// Message routes
 	messages := api.PathPrefix("/messages").Subrouter()
-	messages.HandleFunc("", middleware.AuthMiddleware(h.SendMessage)).Methods("POST")
+	messages.HandleFunc("", middleware.AuthMiddleware(middleware.VerifiedEmailMiddleware(limitMessages(h.SendMessage)))).Methods("POST")
 	messages.HandleFunc("/online-users", middleware.AuthMiddleware(h.GetOnlineUsers)).Methods("GET")
+	messages.HandleFunc("/conversations", middleware.AuthMiddleware(h.GetConversations)).Methods("GET")
+	messages.HandleFunc("/unread", middleware.AuthMiddleware(h.GetUnreadCounts)).Methods("GET")
+	messages.HandleFunc("/read", middleware.AuthMiddleware(h.MarkConversationRead)).Methods("POST")
 	messages.HandleFunc("/{userId}", middleware.AuthMiddleware(h.GetMessages)).Methods("GET")
+	messages.HandleFunc("/{id}", middleware.AuthMiddleware(h.EditMessage)).Methods("PUT")
+	messages.HandleFunc("/{id}", middleware.AuthMiddleware(h.DeleteMessage)).Methods("DELETE")
+	messages.HandleFunc("/{id}/reactions", middleware.AuthMiddleware(h.AddMessageReaction)).Methods("POST")
+	messages.HandleFunc("/{id}/reactions", middleware.AuthMiddleware(h.RemoveMessageReaction)).Methods("DELETE")
+
+	// Search routes
+	api.HandleFunc("/search", middleware.AuthMiddleware(h.Search)).Methods("GET")
+
+	// Hashtag routes
+	tags := api.PathPrefix("/tags").Subrouter()
+	tags.HandleFunc("/trending", middleware.AuthMiddleware(h.GetTrendingTags)).Methods("GET")
+	tags.HandleFunc("/{tag}", middleware.AuthMiddleware(h.GetTagPosts)).Methods("GET")
+	tags.HandleFunc("/{tag}/group-posts", middleware.AuthMiddleware(h.GetTagGroupPosts)).Methods("GET")
+
+	// Report routes
+	reports := api.PathPrefix("/reports").Subrouter()
+	reports.HandleFunc("", middleware.AuthMiddleware(middleware.RateLimitMiddleware(h.RateLimits.Report, middleware.UserKey)(h.CreateReport))).Methods("POST")
+	reports.HandleFunc("/reasons", middleware.AuthMiddleware(h.GetReportReasons)).Methods("GET")
+
+	// Admin routes, open to platform staff; role changes are for admins only
+	staff := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.AuthMiddleware(middleware.RequireRole(models.UserRoleAdmin, models.UserRoleModerator)(next))
+	}
+	adminOnly := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.AuthMiddleware(middleware.RequireRole(models.UserRoleAdmin)(next))
+	}
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.HandleFunc("/users", staff(h.AdminListUsers)).Methods("GET")
+	admin.HandleFunc("/users/{id}", staff(h.AdminGetUser)).Methods("GET")
+	admin.HandleFunc("/users/{id}/suspend", staff(h.SuspendUser)).Methods("POST")
+	admin.HandleFunc("/users/{id}/ban", staff(h.BanUser)).Methods("POST")
+	admin.HandleFunc("/users/{id}/reinstate", staff(h.ReinstateUser)).Methods("POST")
+	admin.HandleFunc("/users/{id}/unlock", staff(h.UnlockAccount)).Methods("POST")
+	admin.HandleFunc("/users/{id}/role", adminOnly(h.ChangeUserRole)).Methods("PUT")
+	admin.HandleFunc("/posts/{id}", staff(h.AdminDeletePost)).Methods("DELETE")
+	admin.HandleFunc("/comments/{id}", staff(h.AdminDeleteComment)).Methods("DELETE")
+	admin.HandleFunc("/groups/{id}", staff(h.AdminDeleteGroup)).Methods("DELETE")
+	admin.HandleFunc("/audit-log", staff(h.GetModerationLog)).Methods("GET")
+	admin.HandleFunc("/reports", staff(h.ListReports)).Methods("GET")
+	admin.HandleFunc("/reports/{id}", staff(h.GetReport)).Methods("GET")
+	admin.HandleFunc("/reports/{id}/claim", staff(h.ClaimReport)).Methods("POST")
+	admin.HandleFunc("/reports/{id}/resolve", staff(h.ResolveReport)).Methods("POST")
+	admin.HandleFunc("/reports/{id}/dismiss", staff(h.DismissReport)).Methods("POST")
 
 	// WebSocket route is registered separately before middleware to avoid hijacker issues
 	// Static file server is registered on the main router
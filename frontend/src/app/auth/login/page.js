@@ -27,7 +27,7 @@ export default function Login() {
     const logoutTimestamp = localStorage.getItem('logoutTimestamp');
     
     if (logoutReason === 'session_invalidated') {
-      let message = 'You were logged out because your account was accessed from another device. Please log in again.';
+      let message = 'This session was signed out from your account settings or another device. Please log in again.';
       
       // Add timestamp information if available
       if (logoutTimestamp) {
@@ -77,7 +77,7 @@ export const AuthProvider = ({ children }) => {
           }
           
           // Also show a console warning for developers
-          console.warn('🔒 SECURITY NOTICE: Session invalidated due to new login from another device');
+          console.warn('🔒 SECURITY NOTICE: Session invalidated by the server');
           
           // Store the reason for logout to show on login page
           localStorage.setItem('logoutReason', 'session_invalidated');
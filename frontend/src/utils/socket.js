@@ -190,9 +190,47 @@ export const leaveChatRoom = (roomId) => {
  * Send a message to a chat room
  * @param {string} roomId - Room ID to send message to
  * @param {object} message - Message content
+ * @param {string} [clientMessageId] - Idempotency key; resending with the same key won't duplicate the message
  */
-export const sendMessage = (roomId, message) => {
-  emit('chat_message', { roomId, content: message });
+export const sendMessage = (roomId, message, clientMessageId) => {
+  emit('chat_message', { roomId, content: message, clientMessageId });
+};
+
+/**
+ * Ask the server for every message sent since the last one this client saw
+ * @param {string} lastMessageId - ID of the newest message already received
+ */
+export const resumeMessages = (lastMessageId) => {
+  emit('resume', { lastMessageId });
+};
+
+/**
+ * Mark a chat room read up to a message
+ * @param {string} roomId - Room ID that was read
+ * @param {string} [upToMessageId] - Newest message read; omit to mark the whole room
+ */
+export const sendReadReceipt = (roomId, upToMessageId) => {
+  emit('read_receipt', { roomId, upToMessageId });
+};
+
+/**
+ * Subscribe to read receipts in joined chat rooms
+ * @param {function} callback - Called with { roomId, readerId, upToMessageId, readAt }
+ * @returns {function} - Function to unsubscribe
+ */
+export const subscribeToReadReceipts = (callback) => {
+  on('read_receipt', callback);
+  return () => off('read_receipt', callback);
+};
+
+/**
+ * Subscribe to acks for messages this client sent
+ * @param {function} callback - Called with { clientMessageId, messageId, status, ... }
+ * @returns {function} - Function to unsubscribe
+ */
+export const subscribeToMessageAcks = (callback) => {
+  on('message_ack', callback);
+  return () => off('message_ack', callback);
 };
 
 /**